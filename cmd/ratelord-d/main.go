@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -9,6 +10,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,29 +21,55 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
 	"github.com/rmax-ai/ratelord/pkg/graph"
 	"github.com/rmax-ai/ratelord/pkg/provider"
+	"github.com/rmax-ai/ratelord/pkg/provider/anthropic"
 	"github.com/rmax-ai/ratelord/pkg/provider/federated"
 	"github.com/rmax-ai/ratelord/pkg/provider/github"
 	"github.com/rmax-ai/ratelord/pkg/provider/openai"
+	"github.com/rmax-ai/ratelord/pkg/provider/plugin"
 	"github.com/rmax-ai/ratelord/pkg/store"
+	"github.com/rmax-ai/ratelord/pkg/store/postgres"
 	"github.com/rmax-ai/ratelord/pkg/store/redis"
 	"github.com/rmax-ai/ratelord/web"
 )
 
+// leaderLease is the lease held by the leader daemon.
+const leaderLease = "ratelord-leader"
+
 type Config struct {
-	DBPath           string
-	PolicyPath       string
-	Port             int
-	WebDir           string
-	TLSCert          string
-	TLSKey           string
-	Mode             string
-	LeaderURL        string
-	FollowerID       string
-	RedisURL         string
-	AdvertisedURL    string
-	ArchiveEnabled   bool
-	ArchiveRetention time.Duration
-	BlobPath         string
+	DBPath             string
+	DBURL              string // PostgreSQL DSN; replaces the SQLite DBPath when set
+	PolicyPath         string
+	Port               int
+	WebDir             string
+	TLSCert            string
+	TLSKey             string
+	Mode               string // "leader", "follower" or "regional"
+	LeaderURL          string // Parent of a follower or regional leader
+	FollowerID         string
+	GrantReclaimAfter  time.Duration // Grant lease duration past the last renewal
+	RedisURL           string
+	AdvertisedURL      string
+	ArchiveEnabled     bool
+	ArchiveRetention   time.Duration
+	ArchiveFormat      string
+	BlobPath           string
+	BlobURL            string
+	SnapshotInterval   time.Duration
+	SnapshotKeepLast   int
+	SnapshotKeepDaily  int
+	SnapshotKeepWeekly int
+	SnapshotExport     bool // Copy each snapshot to the blob store
+	RollupMinuteKeep   time.Duration
+	RollupHourKeep     time.Duration
+	RollupDayKeep      time.Duration // 0 keeps daily rollups forever
+	RollupLateness     time.Duration // Events further behind the latest are dropped
+	CheckpointKey      string
+	CheckpointInterval time.Duration
+	AppendBatchSize    int // Group commit batch size; 1 or less appends directly
+	AppendBatchDelay   time.Duration
+	AppendQueueSize    int
+	AppendAck          string // "commit" or "queued"
+	AppendSync         string // SQLite sync mode: "full" or "normal"
 }
 
 type LeaderServices struct {
@@ -57,12 +85,18 @@ type LeaderServices struct {
 	pruneCancel      context.CancelFunc
 	archiveCtx       context.Context
 	archiveCancel    context.CancelFunc
+	checkpointCtx    context.Context
+	checkpointCancel context.CancelFunc
+	reclaimCtx       context.Context
+	reclaimCancel    context.CancelFunc
 	poller           *engine.Poller
 	rollup           *engine.RollupWorker
 	dispatcher       *engine.Dispatcher
 	snapshotWorker   *engine.SnapshotWorker
 	pruneWorker      *engine.PruneWorker
 	archiveWorker    *engine.ArchiveWorker
+	checkpointer     *engine.ChainCheckpointer
+	grantReclaimer   *engine.GrantReclaimer
 }
 
 func (ls *LeaderServices) Start() {
@@ -80,6 +114,12 @@ func (ls *LeaderServices) Start() {
 		ls.archiveCtx, ls.archiveCancel = context.WithCancel(context.Background())
 		go ls.archiveWorker.Run(ls.archiveCtx)
 	}
+	if ls.checkpointer != nil {
+		ls.checkpointCtx, ls.checkpointCancel = context.WithCancel(context.Background())
+		go ls.checkpointer.Run(ls.checkpointCtx)
+	}
+	ls.reclaimCtx, ls.reclaimCancel = context.WithCancel(context.Background())
+	go ls.grantReclaimer.Run(ls.reclaimCtx)
 }
 
 func (ls *LeaderServices) Stop() {
@@ -101,6 +141,12 @@ func (ls *LeaderServices) Stop() {
 	if ls.archiveCancel != nil {
 		ls.archiveCancel()
 	}
+	if ls.checkpointCancel != nil {
+		ls.checkpointCancel()
+	}
+	if ls.reclaimCancel != nil {
+		ls.reclaimCancel()
+	}
 }
 
 func LoadConfig() Config {
@@ -108,21 +154,39 @@ func LoadConfig() Config {
 	cwd, _ := os.Getwd()
 	hostname, _ := os.Hostname()
 	cfg := Config{
-		DBPath:           filepath.Join(cwd, "ratelord.db"),
-		PolicyPath:       filepath.Join(cwd, "policy.json"),
-		Port:             8090,
-		Mode:             "leader",
-		LeaderURL:        "http://localhost:8090",
-		FollowerID:       hostname,
-		ArchiveEnabled:   false,
-		ArchiveRetention: 720 * time.Hour, // 30 days
-		BlobPath:         filepath.Join(cwd, "blobs"),
+		DBPath:             filepath.Join(cwd, "ratelord.db"),
+		PolicyPath:         filepath.Join(cwd, "policy.json"),
+		Port:               8090,
+		Mode:               "leader",
+		LeaderURL:          "http://localhost:8090",
+		FollowerID:         hostname,
+		GrantReclaimAfter:  engine.DefaultGrantReclaimAfter,
+		ArchiveEnabled:     false,
+		ArchiveRetention:   720 * time.Hour, // 30 days
+		ArchiveFormat:      store.ArchiveFormatJSONL,
+		BlobPath:           filepath.Join(cwd, "blobs"),
+		SnapshotInterval:   5 * time.Minute,
+		SnapshotKeepLast:   12,
+		SnapshotKeepDaily:  7,
+		SnapshotKeepWeekly: 4,
+		RollupMinuteKeep:   engine.DefaultRollupRetention.Minute,
+		RollupHourKeep:     engine.DefaultRollupRetention.Hour,
+		RollupDayKeep:      engine.DefaultRollupRetention.Day,
+		RollupLateness:     engine.DefaultAllowedLateness,
+		CheckpointInterval: time.Hour,
+		AppendBatchSize:    256,
+		AppendQueueSize:    4096,
+		AppendAck:          string(store.AckCommit),
+		AppendSync:         string(store.SyncFull),
 	}
 
 	// Env Vars
 	if val := os.Getenv("RATELORD_DB_PATH"); val != "" {
 		cfg.DBPath = val
 	}
+	if val := os.Getenv("RATELORD_DB_URL"); val != "" {
+		cfg.DBURL = val
+	}
 	if val := os.Getenv("RATELORD_POLICY_PATH"); val != "" {
 		cfg.PolicyPath = val
 	}
@@ -149,6 +213,11 @@ func LoadConfig() Config {
 	if val := os.Getenv("RATELORD_FOLLOWER_ID"); val != "" {
 		cfg.FollowerID = val
 	}
+	if val := os.Getenv("RATELORD_GRANT_RECLAIM_AFTER"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.GrantReclaimAfter = d
+		}
+	}
 	if val := os.Getenv("RATELORD_REDIS_URL"); val != "" {
 		cfg.RedisURL = val
 	}
@@ -163,25 +232,122 @@ func LoadConfig() Config {
 			cfg.ArchiveRetention = d
 		}
 	}
+	if val := os.Getenv("RATELORD_ARCHIVE_FORMAT"); val != "" {
+		cfg.ArchiveFormat = val
+	}
 	if val := os.Getenv("RATELORD_BLOB_PATH"); val != "" {
 		cfg.BlobPath = val
 	}
+	if val := os.Getenv("RATELORD_BLOB_URL"); val != "" {
+		cfg.BlobURL = val
+	}
+	if val := os.Getenv("RATELORD_SNAPSHOT_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.SnapshotInterval = d
+		}
+	}
+	if val := os.Getenv("RATELORD_SNAPSHOT_KEEP_LAST"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.SnapshotKeepLast = n
+		}
+	}
+	if val := os.Getenv("RATELORD_SNAPSHOT_KEEP_DAILY"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.SnapshotKeepDaily = n
+		}
+	}
+	if val := os.Getenv("RATELORD_SNAPSHOT_KEEP_WEEKLY"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.SnapshotKeepWeekly = n
+		}
+	}
+	if val := os.Getenv("RATELORD_SNAPSHOT_EXPORT"); val != "" {
+		cfg.SnapshotExport = val == "true"
+	}
+	if val := os.Getenv("RATELORD_ROLLUP_MINUTE_RETENTION"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.RollupMinuteKeep = d
+		}
+	}
+	if val := os.Getenv("RATELORD_ROLLUP_HOUR_RETENTION"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.RollupHourKeep = d
+		}
+	}
+	if val := os.Getenv("RATELORD_ROLLUP_DAY_RETENTION"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.RollupDayKeep = d
+		}
+	}
+	if val := os.Getenv("RATELORD_ROLLUP_ALLOWED_LATENESS"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.RollupLateness = d
+		}
+	}
+	if val := os.Getenv("RATELORD_CHECKPOINT_KEY"); val != "" {
+		cfg.CheckpointKey = val
+	}
+	if val := os.Getenv("RATELORD_CHECKPOINT_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.CheckpointInterval = d
+		}
+	}
+	if val := os.Getenv("RATELORD_APPEND_BATCH_SIZE"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.AppendBatchSize = n
+		}
+	}
+	if val := os.Getenv("RATELORD_APPEND_BATCH_DELAY"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.AppendBatchDelay = d
+		}
+	}
+	if val := os.Getenv("RATELORD_APPEND_QUEUE_SIZE"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.AppendQueueSize = n
+		}
+	}
+	if val := os.Getenv("RATELORD_APPEND_ACK"); val != "" {
+		cfg.AppendAck = val
+	}
+	if val := os.Getenv("RATELORD_APPEND_SYNC"); val != "" {
+		cfg.AppendSync = val
+	}
 
 	// Flags (override env vars)
 	flag.StringVar(&cfg.DBPath, "db", cfg.DBPath, "Path to SQLite database")
+	flag.StringVar(&cfg.DBURL, "db-url", cfg.DBURL, "PostgreSQL connection URL (overrides -db)")
 	flag.StringVar(&cfg.PolicyPath, "policy", cfg.PolicyPath, "Path to policy file")
 	flag.IntVar(&cfg.Port, "port", cfg.Port, "HTTP server port")
 	flag.StringVar(&cfg.WebDir, "web-dir", cfg.WebDir, "Path to web assets directory (overrides embedded)")
 	flag.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "Path to TLS certificate file")
 	flag.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "Path to TLS key file")
-	flag.StringVar(&cfg.Mode, "mode", cfg.Mode, "Operation mode: leader, follower")
-	flag.StringVar(&cfg.LeaderURL, "leader-url", cfg.LeaderURL, "URL of the leader node (for follower mode)")
-	flag.StringVar(&cfg.FollowerID, "follower-id", cfg.FollowerID, "Unique ID for this follower")
+	flag.StringVar(&cfg.Mode, "mode", cfg.Mode, "Operation mode: leader, follower, regional")
+	flag.StringVar(&cfg.LeaderURL, "leader-url", cfg.LeaderURL, "URL of the parent leader node (for follower and regional modes)")
+	flag.StringVar(&cfg.FollowerID, "follower-id", cfg.FollowerID, "Unique ID for this follower or region")
+	flag.DurationVar(&cfg.GrantReclaimAfter, "grant-reclaim-after", cfg.GrantReclaimAfter, "Grant lease duration past the last renewal; unused budget of expired leases is reclaimed (default 3m)")
 	flag.StringVar(&cfg.RedisURL, "redis-url", cfg.RedisURL, "Redis URL for usage storage")
 	flag.StringVar(&cfg.AdvertisedURL, "advertised-url", cfg.AdvertisedURL, "Public URL of this node (for leader redirection)")
 	flag.BoolVar(&cfg.ArchiveEnabled, "archive-enabled", cfg.ArchiveEnabled, "Enable cold storage archiving")
 	flag.DurationVar(&cfg.ArchiveRetention, "archive-retention", cfg.ArchiveRetention, "Retention period for archiving (default 720h)")
+	flag.StringVar(&cfg.ArchiveFormat, "archive-format", cfg.ArchiveFormat, "Archive segment format: jsonl or parquet")
 	flag.StringVar(&cfg.BlobPath, "blob-path", cfg.BlobPath, "Path to blob storage directory")
+	flag.StringVar(&cfg.BlobURL, "blob-url", cfg.BlobURL, "Blob storage URL, e.g. s3://bucket/prefix (overrides -blob-path)")
+	flag.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", cfg.SnapshotInterval, "Interval between state snapshots (default 5m)")
+	flag.IntVar(&cfg.SnapshotKeepLast, "snapshot-keep-last", cfg.SnapshotKeepLast, "Newest snapshots to keep (0 with no daily/weekly keeps all)")
+	flag.IntVar(&cfg.SnapshotKeepDaily, "snapshot-keep-daily", cfg.SnapshotKeepDaily, "Days for which the newest snapshot is kept")
+	flag.IntVar(&cfg.SnapshotKeepWeekly, "snapshot-keep-weekly", cfg.SnapshotKeepWeekly, "Weeks for which the newest snapshot is kept")
+	flag.BoolVar(&cfg.SnapshotExport, "snapshot-export", cfg.SnapshotExport, "Copy each snapshot to the blob store")
+	flag.DurationVar(&cfg.RollupMinuteKeep, "rollup-minute-retention", cfg.RollupMinuteKeep, "How long minute usage rollups are kept (0 keeps them forever)")
+	flag.DurationVar(&cfg.RollupHourKeep, "rollup-hour-retention", cfg.RollupHourKeep, "How long hourly usage rollups are kept (0 keeps them forever)")
+	flag.DurationVar(&cfg.RollupDayKeep, "rollup-day-retention", cfg.RollupDayKeep, "How long daily usage rollups are kept (0 keeps them forever)")
+	flag.DurationVar(&cfg.RollupLateness, "rollup-allowed-lateness", cfg.RollupLateness, "How far behind the latest event time an event is still rolled up (default 10m)")
+	flag.DurationVar(&cfg.CheckpointInterval, "checkpoint-interval", cfg.CheckpointInterval, "Interval between signed event chain checkpoints (default 1h)")
+	flag.IntVar(&cfg.AppendBatchSize, "append-batch-size", cfg.AppendBatchSize, "Max events per group commit (1 disables batching)")
+	flag.DurationVar(&cfg.AppendBatchDelay, "append-batch-delay", cfg.AppendBatchDelay, "Max time an event waits for its batch to fill (default 0)")
+	flag.IntVar(&cfg.AppendQueueSize, "append-queue-size", cfg.AppendQueueSize, "Pending appends before backpressure")
+	flag.StringVar(&cfg.AppendAck, "append-ack", cfg.AppendAck, "When appends return: commit (once committed) or queued (write-behind)")
+	flag.StringVar(&cfg.AppendSync, "append-sync", cfg.AppendSync, "How SQLite syncs commits to disk: full (every commit) or normal (at checkpoints)")
 
 	flag.Parse()
 
@@ -208,13 +374,23 @@ func main() {
 	// M1.3: Emit system_started log on boot (structured)
 	fmt.Println(`{"level":"info","msg":"system_started","component":"ratelord-d"}`)
 
-	// M2.1: Initialize SQLite Store
-	st, err := store.NewStore(cfg.DBPath)
+	// M2.1: Initialize Event Store (SQLite, or PostgreSQL for multi-host HA)
+	var st store.EventStore
+	var err error
+	if cfg.DBURL != "" {
+		st, err = postgres.NewPostgresStore(cfg.DBURL)
+	} else {
+		st, err = store.NewStoreWithSync(cfg.DBPath, store.SyncMode(cfg.AppendSync))
+	}
 	if err != nil {
 		fmt.Printf(`{"level":"fatal","msg":"failed_to_init_store","error":"%v"}`+"\n", err)
 		os.Exit(1)
 	}
-	fmt.Printf(`{"level":"info","msg":"store_initialized","path":"%s"}`+"\n", cfg.DBPath)
+	if cfg.DBURL != "" {
+		fmt.Println(`{"level":"info","msg":"store_initialized","backend":"postgres"}`)
+	} else {
+		fmt.Printf(`{"level":"info","msg":"store_initialized","backend":"sqlite","path":"%s","sync":"%s"}`+"\n", cfg.DBPath, cfg.AppendSync)
+	}
 
 	// M4.2: Initialize Identity Projection
 	identityProj := engine.NewIdentityProjection()
@@ -228,7 +404,10 @@ func main() {
 			os.Exit(1)
 		}
 		redisClient = redisclient.NewClient(opt)
-		usageStore = redis.NewRedisUsageStore(redisClient)
+		redisUsage := redis.NewRedisUsageStore(redisClient)
+		// Keeps the local cache coherent with writes from other daemons
+		go redisUsage.Watch(context.Background())
+		usageStore = redisUsage
 		fmt.Printf(`{"level":"info","msg":"using_redis_usage_store","url":"%s"}`+"\n", cfg.RedisURL)
 	} else {
 		redisClient = nil
@@ -237,13 +416,78 @@ func main() {
 	}
 	usageProj := engine.NewUsageProjectionWithStore(usageStore)
 
+	// The checkpointer signs the chain of the underlying store
+	chainStore, _ := st.(store.ChainStore)
+
+	// Appends carry the epoch of their writer and are fenced against the
+	// leader lease, so that a deposed leader cannot write after a takeover
 	var leaseStore store.LeaseStore
 	if redisClient != nil {
-		leaseStore = redis.NewRedisLeaseStore(redisClient)
-	} else {
-		leaseStore = st
+		redisLease := redis.NewRedisLeaseStore(redisClient)
+		leaseStore = redisLease
+		st = store.NewFencedStore(st, redisLease, leaderLease)
+	} else if ls, ok := st.(store.LeaseStore); ok {
+		leaseStore = ls
+		if f, ok := st.(store.Fenceable); ok {
+			f.SetFence(leaderLease)
+		}
 	}
 
+	// Set once the election manager exists, for the append writer to step down
+	var electionMgr atomic.Pointer[engine.ElectionManager]
+
+	// Group commit: concurrent appends (e.g. /v1/intent decisions) share one transaction
+	if cfg.AppendBatchSize > 1 {
+		if policy := store.AckPolicy(cfg.AppendAck); policy != store.AckCommit && policy != store.AckQueued {
+			fmt.Printf(`{"level":"fatal","msg":"invalid_append_ack","value":"%s"}`+"\n", cfg.AppendAck)
+			os.Exit(1)
+		}
+		st = store.NewBatchingStore(st, store.BatchConfig{
+			MaxBatch:  cfg.AppendBatchSize,
+			MaxDelay:  cfg.AppendBatchDelay,
+			QueueSize: cfg.AppendQueueSize,
+			Ack:       store.AckPolicy(cfg.AppendAck),
+			OnError: func(events []*store.Event, err error) {
+				fmt.Printf(`{"level":"error","msg":"event_append_failed","event_id":"%s","count":%d,"error":"%v"}`+"\n", events[0].EventID, len(events), err)
+				// With queued acks nobody waits for the write: step down
+				// here as soon as a newer leader fences us off
+				if errors.Is(err, store.ErrStaleEpoch) {
+					if em := electionMgr.Load(); em != nil {
+						go em.StepDown()
+					}
+				}
+			},
+		})
+		fmt.Printf(`{"level":"info","msg":"append_batching_enabled","batch_size":%d,"delay":"%s","ack":"%s"}`+"\n", cfg.AppendBatchSize, cfg.AppendBatchDelay, cfg.AppendAck)
+	}
+
+	// The blob store holds archived events and exported snapshots
+	var blobStore blob.BlobStore
+	blobLocation := cfg.BlobPath
+	if cfg.ArchiveEnabled || cfg.SnapshotExport {
+		blobStore = blob.NewLocalBlobStore(cfg.BlobPath)
+		if cfg.BlobURL != "" {
+			blobLocation = cfg.BlobURL
+			blobStore, err = blob.Open(cfg.BlobURL)
+			if err != nil {
+				fmt.Printf(`{"level":"fatal","msg":"failed_to_open_blob_store","error":"%v"}`+"\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	// Startup replay, rollups and lineage read the live log only; rebuilds
+	// also read the archive
+	logStore := st
+
+	// Queries (reports, /v1/events) also search events moved to cold storage
+	if cfg.ArchiveEnabled {
+		st = store.NewArchiveStore(st, blobStore)
+	}
+
+	// Publish appended events to stream subscribers (/v1/events/stream)
+	broadcaster := store.NewBroadcaster()
+	st = store.NewBroadcastStore(st, broadcaster)
+
 	// Initialize Provider Projection
 	providerProj := engine.NewProviderProjection()
 
@@ -258,52 +502,40 @@ func main() {
 	linearModel := &forecast.LinearModel{}
 	forecaster := forecast.NewForecaster(st, forecastProj, linearModel, usageProj)
 
-	// Replay events to build projection
-	// NOTE: This blocks startup, but safe for small event logs
-	var since time.Time
+	// Register projections; each replays from its own checkpoint
+	projections := engine.NewProjectionRegistry()
+	projections.Register(engine.ProjectionIdentity, identityProj)
+	projections.Register(engine.ProjectionUsage, usageProj)
+	projections.Register(engine.ProjectionProvider, providerProj)
+	projections.Register(engine.ProjectionCluster, clusterProj)
+	projections.Register(engine.ProjectionGraph, graphProj)
+	projections.Register(engine.ProjectionForecast, forecastProj)
+
+	// Grants outstanding per federation child
+	grantLedger := engine.NewGrantLedger()
+	projections.Register(engine.ProjectionGrants, grantLedger)
+	if cfg.Mode != "regional" {
+		// Budget granted to children is held out of the local pools; a
+		// regional leader grants from its parent's grant instead
+		usageProj.SetGrantLedger(grantLedger)
+	}
+
 	// Try loading from snapshot
 	if checkpoint, err := engine.LoadLatestSnapshot(context.Background(), st, identityProj, usageProj, providerProj, forecastProj); err != nil {
 		fmt.Printf(`{"level":"warn","msg":"failed_to_load_snapshot","error":"%v"}`+"\n", err)
-	} else if !checkpoint.IsZero() {
-		since = checkpoint
-		fmt.Printf(`{"level":"info","msg":"snapshot_loaded","checkpoint_ts":"%s"}`+"\n", checkpoint)
+	} else if checkpoint != nil {
+		for _, name := range engine.SnapshotProjections {
+			projections.SetCheckpoint(name, checkpoint)
+		}
+		fmt.Printf(`{"level":"info","msg":"snapshot_loaded","checkpoint_ts":"%s"}`+"\n", checkpoint.TsIngest)
 	}
 
-	events, err := st.ReadEvents(context.Background(), since, 10000) // arbitrary large limit, from beginning or snapshot
-	if err == nil {
-		// Replay identity events
-		if err := identityProj.Replay(events); err != nil {
-			fmt.Printf(`{"level":"error","msg":"failed_to_replay_identity_events","error":"%v"}`+"\n", err)
-		} else {
-			fmt.Printf(`{"level":"info","msg":"identity_projection_replayed","events_count":%d}`+"\n", len(events))
-		}
-		// Replay usage events
-		if err := usageProj.Replay(events); err != nil {
-			fmt.Printf(`{"level":"error","msg":"failed_to_replay_usage_events","error":"%v"}`+"\n", err)
-		} else {
-			fmt.Printf(`{"level":"info","msg":"usage_projection_replayed","events_count":%d}`+"\n", len(events))
-		}
-		// Replay provider events
-		providerProj.Replay(events)
-		fmt.Printf(`{"level":"info","msg":"provider_projection_replayed","events_count":%d}`+"\n", len(events))
-		// Replay cluster events
-		clusterProj.Replay(events)
-		fmt.Printf(`{"level":"info","msg":"cluster_projection_replayed"}` + "\n")
-		// Replay graph events
-		if err := graphProj.Replay(events); err != nil {
-			fmt.Printf(`{"level":"error","msg":"failed_to_replay_graph_events","error":"%v"}`+"\n", err)
-		} else {
-			fmt.Printf(`{"level":"info","msg":"graph_projection_replayed"}` + "\n")
-		}
-		// Replay forecast projection
-		for _, event := range events {
-			if event.EventType == store.EventTypeUsageObserved {
-				forecaster.OnUsageObserved(context.Background(), event)
-			}
-		}
-		fmt.Printf(`{"level":"info","msg":"forecast_projection_replayed"}` + "\n")
+	// Replay the events after the checkpoints in bounded batches
+	// NOTE: This blocks startup
+	if n, err := projections.Replay(context.Background(), logStore, engine.DefaultReplayBatchSize); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_replay_events","events_count":%d,"error":"%v"}`+"\n", n, err)
 	} else {
-		fmt.Printf(`{"level":"error","msg":"failed_to_read_events","error":"%v"}`+"\n", err)
+		fmt.Printf(`{"level":"info","msg":"projections_replayed","events_count":%d}`+"\n", n)
 	}
 
 	// M5.2: Initialize Policy Engine
@@ -322,13 +554,17 @@ func main() {
 
 	// M6.3: Initialize Polling Orchestrator
 	// Use the new Poller to drive the provider loop
-	poller := engine.NewPoller(st, 10*time.Second, forecaster, policyCfg) // Poll every 10s for demo
+	// Default 10s interval; per-provider intervals come from providers.polling in the policy
+	poller := engine.NewPoller(st, 10*time.Second, forecaster, policyCfg)
+	poller.SetUsageProjection(usageProj)
 
 	// Federation: Usage Router
 	var usageRouter *federated.UsageRouter
 
-	if cfg.Mode == "follower" {
-		fmt.Printf(`{"level":"info","msg":"starting_in_follower_mode","leader_url":"%s","follower_id":"%s"}`+"\n", cfg.LeaderURL, cfg.FollowerID)
+	if cfg.Mode == "follower" || cfg.Mode == "regional" {
+		// A regional leader is a follower of its parent that also grants to
+		// followers of its own, from the budget the parent grants it
+		fmt.Printf(`{"level":"info","msg":"starting_in_%s_mode","leader_url":"%s","follower_id":"%s"}`+"\n", cfg.Mode, cfg.LeaderURL, cfg.FollowerID)
 
 		usageRouter = federated.NewUsageRouter()
 
@@ -365,6 +601,28 @@ func main() {
 		// Register GitHub Providers (M14.2)
 		if policyCfg != nil {
 			for _, ghCfg := range policyCfg.Providers.GitHub {
+				if ghCfg.AppID != 0 {
+					// GitHub App: one pool set per installation
+					var keyPEM []byte
+					if ghCfg.PrivateKeyEnvVar != "" {
+						keyPEM = []byte(os.Getenv(ghCfg.PrivateKeyEnvVar))
+					} else if ghCfg.PrivateKeyPath != "" {
+						keyPEM, err = os.ReadFile(ghCfg.PrivateKeyPath)
+						if err != nil {
+							fmt.Printf(`{"level":"error","msg":"github_app_key_read_failed","provider_id":"%s","error":"%v"}`+"\n", ghCfg.ID, err)
+							continue
+						}
+					}
+					appProv, err := github.NewGitHubAppProvider(provider.ProviderID(ghCfg.ID), ghCfg.AppID, keyPEM, ghCfg.InstallationIDs, ghCfg.EnterpriseURL)
+					if err != nil {
+						fmt.Printf(`{"level":"error","msg":"github_app_provider_init_failed","provider_id":"%s","error":"%v"}`+"\n", ghCfg.ID, err)
+						continue
+					}
+					poller.Register(appProv)
+					fmt.Printf(`{"level":"info","msg":"github_app_provider_registered","id":"%s","app_id":%d}`+"\n", ghCfg.ID, ghCfg.AppID)
+					continue
+				}
+
 				token := ""
 				if ghCfg.TokenEnvVar != "" {
 					token = os.Getenv(ghCfg.TokenEnvVar)
@@ -385,10 +643,60 @@ func main() {
 						fmt.Printf(`{"level":"warn","msg":"openai_token_env_var_empty","env_var":"%s","provider_id":"%s"}`+"\n", oaCfg.TokenEnvVar, oaCfg.ID)
 					}
 				}
-				oaProv := openai.NewOpenAIProvider(provider.ProviderID(oaCfg.ID), token, oaCfg.OrgID, oaCfg.BaseURL)
+				oaProv, err := openai.NewOpenAIProviderWithOptions(provider.ProviderID(oaCfg.ID), token, oaCfg.OrgID, oaCfg.BaseURL, openai.Options{
+					ProjectID:        oaCfg.ProjectID,
+					Models:           oaCfg.Models,
+					Probe:            oaCfg.Probe,
+					AzureURLTemplate: oaCfg.AzureURLTemplate,
+				})
+				if err != nil {
+					fmt.Printf(`{"level":"error","msg":"openai_provider_init_failed","provider_id":"%s","error":"%v"}`+"\n", oaCfg.ID, err)
+					continue
+				}
 				poller.Register(oaProv)
 				fmt.Printf(`{"level":"info","msg":"openai_provider_registered","id":"%s"}`+"\n", oaCfg.ID)
 			}
+			// Register Anthropic-style LLM Providers
+			for _, anCfg := range policyCfg.Providers.Anthropic {
+				token := ""
+				if anCfg.TokenEnvVar != "" {
+					token = os.Getenv(anCfg.TokenEnvVar)
+					if token == "" {
+						fmt.Printf(`{"level":"warn","msg":"anthropic_token_env_var_empty","env_var":"%s","provider_id":"%s"}`+"\n", anCfg.TokenEnvVar, anCfg.ID)
+					}
+				}
+				anProv := anthropic.NewAnthropicProvider(provider.ProviderID(anCfg.ID), token, anCfg.BaseURL, anthropic.Options{
+					HeaderPrefix: anCfg.HeaderPrefix,
+					PoolPrefix:   anCfg.PoolPrefix,
+					Model:        anCfg.Model,
+				})
+				poller.Register(anProv)
+				fmt.Printf(`{"level":"info","msg":"anthropic_provider_registered","id":"%s"}`+"\n", anCfg.ID)
+			}
+			// Register Out-of-Process Provider Plugins
+			for _, plCfg := range policyCfg.Providers.Plugins {
+				if plCfg.Command == "" && plCfg.Socket == "" {
+					fmt.Printf(`{"level":"error","msg":"plugin_missing_command_or_socket","provider_id":"%s"}`+"\n", plCfg.ID)
+					continue
+				}
+				var timeout time.Duration
+				if plCfg.Timeout != "" {
+					if timeout, err = time.ParseDuration(plCfg.Timeout); err != nil {
+						fmt.Printf(`{"level":"warn","msg":"invalid_plugin_timeout","provider_id":"%s","error":"%v"}`+"\n", plCfg.ID, err)
+					}
+				}
+				plProv := plugin.New(plugin.Config{
+					ID:      provider.ProviderID(plCfg.ID),
+					Command: plCfg.Command,
+					Args:    plCfg.Args,
+					Env:     plCfg.Env,
+					Socket:  plCfg.Socket,
+					Timeout: timeout,
+				})
+				defer plProv.Close()
+				poller.Register(plProv)
+				fmt.Printf(`{"level":"info","msg":"plugin_provider_registered","id":"%s","command":"%s"}`+"\n", plCfg.ID, plCfg.Command)
+			}
 		}
 	}
 
@@ -397,14 +705,30 @@ func main() {
 	fmt.Println(`{"level":"info","msg":"restored_provider_state_from_event_stream"}`)
 
 	// M25.2: Initialize Rollup Worker
-	rollup := engine.NewRollupWorker(st)
+	// Rollups follow the live log: they never need archived events
+	rollup := engine.NewRollupWorker(logStore)
+	rollup.SetRetention(engine.RollupRetention{
+		Minute: cfg.RollupMinuteKeep,
+		Hour:   cfg.RollupHourKeep,
+		Day:    cfg.RollupDayKeep,
+	})
+	rollup.SetAllowedLateness(cfg.RollupLateness)
 
 	// M26.2: Initialize Webhook Dispatcher
 	dispatcher := engine.NewDispatcher(st)
 
 	// M27.2: Initialize Snapshot Worker
 	// Run every 5 minutes by default
-	snapshotWorker := engine.NewSnapshotWorker(st, identityProj, usageProj, providerProj, forecastProj, 5*time.Minute)
+	snapshotWorker := engine.NewSnapshotWorker(st, identityProj, usageProj, providerProj, forecastProj, cfg.SnapshotInterval)
+	snapshotWorker.SetRetention(engine.SnapshotRetention{
+		KeepLast:   cfg.SnapshotKeepLast,
+		KeepDaily:  cfg.SnapshotKeepDaily,
+		KeepWeekly: cfg.SnapshotKeepWeekly,
+	})
+	if cfg.SnapshotExport {
+		snapshotWorker.SetExportStore(blobStore)
+		fmt.Printf(`{"level":"info","msg":"snapshot_export_enabled","blob":"%s"}`+"\n", blobLocation)
+	}
 
 	// M36.1: Initialize Prune Worker
 	// Defaults to disabled if no policy
@@ -417,15 +741,41 @@ func main() {
 	// M36.2: Initialize Archive Worker
 	var archiveWorker *engine.ArchiveWorker
 	if cfg.ArchiveEnabled {
-		blobStore := blob.NewLocalBlobStore(cfg.BlobPath)
+		if cfg.ArchiveFormat != store.ArchiveFormatJSONL && cfg.ArchiveFormat != store.ArchiveFormatParquet {
+			fmt.Printf(`{"level":"fatal","msg":"invalid_archive_format","value":"%s"}`+"\n", cfg.ArchiveFormat)
+			os.Exit(1)
+		}
 		archiveConfig := engine.ArchiveConfig{
 			Enabled:       true,
 			Retention:     cfg.ArchiveRetention,
 			BatchSize:     1000,
 			CheckInterval: 1 * time.Hour,
+			Format:        cfg.ArchiveFormat,
 		}
 		archiveWorker = engine.NewArchiveWorker(st, blobStore, archiveConfig)
-		fmt.Printf(`{"level":"info","msg":"archive_worker_initialized","blob_path":"%s","retention":"%s"}`+"\n", cfg.BlobPath, cfg.ArchiveRetention)
+		fmt.Printf(`{"level":"info","msg":"archive_worker_initialized","blob":"%s","retention":"%s","format":"%s"}`+"\n", blobLocation, cfg.ArchiveRetention, cfg.ArchiveFormat)
+	}
+
+	// Initialize Chain Checkpointer (signed checkpoints of the event hash chain)
+	var checkpointer *engine.ChainCheckpointer
+	if cfg.CheckpointKey != "" && chainStore != nil {
+		key, err := store.ParseCheckpointKey(cfg.CheckpointKey)
+		if err != nil {
+			fmt.Printf(`{"level":"fatal","msg":"invalid_checkpoint_key","error":"%v"}`+"\n", err)
+			os.Exit(1)
+		}
+		checkpointer = engine.NewChainCheckpointer(st, chainStore, key, cfg.CheckpointInterval)
+		fmt.Printf(`{"level":"info","msg":"chain_checkpointer_initialized","interval":"%s"}`+"\n", cfg.CheckpointInterval)
+	}
+
+	// Reclaim the unused budget of expired federation grant leases
+	grantReclaimer := engine.NewGrantReclaimer(st, grantLedger, cfg.GrantReclaimAfter)
+	if cfg.Mode == "regional" {
+		// Reclaimed budget is ours again, to re-grant or to report unused.
+		// It is accounted against our parent's grant, not the local pool.
+		grantReclaimer.SetOnReclaim(func(g engine.ChildGrant) {
+			usageRouter.TrackUsage(g.ProviderID, g.PoolID, -g.Outstanding)
+		})
 	}
 
 	leaderServices := &LeaderServices{
@@ -435,6 +785,8 @@ func main() {
 		snapshotWorker: snapshotWorker,
 		pruneWorker:    pruneWorker,
 		archiveWorker:  archiveWorker,
+		checkpointer:   checkpointer,
+		grantReclaimer: grantReclaimer,
 	}
 
 	var em *engine.ElectionManager
@@ -446,13 +798,14 @@ func main() {
 	} else {
 		// Use AdvertisedURL as the holder ID so clients can redirect to it
 		holderID := cfg.AdvertisedURL
-		em = engine.NewElectionManager(leaseStore, holderID, "ratelord-leader", 5*time.Second, func() {
+		em = engine.NewElectionManager(leaseStore, holderID, leaderLease, 5*time.Second, func() {
 			fmt.Printf(`{"level":"info","msg":"promoted_to_leader","holder_id":"%s"}`+"\n", holderID)
 			leaderServices.Start()
 		}, func() {
 			fmt.Printf(`{"level":"info","msg":"demoted_from_leader","holder_id":"%s"}`+"\n", holderID)
 			leaderServices.Stop()
 		})
+		electionMgr.Store(em)
 		emCtx, emCancel := context.WithCancel(context.Background())
 		go em.Start(emCtx)
 		defer emCancel()
@@ -461,6 +814,16 @@ func main() {
 		// Wire up Epoch source
 		poller.SetEpochFunc(em.GetEpoch)
 		forecaster.SetEpochFunc(em.GetEpoch)
+		grantReclaimer.SetEpochFunc(em.GetEpoch)
+		if checkpointer != nil {
+			checkpointer.SetEpochFunc(em.GetEpoch)
+			checkpointer.SetOnFenced(em.StepDown)
+		}
+
+		// A fenced write means a newer leader took over while we were not
+		// looking (e.g. paused): stop writing at once
+		poller.SetOnFenced(em.StepDown)
+		forecaster.SetOnFenced(em.StepDown)
 	}
 
 	// M3.1: Start HTTP Server (in background)
@@ -470,11 +833,20 @@ func main() {
 
 	if em != nil {
 		srv.SetElectionManager(em)
+		srv.SetOnFenced(em.StepDown)
 	}
 
 	if usageRouter != nil {
 		srv.SetUsageTracker(usageRouter)
 	}
+	srv.SetGrantLedger(grantLedger, cfg.GrantReclaimAfter)
+	if cfg.Mode == "regional" {
+		srv.SetGrantUpstream(usageRouter)
+	}
+
+	srv.SetBroadcaster(broadcaster)
+	srv.SetProjections(projections)
+	srv.SetLineageStore(logStore)
 
 	// Load and set web assets
 	var webAssets fs.FS
@@ -534,7 +906,8 @@ func main() {
 
 	fmt.Printf(`{"level":"info","msg":"shutdown_initiated","signal":"%s"}`+"\n", shutdownSig)
 
-	// Shutdown Server
+	// Shutdown Server (end event streams first so they do not hold up shutdown)
+	broadcaster.Close()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Stop(ctx); err != nil {
@@ -545,6 +918,16 @@ func main() {
 
 	leaderServices.Stop()
 
+	// Give unused grants back to our leader rather than hold them until
+	// the leases expire
+	if usageRouter != nil {
+		if err := usageRouter.Return(ctx); err != nil {
+			fmt.Printf(`{"level":"warn","msg":"grant_return_failed","error":"%v"}`+"\n", err)
+		} else {
+			fmt.Println(`{"level":"info","msg":"grants_returned"}`)
+		}
+	}
+
 	// Cleanup
 	if err := st.Close(); err != nil {
 		fmt.Printf(`{"level":"error","msg":"failed_to_close_store","error":"%v"}`+"\n", err)
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -26,7 +28,8 @@ const (
 // Config
 const (
 	daemonURL      = "http://localhost:8090"
-	pollRate       = time.Second
+	pollRate       = 5 * time.Second // Identities only; events are streamed
+	reconnectDelay = 2 * time.Second
 	maxEvents      = 20
 	viewportHeight = 20
 )
@@ -76,11 +79,32 @@ var (
 // API Types (mirrored from pkg/store and pkg/api to avoid CGO deps)
 
 type Event struct {
-	EventID    string          `json:"event_id"`
-	EventType  string          `json:"event_type"`
-	TsEvent    time.Time       `json:"ts_event"`
-	Dimensions EventDimensions `json:"dimensions"`
-	Payload    json.RawMessage `json:"payload"`
+	EventID     string           `json:"event_id"`
+	EventType   string           `json:"event_type"`
+	TsEvent     time.Time        `json:"ts_event"`
+	Dimensions  EventDimensions  `json:"dimensions"`
+	Correlation EventCorrelation `json:"correlation"`
+	Payload     json.RawMessage  `json:"payload"`
+}
+
+type EventCorrelation struct {
+	CorrelationID string `json:"correlation_id"`
+	CausationID   string `json:"causation_id"`
+}
+
+// LineageNode and Lineage mirror GET /v1/events/{id}/lineage.
+type LineageNode struct {
+	Event
+	Relation string `json:"relation"`
+	Depth    int    `json:"depth"`
+}
+
+type Lineage struct {
+	EventID      string        `json:"event_id"`
+	Nodes        []LineageNode `json:"nodes"`
+	Correlated   []string      `json:"correlated"`
+	MissingCause string        `json:"missing_cause"`
+	Truncated    bool          `json:"truncated"`
 }
 
 type EventDimensions struct {
@@ -99,12 +123,28 @@ type Identity struct {
 
 type tickMsg time.Time
 
-type dataMsg struct {
-	events     []Event
+type identitiesMsg struct {
 	identities map[string]Identity
 	err        error
 }
 
+// backfillMsg carries the recent events loaded before the stream starts.
+type backfillMsg struct {
+	events []Event
+	err    error
+}
+
+// eventMsg is a single event received from /v1/events/stream.
+type eventMsg Event
+
+// streamErrMsg reports a dropped stream; the streamer reconnects by itself.
+type streamErrMsg struct{ err error }
+
+type lineageMsg struct {
+	lineage *Lineage
+	err     error
+}
+
 type model struct {
 	spinner       spinner.Model
 	viewport      viewport.Model
@@ -114,6 +154,9 @@ type model struct {
 	ready         bool
 	mode          ViewMode
 	selectedEvent int // Index into events slice
+	stream        chan tea.Msg
+	lineage       *Lineage // Lineage of the event shown in the detail view
+	lineageErr    error
 }
 
 func initialModel() model {
@@ -132,13 +175,15 @@ func initialModel() model {
 		events:     []Event{},
 		identities: make(map[string]Identity),
 		mode:       ViewDashboard,
+		stream:     make(chan tea.Msg, 64),
 	}
 }
 
 func (m model) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
-		fetchData(),
+		fetchBackfill(),
+		fetchIdentities(),
 		tick(),
 	)
 }
@@ -161,6 +206,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter":
 				if len(m.events) > 0 {
 					m.mode = ViewEventDetail
+					m.lineage, m.lineageErr = nil, nil
+					cmds = append(cmds, fetchLineage(m.events[m.selectedEvent].EventID))
 				}
 			case "up", "k":
 				if m.selectedEvent > 0 {
@@ -191,29 +238,60 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 
 	case tickMsg:
-		cmds = append(cmds, fetchData(), tick())
+		cmds = append(cmds, fetchIdentities(), tick())
 
-	case dataMsg:
+	case identitiesMsg:
 		if msg.err != nil {
 			m.err = msg.err
 			// Keep old data to prevent flickering
 		} else {
-			m.err = nil
-			m.events = msg.events
 			m.identities = msg.identities
+		}
 
-			// Adjust selection index if list shrank
-			if m.selectedEvent >= len(m.events) && len(m.events) > 0 {
-				m.selectedEvent = len(m.events) - 1
+	case backfillMsg:
+		// Stream from the newest backfilled event so nothing is missed in between.
+		var lastID string
+		if msg.err != nil {
+			m.err = msg.err
+		} else {
+			m.events = msg.events
+			if len(m.events) > 0 {
+				lastID = m.events[0].EventID
 			}
-
 			m.updateViewportContent()
 		}
+		go streamEvents(m.stream, lastID)
+		cmds = append(cmds, waitForStream(m.stream))
 
 		if !m.ready {
 			m.ready = true
 		}
 
+	case eventMsg:
+		m.err = nil
+		// Newest first, as returned by /v1/events
+		m.events = append([]Event{Event(msg)}, m.events...)
+		if len(m.events) > maxEvents {
+			m.events = m.events[:maxEvents]
+		}
+		// Keep the selection on the same event while new ones arrive
+		if m.selectedEvent > 0 && m.selectedEvent < len(m.events)-1 {
+			m.selectedEvent++
+		}
+		m.updateViewportContent()
+		cmds = append(cmds, waitForStream(m.stream))
+
+	case streamErrMsg:
+		m.err = msg.err
+		cmds = append(cmds, waitForStream(m.stream))
+
+	case lineageMsg:
+		// Ignore late responses for an event that is no longer shown
+		if m.mode == ViewEventDetail && m.selectedEvent < len(m.events) &&
+			(msg.lineage == nil || msg.lineage.EventID == m.events[m.selectedEvent].EventID) {
+			m.lineage, m.lineageErr = msg.lineage, msg.err
+		}
+
 	case tea.WindowSizeMsg:
 		if !m.ready {
 			m.viewport = viewport.New(msg.Width, viewportHeight)
@@ -351,6 +429,11 @@ func (m model) eventDetailView() string {
 	sb.WriteString(jsonKeyStyle.Render("Payload:"))
 	sb.WriteString("\n")
 	sb.WriteString(jsonValStyle.Render(prettyPayload))
+	sb.WriteString("\n\n")
+
+	sb.WriteString(jsonKeyStyle.Render("Lineage:"))
+	sb.WriteString("\n")
+	sb.WriteString(m.lineageView())
 
 	sb.WriteString("\n\n")
 	sb.WriteString(subtleStyle.Render("Press Esc to return"))
@@ -358,28 +441,125 @@ func (m model) eventDetailView() string {
 	return paneStyle.Render(sb.String())
 }
 
+// lineageView renders the causation chain from the root cause down to the
+// effects of the selected event, indented by depth.
+func (m model) lineageView() string {
+	if m.lineageErr != nil {
+		return errorStyle.Render(fmt.Sprintf("  unavailable: %v", m.lineageErr))
+	}
+	if m.lineage == nil {
+		return subtleStyle.Render("  loading...")
+	}
+
+	nodes := append([]LineageNode(nil), m.lineage.Nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Depth < nodes[j].Depth })
+
+	var sb strings.Builder
+	if m.lineage.MissingCause != "" {
+		sb.WriteString(subtleStyle.Render(fmt.Sprintf("  (cause %s no longer stored)", m.lineage.MissingCause)))
+		sb.WriteString("\n")
+	}
+	minDepth := 0
+	if len(nodes) > 0 {
+		minDepth = nodes[0].Depth
+	}
+	for _, n := range nodes {
+		indent := strings.Repeat("  ", n.Depth-minDepth+1)
+		line := fmt.Sprintf("%s %s %s", n.TsEvent.Format("15:04:05"), n.EventType, n.EventID)
+		if n.Relation == "self" {
+			line = selectedStyle.Render(line)
+		}
+		sb.WriteString(indent + "└ " + line + "\n")
+	}
+	if m.lineage.Truncated {
+		sb.WriteString(subtleStyle.Render("  (more effects not shown)") + "\n")
+	}
+	if len(m.lineage.Correlated) > 0 {
+		sb.WriteString(subtleStyle.Render(fmt.Sprintf("  + %d correlated event(s)", len(m.lineage.Correlated))))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
 // Commands
 
-func fetchData() tea.Cmd {
+func fetchBackfill() tea.Cmd {
 	return func() tea.Msg {
-		// Fetch Events
 		events, err := getEvents()
-		if err != nil {
-			return dataMsg{err: err}
-		}
+		return backfillMsg{events: events, err: err}
+	}
+}
 
-		// Fetch Identities
+func fetchIdentities() tea.Cmd {
+	return func() tea.Msg {
 		identities, err := getIdentities()
-		if err != nil {
-			return dataMsg{err: err}
-		}
+		return identitiesMsg{identities: identities, err: err}
+	}
+}
+
+// waitForStream delivers the next message produced by streamEvents.
+func waitForStream(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-ch
+	}
+}
+
+// streamEvents follows /v1/events/stream for the lifetime of the program,
+// reconnecting with Last-Event-ID so no events are missed across drops.
+func streamEvents(ch chan<- tea.Msg, lastID string) {
+	for {
+		err := readStream(ch, &lastID)
+		ch <- streamErrMsg{err: err}
+		time.Sleep(reconnectDelay)
+	}
+}
+
+// readStream consumes one SSE connection, updating lastID as events arrive.
+func readStream(ch chan<- tea.Msg, lastID *string) error {
+	req, err := http.NewRequest("GET", daemonURL+"/v1/events/stream", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+
+	// No client timeout: the stream stays open, kept alive by heartbeats.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		*lastID = "" // Cursor pruned; continue with live events only
+		return fmt.Errorf("stream cursor expired")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("stream status %d", resp.StatusCode)
+	}
 
-		return dataMsg{
-			events:     events,
-			identities: identities,
-			err:        nil,
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue // id (repeated in the payload), retry, heartbeats
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+			continue
 		}
+		if e.EventID == "" {
+			continue // Control messages such as "lagged"
+		}
+		*lastID = e.EventID
+		ch <- eventMsg(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
+	return fmt.Errorf("stream closed")
 }
 
 func getEvents() ([]Event, error) {
@@ -401,6 +581,32 @@ func getEvents() ([]Event, error) {
 	return events, nil
 }
 
+func fetchLineage(eventID string) tea.Cmd {
+	return func() tea.Msg {
+		lineage, err := getLineage(eventID)
+		return lineageMsg{lineage: lineage, err: err}
+	}
+}
+
+func getLineage(eventID string) (*Lineage, error) {
+	c := &http.Client{Timeout: 2 * time.Second}
+	resp, err := c.Get(fmt.Sprintf("%s/v1/events/%s/lineage", daemonURL, url.PathEscape(eventID)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lineage status %d", resp.StatusCode)
+	}
+
+	var lineage Lineage
+	if err := json.NewDecoder(resp.Body).Decode(&lineage); err != nil {
+		return nil, err
+	}
+	return &lineage, nil
+}
+
 func getIdentities() (map[string]Identity, error) {
 	c := &http.Client{Timeout: 500 * time.Millisecond}
 	resp, err := c.Get(fmt.Sprintf("%s/v1/identities", daemonURL))
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+	"github.com/rmax-ai/ratelord/pkg/store"
+	"github.com/rmax-ai/ratelord/pkg/store/postgres"
+)
+
+const archiveUsage = `Usage: ratelord admin archive <command> [flags]
+
+Commands:
+  list     [--from <time>] [--to <time>]
+           List archived segments that may hold events in the range
+  restore  --from <time> --to <time> [--into <path> | --db <path> | --db-url <url>]
+           Copy archived events with a timestamp in [from, to) back into a store
+
+Times are RFC 3339 (2025-01-31T12:00:00Z) or dates (2025-01-31).
+Archives are read from --blob-url (default RATELORD_BLOB_URL, e.g.
+s3://bucket/prefix), else from --blob-path (default RATELORD_BLOB_PATH,
+then ./blobs).
+
+restore writes into a scratch SQLite database with --into, which is created
+if needed, or else into the live database (RATELORD_DB_URL, then
+RATELORD_DB_PATH, then ./ratelord.db). Events already present are skipped.
+Restored events keep their original ingest time, so while archiving is
+enabled the daemon moves them back to cold storage on its next pass; restore
+into a scratch database to investigate old data.`
+
+// handleArchive reads the blob store and database directly, like verify.
+func handleArchive(args []string) {
+	if len(args) < 1 {
+		fmt.Println(archiveUsage)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("archive "+args[0], flag.ExitOnError)
+	blobPath := fs.String("blob-path", envOr("RATELORD_BLOB_PATH", "blobs"), "Path to blob storage directory")
+	blobURL := fs.String("blob-url", os.Getenv("RATELORD_BLOB_URL"), "Blob storage URL (overrides --blob-path)")
+	fromStr := fs.String("from", "", "Start of the range (inclusive)")
+	toStr := fs.String("to", "", "End of the range (exclusive)")
+	into := fs.String("into", "", "Scratch SQLite database to restore into")
+	dbPath := fs.String("db", envOr("RATELORD_DB_PATH", "ratelord.db"), "Path to SQLite database")
+	dbURL := fs.String("db-url", os.Getenv("RATELORD_DB_URL"), "PostgreSQL connection URL (overrides --db)")
+	fs.Usage = func() { fmt.Println(archiveUsage) }
+	fs.Parse(args[1:])
+
+	from, err := parseArchiveTime(*fromStr)
+	if err != nil {
+		fmt.Printf("Error: invalid --from: %v\n", err)
+		os.Exit(1)
+	}
+	to, err := parseArchiveTime(*toStr)
+	if err != nil {
+		fmt.Printf("Error: invalid --to: %v\n", err)
+		os.Exit(1)
+	}
+
+	blobs, err := openBlobStore(*blobPath, *blobURL)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	archive := store.NewArchive(blobs)
+
+	switch args[0] {
+	case "list":
+		segments, err := archive.Segments(ctx, from, to)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%-22s %-22s %s\n", "FIRST INGEST", "LAST INGEST", "KEY")
+		for _, seg := range segments {
+			fmt.Printf("%-22s %-22s %s\n", seg.First.Format(time.RFC3339), seg.Last.Format(time.RFC3339), seg.Key)
+		}
+		fmt.Printf("%d segment(s)\n", len(segments))
+
+	case "restore":
+		if from.IsZero() || to.IsZero() {
+			fmt.Println("Error: restore requires --from and --to")
+			os.Exit(1)
+		}
+
+		dst, err := openAdminStore(*into, *dbURL, *dbPath)
+		if err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			os.Exit(1)
+		}
+		defer dst.Close()
+
+		res, err := store.RestoreArchive(ctx, archive, dst, from, to)
+		if err != nil {
+			fmt.Printf("Error: %v (restored %d events before failing)\n", err, res.Restored)
+			dst.Close()
+			os.Exit(1)
+		}
+		fmt.Printf("Restored %d event(s) from %d segment(s), skipped %d already present\n", res.Restored, res.Segments, res.Skipped)
+
+	default:
+		fmt.Println(archiveUsage)
+		os.Exit(1)
+	}
+}
+
+// openBlobStore opens the blob store at url, or else the local directory path.
+func openBlobStore(path, url string) (blob.BlobStore, error) {
+	if url != "" {
+		return blob.Open(url)
+	}
+	return blob.NewLocalBlobStore(path), nil
+}
+
+// openAdminStore opens the scratch SQLite database into, creating it if
+// needed, or else the live database at dbURL or dbPath.
+func openAdminStore(into, dbURL, dbPath string) (store.EventStore, error) {
+	switch {
+	case into != "":
+		return store.NewStore(into)
+	case dbURL != "":
+		return postgres.OpenPostgresStore(dbURL)
+	default:
+		return store.OpenStore(dbPath)
+	}
+}
+
+func parseArchiveTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -47,6 +47,12 @@ func printUsage() {
 	fmt.Println("  ratelord identity add <name> <kind> [token]  Register a new identity")
 	fmt.Println("  ratelord identity delete <id>               Delete an identity")
 	fmt.Println("  ratelord admin prune <retention>             Prune old events (e.g. 720h)")
+	fmt.Println("  ratelord admin migrate status|up|down        Inspect or change the schema version")
+	fmt.Println("  ratelord admin verify                        Verify the event log hash chain")
+	fmt.Println("  ratelord admin archive list|restore          List or restore archived events")
+	fmt.Println("  ratelord admin snapshot list|export|import   Manage snapshots in the blob store")
+	fmt.Println("  ratelord admin restore --at <time>           Restore the state as of an instant")
+	fmt.Println("  ratelord admin rebuild <projection>          Rebuild a projection from the event log")
 	fmt.Println("  ratelord mcp [--url <url>]                   Run MCP server (stdio)")
 }
 
@@ -68,11 +74,38 @@ func handleMCP(args []string) {
 }
 
 func handleAdmin(args []string) {
-	if len(args) < 2 || args[0] != "prune" {
+	if len(args) < 1 {
+		fmt.Println("Usage: ratelord admin prune <retention> | migrate status|up|down | verify | archive list|restore | snapshot list|export|import | restore --at <time> | rebuild <projection>")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "prune":
+		handlePrune(args[1:])
+	case "migrate":
+		handleMigrate(args[1:])
+	case "verify":
+		handleVerify(args[1:])
+	case "archive":
+		handleArchive(args[1:])
+	case "snapshot":
+		handleSnapshot(args[1:])
+	case "restore":
+		handleRestore(args[1:])
+	case "rebuild":
+		handleRebuild(args[1:])
+	default:
+		fmt.Println("Usage: ratelord admin prune <retention> | migrate status|up|down | verify | archive list|restore | snapshot list|export|import | restore --at <time> | rebuild <projection>")
+		os.Exit(1)
+	}
+}
+
+func handlePrune(args []string) {
+	if len(args) < 1 {
 		fmt.Println("Usage: ratelord admin prune <retention>")
 		os.Exit(1)
 	}
-	retention := args[1]
+	retention := args[0]
 
 	token := os.Getenv("RATELORD_ADMIN_TOKEN")
 	if token == "" {
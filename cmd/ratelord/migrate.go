@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+	"github.com/rmax-ai/ratelord/pkg/store/postgres"
+)
+
+const migrateUsage = `Usage: ratelord admin migrate [--db <path> | --db-url <url>] <command>
+
+Commands:
+  status          List migrations and whether they are applied
+  up [version]    Apply pending migrations (up to version, default latest)
+  down [steps]    Revert the most recent migrations (default 1)
+
+The database defaults to RATELORD_DB_URL, then RATELORD_DB_PATH, then ./ratelord.db.
+Stop the daemon before running up or down.`
+
+// handleMigrate works on the database directly, not through the daemon, so it
+// can repair a schema the daemon refuses to start with.
+func handleMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := fs.String("db", envOr("RATELORD_DB_PATH", "ratelord.db"), "Path to SQLite database")
+	dbURL := fs.String("db-url", os.Getenv("RATELORD_DB_URL"), "PostgreSQL connection URL (overrides --db)")
+	fs.Usage = func() { fmt.Println(migrateUsage) }
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println(migrateUsage)
+		os.Exit(1)
+	}
+
+	var migrator *store.Migrator
+	if *dbURL != "" {
+		st, err := postgres.OpenPostgresStore(*dbURL)
+		if err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			os.Exit(1)
+		}
+		defer st.Close()
+		migrator = st.Migrator()
+	} else {
+		st, err := store.OpenStore(*dbPath)
+		if err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			os.Exit(1)
+		}
+		defer st.Close()
+		migrator = st.Migrator()
+	}
+
+	if err := runMigrate(context.Background(), migrator, fs.Arg(0), fs.Args()[1:]); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runMigrate(ctx context.Context, m *store.Migrator, cmd string, args []string) error {
+	// Optional numeric argument: target version for up, steps for down
+	n := 0
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid number %q", args[0])
+		}
+		n = v
+	}
+
+	switch cmd {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-8s %-32s %-10s %s\n", "VERSION", "NAME", "STATE", "APPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05Z07:00")
+			}
+			if !s.Known {
+				state = "unknown"
+			} else if s.Modified {
+				state = "modified"
+			}
+			fmt.Printf("%-8d %-32s %-10s %s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return m.Verify(ctx)
+
+	case "up":
+		applied, err := m.Up(ctx, n)
+		for _, mig := range applied {
+			fmt.Printf("Applied %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return nil
+
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		reverted, err := m.Down(ctx, n)
+		for _, mig := range reverted {
+			fmt.Printf("Reverted %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("Nothing to revert")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (want status, up or down)", cmd)
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/engine"
+)
+
+const rebuildUsage = `Usage: ratelord admin rebuild <projection> [--no-wait]
+       ratelord admin rebuild status
+
+Resets one projection of the running daemon (identity, usage, provider,
+cluster, graph or forecast) and replays the whole event log into it,
+archived events included, without a restart. The command waits for the
+rebuild to finish unless --no-wait is given; 'status' lists the projections
+with their checkpoints and last rebuild.
+
+Requires RATELORD_ADMIN_TOKEN.`
+
+// handleRebuild talks to the daemon like prune.
+func handleRebuild(args []string) {
+	if len(args) < 1 {
+		fmt.Println(rebuildUsage)
+		os.Exit(1)
+	}
+
+	token := os.Getenv("RATELORD_ADMIN_TOKEN")
+	if token == "" {
+		fmt.Println("Error: RATELORD_ADMIN_TOKEN env var required")
+		os.Exit(1)
+	}
+
+	if args[0] == "status" {
+		statuses, err := fetchProjections(token)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%-10s %-11s %-22s %s\n", "NAME", "STATE", "LAST REBUILD", "CHECKPOINT")
+		for _, st := range statuses {
+			state, last := "ready", "-"
+			if st.Rebuilding {
+				state = "rebuilding"
+			}
+			if st.LastRebuild != nil {
+				last = st.LastRebuild.FinishedAt.Format(time.RFC3339)
+				if st.LastRebuild.Error != "" {
+					state = "failed"
+				}
+			}
+			fmt.Printf("%-10s %-11s %-22s %s\n", st.Name, state, last, st.Checkpoint.EventID)
+		}
+		return
+	}
+
+	name := args[0]
+	wait := !(len(args) > 1 && args[1] == "--no-wait")
+
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8090/v1/admin/projections/"+name+"/rebuild", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error contacting daemon: %v\n", err)
+		os.Exit(1)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		fmt.Printf("Error: %s\n%s\n", resp.Status, string(body))
+		os.Exit(1)
+	}
+	if !wait {
+		fmt.Printf("Rebuilding projection %s\n", name)
+		return
+	}
+
+	fmt.Printf("Rebuilding projection %s...\n", name)
+	for {
+		time.Sleep(time.Second)
+		statuses, err := fetchProjections(token)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, st := range statuses {
+			if st.Name != name || st.Rebuilding {
+				continue
+			}
+			if st.LastRebuild == nil {
+				fmt.Println("Error: the daemon reports no rebuild")
+				os.Exit(1)
+			}
+			if st.LastRebuild.Error != "" {
+				fmt.Printf("Error: rebuild failed after %d event(s): %s\n", st.LastRebuild.EventsRead, st.LastRebuild.Error)
+				os.Exit(1)
+			}
+			fmt.Printf("Rebuilt projection %s from %d event(s), checkpoint %s\n", name, st.LastRebuild.EventsRead, st.Checkpoint.EventID)
+			return
+		}
+	}
+}
+
+func fetchProjections(token string) ([]engine.ProjectionStatus, error) {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:8090/v1/admin/projections", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contacting daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s\n%s", resp.Status, string(body))
+	}
+
+	var out struct {
+		Projections []engine.ProjectionStatus `json:"projections"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return out.Projections, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/engine"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+const snapshotUsage = `Usage: ratelord admin snapshot <command> [flags]
+
+Commands:
+  list     List the snapshots in the database and in the blob store
+  export   [--id <snapshot>]
+           Copy a snapshot (default the newest) to the blob store
+  import   [--key <blob key>] [--into <path> | --db <path> | --db-url <url>]
+           Load an exported snapshot (default the newest) into a database
+
+Exported snapshots carry their checkpoint event, so importing one into an
+empty database lets a new node start without replaying the full log.
+The blob store and database flags are those of 'ratelord admin archive'.`
+
+const restoreUsage = `Usage: ratelord admin restore --at <time> [--into <path>] [--export] [--blob-only]
+
+Rebuilds the state as of --at: loads the newest snapshot taken at or before
+that instant, from the database or the blob store, and replays the events
+ingested after it up to --at, including archived ones. The result is saved
+as a new snapshot:
+
+  --into <path>   import it into a SQLite database, created if needed; start
+                  the daemon on that database to resume from --at
+  --export        write it to the blob store
+
+--blob-only ignores the database and restores from exported snapshots and
+archived events alone, e.g. after losing the database.
+
+Times are RFC 3339 (2025-01-31T12:00:00Z) or dates (2025-01-31). The
+source database and blob store flags are those of 'ratelord admin archive'.`
+
+// handleSnapshot reads the database and blob store directly, like archive.
+func handleSnapshot(args []string) {
+	if len(args) < 1 {
+		fmt.Println(snapshotUsage)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("snapshot "+args[0], flag.ExitOnError)
+	blobPath := fs.String("blob-path", envOr("RATELORD_BLOB_PATH", "blobs"), "Path to blob storage directory")
+	blobURL := fs.String("blob-url", os.Getenv("RATELORD_BLOB_URL"), "Blob storage URL (overrides --blob-path)")
+	into := fs.String("into", "", "SQLite database to import into")
+	dbPath := fs.String("db", envOr("RATELORD_DB_PATH", "ratelord.db"), "Path to SQLite database")
+	dbURL := fs.String("db-url", os.Getenv("RATELORD_DB_URL"), "PostgreSQL connection URL (overrides --db)")
+	id := fs.String("id", "", "Snapshot to export")
+	key := fs.String("key", "", "Blob key of the snapshot to import")
+	fs.Usage = func() { fmt.Println(snapshotUsage) }
+	fs.Parse(args[1:])
+
+	blobs, err := openBlobStore(*blobPath, *blobURL)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	st, err := openAdminStore(*into, *dbURL, *dbPath)
+	if err != nil {
+		fmt.Printf("Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	fail := func(err error) {
+		fmt.Printf("Error: %v\n", err)
+		st.Close()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "list":
+		snaps, err := st.ListSnapshots(ctx)
+		if err != nil {
+			fail(err)
+		}
+		exported, err := store.ListExportedSnapshots(ctx, blobs)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("%-8s %-22s %-32s %s\n", "SOURCE", "TAKEN", "SNAPSHOT", "CHECKPOINT / KEY")
+		for _, snap := range snaps {
+			fmt.Printf("%-8s %-22s %-32s %s\n", "db", snap.TsSnapshot.UTC().Format(time.RFC3339), snap.SnapshotID, snap.LastEventID)
+		}
+		for _, exp := range exported {
+			fmt.Printf("%-8s %-22s %-32s %s\n", "blob", exp.TsSnapshot.Format(time.RFC3339), exp.SnapshotID, exp.Key)
+		}
+		fmt.Printf("%d snapshot(s) in the database, %d in the blob store\n", len(snaps), len(exported))
+
+	case "export":
+		snapshotID := *id
+		if snapshotID == "" {
+			latest, err := st.GetLatestSnapshot(ctx)
+			if err != nil {
+				fail(err)
+			}
+			if latest == nil {
+				fail(fmt.Errorf("the database has no snapshot"))
+			}
+			snapshotID = latest.SnapshotID
+		}
+		k, err := store.ExportSnapshot(ctx, st, blobs, snapshotID)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("Exported snapshot %s to %s\n", snapshotID, k)
+
+	case "import":
+		k := *key
+		if k == "" {
+			exported, err := store.ListExportedSnapshots(ctx, blobs)
+			if err != nil {
+				fail(err)
+			}
+			if len(exported) == 0 {
+				fail(fmt.Errorf("the blob store has no snapshot"))
+			}
+			k = exported[0].Key
+		}
+		exp, err := store.GetSnapshotExport(ctx, blobs, k)
+		if err != nil {
+			fail(err)
+		}
+		if err := store.ImportSnapshot(ctx, st, exp); err != nil {
+			fail(err)
+		}
+		fmt.Printf("Imported snapshot %s taken at %s\n", exp.Snapshot.SnapshotID, exp.Snapshot.TsSnapshot.UTC().Format(time.RFC3339))
+
+	default:
+		fmt.Println(snapshotUsage)
+		st.Close()
+		os.Exit(1)
+	}
+}
+
+// handleRestore performs a point-in-time restore; see restoreUsage.
+func handleRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	blobPath := fs.String("blob-path", envOr("RATELORD_BLOB_PATH", "blobs"), "Path to blob storage directory")
+	blobURL := fs.String("blob-url", os.Getenv("RATELORD_BLOB_URL"), "Blob storage URL (overrides --blob-path)")
+	dbPath := fs.String("db", envOr("RATELORD_DB_PATH", "ratelord.db"), "Path to the source SQLite database")
+	dbURL := fs.String("db-url", os.Getenv("RATELORD_DB_URL"), "Source PostgreSQL connection URL (overrides --db)")
+	atStr := fs.String("at", "", "Instant to restore")
+	into := fs.String("into", "", "SQLite database to import the restored snapshot into")
+	export := fs.Bool("export", false, "Write the restored snapshot to the blob store")
+	blobOnly := fs.Bool("blob-only", false, "Restore from the blob store alone")
+	fs.Usage = func() { fmt.Println(restoreUsage) }
+	fs.Parse(args)
+
+	at, err := parseArchiveTime(*atStr)
+	if err != nil || at.IsZero() {
+		fmt.Println("Error: restore requires a valid --at")
+		os.Exit(1)
+	}
+	if *into == "" && !*export {
+		fmt.Println("Error: restore requires --into or --export")
+		os.Exit(1)
+	}
+
+	blobs, err := openBlobStore(*blobPath, *blobURL)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	var src store.EventStore
+	if !*blobOnly {
+		if src, err = openAdminStore("", *dbURL, *dbPath); err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			os.Exit(1)
+		}
+		defer src.Close()
+	}
+
+	ctx := context.Background()
+	res, err := engine.RestoreAt(ctx, src, blobs, at)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if res.Base != "" {
+		fmt.Printf("Loaded snapshot %s from the %s\n", res.Base, res.BaseSource)
+	}
+	fmt.Printf("Replayed %d event(s) up to %s (checkpoint %s)\n", res.Replayed, at.Format(time.RFC3339), res.Export.Checkpoint.EventID)
+
+	if *export {
+		key, err := store.PutSnapshotExport(ctx, blobs, res.Export)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported snapshot %s to %s\n", res.Export.Snapshot.SnapshotID, key)
+	}
+	if *into != "" {
+		dst, err := store.NewStore(*into)
+		if err != nil {
+			fmt.Printf("Error opening database: %v\n", err)
+			os.Exit(1)
+		}
+		defer dst.Close()
+		if err := store.ImportSnapshot(ctx, dst, res.Export); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			dst.Close()
+			os.Exit(1)
+		}
+		fmt.Printf("Imported snapshot %s into %s\n", res.Export.Snapshot.SnapshotID, *into)
+	}
+}
@@ -0,0 +1,105 @@
+// Command quota is an example ratelord provider plugin. It reports a single
+// daily quota pool whose usage grows on every poll, and persists the counter
+// through the daemon so it survives plugin restarts.
+//
+// Configure it in the policy file:
+//
+//	providers:
+//	  plugins:
+//	    - id: "internal-quota"
+//	      command: "/usr/local/bin/ratelord-quota-plugin"
+//	      env: ["QUOTA_LIMIT=5000"]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+	"github.com/rmax-ai/ratelord/pkg/provider/plugin"
+)
+
+type quotaState struct {
+	Used  int64     `json:"used"`
+	Reset time.Time `json:"reset"`
+}
+
+type quotaProvider struct {
+	limit int64
+
+	mu    sync.Mutex
+	state quotaState
+}
+
+func newQuotaProvider(limit int64) *quotaProvider {
+	return &quotaProvider{limit: limit}
+}
+
+func (q *quotaProvider) ID() provider.ProviderID {
+	return "quota"
+}
+
+func (q *quotaProvider) Poll(ctx context.Context) (provider.PollResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now().UTC()
+	if !now.Before(q.state.Reset) {
+		// New day: start over
+		q.state = quotaState{Reset: now.Truncate(24 * time.Hour).Add(24 * time.Hour)}
+	}
+
+	// Stand-in for calling an internal quota API
+	if q.state.Used < q.limit {
+		q.state.Used++
+	}
+
+	state, err := json.Marshal(q.state)
+	if err != nil {
+		return provider.PollResult{}, err
+	}
+
+	return provider.PollResult{
+		ProviderID: q.ID(),
+		Status:     "success",
+		Timestamp:  now,
+		Usage: []provider.UsageObservation{{
+			PoolID:    "quota:daily",
+			Used:      q.state.Used,
+			Remaining: q.limit - q.state.Used,
+			Limit:     q.limit,
+			ResetAt:   q.state.Reset,
+		}},
+		State: state,
+	}, nil
+}
+
+func (q *quotaProvider) Restore(state []byte) error {
+	if len(state) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return json.Unmarshal(state, &q.state)
+}
+
+func main() {
+	// stdout carries the protocol; log to stderr
+	log.SetOutput(os.Stderr)
+
+	limit := int64(1000)
+	if v := os.Getenv("QUOTA_LIMIT"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	if err := plugin.Serve(newQuotaProvider(limit)); err != nil {
+		log.Fatalf("plugin failed: %v", err)
+	}
+}
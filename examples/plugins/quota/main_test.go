@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rmax-ai/ratelord/pkg/provider/plugin/plugintest"
+)
+
+func TestConformance(t *testing.T) {
+	plugintest.Run(t, newQuotaProvider(10))
+}
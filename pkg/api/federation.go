@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,7 +12,14 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
-// handleGrant processes federation grant requests.
+// upstreamGrantTimeout bounds how long a grant request of a regional leader
+// waits for its parent to top up the budget it grants from.
+const upstreamGrantTimeout = 2 * time.Second
+
+// handleGrant processes federation grant requests from children: followers,
+// or regional leaders re-granting to followers of their own. On a regional
+// leader it is also a client, funding grants from its parent (see
+// SetGrantUpstream).
 func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
@@ -24,8 +32,8 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Basic validation
-	if req.FollowerID == "" || req.PoolID == "" || req.Amount <= 0 {
+	// Basic validation. A request for no budget only renews a lease.
+	if req.FollowerID == "" || req.PoolID == "" || req.Amount < 0 || (req.Amount == 0 && req.GrantID == "") {
 		http.Error(w, `{"error":"missing_or_invalid_fields"}`, http.StatusBadRequest)
 		return
 	}
@@ -49,7 +57,7 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 
 	if result.Decision == engine.DecisionApprove || result.Decision == engine.DecisionApproveWithModifications {
 		granted = req.Amount
-		validUntil = time.Now().Add(1 * time.Minute) // Default TTL
+		validUntil = time.Now().Add(engine.DefaultGrantTTL)
 
 		// Apply modifications if any
 		if result.Decision == engine.DecisionApproveWithModifications {
@@ -66,9 +74,81 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 		validUntil = time.Now() // Immediate expiry
 	}
 
+	// A regional leader grants from what its parent granted it. It asks the
+	// parent for more before locking the pool, so that a slow parent holds
+	// up this request only; the budget is read again under the lock.
+	if granted > 0 && s.upstream != nil {
+		want := granted
+		if s.grants != nil {
+			held := req.Held
+			if req.GrantID == "" {
+				held = 0
+			}
+			want = s.grants.Size(req.FollowerID, req.ProviderID, req.PoolID, granted, held, engine.DefaultGrantTTL)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), upstreamGrantTimeout)
+		_, err := s.upstream.Ensure(ctx, req.ProviderID, req.PoolID, want)
+		cancel()
+		if err != nil {
+			fmt.Printf(`{"level":"warn","msg":"upstream_grant_failed","trace_id":"%s","provider_id":"%s","pool_id":"%s","error":"%v"}`+"\n",
+				getTraceID(r.Context()), req.ProviderID, req.PoolID, err)
+		}
+	}
+
+	// Each grant is sized against what the previous ones left
+	defer s.lockPool(req.ProviderID, req.PoolID)()
+
+	// The child's budget is held under a lease, renewed by every request
+	// naming it. Budget held under any other lease is not the child's anymore.
+	var g engine.ChildGrant
+	var ok bool
+	if s.grants != nil {
+		g, ok = s.grants.Get(req.FollowerID, req.ProviderID, req.PoolID)
+	}
+	if req.GrantID != "" && (!ok || g.GrantID != req.GrantID) {
+		// The lease was returned, reclaimed or superseded; the child must
+		// open a new one knowing it holds nothing
+		http.Error(w, `{"error":"grant_closed"}`, http.StatusGone)
+		return
+	}
+
+	held := req.Held
+	var lease engine.ChildGrant
+	leased := false
+	if s.grants != nil {
+		if req.GrantID != "" {
+			lease, leased = g, true
+			held = min(held, g.Outstanding)
+		} else {
+			held = 0
+			// A child that lost its lease (e.g. restarted) opens a new one;
+			// what is left of the old one returns to the pool
+			if ok && g.Outstanding > 0 && !s.reclaimSuperseded(w, r, g) {
+				return
+			}
+		}
+		// Grants are sized to the child's consumption rate
+		if granted > 0 {
+			granted = s.grants.Size(req.FollowerID, req.ProviderID, req.PoolID, granted, held, engine.DefaultGrantTTL)
+		}
+	}
+
 	remaining := int64(0)
-	if poolState, ok := s.usage.GetPoolState(req.ProviderID, req.PoolID); ok {
-		remaining = poolState.Remaining
+	poolState, known := s.usage.GetPoolState(req.ProviderID, req.PoolID)
+	if known {
+		remaining = poolState.Available()
+	}
+
+	if granted > 0 && s.upstream != nil {
+		remaining, known = s.upstream.Held(req.ProviderID, req.PoolID), true
+	}
+
+	// Children share the pool in proportion to their demand
+	if granted > 0 && s.grants != nil && known {
+		share := s.grants.Share(req.FollowerID, req.ProviderID, req.PoolID, remaining, time.Now().Add(-s.reclaimAfter))
+		if granted > share {
+			granted = share
+		}
 	}
 
 	resp := protocol.GrantResponse{
@@ -77,19 +157,35 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 		RemainingGlobal: remaining,
 	}
 
-	// Emit GrantIssued Event only if granted > 0
-	if granted > 0 {
+	// Emit GrantIssued Event if granted > 0 or a lease is renewed
+	if granted > 0 || leased {
 		now := time.Now()
+		eventID := store.EventID(fmt.Sprintf("grant_%d", now.UnixNano()))
+		var grantID string
+		var expiresAt time.Time
+		if s.grants != nil {
+			// A lease is named after the event opening it
+			grantID = string(eventID)
+			if leased {
+				grantID = lease.GrantID
+			}
+			expiresAt = now.Add(s.reclaimAfter)
+			resp.GrantID, resp.ReclaimAt = grantID, expiresAt
+		}
 		payload, _ := json.Marshal(map[string]interface{}{
 			"follower_id": req.FollowerID,
 			"provider_id": req.ProviderID, // Added M38.1
 			"pool_id":     req.PoolID,
 			"amount":      granted,
 			"metadata":    req.Metadata,
+			"grant_id":    grantID,
+			"held":        held,
+			"used":        req.Used,
+			"expires_at":  expiresAt,
 		})
 
 		evt := store.Event{
-			EventID:       store.EventID(fmt.Sprintf("grant_%d", now.UnixNano())),
+			EventID:       eventID,
 			EventType:     store.EventTypeGrantIssued,
 			SchemaVersion: 1,
 			TsEvent:       now,
@@ -114,6 +210,11 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := s.store.AppendEvent(r.Context(), &evt); err != nil {
+			// A newer leader does its own accounting; grant nothing
+			if s.fenced(r, err) {
+				writeLeadershipLost(w)
+				return
+			}
 			fmt.Printf(`{"level":"error","msg":"failed_to_append_grant_event","error":"%v"}`+"\n", err)
 			// Proceed but logging error
 		} else {
@@ -121,8 +222,15 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 			if s.cluster != nil {
 				s.cluster.Apply(evt)
 			}
-			// Update local usage immediately so subsequent grants see the usage
-			s.usage.Apply(evt)
+			// The ledger holds the grant out of the local pool
+			if s.grants != nil {
+				s.grants.Apply(evt)
+			}
+			if s.upstream != nil {
+				// The budget comes from the parent's grant, whose usage the
+				// poller observes
+				s.upstream.TrackUsage(req.ProviderID, req.PoolID, granted)
+			}
 		}
 	}
 
@@ -137,6 +245,165 @@ func (s *Server) handleGrant(w http.ResponseWriter, r *http.Request) {
 		getTraceID(r.Context()), req.FollowerID, req.ProviderID, req.PoolID, resp.Granted)
 }
 
+// handleReturn takes back the unused budget of a child, closing its lease
+// before it expires.
+func (s *Server) handleReturn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req protocol.ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid_json_body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.FollowerID == "" || req.PoolID == "" || req.GrantID == "" || req.Amount < 0 {
+		http.Error(w, `{"error":"missing_or_invalid_fields"}`, http.StatusBadRequest)
+		return
+	}
+
+	if s.grants == nil {
+		http.Error(w, `{"error":"grant_ledger_not_initialized"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	defer s.grants.LockPool(req.ProviderID, req.PoolID)()
+
+	// The lease may have expired and been reclaimed already
+	g, ok := s.grants.Get(req.FollowerID, req.ProviderID, req.PoolID)
+	if !ok || g.GrantID != req.GrantID {
+		http.Error(w, `{"error":"grant_not_found"}`, http.StatusNotFound)
+		return
+	}
+
+	// A child cannot return more than it was granted
+	returned := min(req.Amount, g.Outstanding)
+	evt := s.grantClosedEvent(store.EventTypeGrantReturned, g, returned, req.Used)
+	if err := s.store.AppendEvent(r.Context(), &evt); err != nil {
+		if s.fenced(r, err) {
+			writeLeadershipLost(w)
+			return
+		}
+		fmt.Printf(`{"level":"error","msg":"failed_to_append_grant_return","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+		http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	s.applyGrantClosed(evt, g.ProviderID, g.PoolID, returned)
+	engine.RatelordGrantsReturned.WithLabelValues(g.ProviderID, g.PoolID).Add(float64(returned))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(protocol.ReturnResponse{Returned: returned}); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_return_response","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+
+	fmt.Printf(`{"level":"info","msg":"grant_returned","trace_id":"%s","follower_id":"%s","grant_id":"%s","provider_id":"%s","pool_id":"%s","returned":%d}`+"\n",
+		getTraceID(r.Context()), req.FollowerID, req.GrantID, g.ProviderID, g.PoolID, returned)
+}
+
+// lockPool serializes the grants of a pool, returning the function unlocking
+// it. Without a ledger, grants are serialized across pools.
+func (s *Server) lockPool(providerID, poolID string) func() {
+	if s.grants != nil {
+		return s.grants.LockPool(providerID, poolID)
+	}
+	s.grantMu.Lock()
+	return s.grantMu.Unlock
+}
+
+// reclaimSuperseded reclaims what is left of a lease the child no longer
+// holds. It reports whether the grant request may proceed, having answered
+// it otherwise.
+func (s *Server) reclaimSuperseded(w http.ResponseWriter, r *http.Request, g engine.ChildGrant) bool {
+	evt := s.grantClosedEvent(store.EventTypeGrantReclaimed, g, g.Outstanding, g.Used)
+	if err := s.store.AppendEvent(r.Context(), &evt); err != nil {
+		if s.fenced(r, err) {
+			writeLeadershipLost(w)
+			return false
+		}
+		// The old lease expires on its own
+		fmt.Printf(`{"level":"error","msg":"failed_to_append_grant_reclaim","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+		return true
+	}
+	s.applyGrantClosed(evt, g.ProviderID, g.PoolID, g.Outstanding)
+	engine.RatelordGrantsReclaimed.WithLabelValues(g.ProviderID, g.PoolID).Add(float64(g.Outstanding))
+	fmt.Printf(`{"level":"info","msg":"grant_superseded","trace_id":"%s","follower_id":"%s","grant_id":"%s","reclaimed":%d}`+"\n",
+		getTraceID(r.Context()), g.ChildID, g.GrantID, g.Outstanding)
+	return true
+}
+
+// grantClosedEvent builds the event closing the lease of g, crediting amount
+// back to the pool.
+func (s *Server) grantClosedEvent(eventType store.EventType, g engine.ChildGrant, amount, used int64) store.Event {
+	now := time.Now()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"follower_id": g.ChildID,
+		"provider_id": g.ProviderID,
+		"pool_id":     g.PoolID,
+		"grant_id":    g.GrantID,
+		"amount":      amount,
+		"used":        used,
+	})
+	return store.Event{
+		EventID:       store.EventID(fmt.Sprintf("%s_%d", eventType, now.UnixNano())),
+		EventType:     eventType,
+		SchemaVersion: 1,
+		TsEvent:       now,
+		TsIngest:      now,
+		Epoch:         s.getEpoch(),
+		Source: store.EventSource{
+			OriginKind: "daemon",
+			OriginID:   "api",
+			WriterID:   "ratelord-d",
+		},
+		Dimensions: store.EventDimensions{
+			AgentID:    store.SentinelSystem,
+			IdentityID: g.ChildID,
+			WorkloadID: "federation",
+			ScopeID:    "global",
+		},
+		Correlation: store.EventCorrelation{
+			CorrelationID: fmt.Sprintf("grant_req_%s", g.ChildID),
+			CausationID:   g.GrantID,
+		},
+		Payload: payload,
+	}
+}
+
+// applyGrantClosed credits the budget of a closed lease back to the pool, or
+// on a regional leader, to the budget granted by its parent.
+func (s *Server) applyGrantClosed(evt store.Event, providerID, poolID string, amount int64) {
+	s.grants.Apply(evt)
+	if s.upstream != nil {
+		s.upstream.TrackUsage(providerID, poolID, -amount)
+	}
+}
+
+// handleGrants lists the grants outstanding per child.
+func (s *Server) handleGrants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.grants == nil {
+		http.Error(w, `{"error":"grant_ledger_not_initialized"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"grants": s.grants.List(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_grants","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
+
 // handleClusterNodes returns the current topology.
 func (s *Server) handleClusterNodes(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
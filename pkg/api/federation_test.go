@@ -2,17 +2,22 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/rmax-ai/ratelord/pkg/engine"
 	"github.com/rmax-ai/ratelord/pkg/graph"
 	"github.com/rmax-ai/ratelord/pkg/protocol"
+	"github.com/rmax-ai/ratelord/pkg/provider/federated"
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
@@ -53,6 +58,14 @@ func setupTestServer(t *testing.T) (*Server, func()) {
 	return s, cleanup
 }
 
+// setGrantLedger hands out grants as leases held out of the local pools, as
+// on a global leader.
+func setGrantLedger(s *Server) {
+	ledger := engine.NewGrantLedger()
+	s.SetGrantLedger(ledger, engine.DefaultGrantReclaimAfter)
+	s.usage.(*engine.UsageProjection).SetGrantLedger(ledger)
+}
+
 func TestHandleGrant(t *testing.T) {
 	s, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -110,6 +123,308 @@ func TestHandleGrant(t *testing.T) {
 	}
 }
 
+// TestHandleGrant_Regional grants through a global leader and a regional
+// leader to two followers of the region.
+func TestHandleGrant_Regional(t *testing.T) {
+	global, cleanupGlobal := setupTestServer(t)
+	defer cleanupGlobal()
+	setGrantLedger(global)
+	global.usage.(*engine.UsageProjection).LoadState("", time.Time{}, []engine.PoolState{{ProviderID: "p1", PoolID: "pool-a", Remaining: 1000}})
+	globalMux := http.NewServeMux()
+	globalMux.HandleFunc("/v1/federation/grant", global.handleGrant)
+	globalTS := httptest.NewServer(globalMux)
+	defer globalTS.Close()
+
+	regional, cleanupRegional := setupTestServer(t)
+	defer cleanupRegional()
+	router := federated.NewUsageRouter()
+	router.Register(federated.NewFederatedProvider("p1", globalTS.URL, "region-eu"))
+	regional.SetGrantLedger(engine.NewGrantLedger(), engine.DefaultGrantReclaimAfter)
+	regional.SetGrantUpstream(router)
+	regionalMux := http.NewServeMux()
+	regionalMux.HandleFunc("/v1/federation/grant", regional.handleGrant)
+	regionalMux.HandleFunc("/v1/federation/grants", regional.handleGrants)
+	regionalTS := httptest.NewServer(regionalMux)
+	defer regionalTS.Close()
+
+	ask := func(follower string, amount int64) protocol.GrantResponse {
+		t.Helper()
+		body, _ := json.Marshal(protocol.GrantRequest{FollowerID: follower, ProviderID: "p1", PoolID: "pool-a", Amount: amount})
+		resp, err := http.Post(regionalTS.URL+"/v1/federation/grant", "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		var grant protocol.GrantResponse
+		if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return grant
+	}
+
+	// The region asks the global leader for the budget it grants
+	if grant := ask("node-a", 400); grant.Granted != 400 || grant.ReclaimAt.IsZero() {
+		t.Fatalf("expected node-a to be granted 400 with a reclaim time, got %+v", grant)
+	}
+	// The region holds 400 more for node-b, shared with node-a
+	if grant := ask("node-b", 400); grant.Granted != 200 {
+		t.Fatalf("expected node-b to get its share of 200, got %+v", grant)
+	}
+
+	g, ok := global.grants.Get("region-eu", "p1", "pool-a")
+	if !ok || g.Used != 400 {
+		t.Errorf("expected the global leader to see the region use 400, got %+v", g)
+	}
+	if state, _ := global.usage.GetPoolState("p1", "pool-a"); state.Available() != 200 {
+		t.Errorf("expected 200 left globally, got %d", state.Available())
+	}
+
+	resp, err := http.Get(regionalTS.URL + "/v1/federation/grants")
+	if err != nil {
+		t.Fatalf("Failed to list grants: %v", err)
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Grants []engine.ChildGrant `json:"grants"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode grants: %v", err)
+	}
+	if len(list.Grants) != 2 || list.Grants[0].ChildID != "node-a" || list.Grants[0].Outstanding != 400 ||
+		list.Grants[1].ChildID != "node-b" || list.Grants[1].Outstanding != 200 {
+		t.Errorf("unexpected grants of the region: %+v", list.Grants)
+	}
+}
+
+// TestHandleGrant_Lease opens, renews, replaces and returns grant leases.
+func TestHandleGrant_Lease(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+	setGrantLedger(s)
+	usage := s.usage.(*engine.UsageProjection)
+	usage.LoadState("", time.Time{}, []engine.PoolState{{ProviderID: "p1", PoolID: "pool-a", Remaining: 1000}})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/federation/grant", s.handleGrant)
+	mux.HandleFunc("/v1/federation/return", s.handleReturn)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	post := func(path string, body interface{}, out interface{}) int {
+		t.Helper()
+		bodyBytes, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+path, "application/json", bytes.NewBuffer(bodyBytes))
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		if out != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+	remaining := func() int64 {
+		state, _ := usage.GetPoolState("p1", "pool-a")
+		return state.Available()
+	}
+
+	// Open a lease
+	var grant protocol.GrantResponse
+	post("/v1/federation/grant", protocol.GrantRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", Amount: 300}, &grant)
+	if grant.Granted != 300 || grant.GrantID == "" || grant.ReclaimAt.IsZero() {
+		t.Fatalf("expected a lease on 300, got %+v", grant)
+	}
+	grantID := grant.GrantID
+
+	// Renew it without asking for more
+	time.Sleep(time.Millisecond)
+	var renewal protocol.GrantResponse
+	post("/v1/federation/grant", protocol.GrantRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", GrantID: grantID, Held: 250, Used: 50}, &renewal)
+	if renewal.Granted != 0 || renewal.GrantID != grantID || !renewal.ReclaimAt.After(grant.ReclaimAt) {
+		t.Fatalf("expected the lease to be renewed, got %+v", renewal)
+	}
+	if g, _ := s.grants.Get("node-1", "p1", "pool-a"); g.Outstanding != 250 {
+		t.Errorf("expected 250 outstanding, got %d", g.Outstanding)
+	}
+
+	// A restarted child opens a new lease; the old one is reclaimed
+	post("/v1/federation/grant", protocol.GrantRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", Amount: 100}, &grant)
+	if grant.GrantID == "" || grant.GrantID == grantID {
+		t.Fatalf("expected a new lease, got %+v", grant)
+	}
+	if got := remaining(); got != 1000-300+250-grant.Granted {
+		t.Errorf("expected the old lease to be credited back, remaining %d", got)
+	}
+
+	// The old lease cannot be returned anymore
+	if status := post("/v1/federation/return", protocol.ReturnRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", GrantID: grantID, Amount: 250}, nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a replaced lease, got %d", status)
+	}
+
+	// Nor renewed
+	if status := post("/v1/federation/grant", protocol.GrantRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", GrantID: grantID, Amount: 100, Held: 250}, nil); status != http.StatusGone {
+		t.Errorf("expected 410 renewing a replaced lease, got %d", status)
+	}
+
+	// Return the new one early, claiming more than granted
+	before := remaining()
+	var ret protocol.ReturnResponse
+	if status := post("/v1/federation/return", protocol.ReturnRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", GrantID: grant.GrantID, Amount: 5000}, &ret); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if ret.Returned != grant.Granted || remaining() != before+grant.Granted {
+		t.Errorf("expected %d returned and credited, got %d (remaining %d)", grant.Granted, ret.Returned, remaining())
+	}
+	if _, ok := s.grants.Get("node-1", "p1", "pool-a"); ok {
+		t.Error("returned lease should be closed")
+	}
+
+	if status := post("/v1/federation/return", protocol.ReturnRequest{FollowerID: "node-1", PoolID: "pool-a"}, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 without a grant_id, got %d", status)
+	}
+}
+
+func TestHandleGrant_Concurrent(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+	setGrantLedger(s)
+	usage := s.usage.(*engine.UsageProjection)
+	usage.LoadState("", time.Time{}, []engine.PoolState{{ProviderID: "p1", PoolID: "pool-a", Remaining: 1000}})
+
+	ts := httptest.NewServer(http.HandlerFunc(s.handleGrant))
+	defer ts.Close()
+
+	// Every child asks for more than its share at once
+	var wg sync.WaitGroup
+	var total atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body, _ := json.Marshal(protocol.GrantRequest{FollowerID: fmt.Sprintf("node-%d", i), ProviderID: "p1", PoolID: "pool-a", Amount: 200})
+			resp, err := http.Post(ts.URL, "application/json", bytes.NewBuffer(body))
+			if err != nil {
+				t.Errorf("Failed to send request: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			var grant protocol.GrantResponse
+			if err := json.NewDecoder(resp.Body).Decode(&grant); err != nil {
+				t.Errorf("Failed to decode response: %v", err)
+				return
+			}
+			total.Add(grant.Granted)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := total.Load(); got > 1000 {
+		t.Errorf("expected at most the 1000 of the pool granted, got %d", got)
+	}
+	if state, _ := usage.GetPoolState("p1", "pool-a"); state.Available() < 0 {
+		t.Errorf("pool over-granted: %+v", state)
+	}
+}
+
+// blockingUpstream holds a budget of 1000 per pool, and makes Ensure wait
+// for release.
+type blockingUpstream struct {
+	release chan struct{}
+	ensured chan struct{}
+}
+
+func (u *blockingUpstream) TrackUsage(providerID, poolID string, amount int64) {}
+
+func (u *blockingUpstream) Ensure(ctx context.Context, providerID, poolID string, amount int64) (int64, error) {
+	u.ensured <- struct{}{}
+	select {
+	case <-u.release:
+	case <-ctx.Done():
+	}
+	return 1000, nil
+}
+
+func (u *blockingUpstream) Held(providerID, poolID string) int64 {
+	return 1000
+}
+
+// A parent slow to top up a regional leader holds up only the request that
+// asked for more, not the other requests on the pool.
+func TestHandleGrant_SlowUpstream(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+	s.SetGrantLedger(engine.NewGrantLedger(), engine.DefaultGrantReclaimAfter)
+	upstream := &blockingUpstream{release: make(chan struct{}), ensured: make(chan struct{}, 2)}
+	s.SetGrantUpstream(upstream)
+	ts := httptest.NewServer(http.HandlerFunc(s.handleGrant))
+	defer ts.Close()
+
+	post := func(req protocol.GrantRequest) (protocol.GrantResponse, int) {
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL, "application/json", bytes.NewBuffer(body))
+		if err != nil {
+			t.Errorf("Failed to send request: %v", err)
+			return protocol.GrantResponse{}, 0
+		}
+		defer resp.Body.Close()
+		var grant protocol.GrantResponse
+		json.NewDecoder(resp.Body).Decode(&grant)
+		return grant, resp.StatusCode
+	}
+
+	// node-b opens a lease while the parent answers
+	go func() { <-upstream.ensured; upstream.release <- struct{}{} }()
+	lease, _ := post(protocol.GrantRequest{FollowerID: "node-b", ProviderID: "p1", PoolID: "pool-a", Amount: 100})
+	if lease.GrantID == "" {
+		t.Fatalf("expected a lease, got %+v", lease)
+	}
+
+	// node-a waits for the parent
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		post(protocol.GrantRequest{FollowerID: "node-a", ProviderID: "p1", PoolID: "pool-a", Amount: 100})
+	}()
+	<-upstream.ensured
+
+	// node-b renews its lease meanwhile
+	renewed := make(chan int, 1)
+	go func() {
+		_, status := post(protocol.GrantRequest{FollowerID: "node-b", ProviderID: "p1", PoolID: "pool-a", GrantID: lease.GrantID, Held: 100})
+		renewed <- status
+	}()
+	select {
+	case status := <-renewed:
+		if status != http.StatusOK {
+			t.Errorf("expected the renewal to succeed, got %d", status)
+		}
+	case <-time.After(time.Second):
+		t.Error("renewal blocked behind the upstream request")
+	}
+	close(upstream.release)
+	<-done
+}
+
+// A node without a grant ledger issues no leases, so any lease named is closed.
+func TestHandleGrant_ClosedLeaseWithoutLedger(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+	ts := httptest.NewServer(http.HandlerFunc(s.handleGrant))
+	defer ts.Close()
+
+	body, _ := json.Marshal(protocol.GrantRequest{FollowerID: "node-1", ProviderID: "p1", PoolID: "pool-a", GrantID: "grant_1", Amount: 100})
+	resp, err := http.Post(ts.URL, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("expected 410 renewing a lease without a ledger, got %d", resp.StatusCode)
+	}
+}
+
 func TestHandleGrant_InvalidJSON(t *testing.T) {
 	s := &Server{} // Doesn't need store for this test as it fails before
 	mux := http.NewServeMux()
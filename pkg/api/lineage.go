@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+const (
+	// defaultLineageDepth is how many causation hops are followed in each
+	// direction unless the request asks for another depth.
+	defaultLineageDepth = 10
+	maxLineageDepth     = 50
+
+	// maxLineageNodes bounds the response for events with very wide fan-out
+	// (e.g. a poll that caused thousands of decisions).
+	maxLineageNodes = 500
+)
+
+// EventQuerier is the read side of the event log used by lineage.
+type EventQuerier interface {
+	QueryEvents(ctx context.Context, filter store.EventFilter) ([]*store.Event, error)
+}
+
+// SetLineageStore sets where lineage looks for effects and correlated
+// events, typically the live log without the archive. Each frontier node is
+// one query, which must not scan cold storage. Defaults to the server store.
+func (s *Server) SetLineageStore(q EventQuerier) {
+	s.lineageStore = q
+}
+
+// lineageQuerier returns the store lineage queries read from.
+func (s *Server) lineageQuerier() EventQuerier {
+	if s.lineageStore != nil {
+		return s.lineageStore
+	}
+	return s.store
+}
+
+// LineageNode is an event in a lineage graph. Depth is negative for
+// ancestors, zero for the requested event and positive for descendants.
+type LineageNode struct {
+	*store.Event
+	Relation string `json:"relation"` // "ancestor", "self" or "descendant"
+	Depth    int    `json:"depth"`
+}
+
+// LineageEdge points from a cause to the event it caused.
+type LineageEdge struct {
+	From store.EventID `json:"from"`
+	To   store.EventID `json:"to"`
+}
+
+// Lineage is the causation DAG around an event, plus the other events
+// sharing its correlation ID.
+type Lineage struct {
+	EventID    store.EventID   `json:"event_id"`
+	Nodes      []LineageNode   `json:"nodes"`
+	Edges      []LineageEdge   `json:"edges"`
+	Correlated []store.EventID `json:"correlated"`
+	// MissingCause is set when the oldest ancestor cites a cause that is no
+	// longer stored (pruned or archived).
+	MissingCause string `json:"missing_cause,omitempty"`
+	// Truncated is set when descendants were cut off at maxLineageNodes.
+	Truncated bool `json:"truncated"`
+}
+
+// handleEventSubresource serves /v1/events/{id}/lineage.
+func (s *Server) handleEventSubresource(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/v1/events/")
+	id, sub, ok := strings.Cut(rest, "/")
+	if !ok || id == "" || sub != "lineage" {
+		http.Error(w, `{"error":"not_found"}`, http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	depth := defaultLineageDepth
+	if d := r.URL.Query().Get("depth"); d != "" {
+		val, err := strconv.Atoi(d)
+		if err != nil || val < 0 {
+			http.Error(w, `{"error":"invalid_depth"}`, http.StatusBadRequest)
+			return
+		}
+		depth = min(val, maxLineageDepth)
+	}
+
+	lineage, err := s.buildLineage(r.Context(), store.EventID(id), depth)
+	if err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_build_lineage","trace_id":"%s","event_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), id, err)
+		http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	if lineage == nil {
+		http.Error(w, `{"error":"event_not_found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lineage); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_response","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
+
+// buildLineage follows CausationID up to depth hops towards the root cause
+// and down to depth hops of effects. Returns nil, nil if the event is unknown.
+func (s *Server) buildLineage(ctx context.Context, id store.EventID, depth int) (*Lineage, error) {
+	root, err := s.store.GetEvent(ctx, id)
+	if err != nil || root == nil {
+		return nil, err
+	}
+
+	l := &Lineage{
+		EventID:    id,
+		Nodes:      []LineageNode{{Event: root, Relation: "self"}},
+		Edges:      []LineageEdge{},
+		Correlated: []store.EventID{},
+	}
+	seen := map[store.EventID]bool{id: true}
+
+	// Ancestors: each event has at most one cause, so this is a chain.
+	child := root
+	for d := 1; d <= depth; d++ {
+		causeID := child.Correlation.CausationID
+		if !isEventRef(causeID) || seen[store.EventID(causeID)] {
+			break
+		}
+		cause, err := s.store.GetEvent(ctx, store.EventID(causeID))
+		if err != nil {
+			return nil, err
+		}
+		if cause == nil {
+			l.MissingCause = causeID
+			break
+		}
+		seen[cause.EventID] = true
+		l.Nodes = append(l.Nodes, LineageNode{Event: cause, Relation: "ancestor", Depth: -d})
+		l.Edges = append(l.Edges, LineageEdge{From: cause.EventID, To: child.EventID})
+		child = cause
+	}
+
+	// Descendants: breadth-first over the effects of each level.
+	frontier := []store.EventID{id}
+	for d := 1; d <= depth && len(frontier) > 0; d++ {
+		var next []store.EventID
+		for _, parent := range frontier {
+			effects, err := s.lineageQuerier().QueryEvents(ctx, store.EventFilter{CausationID: string(parent), Limit: maxLineageNodes + 1})
+			if err != nil {
+				return nil, err
+			}
+			for _, evt := range effects {
+				if seen[evt.EventID] {
+					continue
+				}
+				if len(l.Nodes) >= maxLineageNodes {
+					l.Truncated = true
+					return s.addCorrelated(ctx, l, root)
+				}
+				seen[evt.EventID] = true
+				l.Nodes = append(l.Nodes, LineageNode{Event: evt, Relation: "descendant", Depth: d})
+				l.Edges = append(l.Edges, LineageEdge{From: parent, To: evt.EventID})
+				next = append(next, evt.EventID)
+			}
+		}
+		frontier = next
+	}
+
+	return s.addCorrelated(ctx, l, root)
+}
+
+// addCorrelated lists the events sharing the root's correlation ID that are
+// not already part of the causation graph.
+func (s *Server) addCorrelated(ctx context.Context, l *Lineage, root *store.Event) (*Lineage, error) {
+	if !isEventRef(root.Correlation.CorrelationID) {
+		return l, nil
+	}
+	events, err := s.lineageQuerier().QueryEvents(ctx, store.EventFilter{CorrelationID: root.Correlation.CorrelationID, Limit: maxLineageNodes})
+	if err != nil {
+		return nil, err
+	}
+
+	inGraph := make(map[store.EventID]bool, len(l.Nodes))
+	for _, n := range l.Nodes {
+		inGraph[n.EventID] = true
+	}
+	for _, evt := range events {
+		if !inGraph[evt.EventID] {
+			l.Correlated = append(l.Correlated, evt.EventID)
+		}
+	}
+	return l, nil
+}
+
+// isEventRef reports whether a correlation field refers to something, as
+// opposed to being empty or a sentinel such as "sentinel:unknown".
+func isEventRef(id string) bool {
+	return id != "" && !strings.HasPrefix(id, "sentinel:")
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func TestHandleEventLineage(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	chain := []struct {
+		id, eventType, correlation, causation string
+	}{
+		{"poll_1", "provider_poll_observed", "poll_corr", store.SentinelUnknown},
+		{"usage_1", "usage_observed", "poll_corr", "poll_1"},
+		{"forecast_1", "forecast_computed", "forecast_corr", "usage_1"},
+		{"dec_1", "intent_decided", "intent_1", "usage_1"},
+		{"usage_intent_1", "usage_observed", "intent_1", "dec_1"},
+		{"other_1", "intent_submitted", "intent_1", store.SentinelUnknown},
+	}
+	for i, c := range chain {
+		ts := base.Add(time.Duration(i) * time.Second)
+		evt := &store.Event{
+			EventID:       store.EventID(c.id),
+			EventType:     store.EventType(c.eventType),
+			SchemaVersion: 1,
+			TsEvent:       ts,
+			TsIngest:      ts,
+			Dimensions:    store.EventDimensions{AgentID: "a", IdentityID: "id", WorkloadID: "w", ScopeID: "s"},
+			Correlation:   store.EventCorrelation{CorrelationID: c.correlation, CausationID: c.causation},
+			Payload:       json.RawMessage(`{}`),
+		}
+		if err := s.store.AppendEvent(context.Background(), evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleEventSubresource(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/v1/events/dec_1/lineage")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var l Lineage
+	if err := json.NewDecoder(w.Body).Decode(&l); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	depths := map[store.EventID]int{}
+	for _, n := range l.Nodes {
+		depths[n.EventID] = n.Depth
+	}
+	want := map[store.EventID]int{"dec_1": 0, "usage_1": -1, "poll_1": -2, "usage_intent_1": 1}
+	if len(depths) != len(want) {
+		t.Errorf("expected nodes %v, got %v", want, depths)
+	}
+	for id, d := range want {
+		if got, ok := depths[id]; !ok || got != d {
+			t.Errorf("node %s: expected depth %d, got %d (present %v)", id, d, got, ok)
+		}
+	}
+	if len(l.Edges) != 3 {
+		t.Errorf("expected 3 edges, got %v", l.Edges)
+	}
+	if len(l.Correlated) != 1 || l.Correlated[0] != "other_1" {
+		t.Errorf("expected other_1 as correlated, got %v", l.Correlated)
+	}
+
+	// Depth bounds both directions
+	w = get("/v1/events/dec_1/lineage?depth=1")
+	l = Lineage{}
+	json.NewDecoder(w.Body).Decode(&l)
+	if len(l.Nodes) != 3 {
+		t.Errorf("expected 3 nodes at depth 1, got %d", len(l.Nodes))
+	}
+
+	if w := get("/v1/events/missing/lineage"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown event, got %d", w.Code)
+	}
+	if w := get("/v1/events/dec_1/other"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown subresource, got %d", w.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/protocol"
+	"github.com/rmax-ai/ratelord/pkg/provider"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// handleObservations ingests rate-limit headers forwarded by clients (passive observation).
+// Headers are parsed by the registered provider's own parser, and the resulting
+// observations are recorded with the caller's identity and scope dimensions.
+func (s *Server) handleObservations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req protocol.ObservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid_json_body"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.ProviderID == "" || req.IdentityID == "" || req.ScopeID == "" || len(req.Headers) == 0 {
+		http.Error(w, `{"error":"missing_required_fields"}`, http.StatusBadRequest)
+		return
+	}
+
+	if s.poller == nil {
+		http.Error(w, `{"error":"poller_not_configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	prov := s.poller.GetProvider(provider.ProviderID(req.ProviderID))
+	if prov == nil {
+		http.Error(w, `{"error":"provider_not_found"}`, http.StatusNotFound)
+		return
+	}
+
+	parser, ok := prov.(provider.HeaderParser)
+	if !ok {
+		http.Error(w, `{"error":"provider_does_not_support_observations"}`, http.StatusBadRequest)
+		return
+	}
+
+	header := make(http.Header, len(req.Headers))
+	for k, v := range req.Headers {
+		header.Set(k, v)
+	}
+
+	now := time.Now().UTC()
+	observedAt := req.ObservedAt
+	if observedAt.IsZero() {
+		observedAt = now
+	}
+
+	agentID := req.AgentID
+	if agentID == "" {
+		agentID = req.IdentityID
+	}
+	workloadID := req.WorkloadID
+	if workloadID == "" {
+		workloadID = store.SentinelUnknown
+	}
+
+	source := store.EventSource{
+		OriginKind: "client",
+		OriginID:   "observation",
+		WriterID:   "ratelord-d",
+	}
+	dims := store.EventDimensions{
+		AgentID:    agentID,
+		IdentityID: req.IdentityID,
+		WorkloadID: workloadID,
+		ScopeID:    req.ScopeID,
+	}
+	correlationID := fmt.Sprintf("obs_%s_%d", req.ProviderID, now.UnixNano())
+
+	observations := parser.ParseHeaders(header, observedAt)
+	resp := protocol.ObservationResponse{EventIDs: []string{}}
+
+	for i, obs := range observations {
+		costPerUnit, units := s.poller.CostAndUnit(req.ProviderID, obs.PoolID)
+		payloadMap := map[string]interface{}{
+			"provider_id": req.ProviderID,
+			"pool_id":     obs.PoolID,
+			"units":       units,
+			"remaining":   obs.Remaining,
+			"used":        obs.Used,
+			"limit":       obs.Limit,
+		}
+		if costPerUnit > 0 {
+			payloadMap["cost"] = obs.Used * costPerUnit
+		}
+		if !obs.ResetAt.IsZero() {
+			payloadMap["reset_at"] = obs.ResetAt
+		}
+		usagePayload, _ := json.Marshal(payloadMap)
+		usageEvent := store.Event{
+			EventID:       store.EventID(fmt.Sprintf("obs_usage_%s_%s_%d_%d", req.ProviderID, obs.PoolID, now.UnixNano(), i)),
+			EventType:     store.EventTypeUsageObserved,
+			SchemaVersion: 1,
+			TsEvent:       observedAt,
+			TsIngest:      now,
+			Epoch:         s.getEpoch(),
+			Source:        source,
+			Dimensions:    dims,
+			Correlation: store.EventCorrelation{
+				CorrelationID: correlationID,
+				CausationID:   store.SentinelUnknown,
+			},
+			Payload: usagePayload,
+		}
+
+		if err := s.store.AppendEvent(r.Context(), &usageEvent); err != nil {
+			if s.fenced(r, err) {
+				writeLeadershipLost(w)
+				return
+			}
+			fmt.Printf(`{"level":"error","msg":"failed_to_append_observation_event","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+			http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+			return
+		}
+		s.usage.Apply(usageEvent)
+		resp.EventIDs = append(resp.EventIDs, string(usageEvent.EventID))
+		resp.Accepted++
+
+		if obs.ResetAt.IsZero() {
+			continue
+		}
+
+		resetPayload, _ := json.Marshal(map[string]interface{}{
+			"provider_id": req.ProviderID,
+			"pool_id":     obs.PoolID,
+			"reset_at":    obs.ResetAt,
+		})
+		resetEvent := store.Event{
+			EventID:       store.EventID(fmt.Sprintf("obs_reset_%s_%s_%d_%d", req.ProviderID, obs.PoolID, now.UnixNano(), i)),
+			EventType:     store.EventTypeResetObserved,
+			SchemaVersion: 1,
+			TsEvent:       observedAt,
+			TsIngest:      now,
+			Epoch:         s.getEpoch(),
+			Source:        source,
+			Dimensions:    dims,
+			Correlation: store.EventCorrelation{
+				CorrelationID: correlationID,
+				CausationID:   string(usageEvent.EventID),
+			},
+			Payload: resetPayload,
+		}
+
+		if err := s.store.AppendEvent(r.Context(), &resetEvent); err != nil {
+			if s.fenced(r, err) {
+				writeLeadershipLost(w)
+				return
+			}
+			fmt.Printf(`{"level":"error","msg":"failed_to_append_observation_event","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+			http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+			return
+		}
+		s.usage.Apply(resetEvent)
+		resp.EventIDs = append(resp.EventIDs, string(resetEvent.EventID))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_response","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
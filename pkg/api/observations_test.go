@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/engine"
+	"github.com/rmax-ai/ratelord/pkg/protocol"
+	"github.com/rmax-ai/ratelord/pkg/provider"
+	"github.com/rmax-ai/ratelord/pkg/provider/openai"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func TestHandleObservations(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	s.poller = engine.NewPoller(nil, time.Second, nil, nil)
+	s.poller.Register(openai.NewOpenAIProvider("openai-main", "", "", ""))
+
+	reqBody := protocol.ObservationRequest{
+		ProviderID: "openai-main",
+		AgentID:    "agent-1",
+		IdentityID: "identity-1",
+		WorkloadID: "summarize",
+		ScopeID:    "repo:acme/app",
+		Headers: map[string]string{
+			"x-ratelimit-limit-tokens":     "10000",
+			"x-ratelimit-remaining-tokens": "9000",
+			"x-ratelimit-reset-tokens":     "6m0s",
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/v1/observations", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	s.handleObservations(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp protocol.ObservationResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Accepted != 1 {
+		t.Errorf("Expected 1 accepted observation, got %d", resp.Accepted)
+	}
+	if len(resp.EventIDs) != 2 {
+		t.Errorf("Expected usage and reset events, got %v", resp.EventIDs)
+	}
+
+	// Usage projection is updated
+	state, ok := s.usage.GetPoolState("openai-main", "openai:tokens")
+	if !ok {
+		t.Fatal("Expected pool state for openai:tokens")
+	}
+	if state.Remaining != 9000 || state.Used != 1000 {
+		t.Errorf("Unexpected pool state: %+v", state)
+	}
+
+	// Events carry the caller's dimensions instead of sentinel:global
+	events, err := s.store.QueryEvents(context.Background(), store.EventFilter{IdentityID: "identity-1"})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events for identity-1, got %d", len(events))
+	}
+	for _, evt := range events {
+		if evt.Dimensions.ScopeID != "repo:acme/app" {
+			t.Errorf("Expected scope repo:acme/app, got %s", evt.Dimensions.ScopeID)
+		}
+		if evt.Source.OriginKind != "client" {
+			t.Errorf("Expected origin_kind client, got %s", evt.Source.OriginKind)
+		}
+	}
+}
+
+func TestHandleObservations_Errors(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	valid := protocol.ObservationRequest{
+		ProviderID: "openai-main",
+		IdentityID: "identity-1",
+		ScopeID:    "global",
+		Headers:    map[string]string{"x-ratelimit-limit-requests": "10"},
+	}
+
+	post := func(req protocol.ObservationRequest) int {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		s.handleObservations(w, httptest.NewRequest("POST", "/v1/observations", bytes.NewReader(body)))
+		return w.Code
+	}
+
+	// Poller not configured
+	if code := post(valid); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without poller, got %d", code)
+	}
+
+	s.poller = engine.NewPoller(nil, time.Second, nil, nil)
+	s.poller.Register(provider.NewMockProvider("mock-provider-1"))
+
+	// Unknown provider
+	if code := post(valid); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown provider, got %d", code)
+	}
+
+	// Provider without header parser
+	noParser := valid
+	noParser.ProviderID = "mock-provider-1"
+	if code := post(noParser); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for provider without parser, got %d", code)
+	}
+
+	// Missing fields
+	missing := valid
+	missing.Headers = nil
+	if code := post(missing); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing headers, got %d", code)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rmax-ai/ratelord/pkg/engine"
+)
+
+// SetProjections exposes the projection registry to the admin endpoints.
+func (s *Server) SetProjections(r *engine.ProjectionRegistry) {
+	s.projections = r
+}
+
+// handleProjections lists the projections with their checkpoints and the
+// outcome of their last rebuild.
+func (s *Server) handleProjections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.projections == nil {
+		http.Error(w, `{"error":"projections_not_configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+	resp := map[string]interface{}{"projections": s.projections.Status()}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_response","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
+
+// handleProjectionRebuild serves POST /v1/admin/projections/{name}/rebuild.
+// Rebuilds can outlast a request, so the endpoint starts one and answers 202;
+// GET /v1/admin/projections reports when it finishes. Every node keeps its
+// own projections, so followers accept rebuilds too.
+func (s *Server) handleProjectionRebuild(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/v1/admin/projections/"), "/rebuild")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.Error(w, `{"error":"not_found"}`, http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.projections == nil {
+		http.Error(w, `{"error":"projections_not_configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Replay from the store the server reads, which includes archived events
+	// when archiving is enabled
+	err := s.projections.StartRebuild(s.store, name, engine.DefaultReplayBatchSize)
+	switch {
+	case errors.Is(err, engine.ErrUnknownProjection):
+		http.Error(w, fmt.Sprintf(`{"error":"unknown_projection","projection":%q}`, name), http.StatusNotFound)
+		return
+	case errors.Is(err, engine.ErrRebuildInProgress):
+		http.Error(w, fmt.Sprintf(`{"error":"rebuild_in_progress","projection":%q}`, name), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf(`{"level":"info","msg":"projection_rebuild_started","trace_id":"%s","projection":"%s"}`+"\n", getTraceID(r.Context()), name)
+	resp := map[string]interface{}{
+		"status":     "rebuilding",
+		"projection": name,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_response","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/engine"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func TestHandleProjectionRebuild(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		if err := s.store.AppendEvent(ctx, &store.Event{
+			EventID:    store.EventID(fmt.Sprintf("evt_%d", i)),
+			EventType:  store.EventTypeIdentityRegistered,
+			TsEvent:    now,
+			TsIngest:   now.Add(time.Duration(i) * time.Millisecond),
+			Dimensions: store.EventDimensions{IdentityID: fmt.Sprintf("u%d", i)},
+			Payload:    json.RawMessage(`{"kind":"user"}`),
+		}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	// Without a registry the endpoints are unavailable
+	w := httptest.NewRecorder()
+	s.handleProjections(w, httptest.NewRequest("GET", "/v1/admin/projections", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	ids := engine.NewIdentityProjection()
+	registry := engine.NewProjectionRegistry()
+	registry.Register(engine.ProjectionIdentity, ids)
+	s.SetProjections(registry)
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{"POST", "/v1/admin/projections/nope/rebuild", http.StatusNotFound},
+		{"POST", "/v1/admin/projections/identity", http.StatusNotFound},
+		{"GET", "/v1/admin/projections/identity/rebuild", http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		s.handleProjectionRebuild(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.handleProjectionRebuild(w, httptest.NewRequest("POST", "/v1/admin/projections/identity/rebuild", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The rebuild runs in the background; the listing reports its outcome
+	var status engine.ProjectionStatus
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w = httptest.NewRecorder()
+		s.handleProjections(w, httptest.NewRequest("GET", "/v1/admin/projections", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var resp struct {
+			Projections []engine.ProjectionStatus `json:"projections"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(resp.Projections) != 1 {
+			t.Fatalf("Expected one projection, got %+v", resp.Projections)
+		}
+		status = resp.Projections[0]
+		if !status.Rebuilding || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.Rebuilding || status.LastRebuild == nil || status.LastRebuild.EventsRead != 3 || status.LastRebuild.Error != "" {
+		t.Fatalf("Unexpected status %+v", status)
+	}
+	if status.Checkpoint.EventID != "evt_2" || len(ids.GetAll()) != 3 {
+		t.Errorf("Expected 3 identities up to evt_2, got %d at %s", len(ids.GetAll()), status.Checkpoint.EventID)
+	}
+}
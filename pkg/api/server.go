@@ -1,17 +1,22 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -34,6 +39,8 @@ const traceIDKey contextKey = "trace_id"
 type StoreInterface interface {
 	AppendEvent(ctx context.Context, event *store.Event) error
 	ReadRecentEvents(ctx context.Context, limit int) ([]*store.Event, error)
+	ReadEvents(ctx context.Context, since time.Time, limit int) ([]*store.Event, error)
+	GetEvent(ctx context.Context, eventID store.EventID) (*store.Event, error)
 	DeleteIdentityData(ctx context.Context, id string) error
 	PruneEvents(ctx context.Context, retention time.Duration, includeType string, excludeTypes []string) (int64, error)
 	GetUsageStats(ctx context.Context, filter store.UsageFilter) ([]store.UsageStat, error)
@@ -92,8 +99,26 @@ type Server struct {
 	// Federated Usage Tracker
 	tracker UsageTracker
 
+	// Hierarchical federation: grants outstanding per child, and the parent
+	// funding them (regional leaders only)
+	grants       *engine.GrantLedger
+	reclaimAfter time.Duration
+	upstream     GrantUpstream
+	grantMu      sync.Mutex // Serializes grants without a ledger
+
 	// High Availability
 	election ElectionManagerInterface
+	onFenced func()
+
+	// Event streaming
+	broadcaster     *store.Broadcaster
+	streamHeartbeat time.Duration
+
+	// Projection rebuilds
+	projections *engine.ProjectionRegistry
+
+	// Lineage reads the live log only
+	lineageStore EventQuerier
 }
 
 // UsageTracker defines an interface for tracking local usage
@@ -101,17 +126,27 @@ type UsageTracker interface {
 	TrackUsage(providerID, poolID string, amount int64)
 }
 
+// GrantUpstream funds the grants of a regional leader from the budget its
+// own parent granted it. Granting consumes that budget via TrackUsage.
+type GrantUpstream interface {
+	UsageTracker
+	// Ensure makes sure at least amount of the pool is held, asking the
+	// parent for the shortfall, and returns the budget held.
+	Ensure(ctx context.Context, providerID, poolID string, amount int64) (int64, error)
+	// Held returns the budget of the pool held, without asking the parent.
+	Held(providerID, poolID string) int64
+}
+
 // NewServer creates a new API server instance
-func NewServer(st *store.Store, identities *engine.IdentityProjection, usage *engine.UsageProjection, policy PolicyEngineInterface, cluster *engine.ClusterTopology, graphProj *graph.Projection, addr string) *Server {
+func NewServer(st store.EventStore, identities *engine.IdentityProjection, usage *engine.UsageProjection, policy PolicyEngineInterface, cluster *engine.ClusterTopology, graphProj *graph.Projection, addr string) *Server {
 	return NewServerWithPoller(st, identities, usage, policy, cluster, graphProj, nil, addr)
 }
 
 // NewServerWithPoller creates a new API server instance with poller access (for debug endpoints)
-func NewServerWithPoller(st *store.Store, identities *engine.IdentityProjection, usage *engine.UsageProjection, policy PolicyEngineInterface, cluster *engine.ClusterTopology, graphProj *graph.Projection, poller *engine.Poller, addr string) *Server {
+func NewServerWithPoller(st store.EventStore, identities *engine.IdentityProjection, usage *engine.UsageProjection, policy PolicyEngineInterface, cluster *engine.ClusterTopology, graphProj *graph.Projection, poller *engine.Poller, addr string) *Server {
 	mux := http.NewServeMux()
 
 	// Register routes
-	mux.HandleFunc("/v1/health", handleHealth)
 	mux.Handle("/metrics", promhttp.Handler())
 
 	s := &Server{
@@ -124,16 +159,25 @@ func NewServerWithPoller(st *store.Store, identities *engine.IdentityProjection,
 		poller:     poller,
 	}
 
+	mux.HandleFunc("/v1/health", s.handleHealth)
 	mux.HandleFunc("/v1/intent", s.withLeaderCheck(s.withAuth(s.handleIntent)))
 	mux.HandleFunc("/v1/identities", s.withLeaderCheck(s.handleIdentities)) // handleIdentities checks method inside
 	mux.HandleFunc("/v1/events", s.handleEvents)
+	mux.HandleFunc("/v1/events/stream", s.handleEventStream)
+	mux.HandleFunc("/v1/events/", s.handleEventSubresource)
 	mux.HandleFunc("/v1/trends", s.handleTrends)
 	mux.HandleFunc("/v1/reports", s.handleReports)
 	mux.HandleFunc("/v1/graph", s.handleGraph)
 	mux.HandleFunc("/v1/webhooks", s.withLeaderCheck(s.withAuth(s.handleWebhooks)))
 	mux.HandleFunc("/v1/federation/grant", s.withLeaderCheck(s.handleGrant))
+	mux.HandleFunc("/v1/federation/return", s.withLeaderCheck(s.handleReturn))
+	mux.HandleFunc("/v1/federation/grants", s.handleGrants)
+	mux.HandleFunc("/v1/observations", s.withLeaderCheck(s.handleObservations))
 	mux.HandleFunc("/v1/cluster/nodes", s.handleClusterNodes)
+	mux.HandleFunc("/v1/providers", s.handleProviders)
 	mux.HandleFunc("/v1/admin/prune", s.withLeaderCheck(s.withAuth(s.handlePrune)))
+	mux.HandleFunc("/v1/admin/projections", s.withAuth(s.handleProjections))
+	mux.HandleFunc("/v1/admin/projections/", s.withAuth(s.handleProjectionRebuild))
 	mux.HandleFunc("/v1/simulation", s.withLeaderCheck(s.handleSimulation))
 
 	// Debug endpoints
@@ -181,11 +225,51 @@ func (s *Server) SetUsageTracker(t UsageTracker) {
 	s.tracker = t
 }
 
+// SetGrantLedger hands out grants as leases lasting reclaimAfter past their
+// last renewal, sized to each child's demand and sharing the pool between
+// the children in proportion to it.
+func (s *Server) SetGrantLedger(ledger *engine.GrantLedger, reclaimAfter time.Duration) {
+	s.grants = ledger
+	s.reclaimAfter = reclaimAfter
+}
+
+// SetGrantUpstream makes this node a regional leader, granting to its
+// children from the budget granted by its parent.
+func (s *Server) SetGrantUpstream(u GrantUpstream) {
+	s.upstream = u
+}
+
 // SetElectionManager sets the election manager for HA routing
 func (s *Server) SetElectionManager(em ElectionManagerInterface) {
 	s.election = em
 }
 
+// SetOnFenced sets the function called when the store rejects a write
+// because a newer leader took over, typically to step down.
+func (s *Server) SetOnFenced(f func()) {
+	s.onFenced = f
+}
+
+// fenced reports whether err rejects a write as coming from a deposed
+// leader, stepping down if so.
+func (s *Server) fenced(r *http.Request, err error) bool {
+	if !errors.Is(err, store.ErrStaleEpoch) {
+		return false
+	}
+	fmt.Printf(`{"level":"warn","msg":"write_fenced","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	if s.onFenced != nil {
+		s.onFenced()
+	}
+	return true
+}
+
+// writeLeadershipLost responds to a request whose write was fenced. The
+// client retries, and is then redirected to the new leader.
+func writeLeadershipLost(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, `{"error":"service_unavailable","reason":"leadership_lost"}`, http.StatusServiceUnavailable)
+}
+
 // getEpoch returns the current leadership epoch.
 func (s *Server) getEpoch() int64 {
 	if s.election != nil {
@@ -276,9 +360,18 @@ func (s *Server) handleIntent(w http.ResponseWriter, r *http.Request) {
 	// Update metrics
 	engine.RatelordIntentTotal.WithLabelValues(intent.IdentityID, string(result.Decision)).Inc()
 
+	// The decision rests on the latest observation of the pool, so it is
+	// recorded as the cause; lineage then leads back to the provider poll.
+	causationID := store.SentinelUnknown
+	if poolState, ok := s.usage.GetPoolState(intent.ProviderID, intent.PoolID); ok && poolState.LastEventID != "" {
+		causationID = poolState.LastEventID
+	}
+
 	// Persist the decision
 	// Create payload
 	decPayload, _ := json.Marshal(map[string]interface{}{
+		"provider_id":   intent.ProviderID,
+		"pool_id":       intent.PoolID,
 		"decision":      result.Decision,
 		"reason":        result.Reason,
 		"modifications": result.Modifications,
@@ -307,13 +400,24 @@ func (s *Server) handleIntent(w http.ResponseWriter, r *http.Request) {
 		},
 		Correlation: store.EventCorrelation{
 			CorrelationID: fmt.Sprintf("intent_%s", intent.IntentID),
-			CausationID:   store.SentinelUnknown,
+			CausationID:   causationID,
 		},
 		Payload: decPayload,
 	}
 
 	if err := s.store.AppendEvent(r.Context(), &decEvent); err != nil {
 		fmt.Printf(`{"level":"error","msg":"failed_to_append_decision_event","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+		// The event log is saturated; shed load rather than decide unrecorded.
+		if errors.Is(err, store.ErrQueueFull) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"error":"overloaded"}`, http.StatusServiceUnavailable)
+			return
+		}
+		// Nor decide for a newer leader, which may have decided otherwise.
+		if s.fenced(r, err) {
+			writeLeadershipLost(w)
+			return
+		}
 	}
 
 	// Convert trace to []interface{} for JSON serialization
@@ -351,15 +455,24 @@ func (s *Server) handleIntent(w http.ResponseWriter, r *http.Request) {
 
 		poolState, exists := s.usage.GetPoolState(intent.ProviderID, intent.PoolID)
 		if exists {
-			newUsed := poolState.Used + 1
-			newRemaining := poolState.Remaining - 1
+			newUsed := poolState.Used + intent.ExpectedCost
+			newRemaining := poolState.Remaining - intent.ExpectedCost
 			now := time.Now()
-			payload, _ := json.Marshal(map[string]interface{}{
+			// "delta" marks usage recorded per intent, as opposed to the
+			// absolute counters observed from providers
+			usagePayload := map[string]interface{}{
 				"provider_id": intent.ProviderID,
 				"pool_id":     intent.PoolID,
 				"used":        newUsed,
 				"remaining":   newRemaining,
-			})
+				"delta":       intent.ExpectedCost,
+			}
+			if s.poller != nil {
+				if costPerUnit, _ := s.poller.CostAndUnit(intent.ProviderID, intent.PoolID); costPerUnit > 0 {
+					usagePayload["cost"] = intent.ExpectedCost * costPerUnit
+				}
+			}
+			payload, _ := json.Marshal(usagePayload)
 			evt := store.Event{
 				EventID:       store.EventID(fmt.Sprintf("usage_intent_%d", now.UnixNano())),
 				EventType:     store.EventTypeUsageObserved,
@@ -373,19 +486,21 @@ func (s *Server) handleIntent(w http.ResponseWriter, r *http.Request) {
 					WriterID:   "ratelord-d",
 				},
 				Dimensions: store.EventDimensions{
-					AgentID:    store.SentinelSystem,
-					IdentityID: store.SentinelGlobal,
-					WorkloadID: store.SentinelSystem,
-					ScopeID:    store.SentinelGlobal,
+					AgentID:    intent.IdentityID,
+					IdentityID: intent.IdentityID,
+					WorkloadID: intent.WorkloadID,
+					ScopeID:    intent.ScopeID,
 				},
 				Correlation: store.EventCorrelation{
 					CorrelationID: fmt.Sprintf("intent_%s", intent.IntentID),
-					CausationID:   store.SentinelUnknown,
+					CausationID:   string(decEvent.EventID),
 				},
 				Payload: payload,
 			}
 			if err := s.store.AppendEvent(r.Context(), &evt); err != nil {
-				fmt.Printf(`{"level":"error","msg":"failed_to_append_usage_event","error":"%v"}`+"\n", err)
+				if !s.fenced(r, err) {
+					fmt.Printf(`{"level":"error","msg":"failed_to_append_usage_event","error":"%v"}`+"\n", err)
+				}
 			} else {
 				s.usage.Apply(evt)
 			}
@@ -438,6 +553,10 @@ func (s *Server) handleIdentities(w http.ResponseWriter, r *http.Request) {
 			Payload: payload,
 		}
 		if err := s.store.AppendEvent(r.Context(), &evt); err != nil {
+			if s.fenced(r, err) {
+				writeLeadershipLost(w)
+				return
+			}
 			fmt.Printf(`{"level":"error","msg":"failed_to_append_delete_event","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
 			http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
 			return
@@ -531,6 +650,10 @@ func (s *Server) handleIdentities(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.store.AppendEvent(r.Context(), &evt); err != nil {
+		if s.fenced(r, err) {
+			writeLeadershipLost(w)
+			return
+		}
 		fmt.Printf(`{"level":"error","msg":"failed_to_append_identity_event","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
 		http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
 		return
@@ -598,28 +721,41 @@ func (s *Server) handlePrune(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleEvents returns recent events for diagnostics.
+// maxEventsPageSize caps the limit accepted by /v1/events.
+const maxEventsPageSize = 1000
+
+// handleEvents returns a page of events, newest first by default.
+// When more events match, the X-Next-Cursor header carries the opaque cursor
+// for the following page.
 func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Parse limit query param
-	limit := 50
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if val, err := strconv.Atoi(l); err == nil && val > 0 {
-			limit = val
-		}
+	filter, errCode := parseEventFilter(r.URL.Query())
+	if errCode != "" {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, errCode), http.StatusBadRequest)
+		return
 	}
 
-	// Query store
-	events, err := s.store.ReadRecentEvents(r.Context(), limit)
+	// Fetch one extra event to learn whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	events, err := s.store.QueryEvents(r.Context(), filter)
 	if err != nil {
 		fmt.Printf(`{"level":"error","msg":"failed_to_read_events","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
 		http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
 		return
 	}
+	if events == nil {
+		events = []*store.Event{}
+	}
+	if len(events) > limit {
+		events = events[:limit]
+		w.Header().Set("X-Next-Cursor", store.CursorAt(events[limit-1]).Encode())
+	}
 
 	// Return JSON
 	w.Header().Set("Content-Type", "application/json")
@@ -629,6 +765,93 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseEventFilter reads the /v1/events query parameters. On failure it
+// returns the error code to report.
+func parseEventFilter(q url.Values) (store.EventFilter, string) {
+	filter := store.EventFilter{
+		EventTypes:    parseEventTypes(q),
+		IdentityID:    q.Get("identity_id"),
+		ScopeID:       q.Get("scope_id"),
+		ProviderID:    q.Get("provider_id"),
+		PoolID:        q.Get("pool_id"),
+		CorrelationID: q.Get("correlation_id"),
+		CausationID:   q.Get("causation_id"),
+		Descending:    true,
+		Limit:         50,
+	}
+
+	if l := q.Get("limit"); l != "" {
+		val, err := strconv.Atoi(l)
+		if err != nil || val <= 0 {
+			return filter, "invalid_limit"
+		}
+		filter.Limit = min(val, maxEventsPageSize)
+	}
+
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		return filter, "invalid_order"
+	}
+
+	if c := q.Get("cursor"); c != "" {
+		cursor, err := store.DecodeEventCursor(c)
+		if err != nil {
+			return filter, "invalid_cursor"
+		}
+		filter.After = cursor
+	}
+
+	if a := q.Get("archived"); a != "" {
+		include, err := strconv.ParseBool(a)
+		if err != nil {
+			return filter, "invalid_archived"
+		}
+		filter.IncludeArchived = include
+	}
+
+	if e := q.Get("epoch"); e != "" {
+		epoch, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return filter, "invalid_epoch"
+		}
+		filter.Epoch = &epoch
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		if v := q.Get(bound.name); v != "" {
+			ts, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, "invalid_" + bound.name
+			}
+			*bound.dst = ts
+		}
+	}
+
+	return filter, ""
+}
+
+// parseEventTypes reads event_type, which may be repeated or comma-separated.
+func parseEventTypes(q url.Values) []store.EventType {
+	var types []store.EventType
+	for _, v := range q["event_type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, store.EventType(t))
+			}
+		}
+	}
+	return types
+}
+
 // handleTrends returns aggregated usage statistics.
 func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -643,8 +866,8 @@ func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
 	if bucket == "" {
 		bucket = "hour" // default
 	}
-	if bucket != "hour" && bucket != "day" {
-		http.Error(w, `{"error":"invalid_bucket","valid":["hour","day"]}`, http.StatusBadRequest)
+	if _, ok := store.BucketSize(bucket); !ok {
+		http.Error(w, `{"error":"invalid_bucket","valid":["minute","hour","day"]}`, http.StatusBadRequest)
 		return
 	}
 
@@ -775,8 +998,17 @@ func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
 		params.Filters["bucket"] = bucket
 	}
 
+	format := reports.ReportFormat(q.Get("format"))
+	if format == "" {
+		format = reports.ReportFormatCSV
+	}
+	if format != reports.ReportFormatCSV && format != reports.ReportFormatParquet {
+		http.Error(w, `{"error":"invalid_format","supported":["csv","parquet"]}`, http.StatusBadRequest)
+		return
+	}
+
 	// Create generator
-	gen, err := reports.NewReportGenerator(reportType, s.store)
+	gen, err := reports.NewFormattedReportGenerator(reportType, format, s.store)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"invalid_report_type","details":"%v"}`, err), http.StatusBadRequest)
 		return
@@ -791,8 +1023,12 @@ func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set headers
-	w.Header().Set("Content-Type", "text/csv")
-	filename := fmt.Sprintf("report_%s_%d.csv", reportType, time.Now().Unix())
+	contentType := "text/csv"
+	if format == reports.ReportFormatParquet {
+		contentType = "application/vnd.apache.parquet"
+	}
+	w.Header().Set("Content-Type", contentType)
+	filename := fmt.Sprintf("report_%s_%d.%s", reportType, time.Now().Unix(), format)
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 
 	// Stream response
@@ -801,6 +1037,25 @@ func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleProviders returns per-provider poll scheduling and circuit-breaker state.
+func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.poller == nil {
+		http.Error(w, `{"error":"poller_not_configured"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(s.poller.Status()); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_providers","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
+
 // handleDebugInject allows manual injection of usage into a provider (for drift testing)
 func (s *Server) handleDebugInject(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -907,6 +1162,36 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
+// HealthResponse is the body of GET /v1/health when providers are polled.
+type HealthResponse struct {
+	Status    string                  `json:"status"` // "ok" or "degraded"
+	Providers []engine.ProviderHealth `json:"providers"`
+}
+
+// handleHealth adds per-provider health to the basic status.
+// It always answers 200 so that liveness probes do not restart the daemon
+// because an upstream provider is unhealthy.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.poller == nil || r.Method != http.MethodGet {
+		handleHealth(w, r)
+		return
+	}
+
+	resp := HealthResponse{Status: "ok", Providers: s.poller.Health(time.Now())}
+	for _, p := range resp.Providers {
+		if p.Status != engine.HealthHealthy {
+			resp.Status = "degraded"
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		fmt.Printf(`{"level":"error","msg":"failed_to_encode_response","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+	}
+}
+
 // Middleware: Auth
 func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -1017,6 +1302,28 @@ func (w *statusWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+// Flush supports streaming responses (SSE).
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports WebSocket upgrades.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // Middleware: Secure Headers
 func withSecureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
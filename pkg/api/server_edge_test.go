@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
@@ -73,6 +74,80 @@ func TestHandleIntent_StoreError(t *testing.T) {
 	}
 }
 
+// MockStoreFull simulates a saturated append queue
+type MockStoreFull struct {
+	MockStore
+}
+
+func (m *MockStoreFull) AppendEvent(ctx context.Context, event *store.Event) error {
+	return fmt.Errorf("append: %w", store.ErrQueueFull)
+}
+
+func TestHandleIntent_QueueFull(t *testing.T) {
+	server := createServerWithMocks(&MockStoreFull{}, &MockIdentityProjection{}, &MockUsageProjection{}, &MockPolicyEngine{}, &MockGraph{}, nil)
+
+	body, _ := json.Marshal(protocol.IntentRequest{
+		AgentID:    "agent1",
+		IdentityID: "identity1",
+		ScopeID:    "scope1",
+		WorkloadID: "workload1",
+	})
+	req := httptest.NewRequest("POST", "/v1/intent", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	server.handleIntent(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when the event log is saturated, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
+
+// MockStoreFenced simulates a store that rejects the writes of a deposed leader
+type MockStoreFenced struct {
+	MockStore
+}
+
+func (m *MockStoreFenced) AppendEvent(ctx context.Context, event *store.Event) error {
+	return fmt.Errorf("%w: event %s has epoch 1, lease epoch is 2", store.ErrStaleEpoch, event.EventID)
+}
+
+func TestHandleIntent_Fenced(t *testing.T) {
+	server := createServerWithMocks(&MockStoreFenced{}, &MockIdentityProjection{}, &MockUsageProjection{}, &MockPolicyEngine{}, &MockGraph{}, &MockElectionManager{})
+	steppedDown := 0
+	server.SetOnFenced(func() { steppedDown++ })
+
+	body, _ := json.Marshal(protocol.IntentRequest{
+		AgentID:    "agent1",
+		IdentityID: "identity1",
+		ScopeID:    "scope1",
+		WorkloadID: "workload1",
+	})
+	req := httptest.NewRequest("POST", "/v1/intent", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	server.handleIntent(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when the write is fenced, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "leadership_lost") {
+		t.Errorf("Expected reason leadership_lost, got %s", w.Body.String())
+	}
+	if steppedDown != 1 {
+		t.Errorf("Expected the server to step down once, got %d", steppedDown)
+	}
+
+	// Other writes are refused too, rather than reported as internal errors
+	body, _ = json.Marshal(protocol.IdentityRegistration{IdentityID: "identity1", Kind: "agent"})
+	req = httptest.NewRequest("POST", "/v1/identities", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	server.handleIdentities(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 for a fenced identity registration, got %d", w.Code)
+	}
+}
+
 func TestHandleTrends_Validation(t *testing.T) {
 	server := &Server{}
 
@@ -178,6 +253,33 @@ func TestHandleReports_Filters(t *testing.T) {
 	}
 }
 
+func TestHandleReports_Parquet(t *testing.T) {
+	server := &Server{store: &MockStore{}}
+
+	req := httptest.NewRequest("GET", "/v1/reports?type=events&format=parquet", nil)
+	w := httptest.NewRecorder()
+	server.handleReports(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/vnd.apache.parquet" {
+		t.Errorf("Expected parquet content type, got %s", ct)
+	}
+	if !strings.HasSuffix(w.Header().Get("Content-Disposition"), ".parquet") {
+		t.Errorf("Expected a .parquet filename, got %s", w.Header().Get("Content-Disposition"))
+	}
+	if body := w.Body.Bytes(); len(body) < 8 || string(body[:4]) != "PAR1" {
+		t.Errorf("Expected a parquet file, got %q", body)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/reports?type=events&format=xml", nil)
+	w = httptest.NewRecorder()
+	server.handleReports(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unsupported format, got %d", w.Code)
+	}
+}
+
 // MockProvider for Injection
 type MockProvider struct {
 	id       provider.ProviderID
@@ -560,3 +662,60 @@ func TestHandleIntent_UsageEventError(t *testing.T) {
 		t.Errorf("Expected 200, got %d", w.Code)
 	}
 }
+
+func TestHandleProviders(t *testing.T) {
+	// Poller not configured
+	server := &Server{}
+	w := httptest.NewRecorder()
+	server.handleProviders(w, httptest.NewRequest("GET", "/v1/providers", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without poller, got %d", w.Code)
+	}
+
+	poller := engine.NewPoller(nil, time.Minute, nil, nil)
+	poller.Register(&MockProvider{id: "p1"})
+	server = &Server{poller: poller}
+
+	w = httptest.NewRecorder()
+	server.handleProviders(w, httptest.NewRequest("GET", "/v1/providers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var statuses []engine.ProviderPollStatus
+	if err := json.NewDecoder(w.Body).Decode(&statuses); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].ProviderID != "p1" {
+		t.Fatalf("Expected status for p1, got %+v", statuses)
+	}
+	if statuses[0].CircuitState != engine.CircuitClosed {
+		t.Errorf("Expected closed circuit, got %s", statuses[0].CircuitState)
+	}
+	if statuses[0].BaseInterval != "1m0s" {
+		t.Errorf("Expected base interval 1m0s, got %s", statuses[0].BaseInterval)
+	}
+}
+
+func TestHandleHealth_Providers(t *testing.T) {
+	poller := engine.NewPoller(nil, time.Minute, nil, nil)
+	poller.Register(&MockProvider{id: "p1"})
+	server := &Server{poller: poller}
+
+	w := httptest.NewRecorder()
+	server.handleHealth(w, httptest.NewRequest("GET", "/v1/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var resp HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Errorf("Expected status ok, got %s", resp.Status)
+	}
+	if len(resp.Providers) != 1 || resp.Providers[0].ProviderID != "p1" || resp.Providers[0].Status != engine.HealthHealthy {
+		t.Errorf("Unexpected provider health: %+v", resp.Providers)
+	}
+}
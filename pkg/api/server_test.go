@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -906,6 +907,83 @@ func TestHandleEvents(t *testing.T) {
 	}
 }
 
+func TestHandleEvents_CursorPagination(t *testing.T) {
+	s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		evt := &store.Event{
+			EventID:       store.EventID(fmt.Sprintf("evt_%d", i)),
+			EventType:     store.EventTypeUsageObserved,
+			SchemaVersion: 1,
+			TsEvent:       base.Add(time.Duration(i) * time.Second),
+			TsIngest:      base.Add(time.Duration(i) * time.Second),
+			Dimensions:    store.EventDimensions{AgentID: "a", IdentityID: "id", WorkloadID: "w", ScopeID: "s"},
+			Payload:       json.RawMessage(`{"provider_id":"github-main","pool_id":"github:core"}`),
+		}
+		if i == 2 {
+			evt.EventType = store.EventTypeProviderError
+		}
+		if err := s.store.AppendEvent(context.Background(), evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	get := func(query string) ([]store.Event, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.handleEvents(w, httptest.NewRequest("GET", "/v1/events?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var events []store.Event
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		return events, w.Header().Get("X-Next-Cursor")
+	}
+
+	// Ascending, two per page, filtered to usage events of one pool
+	var got []store.EventID
+	query := "order=asc&limit=2&event_type=usage_observed&pool_id=github:core"
+	for page := 0; page < 5; page++ {
+		events, next := get(query)
+		for _, e := range events {
+			got = append(got, e.EventID)
+		}
+		if next == "" {
+			break
+		}
+		query = "order=asc&limit=2&event_type=usage_observed&pool_id=github:core&cursor=" + next
+	}
+	want := []store.EventID{"evt_0", "evt_1", "evt_3", "evt_4"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ascending pages: got %v, want %v", got, want)
+	}
+
+	// Default order is newest first
+	events, next := get("limit=1")
+	if len(events) != 1 || events[0].EventID != "evt_4" || next == "" {
+		t.Errorf("expected newest event with a next cursor, got %v (cursor %q)", events, next)
+	}
+	events, _ = get("limit=1&cursor=" + next)
+	if len(events) != 1 || events[0].EventID != "evt_3" {
+		t.Errorf("expected evt_3 on the second page, got %v", events)
+	}
+}
+
+func TestHandleEvents_InvalidParams(t *testing.T) {
+	s := &Server{store: &MockStore{}}
+	for _, query := range []string{"cursor=not-a-cursor", "order=sideways", "limit=-1", "epoch=x", "from=yesterday", "archived=maybe"} {
+		w := httptest.NewRecorder()
+		s.handleEvents(w, httptest.NewRequest("GET", "/v1/events?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
 func TestHandleIdentities_List(t *testing.T) {
 	mockIdentities := &MockIdentityProjection{
 		identities: []engine.Identity{
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+const (
+	// DefaultStreamHeartbeat is the interval of SSE comments and WebSocket pings
+	// that keep idle streams open through proxies.
+	DefaultStreamHeartbeat = 15 * time.Second
+
+	// streamReplayBatch is the page size used when resuming from Last-Event-ID.
+	streamReplayBatch = 500
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// streamFilter selects events for a subscriber. Empty fields match everything.
+type streamFilter struct {
+	types      map[store.EventType]bool
+	identityID string
+	scopeID    string
+}
+
+// parseStreamFilter reads event_type (repeatable or comma-separated),
+// identity_id and scope_id.
+func parseStreamFilter(q url.Values) streamFilter {
+	f := streamFilter{identityID: q.Get("identity_id"), scopeID: q.Get("scope_id")}
+	for _, t := range parseEventTypes(q) {
+		if f.types == nil {
+			f.types = make(map[store.EventType]bool)
+		}
+		f.types[t] = true
+	}
+	return f
+}
+
+func (f streamFilter) match(evt *store.Event) bool {
+	if f.types != nil && !f.types[evt.EventType] {
+		return false
+	}
+	if f.identityID != "" && evt.Dimensions.IdentityID != f.identityID {
+		return false
+	}
+	if f.scopeID != "" && evt.Dimensions.ScopeID != f.scopeID {
+		return false
+	}
+	return true
+}
+
+// SetBroadcaster enables /v1/events/stream, fed by the given broadcaster.
+func (s *Server) SetBroadcaster(b *store.Broadcaster) {
+	s.broadcaster = b
+}
+
+// handleEventStream pushes events as they are appended. Clients get
+// Server-Sent Events by default, or a WebSocket when they request an upgrade.
+//
+// Passing Last-Event-ID (header, or last_event_id query parameter for
+// WebSocket clients) first replays the events ingested after that event.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method_not_allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if s.broadcaster == nil {
+		http.Error(w, `{"error":"stream_unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	filter := parseStreamFilter(r.URL.Query())
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	// Subscribe before replaying so nothing appended in between is lost.
+	sub := s.broadcaster.Subscribe(0)
+	defer sub.Close()
+
+	var cursor *store.Event
+	if lastID != "" {
+		evt, err := s.store.GetEvent(r.Context(), store.EventID(lastID))
+		if err != nil {
+			fmt.Printf(`{"level":"error","msg":"failed_to_read_stream_cursor","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+			http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+			return
+		}
+		if evt == nil {
+			// Pruned or archived; the client must start over without a cursor.
+			http.Error(w, `{"error":"cursor_not_found"}`, http.StatusGone)
+			return
+		}
+		cursor = evt
+	}
+
+	heartbeat := s.streamHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultStreamHeartbeat
+	}
+
+	// Streams outlive the server's WriteTimeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveEventWebSocket(w, r, sub, cursor, filter, heartbeat)
+	} else {
+		s.serveEventSSE(w, r, sub, cursor, filter, heartbeat)
+	}
+}
+
+// replay sends stored events ingested after cursor and returns their IDs,
+// so the same events arriving on the subscription can be skipped.
+func (s *Server) replay(ctx context.Context, cursor *store.Event, filter streamFilter, send func(*store.Event) error) (map[store.EventID]bool, error) {
+	sent := make(map[store.EventID]bool)
+	if cursor == nil {
+		return sent, nil
+	}
+
+	since := cursor.TsIngest
+	for {
+		events, err := s.store.ReadEvents(ctx, since, streamReplayBatch)
+		if err != nil {
+			return sent, err
+		}
+		for _, evt := range events {
+			sent[evt.EventID] = true
+			if filter.match(evt) {
+				if err := send(evt); err != nil {
+					return sent, err
+				}
+			}
+		}
+		if len(events) < streamReplayBatch {
+			return sent, nil
+		}
+		since = events[len(events)-1].TsIngest
+	}
+}
+
+func (s *Server) serveEventSSE(w http.ResponseWriter, r *http.Request, sub *store.Subscription, cursor *store.Event, filter streamFilter, heartbeat time.Duration) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	send := func(evt *store.Event) error {
+		data, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", evt.EventID, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	replayed, err := s.replay(r.Context(), cursor, filter, send)
+	if err != nil {
+		fmt.Printf(`{"level":"error","msg":"stream_replay_failed","trace_id":"%s","error":"%v"}`+"\n", getTraceID(r.Context()), err)
+		return
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprintf(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case evt, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					// Tell the client to reconnect; Last-Event-ID resumes the gap.
+					fmt.Fprintf(w, "event: lagged\ndata: {}\n\n")
+					rc.Flush()
+				}
+				return
+			}
+			if replayed[evt.EventID] || !filter.match(evt) {
+				continue
+			}
+			if err := send(evt); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) serveEventWebSocket(w http.ResponseWriter, r *http.Request, sub *store.Subscription, cursor *store.Event, filter streamFilter, heartbeat time.Duration) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	defer conn.Close()
+
+	// The stream is one-way; reading only detects the client going away.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(evt *store.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(heartbeat))
+		return conn.WriteJSON(evt)
+	}
+
+	replayed, err := s.replay(r.Context(), cursor, filter, send)
+	if err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(heartbeat)); err != nil {
+				return
+			}
+		case evt, ok := <-sub.C:
+			if !ok {
+				reason := "server shutting down"
+				code := websocket.CloseGoingAway
+				if sub.Lagged() {
+					reason, code = "lagged", websocket.CloseTryAgainLater
+				}
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+				return
+			}
+			if replayed[evt.EventID] || !filter.match(evt) {
+				continue
+			}
+			if err := send(evt); err != nil {
+				return
+			}
+		}
+	}
+}
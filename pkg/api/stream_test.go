@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func setupStreamServer(t *testing.T) (*Server, *store.BroadcastStore, *httptest.Server) {
+	t.Helper()
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	b := store.NewBroadcaster()
+	bs := store.NewBroadcastStore(st, b)
+
+	s := &Server{store: bs}
+	s.SetBroadcaster(b)
+
+	// Go through the logging middleware to exercise Flush/Hijack passthrough
+	ts := httptest.NewServer(withLogging(http.HandlerFunc(s.handleEventStream)))
+	t.Cleanup(func() {
+		b.Close()
+		ts.Close()
+		st.Close()
+	})
+	return s, bs, ts
+}
+
+func streamEvent(id string, eventType store.EventType, identity string, offset time.Duration) *store.Event {
+	ts := time.Now().UTC().Add(offset)
+	return &store.Event{
+		EventID:       store.EventID(id),
+		EventType:     eventType,
+		SchemaVersion: 1,
+		TsEvent:       ts,
+		TsIngest:      ts,
+		Dimensions:    store.EventDimensions{AgentID: "agent", IdentityID: identity, WorkloadID: "wl", ScopeID: "scope"},
+		Payload:       json.RawMessage(`{}`),
+	}
+}
+
+// sseClient reads Server-Sent Events from a response body.
+type sseClient struct {
+	t    *testing.T
+	resp *http.Response
+	r    *bufio.Reader
+}
+
+func openSSE(t *testing.T, url string, header http.Header) *sseClient {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	c := &sseClient{t: t, resp: resp, r: bufio.NewReader(resp.Body)}
+	// The retry hint is written after subscribing, so events appended from
+	// here on are delivered.
+	if line := c.line(); line != "retry: 3000" {
+		t.Fatalf("expected retry hint, got %q", line)
+	}
+	return c
+}
+
+func (c *sseClient) line() string {
+	c.t.Helper()
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		l, err := c.r.ReadString('\n')
+		ch <- result{strings.TrimRight(l, "\n"), err}
+	}()
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			c.t.Fatalf("read failed: %v", res.err)
+		}
+		return res.line
+	case <-time.After(3 * time.Second):
+		c.t.Fatal("timed out waiting for stream data")
+		return ""
+	}
+}
+
+// next returns the ID and decoded data of the next event, skipping comments.
+func (c *sseClient) next() (string, store.Event) {
+	c.t.Helper()
+	var id string
+	for {
+		line := c.line()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			var evt store.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &evt); err != nil {
+				c.t.Fatalf("invalid event data: %v", err)
+			}
+			return id, evt
+		}
+	}
+}
+
+func TestEventStream_SSEFiltersLiveEvents(t *testing.T) {
+	_, bs, ts := setupStreamServer(t)
+	ctx := context.Background()
+
+	c := openSSE(t, ts.URL+"?event_type=intent_decided,identity_registered&identity_id=alice", nil)
+
+	bs.AppendEvent(ctx, streamEvent("evt_usage", store.EventTypeUsageObserved, "alice", 0))
+	bs.AppendEvent(ctx, streamEvent("evt_bob", store.EventTypeIntentDecided, "bob", time.Millisecond))
+	bs.AppendEvent(ctx, streamEvent("evt_alice", store.EventTypeIntentDecided, "alice", 2*time.Millisecond))
+
+	id, evt := c.next()
+	if id != "evt_alice" || evt.EventID != "evt_alice" {
+		t.Errorf("expected evt_alice, got id %q event %q", id, evt.EventID)
+	}
+}
+
+func TestEventStream_SSEResumesFromLastEventID(t *testing.T) {
+	_, bs, ts := setupStreamServer(t)
+	ctx := context.Background()
+
+	for i, id := range []string{"evt_1", "evt_2", "evt_3"} {
+		if err := bs.AppendEvent(ctx, streamEvent(id, store.EventTypeUsageObserved, "alice", time.Duration(i-10)*time.Second)); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	c := openSSE(t, ts.URL, http.Header{"Last-Event-Id": []string{"evt_1"}})
+
+	for _, want := range []string{"evt_2", "evt_3"} {
+		if id, _ := c.next(); id != want {
+			t.Fatalf("expected replayed %s, got %s", want, id)
+		}
+	}
+
+	// Live events follow the replay
+	bs.AppendEvent(ctx, streamEvent("evt_4", store.EventTypeUsageObserved, "alice", 0))
+	if id, _ := c.next(); id != "evt_4" {
+		t.Errorf("expected live evt_4, got %s", id)
+	}
+}
+
+func TestEventStream_UnknownCursor(t *testing.T) {
+	_, _, ts := setupStreamServer(t)
+
+	resp, err := http.Get(ts.URL + "?last_event_id=evt_pruned")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("expected 410 for an unknown cursor, got %d", resp.StatusCode)
+	}
+}
+
+func TestEventStream_SSEHeartbeat(t *testing.T) {
+	s, _, ts := setupStreamServer(t)
+	s.streamHeartbeat = 20 * time.Millisecond
+
+	c := openSSE(t, ts.URL, nil)
+	for {
+		line := c.line()
+		if line == ": heartbeat" {
+			return
+		}
+		if line != "" {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
+
+func TestEventStream_WebSocket(t *testing.T) {
+	s, bs, ts := setupStreamServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"?event_type=intent_decided", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.broadcaster.Subscribers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx := context.Background()
+	bs.AppendEvent(ctx, streamEvent("evt_usage", store.EventTypeUsageObserved, "alice", 0))
+	bs.AppendEvent(ctx, streamEvent("evt_decided", store.EventTypeIntentDecided, "alice", time.Millisecond))
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var evt store.Event
+	if err := conn.ReadJSON(&evt); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if evt.EventID != "evt_decided" {
+		t.Errorf("expected evt_decided, got %s", evt.EventID)
+	}
+}
+
+func TestEventStream_Unavailable(t *testing.T) {
+	s := &Server{}
+	rec := httptest.NewRecorder()
+	s.handleEventStream(rec, httptest.NewRequest("GET", "/v1/events/stream", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a broadcaster, got %d", rec.Code)
+	}
+}
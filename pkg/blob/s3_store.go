@@ -0,0 +1,410 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultS3PartSize is the multipart upload part size. Blobs up to one
+	// part are sent with a single PUT.
+	DefaultS3PartSize = 8 << 20
+	// minS3PartSize is the smallest part S3 accepts (except the last one).
+	minS3PartSize = 5 << 20
+)
+
+// Server-side encryption modes for S3Config.SSE.
+const (
+	SSES3  = "AES256"  // S3-managed keys
+	SSEKMS = "aws:kms" // KMS keys, optionally S3Config.SSEKMSKeyID
+)
+
+// S3Config configures an S3BlobStore.
+type S3Config struct {
+	Bucket string
+	Prefix string // Prepended to every key, e.g. "ratelord/prod"
+
+	// Endpoint is the service URL, e.g. "http://minio:9000". Empty selects
+	// AWS S3 in Region.
+	Endpoint string
+	Region   string // Default "us-east-1"
+	// PathStyle addresses buckets as endpoint/bucket/key instead of
+	// bucket.endpoint/key. MinIO and most S3 stand-ins need it.
+	PathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	SSE         string // "", SSES3 or SSEKMS
+	SSEKMSKeyID string
+
+	PartSize   int64 // Default DefaultS3PartSize, at least 5 MiB
+	HTTPClient *http.Client
+}
+
+// S3BlobStore implements BlobStore on Amazon S3 or an S3-compatible service
+// such as MinIO. Keys are stored as-is under the configured prefix, so the
+// date-based layout used by the archive (events/YYYY/MM/DD/...) can be
+// targeted by bucket lifecycle rules, e.g. to move old days to Glacier.
+type S3BlobStore struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+	signer *signer
+	now    func() time.Time
+}
+
+// NewS3BlobStore creates a new S3BlobStore.
+func NewS3BlobStore(cfg S3Config) (*S3BlobStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3: bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	if cfg.PartSize < minS3PartSize {
+		return nil, fmt.Errorf("s3: part size %d is below the 5 MiB minimum", cfg.PartSize)
+	}
+	switch cfg.SSE {
+	case "", SSES3, SSEKMS:
+	default:
+		return nil, fmt.Errorf("s3: unknown server-side encryption %q", cfg.SSE)
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", endpoint)
+	}
+	if cfg.PathStyle {
+		base.Path = "/" + cfg.Bucket
+	} else {
+		base.Host = cfg.Bucket + "." + base.Host
+		base.Path = ""
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3BlobStore{
+		cfg:    cfg,
+		base:   base,
+		client: client,
+		signer: &signer{
+			accessKeyID:     cfg.AccessKeyID,
+			secretAccessKey: cfg.SecretAccessKey,
+			sessionToken:    cfg.SessionToken,
+			region:          cfg.Region,
+		},
+		now: time.Now,
+	}, nil
+}
+
+// ParseS3URL parses s3://bucket/prefix. Query parameters set the remaining
+// options: endpoint, region, path_style, sse, sse_kms_key_id and part_size.
+// Credentials are taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; the region and endpoint default to AWS_REGION and
+// AWS_ENDPOINT_URL_S3. Path-style addressing is the default when an
+// endpoint is set.
+func ParseS3URL(raw string) (S3Config, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return S3Config{}, fmt.Errorf("invalid blob URL: %w", err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return S3Config{}, fmt.Errorf("invalid blob URL %q: expected s3://bucket/prefix", raw)
+	}
+
+	q := u.Query()
+	cfg := S3Config{
+		Bucket:          u.Host,
+		Prefix:          strings.Trim(u.Path, "/"),
+		Endpoint:        firstNonEmpty(q.Get("endpoint"), os.Getenv("AWS_ENDPOINT_URL_S3")),
+		Region:          firstNonEmpty(q.Get("region"), os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		SSE:             q.Get("sse"),
+		SSEKMSKeyID:     q.Get("sse_kms_key_id"),
+	}
+	cfg.PathStyle = cfg.Endpoint != ""
+	if v := q.Get("path_style"); v != "" {
+		if cfg.PathStyle, err = strconv.ParseBool(v); err != nil {
+			return S3Config{}, fmt.Errorf("invalid path_style %q", v)
+		}
+	}
+	if v := q.Get("part_size"); v != "" {
+		if cfg.PartSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return S3Config{}, fmt.Errorf("invalid part_size %q", v)
+		}
+	}
+	return cfg, nil
+}
+
+// Put uploads content to the blob store. Content larger than one part is
+// sent as a multipart upload, which is aborted if any part fails.
+func (s *S3BlobStore) Put(ctx context.Context, key string, reader io.Reader) error {
+	first, err := readPart(reader, s.cfg.PartSize)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %w", key, err)
+	}
+	if int64(len(first)) < s.cfg.PartSize {
+		resp, err := s.do(ctx, http.MethodPut, key, nil, first, s.sseHeaders())
+		if err != nil {
+			return fmt.Errorf("failed to put blob %s: %w", key, err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return s.putMultipart(ctx, key, first, reader)
+}
+
+func (s *S3BlobStore) putMultipart(ctx context.Context, key string, first []byte, reader io.Reader) error {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, s.sseHeaders())
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload of %s: %w", key, err)
+	}
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if err != nil || created.UploadID == "" {
+		return fmt.Errorf("failed to start multipart upload of %s: invalid response", key)
+	}
+
+	err = s.uploadParts(ctx, key, created.UploadID, first, reader)
+	if err != nil {
+		// Abort so that the parts stop being billed; the error that matters
+		// is the upload's.
+		if resp, aerr := s.do(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {created.UploadID}}, nil, nil); aerr == nil {
+			resp.Body.Close()
+		}
+		return fmt.Errorf("failed to upload blob %s: %w", key, err)
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *S3BlobStore) uploadParts(ctx context.Context, key, uploadID string, part []byte, reader io.Reader) error {
+	var parts []completedPart
+	for n := 1; len(part) > 0; n++ {
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, q, part, nil)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", n, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: n, ETag: resp.Header.Get("ETag")})
+
+		if int64(len(part)) < s.cfg.PartSize {
+			break
+		}
+		if part, err = readPart(reader, s.cfg.PartSize); err != nil {
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, nil)
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// S3 may report a failed completion with a 200 and an error body
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		return fmt.Errorf("complete: %s", parseS3Error(data))
+	}
+	return nil
+}
+
+// Get retrieves content from the blob store.
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		var s3Err *s3Error
+		if errors.As(err, &s3Err) && s3Err.status == http.StatusNotFound {
+			return nil, fmt.Errorf("blob %s not found", key)
+		}
+		return nil, fmt.Errorf("failed to get blob %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// List returns a list of keys matching the prefix.
+func (s *S3BlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.objectKey(prefix)}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs with prefix %s: %w", prefix, err)
+		}
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode list of %s: %w", prefix, err)
+		}
+
+		for _, obj := range page.Contents {
+			key := obj.Key
+			if s.cfg.Prefix != "" {
+				key = strings.TrimPrefix(key, s.cfg.Prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Delete removes a blob. Unlike LocalBlobStore, deleting a missing key is
+// not an error, because S3 does not report it.
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3BlobStore) objectKey(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return s.cfg.Prefix + "/" + strings.TrimPrefix(key, "/")
+}
+
+func (s *S3BlobStore) sseHeaders() http.Header {
+	h := http.Header{}
+	if s.cfg.SSE != "" {
+		h.Set("X-Amz-Server-Side-Encryption", s.cfg.SSE)
+	}
+	if s.cfg.SSE == SSEKMS && s.cfg.SSEKMSKeyID != "" {
+		h.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.cfg.SSEKMSKeyID)
+	}
+	return h
+}
+
+// do sends a signed request for key (the bucket itself when key is empty).
+// Responses other than 2xx are returned as an *s3Error.
+func (s *S3BlobStore) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := *s.base
+	if key != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.objectKey(key)
+	} else if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	s.signer.sign(req, payloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, &s3Error{status: resp.StatusCode, message: parseS3Error(data)}
+	}
+	return resp, nil
+}
+
+// s3Error is a non-2xx response.
+type s3Error struct {
+	status  int
+	message string
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("s3: %d %s", e.status, e.message)
+}
+
+func parseS3Error(data []byte) string {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(data, &body); err != nil || body.Code == "" {
+		return strings.TrimSpace(string(data))
+	}
+	return body.Code + ": " + body.Message
+}
+
+// readPart reads up to size bytes. A short result means reader is drained.
+func readPart(reader io.Reader, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(io.LimitReader(reader, size))
+	return buf.Bytes(), err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -2,7 +2,9 @@ package blob
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
 )
 
 type BlobStore interface {
@@ -18,3 +20,25 @@ type BlobStore interface {
 	// Delete removes a blob.
 	Delete(ctx context.Context, key string) error
 }
+
+// Open returns the blob store for a RATELORD_BLOB_URL: s3://bucket/prefix
+// selects S3 (see ParseS3URL), file:///path or a plain path the local
+// filesystem.
+func Open(rawURL string) (BlobStore, error) {
+	switch {
+	case rawURL == "":
+		return nil, fmt.Errorf("blob URL is empty")
+	case strings.HasPrefix(rawURL, "s3://"):
+		cfg, err := ParseS3URL(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		return NewS3BlobStore(cfg)
+	case strings.HasPrefix(rawURL, "file://"):
+		return NewLocalBlobStore(strings.TrimPrefix(rawURL, "file://")), nil
+	case strings.Contains(rawURL, "://"):
+		return nil, fmt.Errorf("unsupported blob URL %q", rawURL)
+	default:
+		return NewLocalBlobStore(rawURL), nil
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/rmax-ai/ratelord/pkg/graph"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -260,6 +261,81 @@ func (c *Client) GetGraph(ctx context.Context) (*graph.Graph, error) {
 	return &g, nil
 }
 
+// NewObservation builds an Observation from a provider response header,
+// attributing it to the dimensions of the given intent.
+// Only rate-limit related headers are retained.
+func NewObservation(providerID string, intent Intent, header http.Header) Observation {
+	headers := make(map[string]string)
+	for name, values := range header {
+		if len(values) == 0 || !isRateLimitHeader(name) {
+			continue
+		}
+		headers[strings.ToLower(name)] = values[0]
+	}
+	return Observation{
+		ProviderID: providerID,
+		AgentID:    intent.AgentID,
+		IdentityID: intent.IdentityID,
+		WorkloadID: intent.WorkloadID,
+		ScopeID:    intent.ScopeID,
+		Headers:    headers,
+		ObservedAt: time.Now(),
+	}
+}
+
+// isRateLimitHeader reports whether a header carries rate-limit state.
+func isRateLimitHeader(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "ratelimit") || name == "retry-after"
+}
+
+// Observe forwards rate-limit headers seen on a real provider response to the daemon
+// (passive observation). This lets the daemon track limits without polling.
+func (c *Client) Observe(ctx context.Context, obs Observation) (ObservationResult, error) {
+	if obs.ProviderID == "" || obs.IdentityID == "" || obs.ScopeID == "" {
+		return ObservationResult{}, fmt.Errorf("invalid observation: missing required fields")
+	}
+	if len(obs.Headers) == 0 {
+		return ObservationResult{}, fmt.Errorf("invalid observation: no rate-limit headers")
+	}
+
+	body, err := json.Marshal(obs)
+	if err != nil {
+		return ObservationResult{}, fmt.Errorf("failed to marshal observation: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint+"/v1/observations", bytes.NewReader(body))
+	if err != nil {
+		return ObservationResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return ObservationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return ObservationResult{}, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var result ObservationResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return ObservationResult{}, err
+	}
+
+	return result, nil
+}
+
+// ObserveResponse is a convenience wrapper around NewObservation and Observe.
+func (c *Client) ObserveResponse(ctx context.Context, providerID string, intent Intent, resp *http.Response) (ObservationResult, error) {
+	if resp == nil {
+		return ObservationResult{}, fmt.Errorf("invalid observation: nil response")
+	}
+	return c.Observe(ctx, NewObservation(providerID, intent, resp.Header))
+}
+
 // failClosed returns a denied decision with a specific reason.
 func failClosed(reason string) Decision {
 	return Decision{
@@ -170,3 +170,47 @@ func TestClient_Ping(t *testing.T) {
 		t.Errorf("Ping() status = %s, want ok", status.Status)
 	}
 }
+
+func TestClient_ObserveResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/observations" {
+			t.Errorf("Expected path /v1/observations, got %s", r.URL.Path)
+		}
+		var obs Observation
+		if err := json.NewDecoder(r.Body).Decode(&obs); err != nil {
+			t.Fatalf("Failed to decode observation: %v", err)
+		}
+		if obs.ProviderID != "openai-main" || obs.IdentityID != "user-1" || obs.ScopeID != "scope-1" {
+			t.Errorf("Unexpected observation dimensions: %+v", obs)
+		}
+		if obs.Headers["x-ratelimit-remaining-tokens"] != "900" {
+			t.Errorf("Expected forwarded rate limit header, got %v", obs.Headers)
+		}
+		if _, ok := obs.Headers["content-type"]; ok {
+			t.Errorf("Expected unrelated headers to be dropped, got %v", obs.Headers)
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ObservationResult{Accepted: 1, EventIDs: []string{"evt-1"}})
+	}))
+	defer server.Close()
+
+	providerResp := &http.Response{Header: http.Header{}}
+	providerResp.Header.Set("X-Ratelimit-Remaining-Tokens", "900")
+	providerResp.Header.Set("Content-Type", "application/json")
+
+	intent := Intent{AgentID: "agent-1", IdentityID: "user-1", WorkloadID: "work-1", ScopeID: "scope-1"}
+
+	c := NewClient(server.URL)
+	res, err := c.ObserveResponse(context.Background(), "openai-main", intent, providerResp)
+	if err != nil {
+		t.Fatalf("ObserveResponse() error = %v", err)
+	}
+	if res.Accepted != 1 {
+		t.Errorf("ObserveResponse() accepted = %d, want 1", res.Accepted)
+	}
+
+	// No rate-limit headers is rejected client-side
+	if _, err := c.ObserveResponse(context.Background(), "openai-main", intent, &http.Response{Header: http.Header{}}); err == nil {
+		t.Error("Expected error for response without rate-limit headers")
+	}
+}
@@ -104,24 +104,52 @@ type EventCorrelation struct {
 
 // UsageStat represents aggregated usage statistics.
 type UsageStat struct {
-	BucketTs   time.Time `json:"bucket_ts"`
-	ProviderID string    `json:"provider_id"`
-	PoolID     string    `json:"pool_id"`
-	IdentityID string    `json:"identity_id"`
-	ScopeID    string    `json:"scope_id"`
-	TotalUsage int       `json:"total_usage"`
-	MinUsage   int       `json:"min_usage"`
-	MaxUsage   int       `json:"max_usage"`
-	EventCount int       `json:"event_count"`
+	BucketTs      time.Time `json:"bucket_ts"`
+	Bucket        string    `json:"bucket,omitempty"`
+	ProviderID    string    `json:"provider_id"`
+	PoolID        string    `json:"pool_id"`
+	IdentityID    string    `json:"identity_id"`
+	ScopeID       string    `json:"scope_id"`
+	TotalUsage    int       `json:"total_usage"`
+	MinUsage      int       `json:"min_usage"`
+	MaxUsage      int       `json:"max_usage"`
+	EventCount    int       `json:"event_count"`
+	TotalCost     int64     `json:"total_cost"` // micro-USD
+	ApprovedCount int       `json:"approved_count"`
+	DeniedCount   int       `json:"denied_count"`
+	ShapedCount   int       `json:"shaped_count"`
 }
 
 // TrendsOptions defines filters for GetTrends.
 type TrendsOptions struct {
 	From       time.Time
 	To         time.Time
-	Bucket     string // "hour" or "day"
+	Bucket     string // "minute", "hour" or "day"
 	ProviderID string
 	PoolID     string
 	IdentityID string
 	ScopeID    string
 }
+
+// Observation carries rate-limit headers seen on a real provider response.
+type Observation struct {
+	// ProviderID is the daemon-side provider ID (e.g. "openai-main").
+	ProviderID string `json:"provider_id"`
+	// AgentID, IdentityID, WorkloadID and ScopeID attribute the observed usage.
+	AgentID    string `json:"agent_id"`
+	IdentityID string `json:"identity_id"`
+	WorkloadID string `json:"workload_id"`
+	ScopeID    string `json:"scope_id"`
+	// Headers holds the rate-limit related response headers.
+	Headers map[string]string `json:"headers"`
+	// ObservedAt is when the response was received (default: now).
+	ObservedAt time.Time `json:"observed_at,omitempty"`
+}
+
+// ObservationResult is the daemon's acknowledgement of an Observation.
+type ObservationResult struct {
+	// Accepted is the number of pools updated.
+	Accepted int `json:"accepted"`
+	// EventIDs are the IDs of the events recorded.
+	EventIDs []string `json:"event_ids"`
+}
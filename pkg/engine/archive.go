@@ -19,17 +19,20 @@ type ArchiveConfig struct {
 	Retention     time.Duration `json:"retention"`
 	BatchSize     int           `json:"batch_size"`
 	CheckInterval time.Duration `json:"check_interval"`
+	// Format is the segment format: store.ArchiveFormatJSONL (default) or
+	// store.ArchiveFormatParquet, which is partitioned by day and event type.
+	Format string `json:"format"`
 }
 
 // ArchiveWorker handles archiving old events to blob storage.
 type ArchiveWorker struct {
-	store     *store.Store
+	store     store.EventStore
 	blobStore blob.BlobStore
 	config    ArchiveConfig
 }
 
 // NewArchiveWorker creates a new ArchiveWorker.
-func NewArchiveWorker(store *store.Store, blobStore blob.BlobStore, config ArchiveConfig) *ArchiveWorker {
+func NewArchiveWorker(store store.EventStore, blobStore blob.BlobStore, config ArchiveConfig) *ArchiveWorker {
 	return &ArchiveWorker{
 		store:     store,
 		blobStore: blobStore,
@@ -78,7 +81,31 @@ func (w *ArchiveWorker) processBatch(ctx context.Context) error {
 		return nil // Nothing to archive
 	}
 
-	// Serialize events to JSON Lines
+	if w.config.Format == store.ArchiveFormatParquet {
+		err = w.uploadParquet(ctx, events)
+	} else {
+		err = w.uploadJSONL(ctx, events)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Collect event IDs for deletion
+	eventIDs := make([]string, len(events))
+	for i, event := range events {
+		eventIDs[i] = string(event.EventID)
+	}
+
+	// Delete events from store
+	if err := w.store.DeleteEvents(ctx, eventIDs); err != nil {
+		return fmt.Errorf("failed to delete archived events: %w", err)
+	}
+
+	return nil
+}
+
+// uploadJSONL writes the batch as one gzipped JSON Lines segment.
+func (w *ArchiveWorker) uploadJSONL(ctx context.Context, events []*store.Event) error {
 	var buf bytes.Buffer
 	gzWriter := gzip.NewWriter(&buf)
 	encoder := json.NewEncoder(gzWriter)
@@ -94,32 +121,28 @@ func (w *ArchiveWorker) processBatch(ctx context.Context) error {
 		return fmt.Errorf("failed to close gzip writer: %w", err)
 	}
 
-	// Generate key: events/YYYY/MM/DD/first_ts_ingest_last_ts_ingest_uuid.jsonl.gz
-	firstEvent := events[0]
-	lastEvent := events[len(events)-1]
-	year, month, day := firstEvent.TsIngest.Date()
-	key := fmt.Sprintf("events/%04d/%02d/%02d/%d_%d_%s.jsonl.gz",
-		year, month, day,
-		firstEvent.TsIngest.Unix(),
-		lastEvent.TsIngest.Unix(),
-		uuid.New().String(),
-	)
-
-	// Upload to blob store
+	// Key: events/YYYY/MM/DD/first_ts_ingest_last_ts_ingest_uuid.jsonl.gz
+	key := store.ArchiveKey(events[0].TsIngest, events[len(events)-1].TsIngest, uuid.New().String())
+
 	if err := w.blobStore.Put(ctx, key, &buf); err != nil {
 		return fmt.Errorf("failed to upload archive to blob store: %w", err)
 	}
+	return nil
+}
 
-	// Collect event IDs for deletion
-	eventIDs := make([]string, len(events))
-	for i, event := range events {
-		eventIDs[i] = string(event.EventID)
-	}
+// uploadParquet writes one Parquet segment per day and event type.
+func (w *ArchiveWorker) uploadParquet(ctx context.Context, events []*store.Event) error {
+	for _, p := range store.PartitionEvents(events) {
+		var buf bytes.Buffer
+		if err := store.WriteEventsParquet(&buf, p.Events); err != nil {
+			return fmt.Errorf("failed to encode parquet archive: %w", err)
+		}
 
-	// Delete events from store
-	if err := w.store.DeleteEvents(ctx, eventIDs); err != nil {
-		return fmt.Errorf("failed to delete archived events: %w", err)
+		first, last := p.Events[0].TsIngest, p.Events[len(p.Events)-1].TsIngest
+		key := store.ArchiveParquetKey(p.Day, p.EventType, first, last, uuid.New().String())
+		if err := w.blobStore.Put(ctx, key, &buf); err != nil {
+			return fmt.Errorf("failed to upload archive to blob store: %w", err)
+		}
 	}
-
 	return nil
 }
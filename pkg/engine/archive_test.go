@@ -5,9 +5,11 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -122,10 +124,17 @@ func TestArchiveWorker_ProcessBatch(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ReadEvents failed: %v", err)
 	}
-	if len(events) != 5 {
-		t.Errorf("expected 5 events remaining, got %d", len(events))
-	}
+	// The archived events are recorded by an events_tombstoned event
+	var remaining []*store.Event
 	for _, event := range events {
+		if event.EventType != store.EventTypeEventsTombstoned {
+			remaining = append(remaining, event)
+		}
+	}
+	if len(remaining) != 5 {
+		t.Errorf("expected 5 events remaining, got %d", len(remaining))
+	}
+	for _, event := range remaining {
 		if event.TsIngest.Before(newTime.Add(-time.Minute)) {
 			t.Errorf("found old event that should have been archived: %v", event.EventID)
 		}
@@ -186,4 +195,70 @@ func TestArchiveWorker_ProcessBatch(t *testing.T) {
 			}
 		}
 	}
+
+	// Queries through an ArchiveStore still see the archived events
+	events, err = store.NewArchiveStore(dbStore, blobStore).QueryEvents(ctx, store.EventFilter{
+		EventTypes:      []store.EventType{store.EventTypeUsageObserved},
+		IncludeArchived: true,
+	})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(events) != 10 {
+		t.Errorf("expected 10 events including the archive, got %d", len(events))
+	}
+}
+
+func TestArchiveWorker_Parquet(t *testing.T) {
+	dir := t.TempDir()
+	dbStore, err := store.NewStore(filepath.Join(dir, "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer dbStore.Close()
+	blobStore := blob.NewLocalBlobStore(filepath.Join(dir, "blobs"))
+	ctx := context.Background()
+
+	old := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, typ := range []store.EventType{store.EventTypeUsageObserved, store.EventTypeIntentDecided, store.EventTypeUsageObserved} {
+		event := &store.Event{
+			EventID:   store.EventID(uuid.New().String()),
+			EventType: typ,
+			TsEvent:   old.Add(time.Duration(i) * time.Second),
+			TsIngest:  old.Add(time.Duration(i) * time.Second),
+			Payload:   json.RawMessage(`{"usage": 100}`),
+		}
+		if err := dbStore.AppendEvent(ctx, event); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	worker := NewArchiveWorker(dbStore, blobStore, ArchiveConfig{
+		Enabled:   true,
+		Retention: time.Hour,
+		BatchSize: 10,
+		Format:    store.ArchiveFormatParquet,
+	})
+	if err := worker.processBatch(ctx); err != nil {
+		t.Fatalf("processBatch failed: %v", err)
+	}
+
+	// One file per event type, under Hive-style partitions
+	files, _ := blobStore.List(ctx, fmt.Sprintf("events/date=%s/", old.Format("2006-01-02")))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 parquet partitions, got %v", files)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f, ".parquet") || !strings.Contains(f, "/event_type=") {
+			t.Errorf("unexpected archive key %s", f)
+		}
+	}
+
+	events, err := store.NewArchive(blobStore).QueryEvents(ctx, store.EventFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Errorf("expected 3 archived events, got %d", len(events))
+	}
 }
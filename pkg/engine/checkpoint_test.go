@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func TestChainCheckpointer(t *testing.T) {
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	pub, key, _ := ed25519.GenerateKey(nil)
+	c := NewChainCheckpointer(st, st, key, time.Hour)
+
+	// Nothing to sign on an empty chain
+	if err := c.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if head, _ := st.ChainHead(ctx); head.Seq != 0 {
+		t.Fatalf("expected no checkpoint on an empty chain, head is %+v", head)
+	}
+
+	for _, id := range []store.EventID{"evt-1", "evt-2"} {
+		if err := st.AppendEvent(ctx, &store.Event{EventID: id, EventType: store.EventTypeUsageObserved, TsEvent: time.Now()}); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+	if err := c.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	// Only the checkpoint was appended since; no new checkpoint is needed
+	if err := c.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	events, err := st.QueryEvents(ctx, store.EventFilter{EventTypes: []store.EventType{store.EventTypeChainCheckpoint}})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 checkpoint, got %d", len(events))
+	}
+	var cp store.CheckpointPayload
+	if err := json.Unmarshal(events[0].Payload, &cp); err != nil {
+		t.Fatalf("invalid checkpoint payload: %v", err)
+	}
+	if cp.Seq != 2 || !cp.VerifySignature(pub) {
+		t.Errorf("unexpected checkpoint: %+v", cp)
+	}
+
+	report, err := store.VerifyChain(ctx, st, pub)
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if !report.OK() || report.LastCheckpointSeq != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestChainCheckpointer_Fenced(t *testing.T) {
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	const lease = "ratelord-leader"
+	st.SetFence(lease)
+	// Two takeovers: the lease is at epoch 2
+	for _, holder := range []string{"node-a", "node-b"} {
+		if ok, err := st.Acquire(ctx, lease, holder, time.Hour); err != nil || !ok {
+			t.Fatalf("Acquire(%s) failed: %v", holder, err)
+		}
+		if err := st.Release(ctx, lease, holder); err != nil {
+			t.Fatalf("Release(%s) failed: %v", holder, err)
+		}
+	}
+	if err := st.AppendEvent(ctx, &store.Event{EventID: "evt-1", EventType: store.EventTypeUsageObserved, TsEvent: time.Now()}); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(nil)
+	c := NewChainCheckpointer(st, st, key, time.Hour)
+	c.SetEpochFunc(func() int64 { return 1 }) // Deposed leader
+	fenced := false
+	c.SetOnFenced(func() { fenced = true })
+
+	if err := c.Checkpoint(ctx); !errors.Is(err, store.ErrStaleEpoch) {
+		t.Fatalf("expected ErrStaleEpoch, got %v", err)
+	}
+	if !fenced {
+		t.Error("expected onFenced to be called")
+	}
+	if head, _ := st.ChainHead(ctx); head.Seq != 1 {
+		t.Errorf("deposed leader's checkpoint was appended, head is %+v", head)
+	}
+}
@@ -87,6 +87,14 @@ func (c *ClusterTopology) Replay(events []*store.Event) error {
 	return nil
 }
 
+// Reset drops all known nodes
+func (c *ClusterTopology) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodes = make(map[string]*ClusterNode)
+	c.lastEventID = ""
+}
+
 // GetNodes returns all known nodes, updating status based on TTL
 func (c *ClusterTopology) GetNodes(ttl time.Duration) []ClusterNode {
 	c.mu.RLock()
@@ -1,5 +1,10 @@
 package engine
 
+import (
+	"fmt"
+	"time"
+)
+
 // PolicyConfig represents the top-level structure of policy.json
 type PolicyConfig struct {
 	Policies  []PolicyDefinition          `json:"policies" yaml:"policies"`
@@ -7,6 +12,24 @@ type PolicyConfig struct {
 	Pricing   map[string]map[string]int64 `json:"pricing,omitempty" yaml:"pricing,omitempty"`
 	Units     map[string]string           `json:"units,omitempty" yaml:"units,omitempty"` // provider_id -> unit_name
 	Retention *RetentionConfig            `json:"retention,omitempty" yaml:"retention,omitempty"`
+	Degraded  *DegradedConfig             `json:"degraded,omitempty" yaml:"degraded,omitempty"`
+}
+
+// Degraded modes decide how intents are evaluated against stale pool data.
+const (
+	DegradedFailOpen     = "fail_open"    // Evaluate as usual, with a warning
+	DegradedFailClosed   = "fail_closed"  // Deny until fresh data arrives
+	DegradedConservative = "conservative" // Decay remaining using the last forecast burn rate
+)
+
+// DefaultStaleAfter is the observation age after which a pool counts as stale.
+const DefaultStaleAfter = 2 * time.Minute
+
+// DegradedConfig controls decisions when provider data is stale
+type DegradedConfig struct {
+	StaleAfter string            `json:"stale_after,omitempty" yaml:"stale_after,omitempty"` // e.g., "2m"
+	Mode       string            `json:"mode,omitempty" yaml:"mode,omitempty"`               // fail_open (default), fail_closed, conservative
+	ByProvider map[string]string `json:"by_provider,omitempty" yaml:"by_provider,omitempty"` // provider_id -> mode
 }
 
 // RetentionConfig defines data lifecycle rules
@@ -31,6 +54,50 @@ func (c *PolicyConfig) GetCost(providerID, poolID string) int64 {
 	return 0
 }
 
+// ApplyDefaultPricing fills in pricing for a provider's pools that have no
+// explicit entry. Configured prices always win.
+func (c *PolicyConfig) ApplyDefaultPricing(providerID string, defaults map[string]int64) {
+	if len(defaults) == 0 {
+		return
+	}
+	if c.Pricing == nil {
+		c.Pricing = make(map[string]map[string]int64)
+	}
+	pools, ok := c.Pricing[providerID]
+	if !ok {
+		pools = make(map[string]int64, len(defaults))
+		c.Pricing[providerID] = pools
+	}
+	for poolID, cost := range defaults {
+		if _, set := pools[poolID]; !set {
+			pools[poolID] = cost
+		}
+	}
+}
+
+// GetStaleAfter returns the staleness threshold for pools.
+func (c *PolicyConfig) GetStaleAfter() time.Duration {
+	if c == nil || c.Degraded == nil {
+		return DefaultStaleAfter
+	}
+	return parseDurationOr(c.Degraded.StaleAfter, DefaultStaleAfter)
+}
+
+// GetDegradedMode returns the degraded mode for a provider, or "" when
+// degraded handling is not configured.
+func (c *PolicyConfig) GetDegradedMode(providerID string) string {
+	if c == nil || c.Degraded == nil {
+		return ""
+	}
+	if mode, ok := c.Degraded.ByProvider[providerID]; ok && mode != "" {
+		return mode
+	}
+	if c.Degraded.Mode != "" {
+		return c.Degraded.Mode
+	}
+	return DegradedFailOpen
+}
+
 // GetUnit returns the unit for a given provider, defaulting to "requests".
 func (c *PolicyConfig) GetUnit(providerID string) string {
 	if c.Units != nil {
@@ -43,8 +110,87 @@ func (c *PolicyConfig) GetUnit(providerID string) string {
 
 // ProvidersConfig holds configuration for various providers
 type ProvidersConfig struct {
-	GitHub []GitHubConfig `json:"github,omitempty" yaml:"github,omitempty"`
-	OpenAI []OpenAIConfig `json:"openai,omitempty" yaml:"openai,omitempty"`
+	GitHub    []GitHubConfig    `json:"github,omitempty" yaml:"github,omitempty"`
+	OpenAI    []OpenAIConfig    `json:"openai,omitempty" yaml:"openai,omitempty"`
+	Anthropic []AnthropicConfig `json:"anthropic,omitempty" yaml:"anthropic,omitempty"`
+	Plugins   []PluginConfig    `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Polling   *PollingConfig    `json:"polling,omitempty" yaml:"polling,omitempty"`
+}
+
+// PollingConfig controls per-provider poll scheduling.
+// Durations use Go syntax (e.g., "10s", "5m").
+type PollingConfig struct {
+	Interval         string            `json:"interval,omitempty" yaml:"interval,omitempty"`                   // Default interval for all providers
+	ByProvider       map[string]string `json:"by_provider,omitempty" yaml:"by_provider,omitempty"`             // provider_id -> interval
+	MinInterval      string            `json:"min_interval,omitempty" yaml:"min_interval,omitempty"`           // Fastest adaptive interval (default "1s")
+	MaxInterval      string            `json:"max_interval,omitempty" yaml:"max_interval,omitempty"`           // Slowest adaptive interval when idle (default "5m")
+	MaxBackoff       string            `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`             // Cap for error backoff and circuit cool-down (default "5m")
+	FailureThreshold int               `json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty"` // Consecutive errors before the circuit opens (default 5)
+}
+
+// Polling defaults
+const (
+	DefaultMinPollInterval  = 1 * time.Second
+	DefaultMaxPollInterval  = 5 * time.Minute
+	DefaultMaxPollBackoff   = 5 * time.Minute
+	DefaultFailureThreshold = 5
+)
+
+// GetPollInterval returns the base poll interval for a provider, falling back
+// to the global polling interval and then to fallback.
+func (c *PolicyConfig) GetPollInterval(providerID string, fallback time.Duration) time.Duration {
+	if c == nil || c.Providers.Polling == nil {
+		return fallback
+	}
+	pc := c.Providers.Polling
+	if v, ok := pc.ByProvider[providerID]; ok {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return parseDurationOr(pc.Interval, fallback)
+}
+
+// GetPollBounds returns the adaptive interval bounds, backoff cap and failure threshold.
+func (c *PolicyConfig) GetPollBounds() (minInterval, maxInterval, maxBackoff time.Duration, failureThreshold int) {
+	minInterval = DefaultMinPollInterval
+	maxInterval = DefaultMaxPollInterval
+	maxBackoff = DefaultMaxPollBackoff
+	failureThreshold = DefaultFailureThreshold
+	if c == nil || c.Providers.Polling == nil {
+		return
+	}
+	pc := c.Providers.Polling
+	minInterval = parseDurationOr(pc.MinInterval, minInterval)
+	maxInterval = parseDurationOr(pc.MaxInterval, maxInterval)
+	maxBackoff = parseDurationOr(pc.MaxBackoff, maxBackoff)
+	if pc.FailureThreshold > 0 {
+		failureThreshold = pc.FailureThreshold
+	}
+	return
+}
+
+// parseDurationOr parses a positive duration, returning fallback if empty or invalid.
+// Validate rejects provider settings that cannot work, so a bad policy file
+// fails to load instead of failing on every poll.
+func (c *PolicyConfig) Validate() error {
+	for _, oa := range c.Providers.OpenAI {
+		if oa.AzureURLTemplate != "" && len(oa.Models) == 0 {
+			return fmt.Errorf("openai provider %q: azure_url_template requires models (the deployment names to poll)", oa.ID)
+		}
+	}
+	return nil
+}
+
+func parseDurationOr(s string, fallback time.Duration) time.Duration {
+	if s == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
 }
 
 // GitHubConfig defines configuration for the GitHub provider
@@ -52,6 +198,12 @@ type GitHubConfig struct {
 	ID            string `json:"id" yaml:"id"`
 	TokenEnvVar   string `json:"token_env_var" yaml:"token_env_var"` // Prefer env var name for security
 	EnterpriseURL string `json:"enterprise_url,omitempty" yaml:"enterprise_url,omitempty"`
+
+	// GitHub App authentication (used instead of TokenEnvVar when AppID is set)
+	AppID            int64   `json:"app_id,omitempty" yaml:"app_id,omitempty"`
+	PrivateKeyEnvVar string  `json:"private_key_env_var,omitempty" yaml:"private_key_env_var,omitempty"` // PEM contents
+	PrivateKeyPath   string  `json:"private_key_path,omitempty" yaml:"private_key_path,omitempty"`
+	InstallationIDs  []int64 `json:"installation_ids,omitempty" yaml:"installation_ids,omitempty"` // Empty: all installations
 }
 
 // OpenAIConfig defines configuration for the OpenAI provider
@@ -60,6 +212,11 @@ type OpenAIConfig struct {
 	TokenEnvVar string `json:"token_env_var" yaml:"token_env_var"`
 	OrgID       string `json:"org_id,omitempty" yaml:"org_id,omitempty"` // Optional: for 'OpenAI-Organization' header
 	BaseURL     string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+
+	ProjectID        string   `json:"project_id,omitempty" yaml:"project_id,omitempty"`                 // Optional: for 'OpenAI-Project' header
+	Models           []string `json:"models,omitempty" yaml:"models,omitempty"`                         // Per-model pools; Azure deployment names
+	Probe            bool     `json:"probe,omitempty" yaml:"probe,omitempty"`                           // Send a 1-token completion if headers are missing
+	AzureURLTemplate string   `json:"azure_url_template,omitempty" yaml:"azure_url_template,omitempty"` // Contains "{deployment}"
 }
 
 // PolicyDefinition maps a high-level policy block
@@ -87,3 +244,24 @@ type TimeWindow struct {
 	Days      []string `json:"days,omitempty" yaml:"days,omitempty"`             // ["Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"]
 	Location  string   `json:"location,omitempty" yaml:"location,omitempty"`     // e.g., "America/New_York" (defaults to UTC)
 }
+
+// AnthropicConfig defines configuration for Anthropic-style token-bucket
+// providers. Other vendors with the same header layout set HeaderPrefix.
+type AnthropicConfig struct {
+	ID           string `json:"id" yaml:"id"`
+	TokenEnvVar  string `json:"token_env_var" yaml:"token_env_var"`
+	BaseURL      string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	Model        string `json:"model,omitempty" yaml:"model,omitempty"`                 // Enables polling with a 1-token message
+	HeaderPrefix string `json:"header_prefix,omitempty" yaml:"header_prefix,omitempty"` // Default "anthropic-ratelimit"
+	PoolPrefix   string `json:"pool_prefix,omitempty" yaml:"pool_prefix,omitempty"`     // Default "anthropic"
+}
+
+// PluginConfig declares an out-of-process provider plugin
+type PluginConfig struct {
+	ID      string   `json:"id" yaml:"id"`
+	Command string   `json:"command,omitempty" yaml:"command,omitempty"` // Executable; empty to connect to an existing socket
+	Args    []string `json:"args,omitempty" yaml:"args,omitempty"`
+	Env     []string `json:"env,omitempty" yaml:"env,omitempty"`         // Extra KEY=VALUE entries
+	Socket  string   `json:"socket,omitempty" yaml:"socket,omitempty"`   // Unix socket path; default is stdio
+	Timeout string   `json:"timeout,omitempty" yaml:"timeout,omitempty"` // Per-call deadline (default "10s")
+}
@@ -18,7 +18,7 @@ func (dc *DelayController) CalculateWait(state PoolState, now time.Time, kp floa
 	if timeToReset <= 0 {
 		return 0
 	}
-	targetBurn := float64(state.Remaining) / timeToReset.Seconds()
+	targetBurn := float64(state.Available()) / timeToReset.Seconds()
 	currentBurn := state.LatestForecast.BurnRate.Mean
 	error := currentBurn - targetBurn
 	if error <= 0 {
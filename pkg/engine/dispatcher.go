@@ -30,13 +30,13 @@ const (
 
 // Dispatcher handles the delivery of events to registered webhooks.
 type Dispatcher struct {
-	store      *store.Store
+	store      store.EventStore
 	client     *http.Client
 	pollTicker *time.Ticker
 }
 
 // NewDispatcher creates a new webhook dispatcher.
-func NewDispatcher(s *store.Store) *Dispatcher {
+func NewDispatcher(s store.EventStore) *Dispatcher {
 	return &Dispatcher{
 		store: s,
 		client: &http.Client{
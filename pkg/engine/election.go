@@ -96,6 +96,23 @@ func (em *ElectionManager) GetEpoch() int64 {
 	return em.epoch
 }
 
+// StepDown gives up leadership without releasing the lease, e.g. after the
+// store rejected a write as fenced: a newer leader holds the lease, and the
+// next election round finds out who. It is a no-op unless leader.
+func (em *ElectionManager) StepDown() {
+	em.mu.Lock()
+	wasLeader := em.isLeader
+	em.isLeader = false
+	em.mu.Unlock()
+	if !wasLeader {
+		return
+	}
+	if em.onDemote != nil {
+		em.onDemote()
+	}
+	slog.Info("Stepped down from leader", "holderID", em.holderID, "leaseName", em.leaseName)
+}
+
 // GetLeader returns the current leader's holder ID (address) and true if known.
 func (em *ElectionManager) GetLeader(ctx context.Context) (string, bool, error) {
 	// First check if we are leader (fast path)
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
+	"github.com/rmax-ai/ratelord/pkg/provider"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// TestFencing_SplitBrain pauses a leader until its lease expires and another
+// node takes over. The paused leader, unaware, keeps polling: the store must
+// reject its writes, and it must step down and stop on the first rejection.
+func TestFencing_SplitBrain(t *testing.T) {
+	const lease = "ratelord-leader"
+	ttl := 100 * time.Millisecond
+	dsn := filepath.Join(t.TempDir(), "ratelord.db") + "?_busy_timeout=5000"
+
+	// Two nodes sharing the database, each through its own connection pool
+	open := func() *store.Store {
+		st, err := store.NewStore(dsn)
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		st.SetFence(lease)
+		t.Cleanup(func() { st.Close() })
+		return st
+	}
+	stA, stB := open(), open()
+
+	var demotedA atomic.Bool
+	emA := NewElectionManager(stA, "node-a", lease, ttl, nil, func() { demotedA.Store(true) })
+	emB := NewElectionManager(stB, "node-b", lease, ttl, nil, nil)
+
+	newPoller := func(st *store.Store, em *ElectionManager) *Poller {
+		fore := forecast.NewForecaster(st, forecast.NewForecastProjection(10), &forecast.LinearModel{}, nil)
+		fore.SetEpochFunc(em.GetEpoch)
+		fore.SetOnFenced(em.StepDown)
+		p := NewPoller(st, 5*time.Millisecond, fore, &PolicyConfig{})
+		p.SetEpochFunc(em.GetEpoch)
+		p.SetOnFenced(em.StepDown)
+		p.Register(&MockProvider{
+			IDVal: "mock",
+			PollResult: provider.PollResult{
+				ProviderID: "mock",
+				Status:     "ok",
+				Usage:      []provider.UsageObservation{{PoolID: "pool-1", Used: 10, Remaining: 990}},
+			},
+		})
+		return p
+	}
+
+	ctx := context.Background()
+	emA.attemptElection(ctx)
+	emB.attemptElection(ctx)
+	if !emA.IsLeader() || emA.GetEpoch() != 1 || emB.IsLeader() {
+		t.Fatalf("expected node-a to lead at epoch 1 (a: %v/%d, b: %v)", emA.IsLeader(), emA.GetEpoch(), emB.IsLeader())
+	}
+
+	pollerA := newPoller(stA, emA)
+	doneA := make(chan struct{})
+	go func() {
+		pollerA.Start(ctx)
+		close(doneA)
+	}()
+
+	// node-a stalls (no renewals) while its poller keeps writing
+	time.Sleep(2 * ttl)
+	emB.attemptElection(ctx)
+	if !emB.IsLeader() || emB.GetEpoch() != 2 {
+		t.Fatalf("expected node-b to take over at epoch 2 (b: %v/%d)", emB.IsLeader(), emB.GetEpoch())
+	}
+	if !emA.IsLeader() {
+		t.Fatal("node-a should still believe it leads")
+	}
+
+	ctxB, cancelB := context.WithCancel(ctx)
+	defer cancelB()
+	go newPoller(stB, emB).Start(ctxB)
+
+	select {
+	case <-doneA:
+	case <-time.After(5 * time.Second):
+		t.Fatal("node-a's poller did not stop after being fenced")
+	}
+	if emA.IsLeader() || !demotedA.Load() {
+		t.Error("node-a should have stepped down")
+	}
+
+	// Let node-b write for a while, then check the log
+	time.Sleep(50 * time.Millisecond)
+	cancelB()
+
+	events, err := stB.ReadEvents(ctx, time.Time{}, 100000)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	var stale, current int
+	for _, evt := range events {
+		switch evt.Epoch {
+		case 1:
+			if current > 0 {
+				t.Fatalf("event %s of the deposed leader appended after the takeover", evt.EventID)
+			}
+			stale++
+		case 2:
+			current++
+		}
+	}
+	if stale == 0 || current == 0 {
+		t.Errorf("expected events of both leaders, got %d at epoch 1 and %d at epoch 2", stale, current)
+	}
+}
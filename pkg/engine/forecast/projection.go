@@ -24,6 +24,20 @@ func NewForecastProjection(windowSize int) *ForecastProjection {
 	}
 }
 
+// Apply updates the projection with a single event. Only usage observations
+// are relevant.
+func (fp *ForecastProjection) Apply(event store.Event) error {
+	if event.EventType == store.EventTypeUsageObserved {
+		fp.OnUsageObserved(&event)
+	}
+	return nil
+}
+
+// Reset drops all histories
+func (fp *ForecastProjection) Reset() {
+	fp.LoadHistories(nil)
+}
+
 // OnUsageObserved updates the history for a pool with a new usage observation
 func (fp *ForecastProjection) OnUsageObserved(event *store.Event) {
 	var payload struct {
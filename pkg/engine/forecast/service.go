@@ -3,8 +3,10 @@ package forecast
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/rmax-ai/ratelord/pkg/store"
@@ -17,28 +19,49 @@ type ResetTimeProvider interface {
 
 // Forecaster ties together the projection, model, and event emission
 type Forecaster struct {
-	store         *store.Store
+	store         store.EventStore
 	projection    *ForecastProjection
 	model         Model
 	resetProvider ResetTimeProvider
 	epochFunc     func() int64
+	onFenced      func()
+
+	mu          sync.RWMutex
+	latest      map[string]Forecast // provider_id:pool_id -> most recent forecast
+	fencedEpoch int64               // epoch whose writes the store rejected
 }
 
 // NewForecaster creates a new forecaster instance
-func NewForecaster(store *store.Store, projection *ForecastProjection, model Model, resetProvider ResetTimeProvider) *Forecaster {
+func NewForecaster(store store.EventStore, projection *ForecastProjection, model Model, resetProvider ResetTimeProvider) *Forecaster {
 	return &Forecaster{
 		store:         store,
 		projection:    projection,
 		model:         model,
 		resetProvider: resetProvider,
+		latest:        make(map[string]Forecast),
 	}
 }
 
+// Latest returns the most recent forecast computed for a pool.
+func (f *Forecaster) Latest(providerID, poolID string) (Forecast, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	fc, ok := f.latest[providerID+":"+poolID]
+	return fc, ok
+}
+
 // SetEpochFunc sets the function to retrieve the current epoch.
 func (f *Forecaster) SetEpochFunc(funcVal func() int64) {
 	f.epochFunc = funcVal
 }
 
+// SetOnFenced sets the function called when the store rejects a forecast
+// because a newer leader took over. No more forecasts are emitted for the
+// epoch of the rejected one.
+func (f *Forecaster) SetOnFenced(funcVal func()) {
+	f.onFenced = funcVal
+}
+
 // getEpoch returns the current epoch or 0 if not configured.
 func (f *Forecaster) getEpoch() int64 {
 	if f.epochFunc != nil {
@@ -86,11 +109,23 @@ func (f *Forecaster) OnUsageObserved(ctx context.Context, event *store.Event) {
 		return
 	}
 
+	f.mu.Lock()
+	f.latest[payload.ProviderID+":"+payload.PoolID] = forecast
+	f.mu.Unlock()
+
 	// Emit forecast_computed event
 	f.emitForecastComputed(ctx, payload.ProviderID, payload.PoolID, forecast, event)
 }
 
 func (f *Forecaster) emitForecastComputed(ctx context.Context, providerID, poolID string, forecast Forecast, causationEvent *store.Event) {
+	epoch := f.getEpoch()
+	f.mu.RLock()
+	fenced := epoch > 0 && epoch == f.fencedEpoch
+	f.mu.RUnlock()
+	if fenced {
+		return
+	}
+
 	now := time.Now().UTC()
 	correlationID := fmt.Sprintf("forecast_%s_%d", poolID, now.Unix())
 
@@ -100,7 +135,7 @@ func (f *Forecaster) emitForecastComputed(ctx context.Context, providerID, poolI
 		SchemaVersion: 1,
 		TsEvent:       now,
 		TsIngest:      now,
-		Epoch:         f.getEpoch(),
+		Epoch:         epoch,
 		Source: store.EventSource{
 			OriginKind: "daemon",
 			OriginID:   "forecaster",
@@ -127,6 +162,19 @@ func (f *Forecaster) emitForecastComputed(ctx context.Context, providerID, poolI
 	event.Payload = payloadBytes
 
 	if err := f.store.AppendEvent(ctx, event); err != nil {
-		log.Printf("Failed to append forecast event: %v", err)
+		if !errors.Is(err, store.ErrStaleEpoch) {
+			log.Printf("Failed to append forecast event: %v", err)
+			return
+		}
+		f.mu.Lock()
+		first := f.fencedEpoch != epoch
+		f.fencedEpoch = epoch
+		f.mu.Unlock()
+		if first {
+			log.Printf("Forecaster stopping, writes fenced by a newer leader: %v", err)
+			if f.onFenced != nil {
+				f.onFenced()
+			}
+		}
 	}
 }
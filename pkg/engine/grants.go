@@ -0,0 +1,463 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// DefaultGrantTTL is how long a grant may be spent before the child asks
+// again, renewing its lease.
+const DefaultGrantTTL = time.Minute
+
+// DefaultGrantReclaimAfter is how long a grant lease lasts past its last
+// renewal; the unused budget is then reclaimed. Children renew at least once
+// per grant TTL, so this tolerates a couple of missed renewals.
+const DefaultGrantReclaimAfter = 3 * time.Minute
+
+// demandSmoothing weighs the latest consumption rate of a child against its
+// previous estimate.
+const demandSmoothing = 0.5
+
+// grantHeadroom is the number of grant TTLs of consumption a child is sized
+// to hold.
+const grantHeadroom = 2
+
+// ChildGrant is the lease on the budget of a pool handed by this node to one
+// child, a follower or a regional leader, as of the child's last grant request.
+type ChildGrant struct {
+	ChildID     string    `json:"child_id"`
+	ProviderID  string    `json:"provider_id"`
+	PoolID      string    `json:"pool_id"`
+	GrantID     string    `json:"grant_id"`    // ID of the grant_issued event that opened the lease
+	Outstanding int64     `json:"outstanding"` // Granted and not yet consumed
+	Used        int64     `json:"used"`        // Consumption reported by the child
+	Demand      float64   `json:"demand"`      // Consumption rate, units per second
+	LastSeen    time.Time `json:"last_seen"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+
+	measured     bool  // Demand was measured between two requests
+	observedUsed int64 // Used as of the last provider observation of the pool
+}
+
+// GrantLedger tracks the grant leases outstanding per child, folded from
+// grant_issued, grant_returned and grant_reclaimed events. It also follows
+// the provider observations of usage_observed events, to tell what children
+// spent since the provider last counted it.
+type GrantLedger struct {
+	mu         sync.RWMutex
+	grants     map[string]*ChildGrant // child_id|provider_id|pool_id
+	unobserved map[string]int64       // provider_id|pool_id: spent under closed leases since the last observation
+
+	poolsMu sync.Mutex
+	pools   map[string]*sync.Mutex // provider_id|pool_id
+}
+
+// NewGrantLedger creates an empty ledger.
+func NewGrantLedger() *GrantLedger {
+	return &GrantLedger{
+		grants:     make(map[string]*ChildGrant),
+		unobserved: make(map[string]int64),
+		pools:      make(map[string]*sync.Mutex),
+	}
+}
+
+// LockPool serializes the changes to the leases on a pool: whoever sizes a
+// grant against what is outstanding, or closes a lease, holds it until the
+// event is appended and applied. It returns the function unlocking the pool.
+func (l *GrantLedger) LockPool(providerID, poolID string) func() {
+	key := poolKey(providerID, poolID)
+	l.poolsMu.Lock()
+	mu, ok := l.pools[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		l.pools[key] = mu
+	}
+	l.poolsMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+func grantKey(childID, providerID, poolID string) string {
+	return childID + "|" + providerID + "|" + poolID
+}
+
+func poolKey(providerID, poolID string) string {
+	return providerID + "|" + poolID
+}
+
+// grantPayload is the payload of grant_issued, grant_returned and
+// grant_reclaimed events.
+type grantPayload struct {
+	FollowerID string    `json:"follower_id"`
+	ProviderID string    `json:"provider_id"`
+	PoolID     string    `json:"pool_id"`
+	GrantID    string    `json:"grant_id"`
+	Amount     int64     `json:"amount"`
+	Held       int64     `json:"held"`
+	Used       int64     `json:"used"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Apply updates the ledger with a single event.
+func (l *GrantLedger) Apply(event store.Event) error {
+	switch event.EventType {
+	case store.EventTypeGrantIssued, store.EventTypeGrantReturned, store.EventTypeGrantReclaimed:
+	case store.EventTypeUsageObserved:
+		return l.applyObservation(event)
+	default:
+		return nil
+	}
+	var payload grantPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal grant payload for event %s: %w", event.EventID, err)
+	}
+	if payload.FollowerID == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := grantKey(payload.FollowerID, payload.ProviderID, payload.PoolID)
+	g, ok := l.grants[key]
+	if event.EventType != store.EventTypeGrantIssued {
+		// Closes the lease, unless a newer one replaced it. What the child
+		// spent under it stays unobserved until the provider counts it.
+		if ok && (payload.GrantID == "" || payload.GrantID == g.GrantID) {
+			l.unobserved[poolKey(g.ProviderID, g.PoolID)] += max(payload.Used, g.Used) - g.observedUsed
+			delete(l.grants, key)
+		}
+		return nil
+	}
+
+	if !ok {
+		// Earlier consumption of the child was spent under other leases
+		g = &ChildGrant{ChildID: payload.FollowerID, ProviderID: payload.ProviderID, PoolID: payload.PoolID, observedUsed: payload.Used}
+		l.grants[key] = g
+	} else if dt := event.TsEvent.Sub(g.LastSeen).Seconds(); dt > 0 && payload.Used >= g.Used {
+		rate := float64(payload.Used-g.Used) / dt
+		if g.measured {
+			rate = demandSmoothing*rate + (1-demandSmoothing)*g.Demand
+		}
+		g.Demand = rate
+		g.measured = true
+	}
+	// The child reports what it still holds, which supersedes earlier grants
+	g.Outstanding = payload.Held + payload.Amount
+	g.Used = payload.Used
+	g.LastSeen = event.TsEvent
+	g.GrantID = payload.GrantID
+	g.ExpiresAt = payload.ExpiresAt
+	return nil
+}
+
+// applyObservation marks what children reported spending of a pool as
+// counted by the provider. Usage recorded per intent is not an observation.
+func (l *GrantLedger) applyObservation(event store.Event) error {
+	var payload struct {
+		ProviderID string `json:"provider_id"`
+		PoolID     string `json:"pool_id"`
+		Delta      *int64 `json:"delta"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal usage payload for event %s: %w", event.EventID, err)
+	}
+	if payload.Delta != nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, g := range l.grants {
+		if g.ProviderID == payload.ProviderID && g.PoolID == payload.PoolID {
+			g.observedUsed = g.Used
+		}
+	}
+	delete(l.unobserved, poolKey(payload.ProviderID, payload.PoolID))
+	return nil
+}
+
+// Reset drops all grants.
+func (l *GrantLedger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.grants = make(map[string]*ChildGrant)
+	l.unobserved = make(map[string]int64)
+}
+
+// Get returns the grant of a child for a pool.
+func (l *GrantLedger) Get(childID, providerID, poolID string) (ChildGrant, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	g, ok := l.grants[grantKey(childID, providerID, poolID)]
+	if !ok {
+		return ChildGrant{}, false
+	}
+	return *g, true
+}
+
+// List returns all grants, ordered by child, provider and pool.
+func (l *GrantLedger) List() []ChildGrant {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	list := make([]ChildGrant, 0, len(l.grants))
+	for _, g := range l.grants {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return grantKey(list[i].ChildID, list[i].ProviderID, list[i].PoolID) <
+			grantKey(list[j].ChildID, list[j].ProviderID, list[j].PoolID)
+	})
+	return list
+}
+
+// Committed returns the budget of a pool taken by children and not counted
+// by the provider yet: what they hold, and what they reported spending since
+// the last observation of the pool.
+func (l *GrantLedger) Committed(providerID, poolID string) int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	total := l.unobserved[poolKey(providerID, poolID)]
+	for _, g := range l.grants {
+		if g.ProviderID == providerID && g.PoolID == poolID {
+			total += max(g.Outstanding, 0) + max(g.Used-g.observedUsed, 0)
+		}
+	}
+	return total
+}
+
+// Expired returns the grants with budget outstanding whose lease expired at
+// now. Leases granted without an expiry last for after past the last request.
+func (l *GrantLedger) Expired(now time.Time, after time.Duration) []ChildGrant {
+	var expired []ChildGrant
+	for _, g := range l.List() {
+		if g.expired(now, after) {
+			expired = append(expired, g)
+		}
+	}
+	return expired
+}
+
+func (g ChildGrant) expired(now time.Time, after time.Duration) bool {
+	expiresAt := g.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = g.LastSeen.Add(after)
+	}
+	return g.Outstanding > 0 && expiresAt.Before(now)
+}
+
+// Size returns how much of a pool to grant to childID, which asks for
+// requested while holding held: enough to hold its consumption over a few
+// grant TTLs. Until its rate is measured, a child gets what it asks for; an
+// idle child still gets a tenth of it, so that it can resume.
+func (l *GrantLedger) Size(childID, providerID, poolID string, requested, held int64, ttl time.Duration) int64 {
+	g, ok := l.Get(childID, providerID, poolID)
+	if !ok || !g.measured {
+		return requested
+	}
+	target := int64(math.Ceil(g.Demand * ttl.Seconds() * grantHeadroom))
+	if floor := requested / 10; target < floor {
+		target = floor
+	}
+	if target <= held {
+		return 0
+	}
+	return target - held
+}
+
+// Share caps a grant of a pool to childID at its share of available, in
+// proportion to its demand among the children seen since activeSince.
+// Children whose demand is not measured yet weigh as much as the average
+// measured child, or all the same if none is measured.
+func (l *GrantLedger) Share(childID, providerID, poolID string, available int64, activeSince time.Time) int64 {
+	if available <= 0 {
+		return 0
+	}
+
+	l.mu.RLock()
+	var children []*ChildGrant
+	requester := &ChildGrant{ChildID: childID}
+	for _, g := range l.grants {
+		if g.ProviderID != providerID || g.PoolID != poolID {
+			continue
+		}
+		if g.ChildID == childID {
+			requester = g
+			continue
+		}
+		if !g.LastSeen.Before(activeSince) {
+			children = append(children, g)
+		}
+	}
+	children = append(children, requester)
+
+	var measuredSum float64
+	var measured int
+	for _, g := range children {
+		if g.measured {
+			measuredSum += g.Demand
+			measured++
+		}
+	}
+	fallback := 1.0
+	if measured > 0 {
+		fallback = measuredSum / float64(measured)
+	}
+	weight := func(g *ChildGrant) float64 {
+		if g.measured && measuredSum > 0 {
+			return g.Demand
+		}
+		return fallback
+	}
+	var total float64
+	for _, g := range children {
+		total += weight(g)
+	}
+	w := weight(requester)
+	l.mu.RUnlock()
+
+	if total <= 0 {
+		// Every measured child is idle: share equally
+		return available / int64(len(children))
+	}
+	return int64(float64(available) * w / total)
+}
+
+// GrantReclaimer takes back the budget outstanding at children whose lease
+// expired, crediting it to the pool.
+type GrantReclaimer struct {
+	store     store.EventStore
+	ledger    *GrantLedger
+	after     time.Duration
+	epochFunc func() int64
+	onReclaim func(ChildGrant)
+}
+
+// NewGrantReclaimer creates a reclaimer for expired grant leases. Leases
+// with no expiry expire after past the last request of their child.
+func NewGrantReclaimer(st store.EventStore, ledger *GrantLedger, after time.Duration) *GrantReclaimer {
+	if after <= 0 {
+		after = DefaultGrantReclaimAfter
+	}
+	return &GrantReclaimer{store: st, ledger: ledger, after: after}
+}
+
+// SetEpochFunc sets the function to retrieve the current epoch.
+func (r *GrantReclaimer) SetEpochFunc(f func() int64) {
+	r.epochFunc = f
+}
+
+// SetOnReclaim sets a function called for every grant reclaimed, e.g. to
+// return the budget to the parent of a regional leader.
+func (r *GrantReclaimer) SetOnReclaim(f func(ChildGrant)) {
+	r.onReclaim = f
+}
+
+// Run reclaims expired grants periodically until ctx is done.
+func (r *GrantReclaimer) Run(ctx context.Context) {
+	interval := r.after / 3
+	log.Printf("Starting grant reclaimer (reclaim after: %v)", r.after)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Grant reclaimer stopping")
+			return
+		case <-ticker.C:
+			if _, err := r.Reclaim(ctx, time.Now()); err != nil {
+				log.Printf("Failed to reclaim grants: %v", err)
+			}
+		}
+	}
+}
+
+// Reclaim appends a grant_reclaimed event for every lease expired at now,
+// and returns the number of grants reclaimed.
+func (r *GrantReclaimer) Reclaim(ctx context.Context, now time.Time) (int, error) {
+	var epoch int64
+	if r.epochFunc != nil {
+		epoch = r.epochFunc()
+	}
+
+	reclaimed := 0
+	for _, g := range r.ledger.Expired(now, r.after) {
+		g, ok, err := r.reclaim(ctx, g, now, epoch)
+		if err != nil {
+			return reclaimed, err
+		}
+		if !ok {
+			continue
+		}
+		RatelordGrantsReclaimed.WithLabelValues(g.ProviderID, g.PoolID).Add(float64(g.Outstanding))
+		if r.onReclaim != nil {
+			r.onReclaim(g)
+		}
+		log.Printf("Reclaimed %d of %s/%s from expired lease %s of %s (last seen %s)", g.Outstanding, g.ProviderID, g.PoolID, g.GrantID, g.ChildID, g.LastSeen.Format(time.RFC3339))
+		reclaimed++
+	}
+	return reclaimed, nil
+}
+
+// reclaim closes the lease of g with the pool locked, unless it was renewed
+// or closed since it expired. It returns the lease as reclaimed.
+func (r *GrantReclaimer) reclaim(ctx context.Context, g ChildGrant, now time.Time, epoch int64) (ChildGrant, bool, error) {
+	defer r.ledger.LockPool(g.ProviderID, g.PoolID)()
+
+	g, ok := r.ledger.Get(g.ChildID, g.ProviderID, g.PoolID)
+	if !ok || !g.expired(now, r.after) {
+		return g, false, nil
+	}
+
+	causationID := g.GrantID
+	if causationID == "" {
+		causationID = store.SentinelUnknown
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"follower_id": g.ChildID,
+		"provider_id": g.ProviderID,
+		"pool_id":     g.PoolID,
+		"grant_id":    g.GrantID,
+		"amount":      g.Outstanding,
+		"last_seen":   g.LastSeen,
+	})
+	evt := store.Event{
+		EventID:       store.EventID(fmt.Sprintf("grant_reclaim_%s_%s_%s_%d", g.ChildID, g.ProviderID, g.PoolID, now.UnixNano())),
+		EventType:     store.EventTypeGrantReclaimed,
+		SchemaVersion: 1,
+		TsEvent:       now,
+		TsIngest:      now,
+		Epoch:         epoch,
+		Source: store.EventSource{
+			OriginKind: "daemon",
+			OriginID:   "grant_reclaimer",
+			WriterID:   "ratelord-d",
+		},
+		Dimensions: store.EventDimensions{
+			AgentID:    store.SentinelSystem,
+			IdentityID: g.ChildID,
+			WorkloadID: "federation",
+			ScopeID:    "global",
+		},
+		Correlation: store.EventCorrelation{
+			CorrelationID: fmt.Sprintf("grant_req_%s", g.ChildID),
+			CausationID:   causationID,
+		},
+		Payload: payload,
+	}
+	if err := r.store.AppendEvent(ctx, &evt); err != nil {
+		return g, false, fmt.Errorf("failed to append reclaim of %s: %w", g.ChildID, err)
+	}
+	r.ledger.Apply(evt)
+	return g, true, nil
+}
@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func grantIssued(t *testing.T, child string, amount, held, used int64, ts time.Time) store.Event {
+	t.Helper()
+	payload, err := json.Marshal(map[string]interface{}{
+		"follower_id": child,
+		"provider_id": "p1",
+		"pool_id":     "pool-a",
+		"amount":      amount,
+		"held":        held,
+		"used":        used,
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return store.Event{
+		EventID:   store.EventID("grant_" + child + "_" + ts.Format(time.RFC3339Nano)),
+		EventType: store.EventTypeGrantIssued,
+		TsEvent:   ts,
+		TsIngest:  ts,
+		Payload:   payload,
+	}
+}
+
+func TestGrantLedger_Apply(t *testing.T) {
+	ledger := NewGrantLedger()
+	t0 := time.Now()
+
+	if err := ledger.Apply(grantIssued(t, "node-1", 100, 0, 0, t0)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	g, ok := ledger.Get("node-1", "p1", "pool-a")
+	if !ok || g.Outstanding != 100 || g.Demand != 0 {
+		t.Fatalf("unexpected grant after first request: %+v", g)
+	}
+
+	// 10 seconds later the child has used 50 and holds the other 50
+	if err := ledger.Apply(grantIssued(t, "node-1", 100, 50, 50, t0.Add(10*time.Second))); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	g, _ = ledger.Get("node-1", "p1", "pool-a")
+	if g.Outstanding != 150 || g.Used != 50 || g.Demand != 5 {
+		t.Errorf("expected outstanding 150, used 50 and demand 5/s, got %+v", g)
+	}
+
+	// The next rate is smoothed with the previous estimate
+	ledger.Apply(grantIssued(t, "node-1", 0, 120, 80, t0.Add(20*time.Second)))
+	g, _ = ledger.Get("node-1", "p1", "pool-a")
+	if g.Demand != 4 {
+		t.Errorf("expected smoothed demand 4/s, got %v", g.Demand)
+	}
+
+	reclaim := grantIssued(t, "node-1", 120, 0, 0, t0.Add(30*time.Second))
+	reclaim.EventType = store.EventTypeGrantReclaimed
+	ledger.Apply(reclaim)
+	if _, ok := ledger.Get("node-1", "p1", "pool-a"); ok {
+		t.Error("grant should be dropped once reclaimed")
+	}
+}
+
+func TestGrantLedger_Leases(t *testing.T) {
+	ledger := NewGrantLedger()
+	t0 := time.Now()
+
+	lease := func(child, grantID string, amount int64, expiresAt time.Time) store.Event {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"follower_id": child,
+			"provider_id": "p1",
+			"pool_id":     "pool-a",
+			"grant_id":    grantID,
+			"amount":      amount,
+			"expires_at":  expiresAt,
+		})
+		return store.Event{EventID: store.EventID(grantID), EventType: store.EventTypeGrantIssued, TsEvent: t0, Payload: payload}
+	}
+	ledger.Apply(lease("node-1", "grant_1", 100, t0.Add(time.Minute)))
+	ledger.Apply(lease("node-2", "grant_2", 100, t0.Add(3*time.Minute)))
+	// Leases without an expiry fall back to the last request
+	ledger.Apply(grantIssued(t, "node-3", 100, 0, 0, t0))
+
+	expired := ledger.Expired(t0.Add(2*time.Minute), 90*time.Second)
+	if len(expired) != 2 || expired[0].GrantID != "grant_1" || expired[1].ChildID != "node-3" {
+		t.Fatalf("expected the leases of node-1 and node-3 to expire, got %+v", expired)
+	}
+
+	// Closing a lease that was replaced leaves the new one
+	closed := lease("node-2", "grant_0", 100, time.Time{})
+	closed.EventType = store.EventTypeGrantReturned
+	ledger.Apply(closed)
+	if _, ok := ledger.Get("node-2", "p1", "pool-a"); !ok {
+		t.Error("return of a replaced lease should not close the current one")
+	}
+	closed = lease("node-2", "grant_2", 100, time.Time{})
+	closed.EventType = store.EventTypeGrantReturned
+	ledger.Apply(closed)
+	if _, ok := ledger.Get("node-2", "p1", "pool-a"); ok {
+		t.Error("returned lease should be closed")
+	}
+}
+
+func TestGrantLedger_Size(t *testing.T) {
+	ledger := NewGrantLedger()
+	t0 := time.Now()
+
+	// Unmeasured children get what they ask for
+	if got := ledger.Size("node-1", "p1", "pool-a", 1000, 0, time.Minute); got != 1000 {
+		t.Errorf("expected 1000 for an unknown child, got %d", got)
+	}
+	ledger.Apply(grantIssued(t, "node-1", 1000, 0, 0, t0))
+	if got := ledger.Size("node-1", "p1", "pool-a", 1000, 0, time.Minute); got != 1000 {
+		t.Errorf("expected 1000 for an unmeasured child, got %d", got)
+	}
+
+	// 5/s over two TTLs of a minute is 600, of which 200 are held
+	ledger.Apply(grantIssued(t, "node-1", 0, 200, 50, t0.Add(10*time.Second)))
+	if got := ledger.Size("node-1", "p1", "pool-a", 1000, 200, time.Minute); got != 400 {
+		t.Errorf("expected 400, got %d", got)
+	}
+	if got := ledger.Size("node-1", "p1", "pool-a", 1000, 700, time.Minute); got != 0 {
+		t.Errorf("expected nothing for a child holding enough, got %d", got)
+	}
+
+	// An idle child still gets a tenth of its request
+	ledger.Apply(grantIssued(t, "node-2", 1000, 0, 0, t0))
+	ledger.Apply(grantIssued(t, "node-2", 0, 1000, 0, t0.Add(10*time.Second)))
+	if got := ledger.Size("node-2", "p1", "pool-a", 1000, 0, time.Minute); got != 100 {
+		t.Errorf("expected 100 for an idle child, got %d", got)
+	}
+}
+
+func TestGrantLedger_Share(t *testing.T) {
+	ledger := NewGrantLedger()
+	t0 := time.Now()
+
+	// Unknown children share equally
+	if got := ledger.Share("node-1", "p1", "pool-a", 1000, t0); got != 1000 {
+		t.Errorf("a lone child should get everything, got %d", got)
+	}
+	ledger.Apply(grantIssued(t, "node-1", 100, 0, 0, t0))
+	if got := ledger.Share("node-2", "p1", "pool-a", 1000, t0); got != 500 {
+		t.Errorf("expected an equal share of 500, got %d", got)
+	}
+
+	// node-1 consumes 30/s and node-2 10/s
+	t1 := t0.Add(10 * time.Second)
+	ledger.Apply(grantIssued(t, "node-2", 100, 0, 0, t0))
+	ledger.Apply(grantIssued(t, "node-1", 0, 0, 300, t1))
+	ledger.Apply(grantIssued(t, "node-2", 0, 0, 100, t1))
+
+	if got := ledger.Share("node-1", "p1", "pool-a", 1000, t0); got != 750 {
+		t.Errorf("expected node-1 to get 750, got %d", got)
+	}
+	if got := ledger.Share("node-2", "p1", "pool-a", 1000, t0); got != 250 {
+		t.Errorf("expected node-2 to get 250, got %d", got)
+	}
+	// A newcomer weighs as the average child
+	if got := ledger.Share("node-3", "p1", "pool-a", 1200, t0); got != 400 {
+		t.Errorf("expected node-3 to get 400, got %d", got)
+	}
+	// Children not seen since activeSince do not count
+	if got := ledger.Share("node-2", "p1", "pool-a", 1000, t1.Add(time.Second)); got != 1000 {
+		t.Errorf("expected node-2 to get everything once node-1 is inactive, got %d", got)
+	}
+}
+
+func TestGrantReclaimer_Reclaim(t *testing.T) {
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+
+	ledger := NewGrantLedger()
+	usage := NewUsageProjection()
+	usage.SetGrantLedger(ledger)
+	usage.LoadState("", time.Time{}, []PoolState{{ProviderID: "p1", PoolID: "pool-a", Remaining: 1000}})
+
+	t0 := time.Now()
+	ledger.Apply(grantIssued(t, "node-1", 300, 0, 0, t0))
+	ledger.Apply(grantIssued(t, "node-2", 200, 0, 0, t0.Add(2*time.Minute)))
+
+	var returned []ChildGrant
+	r := NewGrantReclaimer(st, ledger, time.Minute)
+	r.SetEpochFunc(func() int64 { return 3 })
+	r.SetOnReclaim(func(g ChildGrant) { returned = append(returned, g) })
+
+	n, err := r.Reclaim(context.Background(), t0.Add(150*time.Second))
+	if err != nil {
+		t.Fatalf("Reclaim failed: %v", err)
+	}
+	if n != 1 || len(returned) != 1 || returned[0].ChildID != "node-1" || returned[0].Outstanding != 300 {
+		t.Fatalf("expected the 300 of node-1 to be reclaimed, got %d: %+v", n, returned)
+	}
+
+	state, _ := usage.GetPoolState("p1", "pool-a")
+	if state.Available() != 800 || state.Granted != 200 || state.Remaining != 1000 {
+		t.Errorf("expected the pool to be credited back (800 available, 200 granted), got %+v", state)
+	}
+	if _, ok := ledger.Get("node-1", "p1", "pool-a"); ok {
+		t.Error("reclaimed grant should leave the ledger")
+	}
+	if _, ok := ledger.Get("node-2", "p1", "pool-a"); !ok {
+		t.Error("active grant should stay in the ledger")
+	}
+
+	events, err := st.ReadEvents(context.Background(), time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	if len(events) != 1 || events[0].EventType != store.EventTypeGrantReclaimed || events[0].Epoch != 3 {
+		t.Fatalf("expected one grant_reclaimed event at epoch 3, got %+v", events)
+	}
+
+	// A lease renewed after it was listed as expired is not reclaimed
+	stale := ledger.Expired(t0.Add(5*time.Minute), time.Minute)
+	if len(stale) != 1 || stale[0].ChildID != "node-2" {
+		t.Fatalf("expected the lease of node-2 to expire, got %+v", stale)
+	}
+	ledger.Apply(grantIssued(t, "node-2", 0, 200, 0, t0.Add(5*time.Minute)))
+	if _, ok, err := r.reclaim(context.Background(), stale[0], t0.Add(5*time.Minute), 3); err != nil || ok {
+		t.Fatalf("expected the renewed lease to be kept, got reclaimed=%v err=%v", ok, err)
+	}
+	if _, ok := ledger.Get("node-2", "p1", "pool-a"); !ok {
+		t.Error("renewed grant should stay in the ledger")
+	}
+
+	// Replaying the log yields the same ledger
+	replayed := NewGrantLedger()
+	replayed.Apply(grantIssued(t, "node-1", 300, 0, 0, t0))
+	replayed.Apply(*events[0])
+	if len(replayed.List()) != 0 {
+		t.Errorf("expected an empty ledger after replay, got %+v", replayed.List())
+	}
+}
+
+func TestUsageProjection_GrantsHeldAcrossObservations(t *testing.T) {
+	ledger := NewGrantLedger()
+	usage := NewUsageProjection()
+	usage.SetGrantLedger(ledger)
+	t0 := time.Now()
+	observe := func(used, remaining int64, ts time.Time) store.Event {
+		payload, _ := json.Marshal(map[string]interface{}{"provider_id": "p1", "pool_id": "pool-a", "used": used, "remaining": remaining})
+		return store.Event{EventID: store.EventID("obs_" + ts.Format(time.RFC3339Nano)), EventType: store.EventTypeUsageObserved, TsEvent: ts, TsIngest: ts, Payload: payload}
+	}
+	returned := func(amount, used int64, ts time.Time) store.Event {
+		payload, _ := json.Marshal(map[string]interface{}{"follower_id": "node-1", "provider_id": "p1", "pool_id": "pool-a", "amount": amount, "used": used})
+		return store.Event{EventID: store.EventID("return_" + ts.Format(time.RFC3339Nano)), EventType: store.EventTypeGrantReturned, TsEvent: ts, TsIngest: ts, Payload: payload}
+	}
+	apply := func(evts ...store.Event) {
+		t.Helper()
+		for _, evt := range evts {
+			if err := ledger.Apply(evt); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			if err := usage.Apply(evt); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+		}
+	}
+	available := func() int64 {
+		t.Helper()
+		state, _ := usage.GetPoolState("p1", "pool-a")
+		return state.Available()
+	}
+
+	apply(observe(0, 1000, t0), grantIssued(t, "node-1", 300, 0, 0, t0.Add(time.Second)))
+	if got := available(); got != 700 {
+		t.Fatalf("expected the grant of 300 held out, got %d available", got)
+	}
+
+	// The child reports spending 100: the provider has not counted it yet
+	apply(grantIssued(t, "node-1", 0, 200, 100, t0.Add(2*time.Second)))
+	if got := available(); got != 700 {
+		t.Errorf("expected the unobserved 100 to stay held, got %d available", got)
+	}
+
+	// The observation counts the 100; the child still holds 200
+	apply(observe(100, 900, t0.Add(3*time.Second)))
+	state, _ := usage.GetPoolState("p1", "pool-a")
+	if state.Remaining != 900 || state.Granted != 200 || state.Available() != 700 {
+		t.Errorf("expected 900 remaining with 200 held, got %+v", state)
+	}
+
+	// It spends 50 more and returns the rest
+	apply(returned(150, 150, t0.Add(4*time.Second)))
+	if got := available(); got != 850 {
+		t.Errorf("expected the 150 returned and the unobserved 50 held, got %d available", got)
+	}
+	apply(observe(150, 850, t0.Add(5*time.Second)))
+	if state, _ := usage.GetPoolState("p1", "pool-a"); state.Granted != 0 || state.Available() != 850 {
+		t.Errorf("expected nothing held once observed, got %+v", state)
+	}
+}
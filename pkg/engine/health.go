@@ -0,0 +1,88 @@
+package engine
+
+import "time"
+
+// Provider health states reported by /v1/health.
+const (
+	HealthHealthy  = "healthy"  // Polling succeeds and all pools are fresh
+	HealthDegraded = "degraded" // Recent poll failures or stale pools
+	HealthDown     = "down"     // Circuit breaker open
+)
+
+// PoolHealth reports the freshness of a single pool.
+type PoolHealth struct {
+	PoolID         string    `json:"pool_id"`
+	LastObservedAt time.Time `json:"last_observed_at,omitempty"`
+	StaleSeconds   float64   `json:"stale_seconds"`
+	Stale          bool      `json:"stale"`
+}
+
+// ProviderHealth summarizes a provider's polling state and pool freshness.
+type ProviderHealth struct {
+	ProviderID          string       `json:"provider_id"`
+	Status              string       `json:"status"`
+	DegradedMode        string       `json:"degraded_mode,omitempty"`
+	CircuitState        string       `json:"circuit_state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastSuccessAt       time.Time    `json:"last_success_at,omitempty"`
+	Pools               []PoolHealth `json:"pools"`
+}
+
+// Health reports per-provider health. Pool freshness requires a usage
+// projection (see SetUsageProjection); without one, pools are omitted.
+func (p *Poller) Health(now time.Time) []ProviderHealth {
+	statuses := p.Status()
+
+	p.mu.RLock()
+	cfg := p.policyCfg
+	p.mu.RUnlock()
+	staleAfter := cfg.GetStaleAfter()
+
+	pools := make(map[string][]PoolHealth)
+	if p.usage != nil {
+		_, _, states := p.usage.GetState()
+		for _, state := range states {
+			stale := state.StaleSeconds(now)
+			pools[state.ProviderID] = append(pools[state.ProviderID], PoolHealth{
+				PoolID:         state.PoolID,
+				LastObservedAt: state.LastObservedAt,
+				StaleSeconds:   stale,
+				Stale:          stale > staleAfter.Seconds(),
+			})
+		}
+	}
+
+	health := make([]ProviderHealth, 0, len(statuses))
+	for _, st := range statuses {
+		h := ProviderHealth{
+			ProviderID:          st.ProviderID,
+			Status:              HealthHealthy,
+			DegradedMode:        cfg.GetDegradedMode(st.ProviderID),
+			CircuitState:        st.CircuitState,
+			ConsecutiveFailures: st.ConsecutiveFailures,
+			LastError:           st.LastError,
+			LastSuccessAt:       st.LastSuccessAt,
+			Pools:               pools[st.ProviderID],
+		}
+		if h.Pools == nil {
+			h.Pools = []PoolHealth{}
+		}
+
+		switch {
+		case st.CircuitState == CircuitOpen:
+			h.Status = HealthDown
+		case st.ConsecutiveFailures > 0 || st.CircuitState == CircuitHalfOpen:
+			h.Status = HealthDegraded
+		default:
+			for _, pool := range h.Pools {
+				if pool.Stale {
+					h.Status = HealthDegraded
+					break
+				}
+			}
+		}
+		health = append(health, h)
+	}
+	return health
+}
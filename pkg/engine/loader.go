@@ -30,5 +30,8 @@ func LoadPolicyConfig(path string) (*PolicyConfig, error) {
 		}
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
@@ -121,6 +121,29 @@ func RunUsageStoreTests(t *testing.T, store UsageStore) {
 		}
 	})
 
+	t.Run("Field writes keep counters", func(t *testing.T) {
+		store.Clear()
+
+		store.Set(PoolState{ProviderID: "p", PoolID: "pool", Used: 10, Remaining: 90})
+		resetAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		store.SetResetAt("p", "pool", resetAt)
+		store.SetForecast("p", "pool", forecast.Forecast{TTE: forecast.TimeToExhaustion{P99Seconds: 60}})
+
+		retrieved, ok := store.Get("p", "pool")
+		if !ok || retrieved.Used != 10 || retrieved.Remaining != 90 {
+			t.Fatalf("expected the counters to be kept, got %+v", retrieved)
+		}
+		if !retrieved.ResetAt.Equal(resetAt) || retrieved.LatestForecast == nil || retrieved.LatestForecast.TTE.P99Seconds != 60 {
+			t.Errorf("expected the reset time and forecast to be set, got %+v", retrieved)
+		}
+
+		// A pool is created by its first write
+		store.SetResetAt("p", "new-pool", resetAt)
+		if retrieved, ok := store.Get("p", "new-pool"); !ok || !retrieved.ResetAt.Equal(resetAt) {
+			t.Errorf("expected the pool to be created, got %+v", retrieved)
+		}
+	})
+
 	t.Run("GetAll", func(t *testing.T) {
 		store.Clear()
 
@@ -40,6 +40,33 @@ var (
 		},
 		[]string{"provider_id", "pool_id"},
 	)
+
+	// RatelordRollupLateEventsDropped counts the events too late to be rolled up
+	RatelordRollupLateEventsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ratelord_rollup_late_events_dropped_total",
+			Help: "Events dropped by the rollup worker for arriving after the allowed lateness",
+		},
+		[]string{"event_type"},
+	)
+
+	// RatelordGrantsReclaimed counts the budget reclaimed from expired federation grant leases
+	RatelordGrantsReclaimed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ratelord_federation_grants_reclaimed_total",
+			Help: "Unused budget reclaimed from federation children whose grant lease expired",
+		},
+		[]string{"provider_id", "pool_id"},
+	)
+
+	// RatelordGrantsReturned counts the budget returned early by federation children
+	RatelordGrantsReturned = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ratelord_federation_grants_returned_total",
+			Help: "Unused budget returned by federation children before their lease expired",
+		},
+		[]string{"provider_id", "pool_id"},
+	)
 )
 
 func init() {
@@ -48,4 +75,7 @@ func init() {
 	prometheus.MustRegister(RatelordLimit)
 	prometheus.MustRegister(RatelordIntentTotal)
 	prometheus.MustRegister(RatelordForecastSeconds)
+	prometheus.MustRegister(RatelordRollupLateEventsDropped)
+	prometheus.MustRegister(RatelordGrantsReclaimed)
+	prometheus.MustRegister(RatelordGrantsReturned)
 }
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
@@ -114,10 +115,10 @@ func (pe *PolicyEngine) Evaluate(intent Intent) PolicyEvaluationResult {
 		return pe.evaluateLegacy(intent)
 	}
 
-	return pe.evaluateDynamic(intent, activeMap)
+	return pe.evaluateDynamic(intent, activePolicies, activeMap)
 }
 
-func (pe *PolicyEngine) evaluateDynamic(intent Intent, policyMap map[string]PolicyDefinition) PolicyEvaluationResult {
+func (pe *PolicyEngine) evaluateDynamic(intent Intent, cfg *PolicyConfig, policyMap map[string]PolicyDefinition) PolicyEvaluationResult {
 	// Identify relevant policies via Graph
 	var policiesToEvaluate []PolicyDefinition
 
@@ -151,6 +152,16 @@ func (pe *PolicyEngine) evaluateDynamic(intent Intent, policyMap map[string]Poli
 		poolState, exists = pe.usage.GetPoolState(intent.ProviderID, intent.PoolID)
 	}
 
+	// Degraded mode: the pool has not been observed recently
+	var warnings []string
+	if exists {
+		var denied *PolicyEvaluationResult
+		poolState, warnings, denied = applyDegraded(cfg, poolState, time.Now())
+		if denied != nil {
+			return *denied
+		}
+	}
+
 	var trace []RuleTrace
 	ruleIndex := 0
 
@@ -186,7 +197,9 @@ func (pe *PolicyEngine) evaluateDynamic(intent Intent, policyMap map[string]Poli
 			ruleIndex++
 
 			if result {
-				return pe.applyAction(rule.Action, rule.Params, poolState, trace)
+				res := pe.applyAction(rule.Action, rule.Params, poolState, trace)
+				res.Warnings = append(res.Warnings, warnings...)
+				return res
 			}
 		}
 	}
@@ -195,10 +208,51 @@ func (pe *PolicyEngine) evaluateDynamic(intent Intent, policyMap map[string]Poli
 	return PolicyEvaluationResult{
 		Decision: DecisionApprove,
 		Reason:   "policy:default_allow",
+		Warnings: warnings,
 		Trace:    trace,
 	}
 }
 
+// applyDegraded applies the configured degraded mode to a stale pool. It returns
+// the (possibly decayed) state, warnings to attach, or a denial for fail-closed.
+func applyDegraded(cfg *PolicyConfig, state PoolState, now time.Time) (PoolState, []string, *PolicyEvaluationResult) {
+	mode := cfg.GetDegradedMode(state.ProviderID)
+	stale := state.StaleSeconds(now)
+	if mode == "" || stale <= cfg.GetStaleAfter().Seconds() {
+		return state, nil, nil
+	}
+
+	warning := fmt.Sprintf("provider_degraded: pool %s stale for %.0fs", state.PoolID, stale)
+	deny := &PolicyEvaluationResult{
+		Decision: DecisionDenyWithReason,
+		Reason:   warning,
+	}
+
+	switch mode {
+	case DegradedFailClosed:
+		return state, nil, deny
+	case DegradedConservative:
+		if state.LatestForecast == nil {
+			// Nothing to extrapolate from; stay on the safe side
+			return state, nil, deny
+		}
+		// Assume consumption continued at the upper end of the last burn rate
+		br := state.LatestForecast.BurnRate
+		rate := br.Mean + 2*math.Sqrt(math.Max(br.Variance, 0))
+		if rate > 0 {
+			consumed := int64(math.Ceil(rate * stale))
+			state.Used += consumed
+			state.Remaining -= consumed
+			if state.Remaining < 0 {
+				state.Remaining = 0
+			}
+		}
+		return state, []string{warning + " (remaining decayed)"}, nil
+	default:
+		return state, []string{warning}, nil
+	}
+}
+
 func (pe *PolicyEngine) checkCondition(cond string, intent Intent, limit int64, poolState PoolState, exists bool) (bool, string) {
 	// Very basic DSL parser for M9.3 & M29.3
 	// Supported:
@@ -206,6 +260,7 @@ func (pe *PolicyEngine) checkCondition(cond string, intent Intent, limit int64,
 	// - "cost > X"
 	// - "forecast_tte < X"
 	// - "provider_id == X"
+	// - "stale_seconds > X"
 
 	// 1. Check provider_id (independent of pool state)
 	var pid string
@@ -233,9 +288,9 @@ func (pe *PolicyEngine) checkCondition(cond string, intent Intent, limit int64,
 
 	var remaining int64
 	if limit > 0 {
-		remaining = limit - poolState.Used
+		remaining = limit - poolState.Used - poolState.Granted
 	} else {
-		remaining = poolState.Remaining
+		remaining = poolState.Available()
 	}
 
 	var threshold int64
@@ -256,6 +311,16 @@ func (pe *PolicyEngine) checkCondition(cond string, intent Intent, limit int64,
 		return false, fmt.Sprintf("failed: cost %d <= %d", poolState.Cost, costThreshold)
 	}
 
+	// Try to parse "stale_seconds > 120" (age of the last observation)
+	var staleThreshold float64
+	if n, err := fmt.Sscanf(cond, "stale_seconds > %f", &staleThreshold); err == nil && n == 1 {
+		stale := poolState.StaleSeconds(time.Now())
+		if stale > staleThreshold {
+			return true, fmt.Sprintf("passed: stale_seconds %.0f > %.0f", stale, staleThreshold)
+		}
+		return false, fmt.Sprintf("failed: stale_seconds %.0f <= %.0f", stale, staleThreshold)
+	}
+
 	// Try to parse "forecast_tte < 3600" (seconds)
 	var tteThreshold float64
 	if n, err := fmt.Sscanf(cond, "forecast_tte < %f", &tteThreshold); err == nil && n == 1 {
@@ -368,10 +433,10 @@ func (pe *PolicyEngine) evaluateLegacy(intent Intent) PolicyEvaluationResult {
 			// Basic check: do we have enough remaining?
 			// Note: This is a simplistic check. Real policy would use forecasts.
 			// But M5.2 focuses on wiring.
-			if poolState.Remaining < intent.ExpectedCost {
+			if poolState.Available() < intent.ExpectedCost {
 				return PolicyEvaluationResult{
 					Decision: DecisionDenyWithReason,
-					Reason:   fmt.Sprintf("insufficient_budget: remaining %d < cost %d", poolState.Remaining, intent.ExpectedCost),
+					Reason:   fmt.Sprintf("insufficient_budget: remaining %d < cost %d", poolState.Available(), intent.ExpectedCost),
 				}
 			}
 		}
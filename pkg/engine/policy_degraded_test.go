@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
+	"github.com/rmax-ai/ratelord/pkg/graph"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// setupStalePool returns a policy engine whose pool p1/pool1 was last observed
+// age ago with 100 remaining, and a policy denying when remaining < 50.
+func setupStalePool(t *testing.T, age time.Duration, degraded *DegradedConfig) (*PolicyEngine, *UsageProjection) {
+	t.Helper()
+	usage := NewUsageProjection()
+	pe := NewPolicyEngine(usage, graph.NewProjection())
+	pe.UpdatePolicies(&PolicyConfig{
+		Policies: []PolicyDefinition{{
+			ID:    "low_budget",
+			Scope: "global",
+			Type:  "hard",
+			Rules: []RuleDefinition{{Condition: "remaining < 50", Action: "deny"}},
+		}},
+		Degraded: degraded,
+	})
+
+	usage.Apply(store.Event{
+		EventType: store.EventTypeUsageObserved,
+		TsEvent:   time.Now().Add(-age),
+		Payload:   []byte(`{"provider_id":"p1","pool_id":"pool1","used":100,"remaining":100}`),
+	})
+	return pe, usage
+}
+
+var staleIntent = Intent{IntentID: "i1", ScopeID: "global", ProviderID: "p1", PoolID: "pool1"}
+
+func TestDegraded_NotConfigured(t *testing.T) {
+	pe, _ := setupStalePool(t, time.Hour, nil)
+	res := pe.Evaluate(staleIntent)
+	if res.Decision != DecisionApprove || len(res.Warnings) != 0 {
+		t.Errorf("Expected plain approval without degraded config, got %+v", res)
+	}
+}
+
+func TestDegraded_FreshPool(t *testing.T) {
+	pe, _ := setupStalePool(t, time.Second, &DegradedConfig{Mode: DegradedFailClosed, StaleAfter: "1m"})
+	if res := pe.Evaluate(staleIntent); res.Decision != DecisionApprove {
+		t.Errorf("Expected approval for fresh pool, got %+v", res)
+	}
+}
+
+func TestDegraded_FailOpen(t *testing.T) {
+	pe, _ := setupStalePool(t, 5*time.Minute, &DegradedConfig{StaleAfter: "1m"})
+	res := pe.Evaluate(staleIntent)
+	if res.Decision != DecisionApprove {
+		t.Fatalf("Expected approval, got %+v", res)
+	}
+	if len(res.Warnings) != 1 || !strings.HasPrefix(res.Warnings[0], "provider_degraded") {
+		t.Errorf("Expected degraded warning, got %v", res.Warnings)
+	}
+}
+
+func TestDegraded_FailClosed(t *testing.T) {
+	pe, _ := setupStalePool(t, 5*time.Minute, &DegradedConfig{
+		Mode:       DegradedFailOpen,
+		StaleAfter: "1m",
+		ByProvider: map[string]string{"p1": DegradedFailClosed},
+	})
+	res := pe.Evaluate(staleIntent)
+	if res.Decision != DecisionDenyWithReason || !strings.HasPrefix(res.Reason, "provider_degraded") {
+		t.Errorf("Expected degraded denial, got %+v", res)
+	}
+}
+
+func TestDegraded_Conservative(t *testing.T) {
+	pe, usage := setupStalePool(t, 5*time.Minute, &DegradedConfig{Mode: DegradedConservative, StaleAfter: "1m"})
+
+	// Without a forecast there is nothing to extrapolate from
+	if res := pe.Evaluate(staleIntent); res.Decision != DecisionDenyWithReason {
+		t.Errorf("Expected denial without forecast, got %+v", res)
+	}
+
+	// 0.1 units/s over ~300s consumes ~30 of the 100 remaining: still above 50
+	usage.SetLatestForecast("p1", "pool1", forecast.Forecast{BurnRate: forecast.BurnRate{Mean: 0.1}})
+	res := pe.Evaluate(staleIntent)
+	if res.Decision != DecisionApprove || len(res.Warnings) != 1 {
+		t.Errorf("Expected approval with warning, got %+v", res)
+	}
+
+	// 0.5 units/s decays remaining to 0, tripping the remaining < 50 rule
+	usage.SetLatestForecast("p1", "pool1", forecast.Forecast{BurnRate: forecast.BurnRate{Mean: 0.5}})
+	if res := pe.Evaluate(staleIntent); res.Decision != DecisionDenyWithReason {
+		t.Errorf("Expected denial after decay, got %+v", res)
+	}
+}
+
+func TestCondition_StaleSeconds(t *testing.T) {
+	usage := NewUsageProjection()
+	pe := NewPolicyEngine(usage, graph.NewProjection())
+	pe.UpdatePolicies(&PolicyConfig{
+		Policies: []PolicyDefinition{{
+			ID:    "stale_guard",
+			Scope: "global",
+			Rules: []RuleDefinition{{Condition: "stale_seconds > 120", Action: "deny"}},
+		}},
+	})
+
+	usage.Apply(store.Event{
+		EventType: store.EventTypeUsageObserved,
+		TsEvent:   time.Now().Add(-10 * time.Second),
+		Payload:   []byte(`{"provider_id":"p1","pool_id":"pool1","used":1,"remaining":99}`),
+	})
+	if res := pe.Evaluate(staleIntent); res.Decision != DecisionApprove {
+		t.Errorf("Expected approval for fresh pool, got %+v", res)
+	}
+
+	usage.Apply(store.Event{
+		EventType: store.EventTypeUsageObserved,
+		TsEvent:   time.Now().Add(-10 * time.Minute),
+		Payload:   []byte(`{"provider_id":"p1","pool_id":"pool1","used":1,"remaining":99}`),
+	})
+	if res := pe.Evaluate(staleIntent); res.Decision != DecisionDenyWithReason {
+		t.Errorf("Expected denial for stale pool, got %+v", res)
+	}
+}
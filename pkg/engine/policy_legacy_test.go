@@ -2,6 +2,8 @@ package engine
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -84,3 +86,22 @@ func TestLoadPolicyConfig(t *testing.T) {
 		t.Errorf("Expected ID pol-1, got %s", config.Policies[0].ID)
 	}
 }
+
+func TestLoadPolicyConfig_AzureWithoutModels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	configJSON := `{
+		"providers": {
+			"openai": [
+				{"id": "azure", "azure_url_template": "https://myres.openai.azure.com/openai/deployments/{deployment}/chat/completions"}
+			]
+		}
+	}`
+	if err := os.WriteFile(path, []byte(configJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadPolicyConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "azure_url_template requires models") {
+		t.Errorf("Expected error for Azure template without models, got %v", err)
+	}
+}
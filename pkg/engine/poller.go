@@ -3,8 +3,10 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -13,25 +15,66 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
-// Poller manages the polling loop for registered providers
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// ProviderPollStatus describes the scheduling and circuit-breaker state of a provider.
+type ProviderPollStatus struct {
+	ProviderID          string    `json:"provider_id"`
+	Interval            string    `json:"interval"`      // Current (adaptive or backoff) delay
+	BaseInterval        string    `json:"base_interval"` // Configured interval
+	CircuitState        string    `json:"circuit_state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastPollAt          time.Time `json:"last_poll_at,omitempty"`
+	LastSuccessAt       time.Time `json:"last_success_at,omitempty"`
+	NextPollAt          time.Time `json:"next_poll_at,omitempty"`
+}
+
+// pollSchedule tracks per-provider scheduling state
+type pollSchedule struct {
+	interval      time.Duration
+	failures      int
+	idleStreak    int
+	circuit       string
+	lastError     string
+	lastPollAt    time.Time
+	lastSuccessAt time.Time
+	nextPollAt    time.Time
+	lastUsed      map[string]int64 // pool_id -> used at last successful poll
+}
+
+// Poller manages the polling loop for registered providers.
+// Each provider runs on its own goroutine and schedule, so a slow or failing
+// provider does not delay the others.
 type Poller struct {
-	store      *store.Store
+	store      store.EventStore
 	providers  []provider.Provider
 	interval   time.Duration
 	forecaster *forecast.Forecaster
 	policyCfg  *PolicyConfig
 	mu         sync.RWMutex
 	epochFunc  func() int64
+	schedules  map[provider.ProviderID]*pollSchedule
+	usage      *UsageProjection // Optional: updated as observations are recorded
+	onFenced   func()
+	cancel     context.CancelFunc // stops the running Start, if any
 }
 
-// NewPoller creates a new poller instance
-func NewPoller(store *store.Store, interval time.Duration, forecaster *forecast.Forecaster, policyCfg *PolicyConfig) *Poller {
+// NewPoller creates a new poller instance.
+// interval is the default poll interval, used unless overridden in ProvidersConfig.Polling.
+func NewPoller(store store.EventStore, interval time.Duration, forecaster *forecast.Forecaster, policyCfg *PolicyConfig) *Poller {
 	return &Poller{
 		store:      store,
 		providers:  make([]provider.Provider, 0),
 		interval:   interval,
 		forecaster: forecaster,
 		policyCfg:  policyCfg,
+		schedules:  make(map[provider.ProviderID]*pollSchedule),
 	}
 }
 
@@ -40,6 +83,18 @@ func (p *Poller) SetEpochFunc(f func() int64) {
 	p.epochFunc = f
 }
 
+// SetOnFenced sets the function called when the store rejects a write of
+// the poller because a newer leader took over. The poller stops first.
+func (p *Poller) SetOnFenced(f func()) {
+	p.onFenced = f
+}
+
+// SetUsageProjection makes the poller apply its observations to the live
+// usage projection, so policy decisions and pool staleness see fresh data.
+func (p *Poller) SetUsageProjection(usage *UsageProjection) {
+	p.usage = usage
+}
+
 // getEpoch returns the current epoch or 0 if not configured.
 func (p *Poller) getEpoch() int64 {
 	if p.epochFunc != nil {
@@ -53,6 +108,29 @@ func (p *Poller) UpdateConfig(cfg *PolicyConfig) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.policyCfg = cfg
+	for _, prov := range p.providers {
+		p.applyDefaultPricing(prov)
+	}
+}
+
+// applyDefaultPricing merges a provider's list prices into the policy pricing
+// table. Caller must hold p.mu.
+func (p *Poller) applyDefaultPricing(prov provider.Provider) {
+	pricer, ok := prov.(provider.PricingDefaulter)
+	if !ok || p.policyCfg == nil {
+		return
+	}
+	p.policyCfg.ApplyDefaultPricing(string(prov.ID()), pricer.DefaultPricing())
+}
+
+// CostAndUnit returns the configured cost per unit (micro-USD) and unit name for a pool.
+func (p *Poller) CostAndUnit(providerID, poolID string) (int64, string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.policyCfg == nil {
+		return 0, "requests"
+	}
+	return p.policyCfg.GetCost(providerID, poolID), p.policyCfg.GetUnit(providerID)
 }
 
 // GetProvider returns a registered provider by ID (helper for testing/debugging)
@@ -91,42 +169,276 @@ func (p *Poller) RestoreProviders(stateLookup func(id provider.ProviderID) []byt
 func (p *Poller) Register(prov provider.Provider) {
 	p.mu.Lock()
 	p.providers = append(p.providers, prov)
+	p.schedules[prov.ID()] = &pollSchedule{circuit: CircuitClosed}
+	if _, ok := prov.(provider.PricingDefaulter); ok && p.policyCfg == nil {
+		p.policyCfg = &PolicyConfig{}
+	}
+	p.applyDefaultPricing(prov)
 	p.mu.Unlock()
 }
 
-// Start begins the polling loop in a background goroutine
+// Start runs one polling loop per registered provider and blocks until ctx is cancelled.
 func (p *Poller) Start(ctx context.Context) {
-	ticker := time.NewTicker(p.interval)
-	defer ticker.Stop()
+	p.mu.RLock()
+	providers := make([]provider.Provider, len(p.providers))
+	copy(providers, p.providers)
+	p.mu.RUnlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.mu.Lock()
+	p.cancel = cancel
+	p.mu.Unlock()
 
 	log.Println("Poller started")
 
+	var wg sync.WaitGroup
+	for _, prov := range providers {
+		wg.Add(1)
+		go func(prov provider.Provider) {
+			defer wg.Done()
+			p.run(ctx, prov)
+		}(prov)
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	p.mu.Lock()
+	p.cancel = nil
+	p.mu.Unlock()
+	log.Println("Poller stopping due to context cancellation")
+}
+
+// fenced reports whether err rejects a write as coming from a deposed
+// leader. If so, the running Start is stopped and onFenced called, once.
+func (p *Poller) fenced(err error) bool {
+	if !errors.Is(err, store.ErrStaleEpoch) {
+		return false
+	}
+	p.mu.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mu.Unlock()
+	if cancel != nil {
+		log.Printf("Poller stopping, writes fenced by a newer leader: %v", err)
+		cancel()
+		if p.onFenced != nil {
+			p.onFenced()
+		}
+	}
+	return true
+}
+
+// run polls a single provider on its own schedule until ctx is cancelled.
+func (p *Poller) run(ctx context.Context, prov provider.Provider) {
+	delay := p.baseInterval(prov.ID())
+	p.setNextPoll(prov.ID(), delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Poller stopping due to context cancellation")
 			return
-		case <-ticker.C:
-			p.pollAll(ctx)
+		case <-timer.C:
+			p.beginTrial(prov.ID())
+			result, err := p.poll(ctx, prov)
+			delay = p.reschedule(prov.ID(), result, err)
+			timer.Reset(delay)
 		}
 	}
 }
 
-// pollAll polls all registered providers
-func (p *Poller) pollAll(ctx context.Context) {
+// baseInterval returns the configured interval for a provider.
+func (p *Poller) baseInterval(id provider.ProviderID) time.Duration {
 	p.mu.RLock()
-	providers := make([]provider.Provider, len(p.providers))
-	copy(providers, p.providers)
-	p.mu.RUnlock()
+	defer p.mu.RUnlock()
+	return p.policyCfg.GetPollInterval(string(id), p.interval)
+}
 
-	for _, prov := range providers {
-		p.poll(ctx, prov)
+func (p *Poller) setNextPoll(id provider.ProviderID, delay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sched, ok := p.schedules[id]; ok {
+		sched.interval = delay
+		sched.nextPollAt = time.Now().Add(delay)
+	}
+}
+
+// beginTrial moves an open circuit to half-open once its cool-down has elapsed,
+// allowing a single trial poll.
+func (p *Poller) beginTrial(id provider.ProviderID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sched, ok := p.schedules[id]; ok && sched.circuit == CircuitOpen {
+		sched.circuit = CircuitHalfOpen
+	}
+}
+
+// reschedule updates the provider's schedule after a poll and returns the delay until the next one.
+// On error it backs off exponentially with jitter and opens the circuit after repeated failures.
+// On success it adapts: faster when a forecast predicts exhaustion soon, slower when usage is idle.
+func (p *Poller) reschedule(id provider.ProviderID, result provider.PollResult, pollErr error) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	base := p.policyCfg.GetPollInterval(string(id), p.interval)
+	minInterval, maxInterval, maxBackoff, threshold := p.policyCfg.GetPollBounds()
+	if minInterval > base {
+		minInterval = base
+	}
+	if maxInterval < base {
+		maxInterval = base
+	}
+	if maxBackoff < base {
+		maxBackoff = base
+	}
+
+	sched, ok := p.schedules[id]
+	if !ok {
+		sched = &pollSchedule{circuit: CircuitClosed}
+		p.schedules[id] = sched
+	}
+
+	now := time.Now()
+	sched.lastPollAt = now
+	var delay time.Duration
+
+	if pollErr != nil {
+		sched.failures++
+		sched.idleStreak = 0
+		sched.lastError = pollErr.Error()
+
+		if sched.circuit == CircuitHalfOpen || sched.failures >= threshold {
+			if sched.circuit != CircuitOpen {
+				log.Printf("Circuit opened for provider %s after %d consecutive failures", id, sched.failures)
+			}
+			sched.circuit = CircuitOpen
+			delay = maxBackoff
+		} else {
+			delay = backoffWithJitter(base, sched.failures, maxBackoff)
+		}
+	} else {
+		if sched.circuit != CircuitClosed {
+			log.Printf("Circuit closed for provider %s", id)
+		}
+		sched.failures = 0
+		sched.circuit = CircuitClosed
+		sched.lastError = ""
+		sched.lastSuccessAt = now
+		delay = p.adaptiveInterval(sched, result, base, minInterval, maxInterval)
+	}
+
+	if result.RetryAfter > delay {
+		delay = result.RetryAfter
+	}
+
+	sched.interval = delay
+	sched.nextPollAt = now.Add(delay)
+	return delay
+}
+
+// adaptiveInterval computes the next interval after a successful poll.
+// Caller must hold p.mu.
+func (p *Poller) adaptiveInterval(sched *pollSchedule, result provider.PollResult, base, minInterval, maxInterval time.Duration) time.Duration {
+	// Idle detection: no pool changed since the previous poll
+	changed := sched.lastUsed == nil
+	used := make(map[string]int64, len(result.Usage))
+	for _, obs := range result.Usage {
+		used[obs.PoolID] = obs.Used
+		if prev, ok := sched.lastUsed[obs.PoolID]; !ok || prev != obs.Used {
+			changed = true
+		}
+	}
+	sched.lastUsed = used
+
+	// Urgency: the soonest predicted exhaustion among this provider's pools
+	minTTE := time.Duration(-1)
+	if p.forecaster != nil {
+		for _, obs := range result.Usage {
+			fc, ok := p.forecaster.Latest(string(result.ProviderID), obs.PoolID)
+			if !ok || fc.TTE.P90Seconds < 0 {
+				continue
+			}
+			tte := time.Duration(fc.TTE.P90Seconds) * time.Second
+			if tte/time.Second != time.Duration(fc.TTE.P90Seconds) {
+				continue // overflow: no exhaustion predicted
+			}
+			if minTTE < 0 || tte < minTTE {
+				minTTE = tte
+			}
+		}
+	}
+
+	interval := base
+	if minTTE >= 0 && minTTE < 10*base {
+		// Sample at least ~10 times before predicted exhaustion
+		sched.idleStreak = 0
+		interval = minTTE / 10
+	} else if !changed && len(result.Usage) > 0 {
+		sched.idleStreak++
+		interval = base << uint(min(sched.idleStreak, 16))
+	} else {
+		sched.idleStreak = 0
 	}
+
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+// backoffWithJitter returns base*2^failures capped at max, with "equal jitter"
+// (half fixed, half random) to avoid synchronized retries.
+func backoffWithJitter(base time.Duration, failures int, max time.Duration) time.Duration {
+	d := base << uint(min(failures, 16))
+	if d <= 0 || d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Status returns the scheduling and circuit-breaker state of every registered provider.
+func (p *Poller) Status() []ProviderPollStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	statuses := make([]ProviderPollStatus, 0, len(p.providers))
+	for _, prov := range p.providers {
+		sched := p.schedules[prov.ID()]
+		if sched == nil {
+			continue
+		}
+		statuses = append(statuses, ProviderPollStatus{
+			ProviderID:          string(prov.ID()),
+			Interval:            sched.interval.String(),
+			BaseInterval:        p.policyCfg.GetPollInterval(string(prov.ID()), p.interval).String(),
+			CircuitState:        sched.circuit,
+			ConsecutiveFailures: sched.failures,
+			LastError:           sched.lastError,
+			LastPollAt:          sched.lastPollAt,
+			LastSuccessAt:       sched.lastSuccessAt,
+			NextPollAt:          sched.nextPollAt,
+		})
+	}
+	return statuses
 }
 
-// poll performs a single poll on a provider and emits events
-func (p *Poller) poll(ctx context.Context, prov provider.Provider) {
+// poll performs a single poll on a provider and emits events.
+// It returns an error if the provider failed (either an error or a result with status "error").
+func (p *Poller) poll(ctx context.Context, prov provider.Provider) (provider.PollResult, error) {
 	result, err := prov.Poll(ctx)
+	if err == nil && result.Status == "error" {
+		err = result.Error
+		if err == nil {
+			err = errors.New("provider returned error status")
+		}
+	}
 	if err != nil {
 		log.Printf("Poll failed for provider %s: %v", prov.ID(), err)
 
@@ -155,16 +467,21 @@ func (p *Poller) poll(ctx context.Context, prov provider.Provider) {
 			},
 		}
 
-		payload, _ := json.Marshal(map[string]interface{}{
+		errPayload := map[string]interface{}{
 			"provider_id": string(prov.ID()),
 			"error":       err.Error(),
-		})
+		}
+		if result.RetryAfter > 0 {
+			// Secondary limit signal (e.g. 403/429 with Retry-After)
+			errPayload["retry_after_seconds"] = int64(result.RetryAfter / time.Second)
+		}
+		payload, _ := json.Marshal(errPayload)
 		errorEvent.Payload = payload
 
-		if err := p.store.AppendEvent(ctx, errorEvent); err != nil {
+		if err := p.store.AppendEvent(ctx, errorEvent); err != nil && !p.fenced(err) {
 			log.Printf("Failed to append error event: %v", err)
 		}
-		return
+		return result, err
 	}
 
 	now := time.Now().UTC()
@@ -207,8 +524,10 @@ func (p *Poller) poll(ctx context.Context, prov provider.Provider) {
 	pollEvent.Payload = payloadBytes
 
 	if err := p.store.AppendEvent(ctx, pollEvent); err != nil {
-		log.Printf("Failed to append poll event: %v", err)
-		return
+		if !p.fenced(err) {
+			log.Printf("Failed to append poll event: %v", err)
+		}
+		return result, nil
 	}
 
 	// Emit usage_observed events for each observation
@@ -237,16 +556,8 @@ func (p *Poller) poll(ctx context.Context, prov provider.Provider) {
 			},
 		}
 
-		units := "requests"
-		var costPerUnit int64
-
 		// Calculate cost and units if policy config is available
-		p.mu.RLock()
-		if p.policyCfg != nil {
-			costPerUnit = p.policyCfg.GetCost(string(result.ProviderID), obs.PoolID)
-			units = p.policyCfg.GetUnit(string(result.ProviderID))
-		}
-		p.mu.RUnlock()
+		costPerUnit, units := p.CostAndUnit(string(result.ProviderID), obs.PoolID)
 
 		usagePayload := map[string]interface{}{
 			"provider_id": string(result.ProviderID),
@@ -259,15 +570,34 @@ func (p *Poller) poll(ctx context.Context, prov provider.Provider) {
 		if costPerUnit > 0 {
 			usagePayload["cost"] = obs.Used * costPerUnit
 		}
+		if !obs.ResetAt.IsZero() {
+			usagePayload["reset_at"] = obs.ResetAt
+		}
 
 		payloadBytes, _ := json.Marshal(usagePayload)
 		usageEvent.Payload = payloadBytes
 
 		if err := p.store.AppendEvent(ctx, usageEvent); err != nil {
+			if p.fenced(err) {
+				return result, nil
+			}
 			log.Printf("Failed to append usage event: %v", err)
-		} else if p.forecaster != nil {
-			// Trigger forecast computation
-			p.forecaster.OnUsageObserved(ctx, usageEvent)
+		} else {
+			if p.usage != nil {
+				// Keep the live projection (and pool staleness) current
+				if err := p.usage.Apply(*usageEvent); err != nil {
+					log.Printf("Failed to apply usage event: %v", err)
+				}
+			}
+			if p.forecaster != nil {
+				// Trigger forecast computation
+				p.forecaster.OnUsageObserved(ctx, usageEvent)
+				if fc, ok := p.forecaster.Latest(string(result.ProviderID), obs.PoolID); ok && p.usage != nil {
+					p.usage.SetLatestForecast(string(result.ProviderID), obs.PoolID, fc)
+				}
+			}
 		}
 	}
+
+	return result, nil
 }
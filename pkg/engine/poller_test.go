@@ -2,6 +2,10 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -129,40 +133,54 @@ func TestPoller_RestoreProviders(t *testing.T) {
 	}
 }
 
-func TestPoller_PollAll(t *testing.T) {
+func TestPoller_PerProviderLoops(t *testing.T) {
 	st, _ := store.NewStore(":memory:")
 	defer st.Close()
 
-	poller := NewPoller(st, time.Hour, nil, nil)
-
-	mock1 := &MockProvider{
-		IDVal: "p1",
-		PollResult: provider.PollResult{
-			ProviderID: "p1",
-			Usage:      []provider.UsageObservation{},
-		},
-	}
-	// We can't easily hook into Poll execution without modifying MockProvider to track calls
-	// So let's rely on events or modify MockProvider.
-	// But MockProvider.Poll just returns result.
-	// Let's check events.
-
-	poller.Register(mock1)
-
-	mock2 := &MockProvider{
-		IDVal: "p2",
-		PollResult: provider.PollResult{
-			ProviderID: "p2",
-			Usage:      []provider.UsageObservation{},
-		},
+	poller := NewPoller(st, 1*time.Millisecond, nil, nil)
+	for _, id := range []provider.ProviderID{"p1", "p2"} {
+		poller.Register(&MockProvider{
+			IDVal: id,
+			PollResult: provider.PollResult{
+				ProviderID: id,
+				Usage:      []provider.UsageObservation{},
+			},
+		})
 	}
-	poller.Register(mock2)
 
-	poller.pollAll(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		poller.Start(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
 
-	events, _ := st.ReadRecentEvents(context.Background(), 10)
-	if len(events) != 2 {
-		t.Errorf("Expected 2 poll events, got %d", len(events))
+	// Each provider is polled by its own loop
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		polled := map[string]bool{}
+		events, _ := st.ReadRecentEvents(context.Background(), 100)
+		for _, e := range events {
+			if e.EventType != store.EventTypeProviderPollObserved {
+				continue
+			}
+			for _, id := range []string{"p1", "p2"} {
+				if strings.HasPrefix(string(e.EventID), "poll_"+id+"_") {
+					polled[id] = true
+				}
+			}
+		}
+		if polled["p1"] && polled["p2"] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected poll events from both providers, got %v", polled)
+		}
+		time.Sleep(5 * time.Millisecond)
 	}
 }
 
@@ -189,8 +207,19 @@ func TestPoller_Start(t *testing.T) {
 		close(done)
 	}()
 
-	// Wait for at least one tick
-	time.Sleep(10 * time.Millisecond)
+	// Wait for at least one event (poll observed)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events, _ := st.ReadRecentEvents(context.Background(), 10)
+		if len(events) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("Expected events generated by background poller")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
 	cancel()
 
 	select {
@@ -199,12 +228,6 @@ func TestPoller_Start(t *testing.T) {
 	case <-time.After(1 * time.Second):
 		t.Fatal("Poller did not stop")
 	}
-
-	// Verify at least one event (poll observed)
-	events, _ := st.ReadRecentEvents(context.Background(), 10)
-	if len(events) == 0 {
-		t.Error("Expected events generated by background poller")
-	}
 }
 
 func TestPoller_ConfigAndHelpers(t *testing.T) {
@@ -237,3 +260,229 @@ func TestPoller_ConfigAndHelpers(t *testing.T) {
 		t.Error("GetProvider should return nil for missing provider")
 	}
 }
+
+func TestPoller_BackoffAndCircuit(t *testing.T) {
+	st, _ := store.NewStore(":memory:")
+	defer st.Close()
+
+	cfg := &PolicyConfig{Providers: ProvidersConfig{Polling: &PollingConfig{
+		Interval:         "1s",
+		MaxBackoff:       "1m",
+		FailureThreshold: 3,
+	}}}
+	poller := NewPoller(st, time.Hour, nil, cfg)
+
+	failing := &MockProvider{IDVal: "p1", PollErr: errors.New("boom")}
+	poller.Register(failing)
+
+	// Backoff grows with each consecutive failure (equal jitter: [d/2, d])
+	var delays []time.Duration
+	for i := 0; i < 2; i++ {
+		result, err := poller.poll(context.Background(), failing)
+		if err == nil {
+			t.Fatal("Expected poll error")
+		}
+		delays = append(delays, poller.reschedule("p1", result, err))
+	}
+	if delays[0] < 1*time.Second || delays[0] > 2*time.Second {
+		t.Errorf("First backoff %v outside [1s, 2s]", delays[0])
+	}
+	if delays[1] < 2*time.Second || delays[1] > 4*time.Second {
+		t.Errorf("Second backoff %v outside [2s, 4s]", delays[1])
+	}
+
+	// Third failure reaches the threshold and opens the circuit
+	result, err := poller.poll(context.Background(), failing)
+	if d := poller.reschedule("p1", result, err); d != time.Minute {
+		t.Errorf("Expected cool-down of max backoff (1m), got %v", d)
+	}
+	status := poller.Status()
+	if len(status) != 1 || status[0].CircuitState != CircuitOpen || status[0].ConsecutiveFailures != 3 {
+		t.Fatalf("Expected open circuit after 3 failures, got %+v", status)
+	}
+
+	// Trial poll succeeds: circuit closes
+	poller.beginTrial("p1")
+	if s := poller.Status()[0].CircuitState; s != CircuitHalfOpen {
+		t.Errorf("Expected half_open before trial, got %s", s)
+	}
+	failing.PollErr = nil
+	failing.PollResult = provider.PollResult{ProviderID: "p1", Status: "success"}
+	result, err = poller.poll(context.Background(), failing)
+	if d := poller.reschedule("p1", result, err); d != time.Second {
+		t.Errorf("Expected base interval after recovery, got %v", d)
+	}
+	if s := poller.Status()[0]; s.CircuitState != CircuitClosed || s.ConsecutiveFailures != 0 {
+		t.Errorf("Expected closed circuit after success, got %+v", s)
+	}
+
+	// Error status results count as failures and emit provider_error
+	failing.PollResult = provider.PollResult{ProviderID: "p1", Status: "error", Error: errors.New("HTTP 500")}
+	if _, err := poller.poll(context.Background(), failing); err == nil {
+		t.Error("Expected error for result with status error")
+	}
+	events, _ := st.QueryEvents(context.Background(), store.EventFilter{EventTypes: []store.EventType{store.EventTypeProviderError}})
+	if len(events) != 4 {
+		t.Errorf("Expected 4 provider_error events, got %d", len(events))
+	}
+
+	// RetryAfter is honoured
+	if d := poller.reschedule("p1", provider.PollResult{RetryAfter: 30 * time.Second}, nil); d != 30*time.Second {
+		t.Errorf("Expected RetryAfter delay of 30s, got %v", d)
+	}
+}
+
+func TestPoller_AdaptiveInterval(t *testing.T) {
+	st, _ := store.NewStore(":memory:")
+	defer st.Close()
+
+	cfg := &PolicyConfig{Providers: ProvidersConfig{Polling: &PollingConfig{
+		ByProvider:  map[string]string{"p1": "10s"},
+		MinInterval: "2s",
+		MaxInterval: "1m",
+	}}}
+	fore := forecast.NewForecaster(st, forecast.NewForecastProjection(10), &forecast.LinearModel{}, nil)
+	poller := NewPoller(st, time.Hour, fore, cfg)
+	poller.Register(&MockProvider{IDVal: "p1"})
+
+	result := provider.PollResult{
+		ProviderID: "p1",
+		Usage:      []provider.UsageObservation{{PoolID: "pool-1", Used: 10, Remaining: 90}},
+	}
+
+	// First observation: base interval
+	if d := poller.reschedule("p1", result, nil); d != 10*time.Second {
+		t.Errorf("Expected base interval 10s, got %v", d)
+	}
+	// Unchanged usage: slows down
+	if d := poller.reschedule("p1", result, nil); d != 20*time.Second {
+		t.Errorf("Expected idle interval 20s, got %v", d)
+	}
+	// Capped at max interval
+	for i := 0; i < 5; i++ {
+		poller.reschedule("p1", result, nil)
+	}
+	if d := poller.reschedule("p1", result, nil); d != time.Minute {
+		t.Errorf("Expected max interval 1m, got %v", d)
+	}
+
+	// Forecast predicts exhaustion soon: speeds up, bounded by min interval
+	now := time.Now()
+	for i, used := range []int64{10, 50, 90} {
+		evt := &store.Event{
+			EventID:   store.EventID(fmt.Sprintf("u%d", i)),
+			EventType: store.EventTypeUsageObserved,
+			TsEvent:   now.Add(time.Duration(i) * time.Second),
+			Payload:   json.RawMessage(fmt.Sprintf(`{"provider_id":"p1","pool_id":"pool-1","used":%d,"remaining":%d}`, used, 100-used)),
+		}
+		fore.OnUsageObserved(context.Background(), evt)
+	}
+	if _, ok := fore.Latest("p1", "pool-1"); !ok {
+		t.Fatal("Expected forecast for pool-1")
+	}
+	result.Usage[0].Used = 90
+	if d := poller.reschedule("p1", result, nil); d != 2*time.Second {
+		t.Errorf("Expected min interval 2s when exhaustion is near, got %v", d)
+	}
+}
+
+// pricedProvider is a MockProvider that publishes list prices.
+type pricedProvider struct {
+	MockProvider
+}
+
+func (p *pricedProvider) DefaultPricing() map[string]int64 {
+	return map[string]int64{"llm:output_tokens": 15, "llm:input_tokens": 3}
+}
+
+func TestPoller_DefaultPricing(t *testing.T) {
+	st, _ := store.NewStore(":memory:")
+	defer st.Close()
+
+	// Explicit pricing wins over provider defaults
+	cfg := &PolicyConfig{Pricing: map[string]map[string]int64{"llm": {"llm:input_tokens": 2}}}
+	poller := NewPoller(st, time.Hour, nil, cfg)
+
+	prov := &pricedProvider{MockProvider{
+		IDVal: "llm",
+		PollResult: provider.PollResult{
+			ProviderID: "llm",
+			Timestamp:  time.Now(),
+			Status:     "success",
+			Usage: []provider.UsageObservation{
+				{PoolID: "llm:input_tokens", Used: 1000, Remaining: 9000},
+				{PoolID: "llm:output_tokens", Used: 100, Remaining: 900},
+			},
+		},
+	}}
+	poller.Register(prov)
+	poller.poll(context.Background(), prov)
+
+	usage := NewUsageProjection()
+	events, _ := st.ReadEvents(context.Background(), time.Time{}, 100)
+	for _, evt := range events {
+		usage.Apply(*evt)
+	}
+
+	input, _ := usage.GetPoolState("llm", "llm:input_tokens")
+	if input.Cost != 2000 {
+		t.Errorf("Expected configured input cost 2000, got %d", input.Cost)
+	}
+	output, _ := usage.GetPoolState("llm", "llm:output_tokens")
+	if output.Cost != 1500 {
+		t.Errorf("Expected default output cost 1500, got %d", output.Cost)
+	}
+
+	// Defaults survive a config reload
+	poller.UpdateConfig(&PolicyConfig{})
+	if cost, _ := poller.CostAndUnit("llm", "llm:output_tokens"); cost != 15 {
+		t.Errorf("Expected default pricing after reload, got %d", cost)
+	}
+}
+
+func TestPoller_Health(t *testing.T) {
+	st, _ := store.NewStore(":memory:")
+	defer st.Close()
+
+	cfg := &PolicyConfig{Degraded: &DegradedConfig{StaleAfter: "1m", Mode: DegradedConservative}}
+	poller := NewPoller(st, time.Hour, nil, cfg)
+	usage := NewUsageProjection()
+	poller.SetUsageProjection(usage)
+
+	ok := &MockProvider{IDVal: "ok", PollResult: provider.PollResult{
+		ProviderID: "ok", Status: "success", Timestamp: time.Now(),
+		Usage: []provider.UsageObservation{{PoolID: "ok:pool", Used: 1, Remaining: 9}},
+	}}
+	failing := &MockProvider{IDVal: "failing", PollErr: errors.New("boom")}
+	poller.Register(ok)
+	poller.Register(failing)
+
+	res, err := poller.poll(context.Background(), ok)
+	poller.reschedule(ok.ID(), res, err)
+	res, err = poller.poll(context.Background(), failing)
+	poller.reschedule(failing.ID(), res, err)
+
+	// Observations are applied to the live projection
+	if _, found := usage.GetPoolState("ok", "ok:pool"); !found {
+		t.Fatal("Expected poller to update the usage projection")
+	}
+
+	byID := map[string]ProviderHealth{}
+	for _, h := range poller.Health(time.Now()) {
+		byID[h.ProviderID] = h
+	}
+	if byID["ok"].Status != HealthHealthy || len(byID["ok"].Pools) != 1 || byID["ok"].DegradedMode != DegradedConservative {
+		t.Errorf("Unexpected health for ok: %+v", byID["ok"])
+	}
+	if byID["failing"].Status != HealthDegraded {
+		t.Errorf("Expected failing provider to be degraded, got %+v", byID["failing"])
+	}
+
+	// The healthy provider's pool goes stale without new observations
+	later := byID["ok"].Pools[0].LastObservedAt.Add(2 * time.Minute)
+	for _, h := range poller.Health(later) {
+		if h.ProviderID == "ok" && (h.Status != HealthDegraded || !h.Pools[0].Stale) {
+			t.Errorf("Expected stale pool to degrade provider, got %+v", h)
+		}
+	}
+}
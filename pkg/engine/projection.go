@@ -97,6 +97,11 @@ func (p *IdentityProjection) Replay(events []*store.Event) error {
 	return nil
 }
 
+// Reset drops all identities
+func (p *IdentityProjection) Reset() {
+	p.LoadState("", time.Time{}, nil)
+}
+
 // GetAll returns a list of all identities
 func (p *IdentityProjection) GetAll() []Identity {
 	p.mu.RLock()
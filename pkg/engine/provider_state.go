@@ -19,13 +19,13 @@ func NewProviderProjection() *ProviderProjection {
 	}
 }
 
-func (p *ProviderProjection) Apply(event store.Event) {
+func (p *ProviderProjection) Apply(event store.Event) error {
 	if event.EventType == store.EventTypeProviderPollObserved {
 		var payload map[string]interface{}
 		json.Unmarshal(event.Payload, &payload)
 		providerID, ok := payload["provider_id"].(string)
 		if !ok {
-			return
+			return nil
 		}
 		stateStr, ok := payload["state"].(string)
 		if ok {
@@ -35,6 +35,7 @@ func (p *ProviderProjection) Apply(event store.Event) {
 			p.mu.Unlock()
 		}
 	}
+	return nil
 }
 
 func (p *ProviderProjection) Replay(events []*store.Event) {
@@ -43,6 +44,13 @@ func (p *ProviderProjection) Replay(events []*store.Event) {
 	}
 }
 
+// Reset drops all provider states.
+func (p *ProviderProjection) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states = make(map[string][]byte)
+}
+
 func (p *ProviderProjection) GetState(providerID provider.ProviderID) []byte {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -10,12 +10,12 @@ import (
 )
 
 type PruneWorker struct {
-	store  *store.Store
+	store  store.EventStore
 	config *RetentionConfig
 	mu     sync.RWMutex
 }
 
-func NewPruneWorker(st *store.Store, cfg *RetentionConfig) *PruneWorker {
+func NewPruneWorker(st store.EventStore, cfg *RetentionConfig) *PruneWorker {
 	return &PruneWorker{
 		store:  st,
 		config: cfg,
@@ -0,0 +1,305 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// Projection is an in-memory read model folded from the event log.
+type Projection interface {
+	// Apply folds one event into the projection. Events arrive in log order.
+	Apply(event store.Event) error
+	// Reset drops all state, as before the first event.
+	Reset()
+}
+
+// Names of the projections registered by the daemon.
+const (
+	ProjectionIdentity = "identity"
+	ProjectionUsage    = "usage"
+	ProjectionProvider = "provider"
+	ProjectionCluster  = "cluster"
+	ProjectionGraph    = "graph"
+	ProjectionForecast = "forecast"
+	ProjectionGrants   = "grants"
+)
+
+// SnapshotProjections are the projections restored by LoadLatestSnapshot.
+var SnapshotProjections = []string{ProjectionIdentity, ProjectionUsage, ProjectionProvider, ProjectionForecast}
+
+// DefaultReplayBatchSize is the number of events read per query by Replay
+// and Rebuild.
+const DefaultReplayBatchSize = 1000
+
+var (
+	// ErrUnknownProjection is returned for a name that was not registered.
+	ErrUnknownProjection = errors.New("unknown projection")
+	// ErrRebuildInProgress is returned when a projection is already being
+	// rebuilt.
+	ErrRebuildInProgress = errors.New("rebuild already in progress")
+)
+
+// EventSource is the part of the event store read by replays.
+type EventSource interface {
+	QueryEvents(ctx context.Context, filter store.EventFilter) ([]*store.Event, error)
+}
+
+// ProjectionCheckpoint is the position in the log up to which the registry
+// has applied events to a projection. The zero value means none.
+type ProjectionCheckpoint struct {
+	EventID  store.EventID `json:"event_id,omitempty"`
+	TsIngest time.Time     `json:"ts_ingest,omitempty"`
+}
+
+// IsZero reports whether no event was applied yet.
+func (c ProjectionCheckpoint) IsZero() bool {
+	return c.EventID == ""
+}
+
+func (c ProjectionCheckpoint) cursor() *store.EventCursor {
+	if c.IsZero() {
+		return nil
+	}
+	return &store.EventCursor{TsIngest: c.TsIngest, EventID: c.EventID}
+}
+
+// ProjectionStatus describes a registered projection.
+type ProjectionStatus struct {
+	Name        string               `json:"name"`
+	Checkpoint  ProjectionCheckpoint `json:"checkpoint"`
+	Rebuilding  bool                 `json:"rebuilding"`
+	LastRebuild *RebuildResult       `json:"last_rebuild,omitempty"`
+}
+
+// RebuildResult is the outcome of a finished rebuild.
+type RebuildResult struct {
+	EventsRead int       `json:"events_read"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+type registeredProjection struct {
+	name        string
+	projection  Projection
+	checkpoint  ProjectionCheckpoint
+	rebuilding  bool
+	lastRebuild *RebuildResult
+}
+
+// ProjectionRegistry holds the projections of the daemon and the checkpoint
+// of each. Replays resume every projection from its own checkpoint, so a
+// projection restored from a snapshot skips the events the snapshot covers
+// while the others are built from the start of the log.
+type ProjectionRegistry struct {
+	mu          sync.Mutex
+	projections []*registeredProjection
+}
+
+// NewProjectionRegistry creates an empty registry.
+func NewProjectionRegistry() *ProjectionRegistry {
+	return &ProjectionRegistry{}
+}
+
+// Register adds a projection under name. Names must be unique.
+func (r *ProjectionRegistry) Register(name string, p Projection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rp := range r.projections {
+		if rp.name == name {
+			panic(fmt.Sprintf("projection %s registered twice", name))
+		}
+	}
+	r.projections = append(r.projections, &registeredProjection{name: name, projection: p})
+}
+
+// Get returns the projection registered under name.
+func (r *ProjectionRegistry) Get(name string) (Projection, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rp := r.lookup(name); rp != nil {
+		return rp.projection, true
+	}
+	return nil, false
+}
+
+// Status lists the registered projections, sorted by name.
+func (r *ProjectionRegistry) Status() []ProjectionStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]ProjectionStatus, 0, len(r.projections))
+	for _, rp := range r.projections {
+		list = append(list, ProjectionStatus{Name: rp.name, Checkpoint: rp.checkpoint, Rebuilding: rp.rebuilding, LastRebuild: rp.lastRebuild})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Checkpoint returns the checkpoint of the projection registered under name.
+func (r *ProjectionRegistry) Checkpoint(name string) (ProjectionCheckpoint, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rp := r.lookup(name); rp != nil {
+		return rp.checkpoint, true
+	}
+	return ProjectionCheckpoint{}, false
+}
+
+// SetCheckpoint records that the named projection already reflects every
+// event up to and including evt, e.g. after loading it from a snapshot.
+func (r *ProjectionRegistry) SetCheckpoint(name string, evt *store.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp := r.lookup(name)
+	if rp == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownProjection, name)
+	}
+	rp.checkpoint = ProjectionCheckpoint{EventID: evt.EventID, TsIngest: evt.TsIngest}
+	return nil
+}
+
+// Replay applies the events after each projection's checkpoint, reading
+// src in batches of batchSize (DefaultReplayBatchSize if zero) so that the
+// log never has to fit in memory. It returns the number of events read.
+func (r *ProjectionRegistry) Replay(ctx context.Context, src EventSource, batchSize int) (int, error) {
+	r.mu.Lock()
+	targets := make([]*registeredProjection, 0, len(r.projections))
+	for _, rp := range r.projections {
+		if !rp.rebuilding {
+			targets = append(targets, rp)
+		}
+	}
+	r.mu.Unlock()
+	return r.replay(ctx, src, targets, batchSize)
+}
+
+// Rebuild resets the named projection and replays the whole log from src
+// into it, leaving the others untouched. Events applied live while the
+// rebuild runs are applied again when the replay reaches them; projections
+// keep the latest state per key, so the result converges to the head of the
+// log. It returns the number of events read.
+func (r *ProjectionRegistry) Rebuild(ctx context.Context, src EventSource, name string, batchSize int) (int, error) {
+	rp, err := r.beginRebuild(name)
+	if err != nil {
+		return 0, err
+	}
+	return r.runRebuild(ctx, src, rp, batchSize)
+}
+
+// StartRebuild is Rebuild in the background. It returns once the projection
+// is reset; Status reports progress and the outcome.
+func (r *ProjectionRegistry) StartRebuild(src EventSource, name string, batchSize int) error {
+	rp, err := r.beginRebuild(name)
+	if err != nil {
+		return err
+	}
+	go func() {
+		n, err := r.runRebuild(context.Background(), src, rp, batchSize)
+		if err != nil {
+			fmt.Printf(`{"level":"error","msg":"projection_rebuild_failed","projection":"%s","events_read":%d,"error":"%v"}`+"\n", name, n, err)
+			return
+		}
+		fmt.Printf(`{"level":"info","msg":"projection_rebuilt","projection":"%s","events_read":%d}`+"\n", name, n)
+	}()
+	return nil
+}
+
+func (r *ProjectionRegistry) beginRebuild(name string) (*registeredProjection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp := r.lookup(name)
+	if rp == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProjection, name)
+	}
+	if rp.rebuilding {
+		return nil, fmt.Errorf("%w: %s", ErrRebuildInProgress, name)
+	}
+	rp.rebuilding = true
+	rp.checkpoint = ProjectionCheckpoint{}
+	rp.projection.Reset()
+	return rp, nil
+}
+
+func (r *ProjectionRegistry) runRebuild(ctx context.Context, src EventSource, rp *registeredProjection, batchSize int) (int, error) {
+	n, err := r.replay(ctx, src, []*registeredProjection{rp}, batchSize)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp.rebuilding = false
+	rp.lastRebuild = &RebuildResult{EventsRead: n, FinishedAt: time.Now().UTC()}
+	if err != nil {
+		rp.lastRebuild.Error = err.Error()
+	}
+	return n, err
+}
+
+func (r *ProjectionRegistry) replay(ctx context.Context, src EventSource, targets []*registeredProjection, batchSize int) (int, error) {
+	if len(targets) == 0 {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultReplayBatchSize
+	}
+
+	// Start from the oldest checkpoint; projections further ahead skip
+	// events until they reach their own
+	r.mu.Lock()
+	start := targets[0].checkpoint
+	for _, rp := range targets[1:] {
+		if c := rp.checkpoint; c.IsZero() || (!start.IsZero() && c.cursor().Less(*start.cursor())) {
+			start = c
+		}
+	}
+	r.mu.Unlock()
+
+	// Through an ArchiveStore, rebuilds from scratch include archived events
+	filter := store.EventFilter{After: start.cursor(), Limit: batchSize, IncludeArchived: true}
+	read := 0
+	for {
+		page, err := src.QueryEvents(ctx, filter)
+		if err != nil {
+			return read, fmt.Errorf("failed to read events: %w", err)
+		}
+		for _, evt := range page {
+			if err := r.apply(targets, evt); err != nil {
+				return read, err
+			}
+			read++
+		}
+		if len(page) < batchSize {
+			return read, nil
+		}
+		filter.After = store.CursorAt(page[len(page)-1])
+	}
+}
+
+// apply folds evt into the targets whose checkpoint precedes it.
+func (r *ProjectionRegistry) apply(targets []*registeredProjection, evt *store.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pos := store.CursorAt(evt)
+	for _, rp := range targets {
+		if c := rp.checkpoint.cursor(); c != nil && !c.Less(*pos) {
+			continue
+		}
+		if err := rp.projection.Apply(*evt); err != nil {
+			return fmt.Errorf("projection %s failed on event %s: %w", rp.name, evt.EventID, err)
+		}
+		rp.checkpoint = ProjectionCheckpoint{EventID: evt.EventID, TsIngest: evt.TsIngest}
+	}
+	return nil
+}
+
+func (r *ProjectionRegistry) lookup(name string) *registeredProjection {
+	for _, rp := range r.projections {
+		if rp.name == name {
+			return rp
+		}
+	}
+	return nil
+}
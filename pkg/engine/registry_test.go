@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// countingSource records the filters of the queries made by a replay.
+type countingSource struct {
+	store.EventStore
+	queries []store.EventFilter
+}
+
+func (c *countingSource) QueryEvents(ctx context.Context, filter store.EventFilter) ([]*store.Event, error) {
+	c.queries = append(c.queries, filter)
+	return c.EventStore.QueryEvents(ctx, filter)
+}
+
+// recordingProjection records the IDs of the events applied to it.
+type recordingProjection struct {
+	applied []store.EventID
+	fail    store.EventID
+}
+
+func (p *recordingProjection) Apply(event store.Event) error {
+	if event.EventID == p.fail {
+		return errors.New("boom")
+	}
+	p.applied = append(p.applied, event.EventID)
+	return nil
+}
+
+func (p *recordingProjection) Reset() { p.applied = nil }
+
+func seedRegistryStore(t *testing.T, n int) (store.EventStore, []*store.Event) {
+	t.Helper()
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var events []*store.Event
+	for i := 0; i < n; i++ {
+		evt := &store.Event{
+			EventID:    store.EventID(fmt.Sprintf("evt_%02d", i)),
+			EventType:  store.EventTypeIdentityRegistered,
+			TsEvent:    base.Add(time.Duration(i) * time.Second),
+			TsIngest:   base.Add(time.Duration(i) * time.Second),
+			Dimensions: store.EventDimensions{IdentityID: fmt.Sprintf("u%02d", i)},
+			Payload:    json.RawMessage(`{"kind":"user"}`),
+		}
+		if err := st.AppendEvent(context.Background(), evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		events = append(events, evt)
+	}
+	return st, events
+}
+
+func TestProjectionRegistry_ReplayFromCheckpoints(t *testing.T) {
+	st, events := seedRegistryStore(t, 10)
+	src := &countingSource{EventStore: st}
+
+	fromSnapshot, fromStart := &recordingProjection{}, &recordingProjection{}
+	r := NewProjectionRegistry()
+	r.Register("snap", fromSnapshot)
+	r.Register("full", fromStart)
+	if err := r.SetCheckpoint("snap", events[5]); err != nil {
+		t.Fatalf("SetCheckpoint failed: %v", err)
+	}
+
+	n, err := r.Replay(context.Background(), src, 3)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if n != 10 || len(src.queries) != 4 {
+		t.Errorf("expected 10 events in 4 batches, read %d in %d", n, len(src.queries))
+	}
+	if got := fmt.Sprint(fromSnapshot.applied); got != "[evt_06 evt_07 evt_08 evt_09]" {
+		t.Errorf("expected the snapshot projection to skip covered events, got %s", got)
+	}
+	if len(fromStart.applied) != 10 {
+		t.Errorf("expected the other projection to see every event, got %v", fromStart.applied)
+	}
+	for _, name := range []string{"snap", "full"} {
+		if c, _ := r.Checkpoint(name); c.EventID != "evt_09" {
+			t.Errorf("expected %s at evt_09, got %+v", name, c)
+		}
+	}
+
+	// Caught up: a second replay starts after the checkpoints and applies nothing
+	src.queries = nil
+	if n, err := r.Replay(context.Background(), src, 3); err != nil || n != 0 {
+		t.Errorf("expected an empty replay, got %d (err %v)", n, err)
+	}
+	if len(src.queries) != 1 || src.queries[0].After == nil || src.queries[0].After.EventID != "evt_09" {
+		t.Errorf("expected one query after evt_09, got %+v", src.queries)
+	}
+}
+
+func TestProjectionRegistry_Rebuild(t *testing.T) {
+	st, _ := seedRegistryStore(t, 5)
+	ctx := context.Background()
+
+	ids, other := NewIdentityProjection(), &recordingProjection{}
+	r := NewProjectionRegistry()
+	r.Register(ProjectionIdentity, ids)
+	r.Register("other", other)
+	if _, err := r.Replay(ctx, st, 0); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	// State that is not in the log disappears, the other projection is untouched
+	ids.Apply(store.Event{EventType: store.EventTypeIdentityRegistered, Dimensions: store.EventDimensions{IdentityID: "stray"}, Payload: json.RawMessage(`{}`)})
+	n, err := r.Rebuild(ctx, st, ProjectionIdentity, 2)
+	if err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	if n != 5 || len(ids.GetAll()) != 5 {
+		t.Errorf("expected 5 identities from 5 events, got %d from %d", len(ids.GetAll()), n)
+	}
+	if len(other.applied) != 5 {
+		t.Errorf("expected the other projection to be untouched, got %v", other.applied)
+	}
+	status := r.Status()
+	if status[0].Name != ProjectionIdentity || status[0].LastRebuild == nil || status[0].LastRebuild.EventsRead != 5 || status[0].Rebuilding {
+		t.Errorf("unexpected status %+v", status[0])
+	}
+
+	if _, err := r.Rebuild(ctx, st, "nope", 0); !errors.Is(err, ErrUnknownProjection) {
+		t.Errorf("expected ErrUnknownProjection, got %v", err)
+	}
+
+	// A failing rebuild is reported and releases the projection
+	other.fail = "evt_03"
+	if _, err := r.Rebuild(ctx, st, "other", 0); err == nil {
+		t.Error("expected the rebuild to fail")
+	}
+	if status := r.Status(); status[1].Rebuilding || status[1].LastRebuild == nil || status[1].LastRebuild.Error == "" || status[1].Checkpoint.EventID != "evt_02" {
+		t.Errorf("unexpected status after a failure %+v", status[1])
+	}
+}
+
+// blockingSource holds the first query until released.
+type blockingSource struct {
+	store.EventStore
+	release chan struct{}
+}
+
+func (b *blockingSource) QueryEvents(ctx context.Context, filter store.EventFilter) ([]*store.Event, error) {
+	<-b.release
+	return b.EventStore.QueryEvents(ctx, filter)
+}
+
+func TestProjectionRegistry_StartRebuild(t *testing.T) {
+	st, _ := seedRegistryStore(t, 3)
+	src := &blockingSource{EventStore: st, release: make(chan struct{})}
+
+	p := &recordingProjection{}
+	r := NewProjectionRegistry()
+	r.Register("p", p)
+
+	if err := r.StartRebuild(src, "p", 0); err != nil {
+		t.Fatalf("StartRebuild failed: %v", err)
+	}
+	if err := r.StartRebuild(src, "p", 0); !errors.Is(err, ErrRebuildInProgress) {
+		t.Errorf("expected ErrRebuildInProgress, got %v", err)
+	}
+	// Replays leave a rebuilding projection alone
+	if n, err := r.Replay(context.Background(), st, 0); err != nil || n != 0 {
+		t.Errorf("expected Replay to skip the rebuilding projection, got %d (err %v)", n, err)
+	}
+	close(src.release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Status()[0].Rebuilding {
+		if time.Now().After(deadline) {
+			t.Fatal("rebuild did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status := r.Status()[0]; status.LastRebuild == nil || status.LastRebuild.EventsRead != 3 || status.Checkpoint.EventID != "evt_02" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// restorePageSize bounds the events read per query while replaying.
+const restorePageSize = 1000
+
+// PointInTimeRestore is the result of RestoreAt.
+type PointInTimeRestore struct {
+	// Export holds the restored state as a snapshot taken at the requested
+	// instant, with the last replayed event as its checkpoint. Import it
+	// with store.ImportSnapshot or write it with store.PutSnapshotExport.
+	Export *store.SnapshotExport
+	// Base is the ID of the snapshot the replay started from, empty if it
+	// started from the beginning of the log.
+	Base string
+	// BaseSource is "store" or "blob".
+	BaseSource string
+	// Replayed is the number of events applied on top of the base.
+	Replayed int
+}
+
+// RestoreAt rebuilds the projection state as of at: it loads the newest
+// snapshot taken at or before at, from st or from the snapshots exported to
+// blobs, and replays the events ingested after its checkpoint up to and
+// including at. Events are read from st, and from the archive in blobs when
+// it is set. Either st or blobs may be nil, e.g. when the database is lost
+// and only the blob store remains.
+func RestoreAt(ctx context.Context, st store.EventStore, blobs blob.BlobStore, at time.Time) (*PointInTimeRestore, error) {
+	if st == nil && blobs == nil {
+		return nil, fmt.Errorf("restore needs a store or a blob store")
+	}
+	res := &PointInTimeRestore{}
+
+	base, err := nearestSnapshot(ctx, st, blobs, at, res)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := NewIdentityProjection()
+	usage := NewUsageProjection()
+	providers := NewProviderProjection()
+	forecasts := forecast.NewForecastProjection(20)
+
+	filter := store.EventFilter{Limit: restorePageSize, IncludeArchived: true}
+	var checkpoint *store.Event
+	if base != nil {
+		if err := applySnapshot(base.Snapshot, base.Checkpoint.TsIngest, ids, usage, providers, forecasts); err != nil {
+			return nil, err
+		}
+		checkpoint = base.Checkpoint
+		filter.After = store.CursorAt(base.Checkpoint)
+	}
+
+	var source interface {
+		QueryEvents(ctx context.Context, filter store.EventFilter) ([]*store.Event, error)
+	}
+	switch {
+	case st != nil && blobs != nil:
+		source = store.NewArchiveStore(st, blobs)
+	case st != nil:
+		source = st
+	default:
+		source = store.NewArchive(blobs)
+	}
+
+	for {
+		page, err := source.QueryEvents(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+		done := len(page) < filter.Limit
+		for _, evt := range page {
+			if evt.TsIngest.After(at) {
+				done = true
+				break
+			}
+			// Tombstones change no state and make a poor checkpoint
+			if evt.EventType == store.EventTypeEventsTombstoned {
+				continue
+			}
+			if err := ids.Apply(*evt); err != nil {
+				return nil, fmt.Errorf("failed to replay %s: %w", evt.EventID, err)
+			}
+			if err := usage.Apply(*evt); err != nil {
+				return nil, fmt.Errorf("failed to replay %s: %w", evt.EventID, err)
+			}
+			providers.Apply(*evt)
+			if evt.EventType == store.EventTypeUsageObserved {
+				forecasts.OnUsageObserved(evt)
+			}
+			checkpoint = evt
+			res.Replayed++
+		}
+		if done || len(page) == 0 {
+			break
+		}
+		filter.After = store.CursorAt(page[len(page)-1])
+	}
+
+	if checkpoint == nil {
+		return nil, fmt.Errorf("no snapshot or event at or before %s", at.Format(time.RFC3339))
+	}
+	snap, err := buildSnapshot(ids, usage, providers, forecasts, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	snap.SnapshotID = fmt.Sprintf("snap_restore_%d", at.UnixNano())
+	res.Export = &store.SnapshotExport{Snapshot: snap, Checkpoint: checkpoint}
+	return res, nil
+}
+
+// nearestSnapshot returns the newest snapshot taken at or before at, from
+// st or blobs, or nil if there is none.
+func nearestSnapshot(ctx context.Context, st store.EventStore, blobs blob.BlobStore, at time.Time, res *PointInTimeRestore) (*store.SnapshotExport, error) {
+	var fromStore *store.Snapshot
+	if st != nil {
+		snaps, err := st.ListSnapshots(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, snap := range snaps {
+			if !snap.TsSnapshot.After(at) {
+				fromStore = snap
+				break
+			}
+		}
+	}
+	var fromBlob *store.ExportedSnapshot
+	if blobs != nil {
+		exported, err := store.ListExportedSnapshots(ctx, blobs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range exported {
+			if !exported[i].TsSnapshot.After(at) {
+				fromBlob = &exported[i]
+				break
+			}
+		}
+	}
+
+	switch {
+	case fromBlob != nil && (fromStore == nil || fromBlob.TsSnapshot.After(fromStore.TsSnapshot)):
+		exp, err := store.GetSnapshotExport(ctx, blobs, fromBlob.Key)
+		if err != nil {
+			return nil, err
+		}
+		res.Base, res.BaseSource = exp.Snapshot.SnapshotID, "blob"
+		return exp, nil
+
+	case fromStore != nil:
+		snap, err := st.GetSnapshot(ctx, fromStore.SnapshotID)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint, err := st.GetEvent(ctx, snap.LastEventID)
+		if err != nil {
+			return nil, err
+		}
+		if checkpoint == nil {
+			return nil, fmt.Errorf("checkpoint event %s of snapshot %s not found", snap.LastEventID, snap.SnapshotID)
+		}
+		res.Base, res.BaseSource = snap.SnapshotID, "store"
+		return &store.SnapshotExport{Snapshot: snap, Checkpoint: checkpoint}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func identityIDs(p *IdentityProjection) []string {
+	var ids []string
+	for _, id := range p.GetAll() {
+		ids = append(ids, id.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func restoredIdentities(t *testing.T, exp *store.SnapshotExport) []string {
+	t.Helper()
+	var payload SnapshotPayload
+	if err := json.Unmarshal(exp.Snapshot.Payload, &payload); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	ids := NewIdentityProjection()
+	ids.LoadState("", time.Time{}, payload.Identities)
+	return identityIDs(ids)
+}
+
+func TestRestoreAt(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+
+	// One identity registered per hour
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var events []*store.Event
+	for i := 0; i < 6; i++ {
+		ts := base.Add(time.Duration(i) * time.Hour)
+		evt := &store.Event{
+			EventID:    store.EventID(fmt.Sprintf("evt_%d", i)),
+			EventType:  store.EventTypeIdentityRegistered,
+			TsEvent:    ts,
+			TsIngest:   ts,
+			Dimensions: store.EventDimensions{IdentityID: fmt.Sprintf("u%d", i)},
+			Payload:    json.RawMessage(`{"kind":"user"}`),
+		}
+		if err := st.AppendEvent(ctx, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		events = append(events, evt)
+	}
+
+	// A snapshot after the third event, holding an identity that only it knows
+	ids, usage := NewIdentityProjection(), NewUsageProjection()
+	ids.Replay(events[:3])
+	usage.Replay(events[:3])
+	ids.Apply(store.Event{EventID: "evt_2", TsIngest: events[2].TsIngest, EventType: store.EventTypeIdentityRegistered,
+		Dimensions: store.EventDimensions{IdentityID: "snap-only"}, Payload: json.RawMessage(`{}`)})
+	snap, err := buildSnapshot(ids, usage, NewProviderProjection(), forecast.NewForecastProjection(20), base.Add(150*time.Minute))
+	if err != nil {
+		t.Fatalf("buildSnapshot failed: %v", err)
+	}
+	if err := st.SaveSnapshot(ctx, snap); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	// After the snapshot: its state plus the events up to the instant
+	res, err := RestoreAt(ctx, st, nil, base.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("RestoreAt failed: %v", err)
+	}
+	if res.Base != snap.SnapshotID || res.BaseSource != "store" || res.Replayed != 2 || res.Export.Checkpoint.EventID != "evt_4" {
+		t.Errorf("unexpected restore %+v", res)
+	}
+	if got := fmt.Sprint(restoredIdentities(t, res.Export)); got != "[snap-only u0 u1 u2 u3 u4]" {
+		t.Errorf("unexpected identities %s", got)
+	}
+	if !res.Export.Snapshot.TsSnapshot.Equal(base.Add(4 * time.Hour)) {
+		t.Errorf("expected the snapshot to be taken at the instant, got %v", res.Export.Snapshot.TsSnapshot)
+	}
+
+	// Before the snapshot: a replay from the beginning of the log
+	res, err = RestoreAt(ctx, st, nil, base.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("RestoreAt failed: %v", err)
+	}
+	if res.Base != "" || res.Replayed != 2 || fmt.Sprint(restoredIdentities(t, res.Export)) != "[u0 u1]" {
+		t.Errorf("unexpected restore before the snapshot %+v", res)
+	}
+	if _, err := RestoreAt(ctx, st, nil, base.Add(-time.Hour)); err == nil {
+		t.Error("expected a restore before the first event to fail")
+	}
+
+	// With only the blob store: the exported snapshot and archived events
+	blobs := blob.NewLocalBlobStore(t.TempDir())
+	if _, err := store.ExportSnapshot(ctx, st, blobs, snap.SnapshotID); err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, evt := range events {
+		json.NewEncoder(gz).Encode(evt)
+	}
+	gz.Close()
+	if err := blobs.Put(ctx, store.ArchiveKey(base, events[5].TsIngest, "x"), &buf); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	res, err = RestoreAt(ctx, nil, blobs, base.Add(4*time.Hour))
+	if err != nil {
+		t.Fatalf("RestoreAt failed: %v", err)
+	}
+	if res.BaseSource != "blob" || res.Replayed != 2 || fmt.Sprint(restoredIdentities(t, res.Export)) != "[snap-only u0 u1 u2 u3 u4]" {
+		t.Errorf("unexpected blob-only restore %+v", res)
+	}
+
+	// A new node starts from the imported snapshot
+	fresh, err := store.NewStore(filepath.Join(t.TempDir(), "fresh.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer fresh.Close()
+	if err := store.ImportSnapshot(ctx, fresh, res.Export); err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	loaded := NewIdentityProjection()
+	checkpoint, err := LoadLatestSnapshot(ctx, fresh, loaded, NewUsageProjection(), NewProviderProjection(), forecast.NewForecastProjection(20))
+	if err != nil {
+		t.Fatalf("LoadLatestSnapshot failed: %v", err)
+	}
+	if checkpoint == nil || !checkpoint.TsIngest.Equal(events[4].TsIngest) || fmt.Sprint(identityIDs(loaded)) != "[snap-only u0 u1 u2 u3 u4]" {
+		t.Errorf("unexpected bootstrap state at %v: %v", checkpoint, identityIDs(loaded))
+	}
+}
@@ -5,23 +5,133 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
+// rollupStateKey holds the progress of the rollup worker in system_state.
+// rollupLegacyHWMKey is the ingest high water mark of earlier versions.
+const (
+	rollupStateKey     = "rollup_state"
+	rollupLegacyHWMKey = "rollup_hwm_ts"
+)
+
+// RollupRetention is how long the rollups of each resolution are kept. Once
+// minute buckets expire, their usage lives on in the hour and day buckets
+// they were downsampled into. Zero keeps a resolution forever.
+type RollupRetention struct {
+	Minute time.Duration
+	Hour   time.Duration
+	Day    time.Duration
+}
+
+// DefaultRollupRetention keeps minutes for two days and hours for 90 days.
+var DefaultRollupRetention = RollupRetention{Minute: 48 * time.Hour, Hour: 90 * 24 * time.Hour}
+
+// DefaultAllowedLateness is how far behind the latest event time an event
+// may be and still be rolled up.
+const DefaultAllowedLateness = 10 * time.Minute
+
+func (r RollupRetention) of(bucket string) time.Duration {
+	switch bucket {
+	case store.BucketMinute:
+		return r.Minute
+	case store.BucketHour:
+		return r.Hour
+	default:
+		return r.Day
+	}
+}
+
+// RollupWorker aggregates usage observations and intent decisions into
+// minute buckets and downsamples them into hour and day buckets.
+//
+// Usage observations come in two kinds. Those carrying "delta" (usage
+// recorded per intent) add that amount. The others carry "used", the
+// absolute counter of a provider window: the consumption is its increase
+// since the previous observation of the same series, and a counter that
+// moves to a later "reset_at" or goes down has been reset, so its whole
+// value is new consumption. The first observation of a series only sets the
+// baseline.
+//
+// Events are read in log order but bucketed by event time, so events from
+// followers or passive observers may arrive after later ones. The watermark
+// trails the latest event time by the allowed lateness: an event older than
+// the watermark is dropped and counted in ratelord_rollup_late_events_dropped_total.
+// A counter observation that lands between two earlier ones also corrects
+// the consumption of the bucket of the next one, which had been measured
+// from the previous one. Stats are upserted per event, so a batch retried
+// after a failure never counts an event twice.
 type RollupWorker struct {
-	store *store.Store
+	store     store.EventStore
+	interval  time.Duration
+	batchSize int
+	retention RollupRetention
+	lateness  time.Duration
+}
+
+func NewRollupWorker(st store.EventStore) *RollupWorker {
+	return &RollupWorker{
+		store:     st,
+		interval:  30 * time.Second,
+		batchSize: DefaultReplayBatchSize,
+		retention: DefaultRollupRetention,
+		lateness:  DefaultAllowedLateness,
+	}
+}
+
+// SetRetention sets how long each resolution is kept.
+func (r *RollupWorker) SetRetention(retention RollupRetention) {
+	r.retention = retention
+}
+
+// SetAllowedLateness sets how far behind the latest event time an event may
+// be and still be rolled up.
+func (r *RollupWorker) SetAllowedLateness(d time.Duration) {
+	r.lateness = d
+}
+
+// rollupState is the persisted progress: the position in the log, the
+// latest event time and the observations of every absolute counter since
+// the last one before the watermark.
+type rollupState struct {
+	Cursor    *store.EventCursor         `json:"cursor,omitempty"`
+	EventTime time.Time                  `json:"event_time,omitempty"`
+	Counters  map[string][]counterSample `json:"counters,omitempty"`
+}
+
+// watermark is the event time before which events are dropped.
+func (s *rollupState) watermark(lateness time.Duration) time.Time {
+	if s.EventTime.IsZero() {
+		return time.Time{}
+	}
+	return s.EventTime.Add(-lateness)
 }
 
-func NewRollupWorker(st *store.Store) *RollupWorker {
-	return &RollupWorker{store: st}
+// counterSample is an observation of an absolute usage counter.
+type counterSample struct {
+	Ts       time.Time `json:"ts"`
+	Used     int64     `json:"used"`
+	ResetAt  time.Time `json:"reset_at,omitempty"`
+	UnitCost int64     `json:"unit_cost,omitempty"`
+}
+
+// consumedSince is the consumption between the observations prev and s.
+func (s counterSample) consumedSince(prev counterSample) int64 {
+	windowRolled := !prev.ResetAt.IsZero() && s.ResetAt.After(prev.ResetAt)
+	if windowRolled || s.Used < prev.Used {
+		return s.Used
+	}
+	return s.Used - prev.Used
 }
 
 func (r *RollupWorker) Run(ctx context.Context) {
 	log.Println("Starting rollup worker")
 
-	ticker := time.NewTicker(30 * time.Second) // Run every 30 seconds for demo
+	ticker := time.NewTicker(r.interval)
 	defer ticker.Stop()
 
 	for {
@@ -33,125 +143,275 @@ func (r *RollupWorker) Run(ctx context.Context) {
 			if err := r.ProcessBatch(ctx); err != nil {
 				log.Printf("Rollup batch failed: %v", err)
 			}
+			if err := r.Prune(ctx); err != nil {
+				log.Printf("Rollup prune failed: %v", err)
+			}
 		}
 	}
 }
 
+// ProcessBatch rolls up the events appended since the last call, reading
+// them in batches until it has caught up with the log.
 func (r *RollupWorker) ProcessBatch(ctx context.Context) error {
-	// Get high water mark
-	hwmStr, err := r.store.GetSystemState(ctx, "rollup_hwm_ts")
-	if err != nil && err.Error() != "key not found: rollup_hwm_ts" {
-		return fmt.Errorf("failed to get rollup_hwm_ts: %w", err)
+	state, err := r.loadState(ctx)
+	if err != nil {
+		return err
 	}
-	var since time.Time
-	if hwmStr != "" {
-		if ts, err := time.Parse(time.RFC3339, hwmStr); err == nil {
-			since = ts
+
+	for {
+		events, err := r.store.QueryEvents(ctx, store.EventFilter{
+			EventTypes: []store.EventType{store.EventTypeUsageObserved, store.EventTypeIntentDecided},
+			After:      state.Cursor,
+			Limit:      r.batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read events: %w", err)
+		}
+		if len(events) == 0 {
+			return nil
 		}
-	}
 
-	// Read batch of events
-	events, err := r.store.ReadEvents(ctx, since, 1000) // Limit to 1000 events
-	if err != nil {
-		return fmt.Errorf("failed to read events: %w", err)
-	}
+		var stats []store.UsageStat
+		dropped := 0
+		for _, evt := range events {
+			if watermark := state.watermark(r.lateness); evt.TsEvent.Before(watermark) {
+				RatelordRollupLateEventsDropped.WithLabelValues(string(evt.EventType)).Inc()
+				dropped++
+				continue
+			}
+			// Event times ahead of ingestion are clock skew and must not
+			// move the watermark past events still to come
+			eventTime := evt.TsEvent
+			if evt.TsIngest.Before(eventTime) {
+				eventTime = evt.TsIngest
+			}
+			if eventTime.After(state.EventTime) {
+				state.EventTime = eventTime
+			}
 
-	if len(events) == 0 {
-		return nil // No new events
-	}
+			var minutes []store.UsageStat
+			switch evt.EventType {
+			case store.EventTypeUsageObserved:
+				minutes = rollupUsage(state.Counters, evt)
+			case store.EventTypeIntentDecided:
+				minutes = rollupDecision(evt)
+			}
+			stats = append(stats, resolutions(evt.EventID, minutes)...)
+		}
+		if dropped > 0 {
+			log.Printf("Rollup dropped %d events older than the watermark %s", dropped, state.watermark(r.lateness).Format(time.RFC3339))
+		}
 
-	// Filter for usage_observed
-	var usageEvents []*store.Event
-	for _, evt := range events {
-		if evt.EventType == store.EventTypeUsageObserved {
-			usageEvents = append(usageEvents, evt)
+		if err := r.store.UpsertUsageStats(ctx, stats); err != nil {
+			return fmt.Errorf("failed to upsert usage stats: %w", err)
 		}
-	}
 
-	if len(usageEvents) == 0 {
-		// Update hwm anyway
-		lastTs := events[len(events)-1].TsIngest
-		return r.store.SetSystemState(ctx, "rollup_hwm_ts", lastTs.Format(time.RFC3339))
+		state.Cursor = store.CursorAt(events[len(events)-1])
+		trimCounters(state.Counters, state.watermark(r.lateness))
+		if err := r.saveState(ctx, state); err != nil {
+			return err
+		}
+		if len(events) < r.batchSize {
+			return nil
+		}
 	}
+}
 
-	// Group and aggregate
-	groups := make(map[string]*store.UsageStat)
-
-	for _, evt := range usageEvents {
-		var payload struct {
-			ProviderID string `json:"provider_id"`
-			PoolID     string `json:"pool_id"`
-			Used       *int   `json:"used,omitempty"`
-			Delta      *int   `json:"delta,omitempty"`
-		}
-		if err := json.Unmarshal(evt.Payload, &payload); err != nil {
-			log.Printf("Failed to unmarshal usage payload: %v", err)
+// Prune deletes the rollups past their retention, and forgets the events
+// applied before the watermark: those are dropped if read again.
+func (r *RollupWorker) Prune(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, bucket := range store.UsageBuckets {
+		keep := r.retention.of(bucket)
+		if keep <= 0 {
 			continue
 		}
+		if _, err := r.store.PruneUsageStats(ctx, bucket, now.Add(-keep)); err != nil {
+			return err
+		}
+	}
 
-		if payload.ProviderID == "" || payload.PoolID == "" {
-			continue
+	state, err := r.loadState(ctx)
+	if err != nil {
+		return err
+	}
+	if watermark := state.watermark(r.lateness); !watermark.IsZero() {
+		if _, err := r.store.PruneUsageStatEvents(ctx, watermark.Truncate(time.Minute)); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Bucket hour: truncate TsEvent to hour
-		bucket := evt.TsEvent.Truncate(time.Hour)
+func (r *RollupWorker) loadState(ctx context.Context) (*rollupState, error) {
+	state := &rollupState{}
+	raw, err := r.store.GetSystemState(ctx, rollupStateKey)
+	if err != nil && err.Error() != "key not found: "+rollupStateKey {
+		return nil, fmt.Errorf("failed to get %s: %w", rollupStateKey, err)
+	}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), state); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", rollupStateKey, err)
+		}
+	} else if hwm, _ := r.store.GetSystemState(ctx, rollupLegacyHWMKey); hwm != "" {
+		// Resume from the high water mark of earlier versions
+		if ts, err := time.Parse(time.RFC3339, hwm); err == nil {
+			state.Cursor = &store.EventCursor{TsIngest: ts}
+		}
+	}
+	if state.Counters == nil {
+		state.Counters = make(map[string][]counterSample)
+	}
+	return state, nil
+}
 
-		key := fmt.Sprintf("%s|%s|%s|%s|%s", bucket.Format(time.RFC3339), payload.ProviderID, payload.PoolID, evt.Dimensions.IdentityID, evt.Dimensions.ScopeID)
+func (r *RollupWorker) saveState(ctx context.Context, state *rollupState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", rollupStateKey, err)
+	}
+	return r.store.SetSystemState(ctx, rollupStateKey, string(raw))
+}
 
-		stat, exists := groups[key]
-		if !exists {
-			stat = &store.UsageStat{
-				BucketTs:   bucket,
-				ProviderID: payload.ProviderID,
-				PoolID:     payload.PoolID,
-				IdentityID: evt.Dimensions.IdentityID,
-				ScopeID:    evt.Dimensions.ScopeID,
-				TotalUsage: 0,
-				MinUsage:   0,
-				MaxUsage:   0,
-				EventCount: 0,
-			}
-			groups[key] = stat
-		}
+func minuteStat(evt *store.Event, providerID, poolID string, ts time.Time) store.UsageStat {
+	return store.UsageStat{
+		BucketTs:   ts.UTC().Truncate(time.Minute),
+		Bucket:     store.BucketMinute,
+		ProviderID: providerID,
+		PoolID:     poolID,
+		IdentityID: evt.Dimensions.IdentityID,
+		ScopeID:    evt.Dimensions.ScopeID,
+	}
+}
 
-		stat.EventCount++
+// rollupUsage returns the minute stats of a usage observation: its own and,
+// for a counter observation older than the latest one of its series, the
+// correction of the bucket of the next observation.
+func rollupUsage(counters map[string][]counterSample, evt *store.Event) []store.UsageStat {
+	var payload struct {
+		ProviderID string    `json:"provider_id"`
+		PoolID     string    `json:"pool_id"`
+		Used       *int64    `json:"used,omitempty"`
+		Delta      *int64    `json:"delta,omitempty"`
+		Cost       *int64    `json:"cost,omitempty"`
+		ResetAt    time.Time `json:"reset_at,omitempty"`
+	}
+	if err := json.Unmarshal(evt.Payload, &payload); err != nil {
+		log.Printf("Failed to unmarshal usage payload: %v", err)
+		return nil
+	}
+	if payload.ProviderID == "" || payload.PoolID == "" {
+		return nil
+	}
 
-		var value int
-		if payload.Delta != nil {
-			value = *payload.Delta
-			stat.TotalUsage += value
-		} else if payload.Used != nil {
-			value = *payload.Used
-			stat.TotalUsage += value
-		} else {
-			continue
+	stat := minuteStat(evt, payload.ProviderID, payload.PoolID, evt.TsEvent)
+	var value, consumed, cost int64
+	var correction *store.UsageStat
+	switch {
+	case payload.Delta != nil:
+		value, consumed = *payload.Delta, *payload.Delta
+		if payload.Cost != nil {
+			cost = *payload.Cost
+		}
+	case payload.Used != nil:
+		value = *payload.Used
+		sample := counterSample{Ts: evt.TsEvent, Used: value, ResetAt: payload.ResetAt}
+		// The cost of an absolute observation is that of the whole counter
+		if payload.Cost != nil && value > 0 {
+			sample.UnitCost = *payload.Cost / value
 		}
 
-		if stat.EventCount == 1 {
-			stat.MinUsage = value
-			stat.MaxUsage = value
-		} else {
-			if value < stat.MinUsage {
-				stat.MinUsage = value
+		key := strings.Join([]string{payload.ProviderID, payload.PoolID, evt.Dimensions.IdentityID, evt.Dimensions.ScopeID}, "|")
+		series := counters[key]
+		i := sort.Search(len(series), func(i int) bool { return series[i].Ts.After(sample.Ts) })
+		if i > 0 {
+			consumed = sample.consumedSince(series[i-1])
+		}
+		cost = consumed * sample.UnitCost
+		if i < len(series) {
+			// Out of order: the next observation now follows this one
+			next := series[i]
+			var before int64
+			if i > 0 {
+				before = next.consumedSince(series[i-1])
 			}
-			if value > stat.MaxUsage {
-				stat.MaxUsage = value
+			if diff := next.consumedSince(sample) - before; diff != 0 {
+				c := minuteStat(evt, payload.ProviderID, payload.PoolID, next.Ts)
+				c.TotalUsage, c.TotalCost = int(diff), diff*next.UnitCost
+				correction = &c
 			}
 		}
+		series = append(series, counterSample{})
+		copy(series[i+1:], series[i:])
+		series[i] = sample
+		counters[key] = series
+	default:
+		return nil
 	}
 
-	// Prepare stats slice
-	var stats []store.UsageStat
-	for _, stat := range groups {
-		stats = append(stats, *stat)
+	stat.MinUsage, stat.MaxUsage = int(value), int(value)
+	stat.EventCount = 1
+	stat.TotalUsage = int(consumed)
+	stat.TotalCost = cost
+	if correction != nil {
+		return []store.UsageStat{stat, *correction}
 	}
+	return []store.UsageStat{stat}
+}
 
-	// Upsert
-	if err := r.store.UpsertUsageStats(ctx, stats); err != nil {
-		return fmt.Errorf("failed to upsert usage stats: %w", err)
+func rollupDecision(evt *store.Event) []store.UsageStat {
+	var payload struct {
+		Decision   Decision `json:"decision"`
+		ProviderID string   `json:"provider_id"`
+		PoolID     string   `json:"pool_id"`
+	}
+	if err := json.Unmarshal(evt.Payload, &payload); err != nil {
+		log.Printf("Failed to unmarshal decision payload: %v", err)
+		return nil
+	}
+	// Decisions recorded before they named their pool
+	if payload.ProviderID == "" {
+		payload.ProviderID = store.SentinelUnknown
 	}
+	if payload.PoolID == "" {
+		payload.PoolID = store.SentinelUnknown
+	}
+
+	stat := minuteStat(evt, payload.ProviderID, payload.PoolID, evt.TsEvent)
+	switch payload.Decision {
+	case DecisionApprove:
+		stat.ApprovedCount++
+	case DecisionApproveWithModifications:
+		stat.ShapedCount++
+	case DecisionDenyWithReason:
+		stat.DeniedCount++
+	default:
+		return nil
+	}
+	return []store.UsageStat{stat}
+}
+
+// resolutions returns the minute stats of an event followed by their
+// downsampling into every other resolution, all marked with the event.
+func resolutions(id store.EventID, minutes []store.UsageStat) []store.UsageStat {
+	stats := make([]store.UsageStat, 0, len(minutes)*len(store.UsageBuckets))
+	for _, bucket := range store.UsageBuckets {
+		size, _ := store.BucketSize(bucket)
+		for _, stat := range minutes {
+			stat.BucketTs, stat.Bucket, stat.EventID = stat.BucketTs.Truncate(size), bucket, id
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
 
-	// Update hwm
-	lastTs := events[len(events)-1].TsIngest
-	return r.store.SetSystemState(ctx, "rollup_hwm_ts", lastTs.Format(time.RFC3339))
+// trimCounters drops the counter observations that no event at or after the
+// watermark can follow: all but the last one before it.
+func trimCounters(counters map[string][]counterSample, watermark time.Time) {
+	for key, series := range counters {
+		i := sort.Search(len(series), func(i int) bool { return !series[i].Ts.Before(watermark) })
+		if i > 1 {
+			counters[key] = append([]counterSample(nil), series[i-1:]...)
+		}
+	}
 }
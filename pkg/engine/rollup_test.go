@@ -3,100 +3,222 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
+func rollupEvent(id string, typ store.EventType, ts time.Time, identity string, payload interface{}) *store.Event {
+	raw, _ := json.Marshal(payload)
+	return &store.Event{
+		EventID:    store.EventID(id),
+		EventType:  typ,
+		TsEvent:    ts,
+		TsIngest:   ts,
+		Payload:    raw,
+		Dimensions: store.EventDimensions{IdentityID: identity, ScopeID: "scope-1"},
+	}
+}
+
 func TestRollupWorker_ProcessBatch(t *testing.T) {
-	// 1. Setup Store
-	st, err := store.NewStore(":memory:")
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
 	defer st.Close()
-
-	// 2. Insert usage events
 	ctx := context.Background()
-	used := 100
-	payload := struct {
-		ProviderID string `json:"provider_id"`
-		PoolID     string `json:"pool_id"`
-		Used       *int   `json:"used,omitempty"`
-	}{
-		ProviderID: "prov-1",
-		PoolID:     "pool-1",
-		Used:       &used,
-	}
-	payloadBytes, _ := json.Marshal(payload)
-
-	// Event 1
-	st.AppendEvent(ctx, &store.Event{
-		EventID:   "evt-1",
-		EventType: store.EventTypeUsageObserved,
-		TsEvent:   time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC),
-		TsIngest:  time.Now(),
-		Payload:   payloadBytes,
-		Dimensions: store.EventDimensions{
-			IdentityID: "user-1",
-			ScopeID:    "scope-1",
-		},
-	})
-
-	// Event 2 (Same bucket)
-	used2 := 200
-	payload.Used = &used2
-	payloadBytes2, _ := json.Marshal(payload)
-	st.AppendEvent(ctx, &store.Event{
-		EventID:   "evt-2",
-		EventType: store.EventTypeUsageObserved,
-		TsEvent:   time.Date(2023, 1, 1, 10, 30, 0, 0, time.UTC),
-		TsIngest:  time.Now(),
-		Payload:   payloadBytes2,
-		Dimensions: store.EventDimensions{
-			IdentityID: "user-1",
-			ScopeID:    "scope-1",
-		},
-	})
-
-	// 3. Run Rollup
+
+	base := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	window := base.Add(time.Hour)
+	poll := func(used int64, resetAt time.Time) map[string]interface{} {
+		return map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "used": used, "cost": used * 10, "reset_at": resetAt}
+	}
+	intent := map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "used": 999, "delta": 2, "cost": 20}
+	events := []*store.Event{
+		// Absolute counter: baseline, +100, reset to a new window (+30), +20
+		rollupEvent("poll-1", store.EventTypeUsageObserved, base, store.SentinelGlobal, poll(100, window)),
+		rollupEvent("poll-2", store.EventTypeUsageObserved, base.Add(30*time.Second), store.SentinelGlobal, poll(200, window)),
+		rollupEvent("poll-3", store.EventTypeUsageObserved, base.Add(90*time.Second), store.SentinelGlobal, poll(30, window.Add(time.Hour))),
+		rollupEvent("poll-4", store.EventTypeUsageObserved, base.Add(2*time.Hour), store.SentinelGlobal, poll(50, window.Add(time.Hour))),
+		// Usage recorded per intent adds its delta, whatever "used" says
+		rollupEvent("usage-1", store.EventTypeUsageObserved, base.Add(10*time.Second), "user-1", intent),
+		rollupEvent("usage-2", store.EventTypeUsageObserved, base.Add(20*time.Second), "user-1", intent),
+		rollupEvent("dec-1", store.EventTypeIntentDecided, base.Add(5*time.Second), "user-1", map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "decision": DecisionApprove}),
+		rollupEvent("dec-2", store.EventTypeIntentDecided, base.Add(6*time.Second), "user-1", map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "decision": DecisionApproveWithModifications}),
+		rollupEvent("dec-3", store.EventTypeIntentDecided, base.Add(7*time.Second), "user-1", map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "decision": DecisionDenyWithReason}),
+		rollupEvent("other", store.EventTypeIdentityRegistered, base, "user-1", map[string]interface{}{}),
+	}
+	for _, evt := range events {
+		if err := st.AppendEvent(ctx, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	// Small batches: a single call still catches up with the whole log
 	worker := NewRollupWorker(st)
+	worker.batchSize = 2
 	if err := worker.ProcessBatch(ctx); err != nil {
 		t.Fatalf("ProcessBatch failed: %v", err)
 	}
+	// Nothing new: a second pass adds nothing
+	if err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatalf("ProcessBatch failed: %v", err)
+	}
+
+	get := func(bucket, identity string) []store.UsageStat {
+		t.Helper()
+		stats, err := st.GetUsageStats(ctx, store.UsageFilter{From: base.Add(-24 * time.Hour), To: base.Add(24 * time.Hour), Bucket: bucket, IdentityID: identity})
+		if err != nil {
+			t.Fatalf("GetUsageStats failed: %v", err)
+		}
+		return stats
+	}
+
+	minutes := get(store.BucketMinute, store.SentinelGlobal)
+	if len(minutes) != 3 {
+		t.Fatalf("expected 3 minute buckets for the counter, got %+v", minutes)
+	}
+	if m := minutes[0]; m.TotalUsage != 100 || m.TotalCost != 1000 || m.EventCount != 2 || m.MinUsage != 100 || m.MaxUsage != 200 {
+		t.Errorf("unexpected first minute %+v", m)
+	}
+	if m := minutes[1]; !m.BucketTs.Equal(base.Add(time.Minute)) || m.TotalUsage != 30 {
+		t.Errorf("expected the reset to count the new window in full, got %+v", m)
+	}
+
+	hours := get(store.BucketHour, store.SentinelGlobal)
+	if len(hours) != 2 || hours[0].TotalUsage != 130 || hours[0].EventCount != 3 || hours[0].MinUsage != 30 || hours[1].TotalUsage != 20 {
+		t.Errorf("unexpected hourly counter stats %+v", hours)
+	}
+	days := get(store.BucketDay, store.SentinelGlobal)
+	if len(days) != 1 || days[0].TotalUsage != 150 || days[0].TotalCost != 1500 || days[0].EventCount != 4 {
+		t.Errorf("unexpected daily counter stats %+v", days)
+	}
 
-	// 4. Verify UsageStats
-	stats, err := st.GetUsageStats(ctx, store.UsageFilter{
-		From:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
-		To:     time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
-		Bucket: "hour",
-	})
+	user := get(store.BucketHour, "user-1")
+	if len(user) != 1 {
+		t.Fatalf("expected one hourly bucket for the identity, got %+v", user)
+	}
+	if u := user[0]; u.TotalUsage != 4 || u.TotalCost != 40 || u.EventCount != 2 || u.ApprovedCount != 1 || u.ShapedCount != 1 || u.DeniedCount != 1 {
+		t.Errorf("unexpected identity stats %+v", u)
+	}
+}
+
+func TestRollupWorker_LateEvents(t *testing.T) {
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
 	if err != nil {
-		t.Fatalf("GetUsageStats failed: %v", err)
+		t.Fatalf("Failed to create store: %v", err)
 	}
+	defer st.Close()
+	ctx := context.Background()
 
-	if len(stats) == 0 {
-		t.Fatal("No usage stats found")
+	base := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	poll := func(used int64) map[string]interface{} {
+		return map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "used": used, "cost": used * 10}
+	}
+	add := func(evt *store.Event, ingest time.Time) {
+		t.Helper()
+		evt.TsIngest = ingest
+		if err := st.AppendEvent(ctx, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+	minutes := func() map[time.Time]int {
+		t.Helper()
+		stats, err := st.GetUsageStats(ctx, store.UsageFilter{From: base.Add(-time.Hour), To: base.Add(time.Hour), Bucket: store.BucketMinute})
+		if err != nil {
+			t.Fatalf("GetUsageStats failed: %v", err)
+		}
+		got := make(map[time.Time]int)
+		for _, s := range stats {
+			got[s.BucketTs] += s.TotalUsage
+		}
+		return got
 	}
 
-	// Should have aggregated 2 events in 10:00 bucket
-	stat := stats[0]
-	if stat.BucketTs.Hour() != 10 {
-		t.Errorf("Expected bucket hour 10, got %d", stat.BucketTs.Hour())
+	worker := NewRollupWorker(st)
+	add(rollupEvent("poll-0", store.EventTypeUsageObserved, base, store.SentinelGlobal, poll(100)), base)
+	add(rollupEvent("poll-2", store.EventTypeUsageObserved, base.Add(2*time.Minute), store.SentinelGlobal, poll(300)), base.Add(2*time.Minute))
+	if err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatalf("ProcessBatch failed: %v", err)
+	}
+	if got := minutes(); got[base.Add(2*time.Minute)] != 200 {
+		t.Fatalf("unexpected minutes before the late events %v", got)
+	}
+	saved, _ := st.GetSystemState(ctx, rollupStateKey)
+
+	// A poll from between the two, and usage within the allowed lateness,
+	// arrive late; usage from before the watermark is dropped
+	dropped := testutil.ToFloat64(RatelordRollupLateEventsDropped.WithLabelValues(string(store.EventTypeUsageObserved)))
+	late := map[string]interface{}{"provider_id": "prov-1", "pool_id": "pool-1", "delta": 5}
+	add(rollupEvent("poll-1", store.EventTypeUsageObserved, base.Add(time.Minute), store.SentinelGlobal, poll(150)), base.Add(3*time.Minute))
+	add(rollupEvent("usage-late", store.EventTypeUsageObserved, base.Add(time.Minute), "user-1", late), base.Add(3*time.Minute))
+	add(rollupEvent("usage-dropped", store.EventTypeUsageObserved, base.Add(-DefaultAllowedLateness-time.Second), "user-1", late), base.Add(3*time.Minute))
+	if err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatalf("ProcessBatch failed: %v", err)
+	}
+	want := map[time.Time]int{base: 0, base.Add(time.Minute): 55, base.Add(2 * time.Minute): 150}
+	check := func() {
+		t.Helper()
+		got := minutes()
+		for ts, usage := range want {
+			if got[ts] != usage {
+				t.Errorf("minute %s: expected %d, got %d", ts.Format("15:04"), usage, got[ts])
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("unexpected minutes %v", got)
+		}
+	}
+	check()
+	if n := testutil.ToFloat64(RatelordRollupLateEventsDropped.WithLabelValues(string(store.EventTypeUsageObserved))) - dropped; n != 1 {
+		t.Errorf("expected 1 dropped event, got %v", n)
+	}
+	hours, _ := st.GetUsageStats(ctx, store.UsageFilter{From: base, To: base.Add(time.Hour), Bucket: store.BucketHour, IdentityID: store.SentinelGlobal})
+	if len(hours) != 1 || hours[0].TotalUsage != 200 || hours[0].TotalCost != 2000 || hours[0].EventCount != 3 {
+		t.Errorf("expected the late poll to leave the hour unchanged, got %+v", hours)
 	}
 
-	if stat.TotalUsage != 300 {
-		t.Errorf("Expected TotalUsage 300, got %d", stat.TotalUsage)
+	// A retry from before the batch was checkpointed counts nothing twice
+	if err := st.SetSystemState(ctx, rollupStateKey, saved); err != nil {
+		t.Fatalf("SetSystemState failed: %v", err)
 	}
-	if stat.MinUsage != 100 {
-		t.Errorf("Expected MinUsage 100, got %d", stat.MinUsage)
+	if err := worker.ProcessBatch(ctx); err != nil {
+		t.Fatalf("ProcessBatch failed: %v", err)
 	}
-	if stat.MaxUsage != 200 {
-		t.Errorf("Expected MaxUsage 200, got %d", stat.MaxUsage)
+	check()
+}
+
+func TestRollupWorker_Prune(t *testing.T) {
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	old := time.Now().UTC().Add(-72 * time.Hour).Truncate(time.Hour)
+	var stats []store.UsageStat
+	for _, bucket := range store.UsageBuckets {
+		size, _ := store.BucketSize(bucket)
+		stats = append(stats, store.UsageStat{BucketTs: old.Truncate(size), Bucket: bucket, ProviderID: "p", PoolID: "p", IdentityID: "i", ScopeID: "s", TotalUsage: 1, EventCount: 1})
+	}
+	if err := st.UpsertUsageStats(ctx, stats); err != nil {
+		t.Fatalf("UpsertUsageStats failed: %v", err)
+	}
+
+	// Minutes expire after two days by default; hours and days remain
+	worker := NewRollupWorker(st)
+	if err := worker.Prune(ctx); err != nil {
+		t.Fatalf("Prune failed: %v", err)
 	}
-	if stat.EventCount != 2 {
-		t.Errorf("Expected EventCount 2, got %d", stat.EventCount)
+	for _, bucket := range store.UsageBuckets {
+		got, _ := st.GetUsageStats(ctx, store.UsageFilter{From: old.Add(-48 * time.Hour), To: time.Now(), Bucket: bucket})
+		if want := bucket != store.BucketMinute; (len(got) == 1) != want {
+			t.Errorf("%s: expected kept=%v, got %+v", bucket, want, got)
+		}
 	}
 }
 
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/rmax-ai/ratelord/pkg/blob"
 	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
@@ -18,18 +20,79 @@ type SnapshotPayload struct {
 	ForecastHistories map[string][]forecast.UsagePoint `json:"forecast_histories"`
 }
 
+// SnapshotRetention selects the snapshots kept in the store after each new
+// one: the KeepLast newest, plus the newest of each of the KeepDaily most
+// recent days and of the KeepWeekly most recent ISO weeks (UTC) that have a
+// snapshot. The zero value keeps every snapshot.
+type SnapshotRetention struct {
+	KeepLast   int `json:"keep_last"`
+	KeepDaily  int `json:"keep_daily"`
+	KeepWeekly int `json:"keep_weekly"`
+}
+
+// Enabled reports whether the retention deletes anything.
+func (r SnapshotRetention) Enabled() bool {
+	return r.KeepLast > 0 || r.KeepDaily > 0 || r.KeepWeekly > 0
+}
+
+// Expired returns the IDs of the snapshots that fall outside the retention.
+// The newest snapshot is always kept.
+func (r SnapshotRetention) Expired(snaps []*store.Snapshot) []string {
+	if !r.Enabled() || len(snaps) == 0 {
+		return nil
+	}
+	sorted := make([]*store.Snapshot, len(snaps))
+	copy(sorted, snaps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TsSnapshot.After(sorted[j].TsSnapshot)
+	})
+
+	keep := map[string]bool{sorted[0].SnapshotID: true}
+	for i := 0; i < r.KeepLast && i < len(sorted); i++ {
+		keep[sorted[i].SnapshotID] = true
+	}
+	keepNewestPer := func(n int, period func(time.Time) string) {
+		seen := make(map[string]bool)
+		for _, snap := range sorted {
+			if len(seen) >= n {
+				return
+			}
+			p := period(snap.TsSnapshot.UTC())
+			if !seen[p] {
+				seen[p] = true
+				keep[snap.SnapshotID] = true
+			}
+		}
+	}
+	keepNewestPer(r.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	keepNewestPer(r.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	var expired []string
+	for _, snap := range sorted {
+		if !keep[snap.SnapshotID] {
+			expired = append(expired, snap.SnapshotID)
+		}
+	}
+	return expired
+}
+
 // SnapshotWorker periodically persists the state of projections to the store
 type SnapshotWorker struct {
-	store      *store.Store
+	store      store.EventStore
 	identities *IdentityProjection
 	usage      *UsageProjection
 	providers  *ProviderProjection
 	forecasts  *forecast.ForecastProjection
 	interval   time.Duration
+	retention  SnapshotRetention
+	exportTo   blob.BlobStore
 }
 
 // NewSnapshotWorker creates a new worker
-func NewSnapshotWorker(st *store.Store, id *IdentityProjection, usage *UsageProjection, prov *ProviderProjection, fore *forecast.ForecastProjection, interval time.Duration) *SnapshotWorker {
+func NewSnapshotWorker(st store.EventStore, id *IdentityProjection, usage *UsageProjection, prov *ProviderProjection, fore *forecast.ForecastProjection, interval time.Duration) *SnapshotWorker {
 	if interval == 0 {
 		interval = 5 * time.Minute
 	}
@@ -43,6 +106,18 @@ func NewSnapshotWorker(st *store.Store, id *IdentityProjection, usage *UsageProj
 	}
 }
 
+// SetRetention sets the retention applied after each snapshot.
+func (w *SnapshotWorker) SetRetention(r SnapshotRetention) {
+	w.retention = r
+}
+
+// SetExportStore makes the worker copy each snapshot to b, where it outlives
+// the database (see store.ExportSnapshot). Exported snapshots are not
+// subject to the retention.
+func (w *SnapshotWorker) SetExportStore(b blob.BlobStore) {
+	w.exportTo = b
+}
+
 // Run starts the snapshot loop
 func (w *SnapshotWorker) Run(ctx context.Context) {
 	ticker := time.NewTicker(w.interval)
@@ -56,10 +131,24 @@ func (w *SnapshotWorker) Run(ctx context.Context) {
 			fmt.Println(`{"level":"info","msg":"snapshot_worker_stopped"}`)
 			return
 		case <-ticker.C:
-			if err := w.TakeSnapshot(ctx); err != nil {
+			snap, err := w.takeSnapshot(ctx)
+			if err != nil {
 				fmt.Printf(`{"level":"error","msg":"snapshot_failed","error":"%v"}`+"\n", err)
-			} else {
-				fmt.Println(`{"level":"info","msg":"snapshot_created"}`)
+				continue
+			}
+			fmt.Println(`{"level":"info","msg":"snapshot_created"}`)
+
+			if w.exportTo != nil {
+				if key, err := store.ExportSnapshot(ctx, w.store, w.exportTo, snap.SnapshotID); err != nil {
+					fmt.Printf(`{"level":"error","msg":"snapshot_export_failed","error":"%v"}`+"\n", err)
+				} else {
+					fmt.Printf(`{"level":"info","msg":"snapshot_exported","key":"%s"}`+"\n", key)
+				}
+			}
+			if n, err := w.ApplyRetention(ctx); err != nil {
+				fmt.Printf(`{"level":"error","msg":"snapshot_retention_failed","error":"%v"}`+"\n", err)
+			} else if n > 0 {
+				fmt.Printf(`{"level":"info","msg":"snapshots_expired","count":%d}`+"\n", n)
 			}
 		}
 	}
@@ -67,15 +156,50 @@ func (w *SnapshotWorker) Run(ctx context.Context) {
 
 // TakeSnapshot captures the current state and saves it to the store
 func (w *SnapshotWorker) TakeSnapshot(ctx context.Context) error {
-	idEventID, idTime, identities := w.identities.GetState()
-	usageEventID, usageTime, pools := w.usage.GetState()
+	_, err := w.takeSnapshot(ctx)
+	return err
+}
+
+// ApplyRetention deletes the snapshots outside the retention and returns
+// how many were deleted.
+func (w *SnapshotWorker) ApplyRetention(ctx context.Context) (int, error) {
+	if !w.retention.Enabled() {
+		return 0, nil
+	}
+	snaps, err := w.store.ListSnapshots(ctx)
+	if err != nil {
+		return 0, err
+	}
+	expired := w.retention.Expired(snaps)
+	if err := w.store.DeleteSnapshots(ctx, expired); err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+func (w *SnapshotWorker) takeSnapshot(ctx context.Context) (*store.Snapshot, error) {
+	snap, err := buildSnapshot(w.identities, w.usage, w.providers, w.forecasts, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if err := w.store.SaveSnapshot(ctx, snap); err != nil {
+		return nil, fmt.Errorf("store save failed: %w", err)
+	}
+	return snap, nil
+}
+
+// buildSnapshot captures the state of the projections as a snapshot taken
+// at ts.
+func buildSnapshot(ids *IdentityProjection, usage *UsageProjection, providers *ProviderProjection, forecasts *forecast.ForecastProjection, ts time.Time) (*store.Snapshot, error) {
+	idEventID, idTime, identities := ids.GetState()
+	usageEventID, usageTime, pools := usage.GetState()
 	// Providers and Forecasts don't track "LastEventID" explicitly in the same way,
 	// relying on event stream integrity. We assume they are up to date with the stream processed by ID/Usage projections.
 	// Since all projections are updated in the same replay loop or stream processing,
 	// taking the Minimum of (idTime, usageTime) is safe enough as the checkpoint.
 
-	providerStates := w.providers.GetAllStates()
-	forecastHistories := w.forecasts.GetAllHistories()
+	providerStates := providers.GetAllStates()
+	forecastHistories := forecasts.GetAllHistories()
 
 	// Determine the "safe" checkpoint.
 	var safeEventID string
@@ -83,7 +207,7 @@ func (w *SnapshotWorker) TakeSnapshot(ctx context.Context) error {
 		// If mostly empty, maybe check if we have other data?
 		// For now, strict check on core projections.
 		// If system is fresh, we might skip snapshotting until some activity.
-		return fmt.Errorf("cannot snapshot: not all projections have processed events (id=%s, usage=%s)", idEventID, usageEventID)
+		return nil, fmt.Errorf("cannot snapshot: not all projections have processed events (id=%s, usage=%s)", idEventID, usageEventID)
 	}
 
 	if idTime.Before(usageTime) {
@@ -101,53 +225,55 @@ func (w *SnapshotWorker) TakeSnapshot(ctx context.Context) error {
 
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal snapshot payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal snapshot payload: %w", err)
 	}
 
-	snap := &store.Snapshot{
-		SnapshotID:    fmt.Sprintf("snap_%d", time.Now().UnixNano()),
+	return &store.Snapshot{
+		SnapshotID:    fmt.Sprintf("snap_%d", ts.UnixNano()),
 		SchemaVersion: 1,
-		TsSnapshot:    time.Now().UTC(),
+		TsSnapshot:    ts,
 		LastEventID:   store.EventID(safeEventID),
 		Payload:       payloadJSON,
-	}
-
-	if err := w.store.SaveSnapshot(ctx, snap); err != nil {
-		return fmt.Errorf("store save failed: %w", err)
-	}
-
-	return nil
+	}, nil
 }
 
 // LoadLatestSnapshot attempts to load the latest snapshot from the store.
-// If successful, it restores the projections and returns the ingestion time of the last processed event.
-// If no snapshot exists, it returns a zero time (indicating full replay is needed).
-func LoadLatestSnapshot(ctx context.Context, st *store.Store, idProj *IdentityProjection, usageProj *UsageProjection, provProj *ProviderProjection, foreProj *forecast.ForecastProjection) (time.Time, error) {
+// If successful, it restores the projections (see SnapshotProjections) and returns the checkpoint event,
+// the last event they reflect. If no snapshot exists, it returns nil (indicating full replay is needed).
+func LoadLatestSnapshot(ctx context.Context, st store.EventStore, idProj *IdentityProjection, usageProj *UsageProjection, provProj *ProviderProjection, foreProj *forecast.ForecastProjection) (*store.Event, error) {
 	snap, err := st.GetLatestSnapshot(ctx)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to get latest snapshot: %w", err)
+		return nil, fmt.Errorf("failed to get latest snapshot: %w", err)
 	}
 	if snap == nil {
-		return time.Time{}, nil // No snapshot, full replay
+		return nil, nil // No snapshot, full replay
 	}
 
 	// Fetch the checkpoint event to get the accurate ingest timestamp
 	checkpointEvent, err := st.GetEvent(ctx, snap.LastEventID)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to get checkpoint event %s: %w", snap.LastEventID, err)
+		return nil, fmt.Errorf("failed to get checkpoint event %s: %w", snap.LastEventID, err)
 	}
 	if checkpointEvent == nil {
-		return time.Time{}, fmt.Errorf("checkpoint event %s not found (inconsistent state)", snap.LastEventID)
+		return nil, fmt.Errorf("checkpoint event %s not found (inconsistent state)", snap.LastEventID)
+	}
+
+	if err := applySnapshot(snap, checkpointEvent.TsIngest, idProj, usageProj, provProj, foreProj); err != nil {
+		return nil, err
 	}
+	return checkpointEvent, nil
+}
 
+// applySnapshot restores the projections from snap, whose checkpoint event
+// was ingested at checkpoint.
+func applySnapshot(snap *store.Snapshot, checkpoint time.Time, idProj *IdentityProjection, usageProj *UsageProjection, provProj *ProviderProjection, foreProj *forecast.ForecastProjection) error {
 	var payload SnapshotPayload
 	if err := json.Unmarshal(snap.Payload, &payload); err != nil {
-		return time.Time{}, fmt.Errorf("failed to unmarshal snapshot payload: %w", err)
+		return fmt.Errorf("failed to unmarshal snapshot payload: %w", err)
 	}
 
-	// Restore Projections
-	idProj.LoadState(string(snap.LastEventID), checkpointEvent.TsIngest, payload.Identities)
-	usageProj.LoadState(string(snap.LastEventID), checkpointEvent.TsIngest, payload.Pools)
+	idProj.LoadState(string(snap.LastEventID), checkpoint, payload.Identities)
+	usageProj.LoadState(string(snap.LastEventID), checkpoint, payload.Pools)
 
 	if payload.ProviderStates != nil {
 		provProj.LoadState(payload.ProviderStates)
@@ -155,6 +281,5 @@ func LoadLatestSnapshot(ctx context.Context, st *store.Store, idProj *IdentityPr
 	if payload.ForecastHistories != nil {
 		foreProj.LoadHistories(payload.ForecastHistories)
 	}
-
-	return checkpointEvent.TsIngest, nil
+	return nil
 }
@@ -3,9 +3,12 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/rmax-ai/ratelord/pkg/blob"
 	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
@@ -71,12 +74,12 @@ func TestSnapshotWorker_TakeAndLoad(t *testing.T) {
 	newProvProj := NewProviderProjection()
 	newForeProj := forecast.NewForecastProjection(100)
 
-	ts, err := LoadLatestSnapshot(ctx, st, newIdProj, newUsageProj, newProvProj, newForeProj)
+	checkpoint, err := LoadLatestSnapshot(ctx, st, newIdProj, newUsageProj, newProvProj, newForeProj)
 	if err != nil {
 		t.Fatalf("LoadLatestSnapshot failed: %v", err)
 	}
-	if ts.IsZero() {
-		t.Error("Expected non-zero timestamp")
+	if checkpoint == nil || checkpoint.EventID != "evt-1" || checkpoint.TsIngest.IsZero() {
+		t.Errorf("Expected checkpoint evt-1, got %+v", checkpoint)
 	}
 
 	// Verify restored state
@@ -145,3 +148,103 @@ func TestNewSnapshotWorker_Defaults(t *testing.T) {
 		t.Errorf("expected default interval 5m, got %v", w.interval)
 	}
 }
+
+func TestSnapshotRetention_Expired(t *testing.T) {
+	// Hourly snapshots over 21 days, newest last
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var snaps []*store.Snapshot
+	for i := 0; i < 21*24; i++ {
+		snaps = append(snaps, &store.Snapshot{
+			SnapshotID: fmt.Sprintf("snap_%03d", i),
+			TsSnapshot: base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	if got := (SnapshotRetention{}).Expired(snaps); got != nil {
+		t.Errorf("expected the zero retention to keep everything, got %d expired", len(got))
+	}
+
+	r := SnapshotRetention{KeepLast: 3, KeepDaily: 2, KeepWeekly: 3}
+	expired := r.Expired(snaps)
+	kept := make(map[string]bool)
+	for _, snap := range snaps {
+		kept[snap.SnapshotID] = true
+	}
+	for _, id := range expired {
+		delete(kept, id)
+	}
+	// Last 3 (503, 502, 501); daily: Jan 21 (503) and Jan 20 (479);
+	// weekly: W04 (503), W03 Sun Jan 19 (455), W02 Sun Jan 12 (287)
+	want := []string{"snap_287", "snap_455", "snap_479", "snap_501", "snap_502", "snap_503"}
+	if len(kept) != len(want) {
+		t.Fatalf("expected %v kept, got %v", want, kept)
+	}
+	for _, id := range want {
+		if !kept[id] {
+			t.Errorf("expected %s to be kept, got %v", id, kept)
+		}
+	}
+
+	// The newest snapshot survives any policy
+	if got := (SnapshotRetention{KeepDaily: 1}).Expired(snaps[:1]); len(got) != 0 {
+		t.Errorf("expected the only snapshot to be kept, got %v", got)
+	}
+}
+
+func TestSnapshotWorker_ExportAndRetention(t *testing.T) {
+	st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	idProj := NewIdentityProjection()
+	idProj.LoadState("evt-1", time.Now(), []Identity{{ID: "u1"}})
+	usageProj := NewUsageProjection()
+	usageProj.LoadState("evt-1", time.Now(), []PoolState{{PoolID: "p1"}})
+	st.AppendEvent(ctx, &store.Event{EventID: "evt-1", TsIngest: time.Now(), EventType: "init", Payload: json.RawMessage("{}")})
+
+	blobs := blob.NewLocalBlobStore(t.TempDir())
+	worker := NewSnapshotWorker(st, idProj, usageProj, NewProviderProjection(), forecast.NewForecastProjection(10), 10*time.Millisecond)
+	worker.SetRetention(SnapshotRetention{KeepLast: 2})
+	worker.SetExportStore(blobs)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		worker.Run(runCtx)
+		close(done)
+	}()
+	// Snapshots are taken while we look, so wait for a pass whose retention
+	// has completed
+	var snaps []*store.Snapshot
+	var exported []store.ExportedSnapshot
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var err error
+		if exported, err = store.ListExportedSnapshots(ctx, blobs); err != nil {
+			t.Fatalf("ListExportedSnapshots failed: %v", err)
+		}
+		if snaps, err = st.ListSnapshots(ctx); err != nil {
+			t.Fatalf("ListSnapshots failed: %v", err)
+		}
+		if (len(exported) >= 3 && len(snaps) <= 2) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(exported) < 3 {
+		t.Fatalf("expected at least 3 exported snapshots, got %d", len(exported))
+	}
+	if len(snaps) > 2 {
+		t.Fatalf("expected at most 2 snapshots in the store, got %d", len(snaps))
+	}
+	// Exports outlive the retention
+	if exported[len(exported)-1].SnapshotID == snaps[len(snaps)-1].SnapshotID {
+		t.Errorf("expected the oldest export to be expired from the store")
+	}
+}
@@ -11,13 +11,19 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
-// UsageStore abstracts the storage of pool state
+// UsageStore abstracts the storage of pool state. Stores may be shared by
+// several daemons: Set replaces a whole pool, for snapshots and absolute
+// observations, while the other writes change only the fields they name.
 type UsageStore interface {
 	Get(providerID, poolID string) (PoolState, bool)
 	Set(state PoolState)
 	GetAll() []PoolState
 	Clear()
+	// Increment adds deltas to the counters of a pool, creating it if needed.
 	Increment(providerID, poolID string, usedDelta, remainingDelta int64, costDelta currency.MicroUSD)
+	// SetResetAt and SetForecast set one field of a pool, creating it if needed.
+	SetResetAt(providerID, poolID string, resetAt time.Time)
+	SetForecast(providerID, poolID string, fc forecast.Forecast)
 }
 
 // MemoryUsageStore implements UsageStore using an in-memory map
@@ -79,6 +85,30 @@ func (s *MemoryUsageStore) Increment(providerID, poolID string, usedDelta, remai
 	s.pools[key] = state
 }
 
+func (s *MemoryUsageStore) SetResetAt(providerID, poolID string, resetAt time.Time) {
+	s.update(providerID, poolID, func(state *PoolState) { state.ResetAt = resetAt })
+}
+
+func (s *MemoryUsageStore) SetForecast(providerID, poolID string, fc forecast.Forecast) {
+	s.update(providerID, poolID, func(state *PoolState) { state.LatestForecast = &fc })
+}
+
+func (s *MemoryUsageStore) update(providerID, poolID string, f func(*PoolState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := makePoolKey(providerID, poolID)
+	state, exists := s.pools[key]
+	if !exists {
+		state = PoolState{
+			ProviderID: providerID,
+			PoolID:     poolID,
+		}
+	}
+	f(&state)
+	state.LastUpdated = time.Now()
+	s.pools[key] = state
+}
+
 // PoolState represents the current usage state of a constraint pool
 type PoolState struct {
 	ProviderID     string             `json:"provider_id"`
@@ -88,13 +118,36 @@ type PoolState struct {
 	Cost           currency.MicroUSD  `json:"cost,omitempty"`
 	ResetAt        time.Time          `json:"reset_at"`
 	LastUpdated    time.Time          `json:"last_updated"`
+	LastObservedAt time.Time          `json:"last_observed_at,omitempty"` // Time of the last successful usage observation
+	LastEventID    string             `json:"last_event_id,omitempty"`    // usage_observed event the state was taken from; cited as the cause of decisions
 	LatestForecast *forecast.Forecast `json:"latest_forecast,omitempty"`
+
+	// Granted is the budget taken by federation children and not counted by
+	// the provider yet: what they hold, and what they spent since the last
+	// observation. It is read from the grant ledger (see SetGrantLedger) and
+	// never stored.
+	Granted int64 `json:"granted,omitempty"`
+}
+
+// Available returns the remaining budget not held by federation children.
+func (s PoolState) Available() int64 {
+	return s.Remaining - s.Granted
+}
+
+// StaleSeconds returns the age of the last usage observation at now.
+// Pools that were never observed (e.g. restored from older snapshots) report 0.
+func (s PoolState) StaleSeconds(now time.Time) float64 {
+	if s.LastObservedAt.IsZero() || now.Before(s.LastObservedAt) {
+		return 0
+	}
+	return now.Sub(s.LastObservedAt).Seconds()
 }
 
 // UsageProjection maintains in-memory usage state per pool
 type UsageProjection struct {
 	mu             sync.RWMutex
 	store          UsageStore
+	grants         *GrantLedger
 	lastEventID    string
 	lastIngestTime time.Time
 }
@@ -111,6 +164,23 @@ func NewUsageProjectionWithStore(store UsageStore) *UsageProjection {
 	}
 }
 
+// SetGrantLedger holds the budget committed to federation children, as
+// tracked by ledger, out of the budget available in each pool.
+func (p *UsageProjection) SetGrantLedger(ledger *GrantLedger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.grants = ledger
+}
+
+// withGrants sets the budget committed to federation children in state.
+func (p *UsageProjection) withGrants(state PoolState) PoolState {
+	state.Granted = 0
+	if p.grants != nil {
+		state.Granted = p.grants.Committed(state.ProviderID, state.PoolID)
+	}
+	return state
+}
+
 // makePoolKey generates a unique key for a pool
 func makePoolKey(providerID, poolID string) string {
 	return fmt.Sprintf("%s:%s", providerID, poolID)
@@ -131,51 +201,7 @@ func (p *UsageProjection) Apply(event store.Event) error {
 		return p.applyReset(event)
 	case store.EventTypeForecastComputed:
 		return p.applyForecast(event)
-	case store.EventTypeGrantIssued:
-		return p.applyGrant(event)
-	}
-	return nil
-}
-
-func (p *UsageProjection) applyGrant(event store.Event) error {
-	var payload struct {
-		ProviderID string `json:"provider_id"`
-		FollowerID string `json:"follower_id"`
-		PoolID     string `json:"pool_id"`
-		Amount     int64  `json:"amount"`
-	}
-
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		return fmt.Errorf("failed to unmarshal grant payload: %w", err)
-	}
-
-	if payload.ProviderID == "" {
-		// Fallback for legacy events or incomplete data: try to infer or skip
-		// For now, logging error or returning nil is safer than crashing
-		return fmt.Errorf("grant event missing provider_id")
-	}
-
-	state, exists := p.store.Get(payload.ProviderID, payload.PoolID)
-	if !exists {
-		// If pool doesn't exist, we can create it or ignore.
-		// Creating it seems correct as we are tracking usage.
-		state = PoolState{
-			ProviderID: payload.ProviderID,
-			PoolID:     payload.PoolID,
-		}
 	}
-
-	// Grants deplete remaining budget and increase used count
-	state.Used += payload.Amount
-	state.Remaining -= payload.Amount
-	state.LastUpdated = event.TsIngest
-
-	p.store.Set(state)
-
-	// Update metrics
-	RatelordUsage.WithLabelValues(payload.ProviderID, payload.PoolID).Set(float64(state.Used))
-	RatelordLimit.WithLabelValues(payload.ProviderID, payload.PoolID).Set(float64(state.Remaining))
-
 	return nil
 }
 
@@ -192,18 +218,7 @@ func (p *UsageProjection) applyForecast(event store.Event) error {
 
 	RatelordForecastSeconds.WithLabelValues(payload.ProviderID, payload.PoolID).Set(float64(payload.Forecast.TTE.P99Seconds))
 
-	state, exists := p.store.Get(payload.ProviderID, payload.PoolID)
-	if !exists {
-		state = PoolState{
-			ProviderID: payload.ProviderID,
-			PoolID:     payload.PoolID,
-		}
-	}
-
-	state.LatestForecast = &payload.Forecast
-	state.LastUpdated = event.TsIngest
-
-	p.store.Set(state)
+	p.store.SetForecast(payload.ProviderID, payload.PoolID, payload.Forecast)
 
 	return nil
 }
@@ -215,12 +230,23 @@ func (p *UsageProjection) applyUsage(event store.Event) error {
 		Used       int64             `json:"used"`
 		Remaining  int64             `json:"remaining"`
 		Cost       currency.MicroUSD `json:"cost"`
+		Delta      *int64            `json:"delta"` // Set for usage recorded per intent
 	}
 
 	if err := json.Unmarshal(event.Payload, &payload); err != nil {
 		return fmt.Errorf("failed to unmarshal usage payload: %w", err)
 	}
 
+	if payload.Delta != nil {
+		// Usage recorded per intent adds up with that of other daemons
+		p.store.Increment(payload.ProviderID, payload.PoolID, *payload.Delta, -*payload.Delta, payload.Cost)
+		if state, ok := p.store.Get(payload.ProviderID, payload.PoolID); ok {
+			RatelordUsage.WithLabelValues(payload.ProviderID, payload.PoolID).Set(float64(state.Used))
+			RatelordLimit.WithLabelValues(payload.ProviderID, payload.PoolID).Set(float64(state.Remaining))
+		}
+		return nil
+	}
+
 	state, exists := p.store.Get(payload.ProviderID, payload.PoolID)
 	if !exists {
 		state = PoolState{
@@ -236,6 +262,8 @@ func (p *UsageProjection) applyUsage(event store.Event) error {
 	state.Remaining = payload.Remaining
 	state.Cost = payload.Cost
 	state.LastUpdated = event.TsIngest
+	state.LastObservedAt = event.TsEvent
+	state.LastEventID = string(event.EventID)
 
 	p.store.Set(state)
 
@@ -257,19 +285,20 @@ func (p *UsageProjection) applyReset(event store.Event) error {
 		return fmt.Errorf("failed to unmarshal reset payload: %w", err)
 	}
 
-	state, exists := p.store.Get(payload.ProviderID, payload.PoolID)
-	if !exists {
-		state = PoolState{
-			ProviderID: payload.ProviderID,
-			PoolID:     payload.PoolID,
-		}
-	}
+	p.store.SetResetAt(payload.ProviderID, payload.PoolID, payload.ResetAt)
+	return nil
+}
 
-	state.ResetAt = payload.ResetAt
-	state.LastUpdated = event.TsIngest
+// SetLatestForecast attaches a forecast computed outside the event stream
+// (e.g. by the live forecaster) to an existing pool.
+func (p *UsageProjection) SetLatestForecast(providerID, poolID string, fc forecast.Forecast) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	p.store.Set(state)
-	return nil
+	if _, exists := p.store.Get(providerID, poolID); !exists {
+		return
+	}
+	p.store.SetForecast(providerID, poolID, fc)
 }
 
 // Replay rebuilds the projection from a slice of events
@@ -296,16 +325,26 @@ func (p *UsageProjection) LoadState(lastEventID string, lastIngestTime time.Time
 	p.store.Clear()
 
 	for _, pool := range pools {
+		pool.Granted = 0 // Read from the grant ledger
 		p.store.Set(pool)
 	}
 }
 
+// Reset drops all pool states
+func (p *UsageProjection) Reset() {
+	p.LoadState("", time.Time{}, nil)
+}
+
 // GetPoolState returns the state for a specific pool
 func (p *UsageProjection) GetPoolState(providerID, poolID string) (PoolState, bool) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	return p.store.Get(providerID, poolID)
+	state, ok := p.store.Get(providerID, poolID)
+	if !ok {
+		return PoolState{}, false
+	}
+	return p.withGrants(state), true
 }
 
 // GetState returns the current state and the last applied event ID/Timestamp
@@ -313,7 +352,11 @@ func (p *UsageProjection) GetState() (string, time.Time, []PoolState) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	return p.lastEventID, p.lastIngestTime, p.store.GetAll()
+	pools := p.store.GetAll()
+	for i := range pools {
+		pools[i] = p.withGrants(pools[i])
+	}
+	return p.lastEventID, p.lastIngestTime, pools
 }
 
 // GetResetAt returns the reset time for a specific pool
@@ -206,6 +206,16 @@ func (p *Projection) Replay(events []*store.Event) error {
 	return nil
 }
 
+// Reset drops the whole graph.
+func (p *Projection) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.graph = NewGraph()
+	p.scopeConstraints = make(map[string][]string)
+	p.lastEventID = ""
+	p.lastIngestTime = time.Time{}
+}
+
 // FindConstraintsForScope returns all constraint nodes that apply to the given scope.
 func (p *Projection) FindConstraintsForScope(scopeID string) ([]*Node, error) {
 	p.mu.RLock()
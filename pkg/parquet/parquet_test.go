@@ -0,0 +1,78 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSchema = []Column{
+	{Name: "id", Type: String},
+	{Name: "payload", Type: JSON},
+	{Name: "version", Type: Int32},
+	{Name: "amount", Type: Int64},
+	{Name: "ts", Type: Timestamp},
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, testSchema)
+	w.RowGroupSize = 3 // Several row groups, the last one short
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 123456000, time.UTC)
+	var want [][]interface{}
+	for i := 0; i < 7; i++ {
+		row := []interface{}{
+			fmt.Sprintf("evt_%d", i),
+			fmt.Sprintf(`{"n":%d}`, i),
+			int32(i),
+			int64(-i) * 1 << 40,
+			base.Add(time.Duration(i) * time.Hour),
+		}
+		want = append(want, row)
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := w.Write(want[0]); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	schema, rows, err := ReadFile(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !reflect.DeepEqual(schema, testSchema) {
+		t.Errorf("schema mismatch: %+v", schema)
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows mismatch:\ngot  %v\nwant %v", rows, want)
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, testSchema)
+	if err := w.Write([]interface{}{"a"}); err == nil {
+		t.Error("expected a short row to fail")
+	}
+	if err := w.Write([]interface{}{"a", "{}", 1, int64(1), time.Now()}); err == nil {
+		t.Error("expected an int for an Int32 column to fail")
+	}
+
+	// An empty file is still valid
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, testSchema).Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, rows, err := ReadFile(buf.Bytes()); err != nil || len(rows) != 0 {
+		t.Errorf("expected an empty file, got %d rows (err %v)", len(rows), err)
+	}
+	if _, _, err := ReadFile([]byte("not parquet at all")); err == nil {
+		t.Error("expected garbage to be rejected")
+	}
+}
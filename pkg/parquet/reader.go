@@ -0,0 +1,175 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ReadFile decodes a file written by Writer: a flat schema of required
+// columns with PLAIN-encoded data pages, uncompressed or gzip. It returns
+// the schema and the rows; values have the Go types accepted by Write, with
+// JSON columns as strings.
+func ReadFile(data []byte) ([]Column, [][]interface{}, error) {
+	n := len(data)
+	if n < 12 || !bytes.Equal(data[:4], magic) || !bytes.Equal(data[n-4:], magic) {
+		return nil, nil, fmt.Errorf("parquet: not a parquet file")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[n-8 : n-4]))
+	if footerLen <= 0 || footerLen > n-12 {
+		return nil, nil, fmt.Errorf("parquet: invalid footer length")
+	}
+	r := &thriftReader{buf: data[n-8-footerLen : n-8]}
+	meta := r.readStruct()
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+
+	schema, err := readSchema(meta.list(2))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows [][]interface{}
+	for _, g := range meta.list(4) {
+		rg, _ := g.(thriftStruct)
+		chunks := rg.list(1)
+		if len(chunks) != len(schema) {
+			return nil, nil, fmt.Errorf("parquet: row group has %d columns, schema has %d", len(chunks), len(schema))
+		}
+		numRows := int(rg.i64(3))
+		groupRows := make([][]interface{}, numRows)
+		for i := range groupRows {
+			groupRows[i] = make([]interface{}, len(schema))
+		}
+		for c, ch := range chunks {
+			cm := ch.(thriftStruct).child(3)
+			values, err := readColumnChunk(data, cm, schema[c], numRows)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parquet: column %s: %w", schema[c].Name, err)
+			}
+			for i, v := range values {
+				groupRows[i][c] = v
+			}
+		}
+		rows = append(rows, groupRows...)
+	}
+	return schema, rows, nil
+}
+
+func readSchema(elements []interface{}) ([]Column, error) {
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("parquet: empty schema")
+	}
+	var schema []Column
+	for _, e := range elements[1:] {
+		el, _ := e.(thriftStruct)
+		if el.i64(3) != repetitionRequired || el.i64(5) != 0 {
+			return nil, fmt.Errorf("parquet: only flat schemas of required columns are supported")
+		}
+		col := Column{Name: el.str(4)}
+		_, hasConverted := el[6]
+		switch converted := el.i64(6); {
+		case el.i64(1) == physicalByteArray && hasConverted && converted == convertedJSON:
+			col.Type = JSON
+		case el.i64(1) == physicalByteArray:
+			col.Type = String
+		case el.i64(1) == physicalInt32:
+			col.Type = Int32
+		case el.i64(1) == physicalInt64 && hasConverted && converted == convertedTimestampMicros:
+			col.Type = Timestamp
+		case el.i64(1) == physicalInt64:
+			col.Type = Int64
+		default:
+			return nil, fmt.Errorf("parquet: unsupported type for column %s", col.Name)
+		}
+		schema = append(schema, col)
+	}
+	return schema, nil
+}
+
+func readColumnChunk(data []byte, cm thriftStruct, col Column, numRows int) ([]interface{}, error) {
+	codec := cm.i64(4)
+	pos := int(cm.i64(9))
+	values := make([]interface{}, 0, numRows)
+	for len(values) < numRows {
+		if pos <= 0 || pos >= len(data) {
+			return nil, fmt.Errorf("invalid page offset")
+		}
+		r := &thriftReader{buf: data, pos: pos}
+		header := r.readStruct()
+		if r.err != nil {
+			return nil, r.err
+		}
+		size := int(header.i64(3))
+		if header.i64(1) != pageTypeData || r.pos+size > len(data) {
+			return nil, fmt.Errorf("unsupported page")
+		}
+		page := data[r.pos : r.pos+size]
+		pos = r.pos + size
+
+		switch codec {
+		case 0:
+		case codecGzip:
+			gz, err := gzip.NewReader(bytes.NewReader(page))
+			if err != nil {
+				return nil, err
+			}
+			if page, err = io.ReadAll(gz); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported codec %d", codec)
+		}
+
+		dph := header.child(5)
+		if dph.i64(2) != encodingPlain {
+			return nil, fmt.Errorf("unsupported encoding %d", dph.i64(2))
+		}
+		decoded, err := decodePlain(page, col, int(dph.i64(1)))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, decoded...)
+	}
+	return values, nil
+}
+
+func decodePlain(page []byte, col Column, n int) ([]interface{}, error) {
+	values := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		switch col.Type {
+		case String, JSON:
+			if len(page) < 4 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			l := int(binary.LittleEndian.Uint32(page))
+			if len(page) < 4+l {
+				return nil, io.ErrUnexpectedEOF
+			}
+			values = append(values, string(page[4:4+l]))
+			page = page[4+l:]
+		case Int32:
+			if len(page) < 4 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			values = append(values, int32(binary.LittleEndian.Uint32(page)))
+			page = page[4:]
+		case Int64, Timestamp:
+			if len(page) < 8 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			v := int64(binary.LittleEndian.Uint64(page))
+			if col.Type == Timestamp {
+				values = append(values, time.UnixMicro(v).UTC())
+			} else {
+				values = append(values, v)
+			}
+			page = page[8:]
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,259 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Parquet metadata is serialized with the Thrift compact protocol. Only the
+// subset needed for the structures in this package is implemented.
+
+const (
+	tStop   = 0
+	tTrue   = 1
+	tFalse  = 2
+	tByte   = 3
+	tI16    = 4
+	tI32    = 5
+	tI64    = 6
+	tDouble = 7
+	tBinary = 8
+	tList   = 9
+	tSet    = 10
+	tMap    = 11
+	tStruct = 12
+)
+
+var errThrift = errors.New("parquet: malformed metadata")
+
+// thriftWriter encodes one struct at a time. Nested structs push the field
+// ID of their parent.
+type thriftWriter struct {
+	buf     []byte
+	lastID  int16
+	idStack []int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - w.lastID; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	w.lastID = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, tI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, tI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(id int16, v string) {
+	w.fieldHeader(id, tBinary)
+	w.varint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *thriftWriter) listHeader(id int16, elem byte, n int) {
+	w.fieldHeader(id, tList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elem)
+		return
+	}
+	w.buf = append(w.buf, 0xF0|elem)
+	w.varint(uint64(n))
+}
+
+func (w *thriftWriter) i32List(id int16, values []int32) {
+	w.listHeader(id, tI32, len(values))
+	for _, v := range values {
+		w.zigzag(int64(v))
+	}
+}
+
+func (w *thriftWriter) binaryList(id int16, values []string) {
+	w.listHeader(id, tBinary, len(values))
+	for _, v := range values {
+		w.varint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	}
+}
+
+// beginStruct starts a struct-valued field; id 0 starts a list element.
+func (w *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		w.fieldHeader(id, tStruct)
+	}
+	w.idStack = append(w.idStack, w.lastID)
+	w.lastID = 0
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf = append(w.buf, tStop)
+	w.lastID = w.idStack[len(w.idStack)-1]
+	w.idStack = w.idStack[:len(w.idStack)-1]
+}
+
+// thriftReader decodes compact-protocol structs as generic field maps.
+type thriftReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+// tStruct values decode to thriftStruct, lists to []interface{}, integers to
+// int64, binaries to string and booleans to bool.
+type thriftStruct map[int16]interface{}
+
+func (s thriftStruct) i64(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStruct) str(id int16) string {
+	v, _ := s[id].(string)
+	return v
+}
+
+func (s thriftStruct) child(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
+
+func (s thriftStruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+func (r *thriftReader) fail() {
+	if r.err == nil {
+		r.err = errThrift
+	}
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		r.fail()
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() thriftStruct {
+	s := thriftStruct{}
+	var lastID int16
+	for r.err == nil {
+		h := r.byte()
+		typ := h & 0x0F
+		if typ == tStop {
+			return s
+		}
+		id := lastID + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		lastID = id
+		switch typ {
+		case tTrue, tFalse:
+			s[id] = typ == tTrue
+		default:
+			s[id] = r.value(typ)
+		}
+	}
+	return s
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case tTrue, tFalse:
+		// Only list elements reach here; booleans in fields are handled
+		// by readStruct
+		return r.byte() == tTrue
+	case tByte:
+		return int64(int8(r.byte()))
+	case tI16, tI32, tI64:
+		return r.zigzag()
+	case tDouble:
+		if r.pos+8 > len(r.buf) {
+			r.fail()
+			return nil
+		}
+		r.pos += 8
+		return nil
+	case tBinary:
+		n := int(r.varint())
+		if r.err != nil || n < 0 || r.pos+n > len(r.buf) {
+			r.fail()
+			return ""
+		}
+		v := string(r.buf[r.pos : r.pos+n])
+		r.pos += n
+		return v
+	case tList, tSet:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.varint())
+		}
+		if n > len(r.buf) {
+			r.fail()
+			return nil
+		}
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			values = append(values, r.value(h&0x0F))
+		}
+		return values
+	case tMap:
+		n := int(r.varint())
+		if n == 0 {
+			return nil
+		}
+		kv := r.byte()
+		for i := 0; i < n && r.err == nil; i++ {
+			r.value(kv >> 4)
+			r.value(kv & 0x0F)
+		}
+		return nil
+	case tStruct:
+		return r.readStruct()
+	default:
+		r.err = fmt.Errorf("parquet: unknown thrift type %d", typ)
+		return nil
+	}
+}
@@ -0,0 +1,334 @@
+// Package parquet writes and reads flat Apache Parquet files.
+//
+// It supports what ratelord exports need: a flat schema of required string,
+// JSON, integer and timestamp columns, PLAIN encoding and gzip compression.
+// Files can be read by any Parquet reader (DuckDB, Spark, pyarrow); Reader
+// only reads files of the same shape.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ColumnType is the type of a column's values.
+type ColumnType int
+
+const (
+	String    ColumnType = iota // UTF-8 string (string)
+	JSON                        // JSON document (string or []byte)
+	Int32                       // int32
+	Int64                       // int64
+	Timestamp                   // time.Time, stored as UTC microseconds
+)
+
+// Column is a field of a flat schema. All columns are required.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Parquet enum values used in the file metadata.
+const (
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+	convertedJSON            = 19
+
+	repetitionRequired = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	pageTypeData       = 0
+	codecGzip          = 2
+)
+
+var magic = []byte("PAR1")
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is
+// written.
+const DefaultRowGroupSize = 64 * 1024
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("parquet: writer is closed")
+
+type columnChunkMeta struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type rowGroupMeta struct {
+	numRows int64
+	size    int64
+	columns []columnChunkMeta
+}
+
+// Writer writes rows to a Parquet file. Rows are buffered and written a row
+// group at a time; Close writes the footer and must be called.
+type Writer struct {
+	w            io.Writer
+	schema       []Column
+	offset       int64
+	RowGroupSize int
+
+	columns   []bytes.Buffer // PLAIN-encoded values of the pending rows
+	rows      int
+	rowGroups []rowGroupMeta
+	closed    bool
+}
+
+// NewWriter starts a Parquet file on w.
+func NewWriter(w io.Writer, schema []Column) *Writer {
+	return &Writer{
+		w:            w,
+		schema:       schema,
+		RowGroupSize: DefaultRowGroupSize,
+		columns:      make([]bytes.Buffer, len(schema)),
+	}
+}
+
+// Write buffers one row. Values must match the schema's column types.
+func (w *Writer) Write(row []interface{}) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if len(row) != len(w.schema) {
+		return fmt.Errorf("parquet: row has %d values, schema has %d columns", len(row), len(w.schema))
+	}
+	for i, col := range w.schema {
+		if err := encodeValue(&w.columns[i], col, row[i]); err != nil {
+			return err
+		}
+	}
+	w.rows++
+	if w.rows >= w.RowGroupSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered rows as a row group.
+func (w *Writer) Flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if w.offset == 0 {
+		if err := w.write(magic); err != nil {
+			return err
+		}
+	}
+
+	rg := rowGroupMeta{numRows: int64(w.rows)}
+	for i := range w.schema {
+		chunk, err := w.writeColumnChunk(&w.columns[i])
+		if err != nil {
+			return err
+		}
+		w.columns[i].Reset()
+		rg.size += chunk.uncompressedSize
+		rg.columns = append(rg.columns, chunk)
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+	w.rows = 0
+	return nil
+}
+
+// writeColumnChunk writes the values as one gzip-compressed data page.
+func (w *Writer) writeColumnChunk(values *bytes.Buffer) (columnChunkMeta, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(values.Bytes()); err != nil {
+		return columnChunkMeta{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return columnChunkMeta{}, err
+	}
+
+	var h thriftWriter
+	h.beginStruct(0)
+	h.i32(1, pageTypeData)
+	h.i32(2, int32(values.Len()))
+	h.i32(3, int32(compressed.Len()))
+	h.beginStruct(5)
+	h.i32(1, int32(w.rows))
+	h.i32(2, encodingPlain)
+	h.i32(3, encodingRLE)
+	h.i32(4, encodingRLE)
+	h.endStruct()
+	h.endStruct()
+
+	chunk := columnChunkMeta{
+		offset:           w.offset,
+		numValues:        int64(w.rows),
+		uncompressedSize: int64(len(h.buf) + values.Len()),
+		compressedSize:   int64(len(h.buf) + compressed.Len()),
+	}
+	if err := w.write(h.buf); err != nil {
+		return chunk, err
+	}
+	return chunk, w.write(compressed.Bytes())
+}
+
+// Close flushes pending rows and writes the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	if w.offset == 0 {
+		if err := w.write(magic); err != nil {
+			return err
+		}
+	}
+
+	footer := w.fileMetadata()
+	var tail [4]byte
+	binary.LittleEndian.PutUint32(tail[:], uint32(len(footer)))
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(tail[:]); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+func (w *Writer) fileMetadata() []byte {
+	var numRows int64
+	for _, rg := range w.rowGroups {
+		numRows += rg.numRows
+	}
+
+	var t thriftWriter
+	t.beginStruct(0)
+	t.i32(1, 1) // version
+
+	t.listHeader(2, tStruct, len(w.schema)+1)
+	t.beginStruct(0)
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.schema)))
+	t.endStruct()
+	for _, col := range w.schema {
+		physical, converted := col.Type.physical()
+		t.beginStruct(0)
+		t.i32(1, physical)
+		t.i32(3, repetitionRequired)
+		t.binary(4, col.Name)
+		if converted >= 0 {
+			t.i32(6, converted)
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, numRows)
+
+	t.listHeader(4, tStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		t.beginStruct(0)
+		t.listHeader(1, tStruct, len(rg.columns))
+		for i, chunk := range rg.columns {
+			physical, _ := w.schema[i].Type.physical()
+			t.beginStruct(0)
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			t.i32(1, physical)
+			t.i32List(2, []int32{encodingPlain, encodingRLE})
+			t.binaryList(3, []string{w.schema[i].Name})
+			t.i32(4, codecGzip)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, rg.size)
+		t.i64(3, rg.numRows)
+		t.endStruct()
+	}
+
+	t.binary(6, "ratelord")
+	t.endStruct()
+	return t.buf
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+// physical returns the Parquet physical and converted types; -1 means no
+// converted type.
+func (t ColumnType) physical() (int32, int32) {
+	switch t {
+	case String:
+		return physicalByteArray, convertedUTF8
+	case JSON:
+		return physicalByteArray, convertedJSON
+	case Int32:
+		return physicalInt32, -1
+	case Timestamp:
+		return physicalInt64, convertedTimestampMicros
+	default:
+		return physicalInt64, -1
+	}
+}
+
+func encodeValue(buf *bytes.Buffer, col Column, v interface{}) error {
+	var scratch [8]byte
+	switch col.Type {
+	case String, JSON:
+		var s []byte
+		switch x := v.(type) {
+		case string:
+			s = []byte(x)
+		case []byte:
+			s = x
+		default:
+			return typeError(col, v)
+		}
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+		buf.Write(scratch[:4])
+		buf.Write(s)
+	case Int32:
+		x, ok := v.(int32)
+		if !ok {
+			return typeError(col, v)
+		}
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(x))
+		buf.Write(scratch[:4])
+	case Int64:
+		x, ok := v.(int64)
+		if !ok {
+			return typeError(col, v)
+		}
+		binary.LittleEndian.PutUint64(scratch[:], uint64(x))
+		buf.Write(scratch[:])
+	case Timestamp:
+		x, ok := v.(time.Time)
+		if !ok {
+			return typeError(col, v)
+		}
+		binary.LittleEndian.PutUint64(scratch[:], uint64(x.UnixMicro()))
+		buf.Write(scratch[:])
+	}
+	return nil
+}
+
+func typeError(col Column, v interface{}) error {
+	return fmt.Errorf("parquet: invalid value %T for column %s", v, col.Name)
+}
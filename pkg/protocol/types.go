@@ -49,6 +49,9 @@ type GrantRequest struct {
 	PoolID     string                 `json:"pool_id"`
 	Amount     int64                  `json:"amount"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"` // Added for M34.2
+	Held       int64                  `json:"held,omitempty"`     // Unused budget left from earlier grants
+	Used       int64                  `json:"used,omitempty"`     // Units consumed since the follower started
+	GrantID    string                 `json:"grant_id,omitempty"` // Lease to renew; empty to open one
 }
 
 // GrantResponse matches the response for POST /v1/federation/grant
@@ -56,4 +59,39 @@ type GrantResponse struct {
 	Granted         int64     `json:"granted"`
 	ValidUntil      time.Time `json:"valid_until"`
 	RemainingGlobal int64     `json:"remaining_global,omitempty"`
+	GrantID         string    `json:"grant_id,omitempty"`   // Lease holding the budget granted so far
+	ReclaimAt       time.Time `json:"reclaim_at,omitempty"` // Lease expiry: unused budget is reclaimed unless the follower renews by then
+}
+
+// ReturnRequest matches the POST /v1/federation/return body schema
+type ReturnRequest struct {
+	FollowerID string `json:"follower_id"`
+	ProviderID string `json:"provider_id"`
+	PoolID     string `json:"pool_id"`
+	GrantID    string `json:"grant_id"`
+	Amount     int64  `json:"amount"` // Unused budget given back
+	Used       int64  `json:"used,omitempty"`
+}
+
+// ReturnResponse matches the response for POST /v1/federation/return
+type ReturnResponse struct {
+	Returned int64 `json:"returned"`
+}
+
+// ObservationRequest matches the POST /v1/observations body schema.
+// Clients forward the rate-limit headers seen on real provider responses.
+type ObservationRequest struct {
+	ProviderID string            `json:"provider_id"`
+	AgentID    string            `json:"agent_id"`
+	IdentityID string            `json:"identity_id"`
+	WorkloadID string            `json:"workload_id"`
+	ScopeID    string            `json:"scope_id"`
+	Headers    map[string]string `json:"headers"`
+	ObservedAt time.Time         `json:"observed_at,omitempty"` // Defaults to receipt time
+}
+
+// ObservationResponse matches the response for POST /v1/observations
+type ObservationResponse struct {
+	Accepted int      `json:"accepted"` // Number of pools observed
+	EventIDs []string `json:"event_ids"`
 }
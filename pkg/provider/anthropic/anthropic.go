@@ -0,0 +1,201 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+)
+
+const (
+	// DefaultHeaderPrefix is the rate limit header family used by Anthropic.
+	DefaultHeaderPrefix = "anthropic-ratelimit"
+
+	// DefaultAPIVersion is sent as 'anthropic-version'.
+	DefaultAPIVersion = "2023-06-01"
+)
+
+// metrics are the header families reported by token-bucket style LLM APIs,
+// e.g. anthropic-ratelimit-input-tokens-remaining. Each becomes its own pool.
+var metrics = []struct {
+	header string
+	pool   string
+}{
+	{"requests", "requests"},
+	{"tokens", "tokens"},
+	{"input-tokens", "input_tokens"},
+	{"output-tokens", "output_tokens"},
+}
+
+// defaultPricing is the list price in micro-USD per unit (USD per million tokens).
+var defaultPricing = map[string]int64{
+	"input_tokens":  3,
+	"output_tokens": 15,
+}
+
+// Options holds optional provider settings.
+type Options struct {
+	// HeaderPrefix selects the header family, allowing other vendors with the
+	// same <prefix>-<metric>-limit|remaining|reset layout. Defaults to "anthropic-ratelimit".
+	HeaderPrefix string
+
+	// PoolPrefix names the pools ("<prefix>:input_tokens"). Defaults to "anthropic".
+	PoolPrefix string
+
+	// Model enables polling via a 1-token message. Without it, Poll only lists
+	// models, and usage is expected to arrive via passive observations.
+	Model string
+}
+
+// AnthropicProvider tracks Anthropic-style token-bucket limits. Requests,
+// input tokens and output tokens are separate pools with RFC3339 reset times.
+type AnthropicProvider struct {
+	id      provider.ProviderID
+	token   string
+	baseURL string
+	opts    Options
+	client  *http.Client
+}
+
+func NewAnthropicProvider(id provider.ProviderID, token string, baseURL string, opts Options) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+	if opts.HeaderPrefix == "" {
+		opts.HeaderPrefix = DefaultHeaderPrefix
+	}
+	if opts.PoolPrefix == "" {
+		opts.PoolPrefix = "anthropic"
+	}
+	return &AnthropicProvider{
+		id:      id,
+		token:   token,
+		baseURL: baseURL,
+		opts:    opts,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *AnthropicProvider) ID() provider.ProviderID {
+	return a.id
+}
+
+// Poll captures rate limit headers from a cheap request. With a model
+// configured it sends a 1-token message, which consumes a small amount of quota.
+func (a *AnthropicProvider) Poll(ctx context.Context) (provider.PollResult, error) {
+	base := strings.TrimRight(a.baseURL, "/")
+
+	var req *http.Request
+	var err error
+	if a.opts.Model != "" {
+		body, _ := json.Marshal(map[string]interface{}{
+			"model":      a.opts.Model,
+			"max_tokens": 1,
+			"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+		})
+		req, err = http.NewRequestWithContext(ctx, "POST", base+"/messages", bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "GET", base+"/models", nil)
+	}
+	if err != nil {
+		return provider.PollResult{}, err
+	}
+	if a.token != "" {
+		req.Header.Set("x-api-key", a.token)
+	}
+	req.Header.Set("anthropic-version", DefaultAPIVersion)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return provider.PollResult{ProviderID: a.id, Status: "error", Error: err, Timestamp: time.Now()}, nil
+	}
+	defer resp.Body.Close()
+
+	// 429 still carries the headers, so it counts as a successful poll.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+		return provider.PollResult{ProviderID: a.id, Status: "error", Error: fmt.Errorf("HTTP %d", resp.StatusCode), Timestamp: time.Now()}, nil
+	}
+
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+	}
+
+	return provider.PollResult{
+		ProviderID: a.id,
+		Status:     "success",
+		Timestamp:  time.Now(),
+		Usage:      a.ParseHeaders(resp.Header, time.Now()),
+		State:      nil, // stateless
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+// ParseHeaders maps each header family present to a pool:
+//
+//	anthropic-ratelimit-input-tokens-limit: 400000
+//	anthropic-ratelimit-input-tokens-remaining: 399000
+//	anthropic-ratelimit-input-tokens-reset: 2025-01-01T00:01:00Z
+func (a *AnthropicProvider) ParseHeaders(header http.Header, now time.Time) []provider.UsageObservation {
+	var usages []provider.UsageObservation
+
+	for _, m := range metrics {
+		prefix := fmt.Sprintf("%s-%s-", a.opts.HeaderPrefix, m.header)
+		limitStr := header.Get(prefix + "limit")
+		remStr := header.Get(prefix + "remaining")
+		if limitStr == "" || remStr == "" {
+			continue
+		}
+
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		rem, err := strconv.ParseInt(remStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// If parse fails, use now (conservative).
+		resetAt := now
+		if t, err := time.Parse(time.RFC3339, header.Get(prefix+"reset")); err == nil {
+			resetAt = t
+		}
+
+		usages = append(usages, provider.UsageObservation{
+			PoolID:    a.opts.PoolPrefix + ":" + m.pool,
+			Used:      limit - rem,
+			Remaining: rem,
+			Limit:     limit,
+			ResetAt:   resetAt,
+		})
+	}
+
+	return usages
+}
+
+// DefaultPricing returns per-token list prices in micro-USD keyed by pool ID.
+// Entries under pricing.<provider_id> in the policy take precedence.
+func (a *AnthropicProvider) DefaultPricing() map[string]int64 {
+	pricing := make(map[string]int64, len(defaultPricing))
+	for pool, cost := range defaultPricing {
+		pricing[a.opts.PoolPrefix+":"+pool] = cost
+	}
+	return pricing
+}
+
+func (a *AnthropicProvider) Restore(state []byte) error {
+	// No-op, stateless
+	return nil
+}
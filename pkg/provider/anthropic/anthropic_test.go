@@ -0,0 +1,138 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+)
+
+// fixture is a recorded API response.
+type fixture struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+func loadFixture(t *testing.T, name string) fixture {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+	return f
+}
+
+// replay serves a fixture and checks the request shape.
+func replay(t *testing.T, f fixture) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/messages" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "fake-key" {
+			t.Errorf("Expected x-api-key header, got %q", r.Header.Get("x-api-key"))
+		}
+		if r.Header.Get("anthropic-version") != DefaultAPIVersion {
+			t.Errorf("Expected anthropic-version header, got %q", r.Header.Get("anthropic-version"))
+		}
+		for k, v := range f.Headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(f.Status)
+		w.Write(f.Body)
+	}))
+}
+
+func poolsByID(usages []provider.UsageObservation) map[string]provider.UsageObservation {
+	m := make(map[string]provider.UsageObservation, len(usages))
+	for _, u := range usages {
+		m[u.PoolID] = u
+	}
+	return m
+}
+
+func TestPoll_Fixture(t *testing.T) {
+	server := replay(t, loadFixture(t, "messages_ok.json"))
+	defer server.Close()
+
+	p := NewAnthropicProvider("claude", "fake-key", server.URL, Options{Model: "claude-sonnet-4-5"})
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s): %v", err, result.Status, result.Error)
+	}
+
+	pools := poolsByID(result.Usage)
+	if len(pools) != 4 {
+		t.Fatalf("Expected 4 pools, got %v", pools)
+	}
+	input := pools["anthropic:input_tokens"]
+	if input.Limit != 400000 || input.Remaining != 399000 || input.Used != 1000 {
+		t.Errorf("Unexpected input_tokens pool: %+v", input)
+	}
+	if !input.ResetAt.Equal(time.Date(2025, 1, 1, 0, 0, 9, 0, time.UTC)) {
+		t.Errorf("Unexpected input_tokens reset: %v", input.ResetAt)
+	}
+	if pools["anthropic:output_tokens"].Used != 100 {
+		t.Errorf("Unexpected output_tokens pool: %+v", pools["anthropic:output_tokens"])
+	}
+	if pools["anthropic:requests"].Remaining != 3999 {
+		t.Errorf("Unexpected requests pool: %+v", pools["anthropic:requests"])
+	}
+}
+
+func TestPoll_RateLimitedFixture(t *testing.T) {
+	server := replay(t, loadFixture(t, "messages_rate_limited.json"))
+	defer server.Close()
+
+	p := NewAnthropicProvider("claude", "fake-key", server.URL, Options{Model: "claude-sonnet-4-5"})
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s): %v", err, result.Status, result.Error)
+	}
+	if result.RetryAfter != 12*time.Second {
+		t.Errorf("Expected retry after 12s, got %v", result.RetryAfter)
+	}
+	pools := poolsByID(result.Usage)
+	if len(pools) != 2 || pools["anthropic:output_tokens"].Remaining != 0 {
+		t.Errorf("Unexpected pools: %v", pools)
+	}
+}
+
+func TestParseHeaders_GenericPrefix(t *testing.T) {
+	p := NewAnthropicProvider("vendor", "", "", Options{HeaderPrefix: "x-vendor-ratelimit", PoolPrefix: "vendor"})
+	now := time.Now()
+
+	h := http.Header{}
+	h.Set("x-vendor-ratelimit-input-tokens-limit", "1000")
+	h.Set("x-vendor-ratelimit-input-tokens-remaining", "750")
+	h.Set("x-vendor-ratelimit-input-tokens-reset", "not-a-time")
+
+	usages := p.ParseHeaders(h, now)
+	if len(usages) != 1 || usages[0].PoolID != "vendor:input_tokens" || usages[0].Used != 250 {
+		t.Fatalf("Unexpected usages: %+v", usages)
+	}
+	if !usages[0].ResetAt.Equal(now) {
+		t.Errorf("Expected unparseable reset to default to now, got %v", usages[0].ResetAt)
+	}
+}
+
+func TestDefaultPricing(t *testing.T) {
+	p := NewAnthropicProvider("claude", "", "", Options{})
+	pricing := p.DefaultPricing()
+	if pricing["anthropic:input_tokens"] != 3 || pricing["anthropic:output_tokens"] != 15 {
+		t.Errorf("Unexpected default pricing: %v", pricing)
+	}
+	if _, ok := pricing["anthropic:requests"]; ok {
+		t.Error("Requests should not be priced")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -20,6 +21,7 @@ type FederatedProvider struct {
 	leaderURL   string
 	followerID  string
 	client      *http.Client
+	grantMu     sync.Mutex // Serializes grant requests; taken before mu
 	mu          sync.RWMutex
 	pools       map[string]*PoolState // poolID -> State
 	defaultPoll time.Duration
@@ -30,6 +32,8 @@ type PoolState struct {
 	Granted    int64
 	UsedLocal  int64
 	ValidUntil time.Time
+	GrantID    string    // Lease the leader holds our grants under
+	ReclaimAt  time.Time // The leader takes back the unused grant unless the lease is renewed by then
 }
 
 // NewFederatedProvider creates a new follower provider
@@ -65,6 +69,8 @@ func (p *FederatedProvider) TrackUsage(poolID string, amount int64) {
 
 // Poll contacts the leader to refresh grants and reports current state
 func (p *FederatedProvider) Poll(ctx context.Context) (provider.PollResult, error) {
+	p.grantMu.Lock()
+	defer p.grantMu.Unlock()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -81,6 +87,14 @@ func (p *FederatedProvider) Poll(ctx context.Context) (provider.PollResult, erro
 	var pollErrors []error
 
 	for poolID, state := range p.pools {
+		// The leader reclaimed what we did not use while we could not reach
+		// it; using it now would spend budget handed out again
+		if !state.ReclaimAt.IsZero() && time.Now().After(state.ReclaimAt) && state.Granted > state.UsedLocal {
+			fmt.Printf("federated_provider: grant for %s reclaimed by the leader, forfeiting %d\n", poolID, state.Granted-state.UsedLocal)
+			state.Granted = state.UsedLocal
+			state.GrantID = ""
+		}
+
 		// Logic: If remaining is low (< 20%) or expired, ask for more.
 		// Remaining = Granted - UsedLocal
 		remaining := state.Granted - state.UsedLocal
@@ -95,36 +109,18 @@ func (p *FederatedProvider) Poll(ctx context.Context) (provider.PollResult, erro
 		}
 
 		if needsGrant {
-			// Request Grant
-			// Assume a default request amount or base it on burn rate.
-			// Simple start: Ask for 1000 or 2x used.
+			// Request Grant, renewing our lease. The leader sizes grants
+			// to our consumption rate once it has measured it; until then
+			// it grants what we ask.
 			askAmount := int64(1000)
 
-			req := protocol.GrantRequest{
-				FollowerID: p.followerID,
-				ProviderID: string(p.id),
-				PoolID:     poolID,
-				Amount:     askAmount,
-				Metadata: map[string]interface{}{
-					"uptime":  time.Since(p.startTime).String(),
-					"version": version.Version,
-				},
-			}
-
 			// Call Leader
-			granted, validUntil, err := p.requestGrant(ctx, req)
-			if err != nil {
+			if err := p.grant(ctx, poolID, state, askAmount); err != nil {
 				// Log error but continue with other pools
 				err = fmt.Errorf("failed to get grant for %s: %w", poolID, err)
 				pollErrors = append(pollErrors, err)
 				fmt.Printf("federated_provider: %v\n", err)
 			} else {
-				// Update State
-				// Strategy: New grant *adds* to existing? Or replaces?
-				// Leader implementation (M30.1) just returns an amount.
-				// If Leader "deducts from global", we "add to local".
-				state.Granted += granted
-				state.ValidUntil = validUntil
 				remaining = state.Granted - state.UsedLocal
 			}
 		}
@@ -151,35 +147,169 @@ func (p *FederatedProvider) Poll(ctx context.Context) (provider.PollResult, erro
 	return result, nil
 }
 
-func (p *FederatedProvider) requestGrant(ctx context.Context, req protocol.GrantRequest) (int64, time.Time, error) {
-	body, err := json.Marshal(req)
+// Ensure makes sure at least amount of a pool's budget is held, asking the
+// leader for the shortfall if needed, and returns the budget held. Regional
+// leaders fund the grants to their own followers this way. Caller consumes
+// the budget with TrackUsage.
+func (p *FederatedProvider) Ensure(ctx context.Context, poolID string, amount int64) (int64, error) {
+	p.grantMu.Lock()
+	defer p.grantMu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, ok := p.pools[poolID]
+	if !ok {
+		state = &PoolState{}
+		p.pools[poolID] = state
+	}
+	held := state.Granted - state.UsedLocal
+	if held >= amount && time.Now().Before(state.ValidUntil) {
+		return held, nil
+	}
+	if err := p.grant(ctx, poolID, state, max(amount-held, 1)); err != nil {
+		return max(held, 0), err
+	}
+	return max(state.Granted-state.UsedLocal, 0), nil
+}
+
+// Held returns the budget of a pool held and not used yet, without asking
+// the leader.
+func (p *FederatedProvider) Held(poolID string) int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	state, ok := p.pools[poolID]
+	if !ok {
+		return 0
+	}
+	return max(state.Granted-state.UsedLocal, 0)
+}
+
+// grant asks the leader for amount more of a pool, reporting what we hold
+// and have used so it can size grants to our demand. Caller must hold
+// p.grantMu and p.mu; p.mu is released while the request is in flight, so
+// that usage is tracked and read meanwhile.
+func (p *FederatedProvider) grant(ctx context.Context, poolID string, state *PoolState, amount int64) error {
+	req := protocol.GrantRequest{
+		FollowerID: p.followerID,
+		ProviderID: string(p.id),
+		PoolID:     poolID,
+		Amount:     amount,
+		Metadata: map[string]interface{}{
+			"uptime":  time.Since(p.startTime).String(),
+			"version": version.Version,
+		},
+		Held:    max(state.Granted-state.UsedLocal, 0),
+		Used:    state.UsedLocal,
+		GrantID: state.GrantID,
+	}
+
+	p.mu.Unlock()
+	resp, status, err := p.requestGrant(ctx, req)
+	p.mu.Lock()
+	if status == http.StatusGone {
+		// The leader closed our lease; what we held under it is gone
+		if state.Granted > state.UsedLocal {
+			fmt.Printf("federated_provider: lease %s for %s closed by the leader, forfeiting %d\n", state.GrantID, poolID, state.Granted-state.UsedLocal)
+			state.Granted = state.UsedLocal
+		}
+		state.GrantID = ""
+		req.GrantID, req.Held = "", 0
+		p.mu.Unlock()
+		resp, _, err = p.requestGrant(ctx, req)
+		p.mu.Lock()
+	}
 	if err != nil {
-		return 0, time.Time{}, err
+		return err
 	}
 
-	url := fmt.Sprintf("%s/v1/federation/grant", p.leaderURL)
+	// A new lease means the leader took back what we held under the old one
+	if resp.GrantID != "" && resp.GrantID != state.GrantID {
+		if state.Granted > state.UsedLocal {
+			fmt.Printf("federated_provider: lease for %s replaced by %s, forfeiting %d\n", poolID, resp.GrantID, state.Granted-state.UsedLocal)
+			state.Granted = state.UsedLocal
+		}
+		state.GrantID = resp.GrantID
+	}
+
+	// Strategy: New grant *adds* to existing? Or replaces?
+	// Leader implementation (M30.1) just returns an amount.
+	// If Leader "deducts from global", we "add to local".
+	state.Granted += resp.Granted
+	state.ValidUntil = resp.ValidUntil
+	if !resp.ReclaimAt.IsZero() {
+		state.ReclaimAt = resp.ReclaimAt
+	}
+	return nil
+}
+
+func (p *FederatedProvider) requestGrant(ctx context.Context, req protocol.GrantRequest) (protocol.GrantResponse, int, error) {
+	var grantResp protocol.GrantResponse
+	status, err := p.post(ctx, "/v1/federation/grant", req, &grantResp)
+	if err != nil {
+		return protocol.GrantResponse{}, status, err
+	}
+	return grantResp, status, nil
+}
+
+// Return gives the unused budget of every pool back to the leader, closing
+// our leases, so that it need not wait for them to expire. Called on
+// shutdown.
+func (p *FederatedProvider) Return(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for poolID, state := range p.pools {
+		unused := state.Granted - state.UsedLocal
+		if state.GrantID == "" || unused <= 0 {
+			continue
+		}
+		req := protocol.ReturnRequest{
+			FollowerID: p.followerID,
+			ProviderID: string(p.id),
+			PoolID:     poolID,
+			GrantID:    state.GrantID,
+			Amount:     unused,
+			Used:       state.UsedLocal,
+		}
+		var resp protocol.ReturnResponse
+		// Not found: the lease expired and the leader reclaimed it already
+		if status, err := p.post(ctx, "/v1/federation/return", req, &resp); err != nil && status != http.StatusNotFound {
+			errs = append(errs, fmt.Errorf("failed to return grant for %s: %w", poolID, err))
+			continue
+		}
+		state.Granted = state.UsedLocal
+		state.GrantID = ""
+	}
+	return errors.Join(errs...)
+}
+
+// post sends in to path on the leader and decodes the response into out. It
+// returns the status code of the response, if any.
+func (p *FederatedProvider) post(ctx context.Context, path string, in, out interface{}) (int, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return 0, err
+	}
+
+	url := p.leaderURL + path
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return 0, time.Time{}, err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, time.Time{}, fmt.Errorf("leader returned status %d", resp.StatusCode)
-	}
-
-	var grantResp protocol.GrantResponse
-	if err := json.NewDecoder(resp.Body).Decode(&grantResp); err != nil {
-		return 0, time.Time{}, err
+		return resp.StatusCode, fmt.Errorf("leader returned status %d", resp.StatusCode)
 	}
 
-	return grantResp.Granted, grantResp.ValidUntil, nil
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
 }
 
 // Restore is a no-op for now, or could restore grants
@@ -221,3 +351,39 @@ func (r *UsageRouter) TrackUsage(providerID, poolID string, amount int64) {
 		p.TrackUsage(poolID, amount)
 	}
 }
+
+// Return gives the unused budget of every provider back to its leader. See
+// FederatedProvider.Return.
+func (r *UsageRouter) Return(ctx context.Context) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var errs []error
+	for _, p := range r.providers {
+		errs = append(errs, p.Return(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Ensure makes sure at least amount of a pool's budget is held by the
+// provider of providerID. See FederatedProvider.Ensure.
+func (r *UsageRouter) Ensure(ctx context.Context, providerID, poolID string, amount int64) (int64, error) {
+	r.mu.RLock()
+	p, ok := r.providers[providerID]
+	r.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("provider %s is not federated", providerID)
+	}
+	return p.Ensure(ctx, poolID, amount)
+}
+
+// Held returns the budget of a pool held by the provider of providerID.
+// See FederatedProvider.Held.
+func (r *UsageRouter) Held(providerID, poolID string) int64 {
+	r.mu.RLock()
+	p, ok := r.providers[providerID]
+	r.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	return p.Held(poolID)
+}
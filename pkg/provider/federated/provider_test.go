@@ -96,3 +96,187 @@ func TestUsageRouter(t *testing.T) {
 	router.TrackUsage("p2", "pool1", 10)
 	// Should not panic
 }
+
+func TestFederatedProvider_EnsureAndReclaim(t *testing.T) {
+	var last protocol.GrantRequest
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.GrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		last = req
+		json.NewEncoder(w).Encode(protocol.GrantResponse{
+			Granted:    last.Amount,
+			ValidUntil: time.Now().Add(time.Minute),
+			ReclaimAt:  time.Now().Add(-time.Second), // Already past, to test forfeiture
+		})
+	}))
+	defer leader.Close()
+
+	fp := NewFederatedProvider("p1", leader.URL, "region-eu")
+	ctx := context.Background()
+
+	held, err := fp.Ensure(ctx, "pool1", 300)
+	if err != nil || held != 300 {
+		t.Fatalf("expected 300 held, got %d (err %v)", held, err)
+	}
+	fp.TrackUsage("pool1", 100)
+
+	// Enough is held: no request to the leader
+	last = protocol.GrantRequest{}
+	if held, err := fp.Ensure(ctx, "pool1", 200); err != nil || held != 200 || last.FollowerID != "" {
+		t.Fatalf("expected 200 held without a request, got %d (err %v, request %+v)", held, err, last)
+	}
+
+	// The shortfall is requested, reporting what is held and used
+	if held, err := fp.Ensure(ctx, "pool1", 250); err != nil || held != 250 {
+		t.Fatalf("expected 250 held, got %d (err %v)", held, err)
+	}
+	if last.Amount != 50 || last.Held != 200 || last.Used != 100 {
+		t.Errorf("expected a request of 50 holding 200 and using 100, got %+v", last)
+	}
+
+	// Past ReclaimAt the unused budget is forfeited before asking again
+	if _, err := fp.Poll(ctx); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if last.Held != 0 || last.Used != 100 {
+		t.Errorf("expected the unused grant to be forfeited, got request %+v", last)
+	}
+
+	if _, err := NewUsageRouter().Ensure(ctx, "p2", "pool1", 1); err == nil {
+		t.Error("expected an error for a provider that is not federated")
+	}
+}
+
+// Usage is tracked and read while a grant request waits for the leader.
+func TestFederatedProvider_UnlockedRequests(t *testing.T) {
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(protocol.GrantResponse{Granted: 100, ValidUntil: time.Now().Add(time.Minute)})
+	}))
+	defer leader.Close()
+	defer close(release)
+
+	fp := NewFederatedProvider("p1", leader.URL, "region-eu")
+	fp.RegisterPool("pool1")
+	go fp.Ensure(context.Background(), "pool1", 100)
+	<-received
+
+	done := make(chan int64)
+	go func() {
+		fp.TrackUsage("pool1", 10)
+		done <- fp.Held("pool1")
+	}()
+	select {
+	case held := <-done:
+		if held != 0 {
+			t.Errorf("expected nothing held before the grant, got %d", held)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("usage blocked behind the grant request")
+	}
+}
+
+func TestFederatedProvider_Lease(t *testing.T) {
+	lease := "grant_1"
+	closed := ""
+	var grants []protocol.GrantRequest
+	var returned protocol.ReturnRequest
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/federation/grant":
+			var req protocol.GrantRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			grants = append(grants, req)
+			if req.GrantID != "" && req.GrantID == closed {
+				http.Error(w, `{"error":"grant_closed"}`, http.StatusGone)
+				return
+			}
+			json.NewEncoder(w).Encode(protocol.GrantResponse{
+				Granted:    100,
+				ValidUntil: time.Now().Add(-time.Second), // Ask again on every poll
+				GrantID:    lease,
+				ReclaimAt:  time.Now().Add(time.Minute),
+			})
+		case "/v1/federation/return":
+			if err := json.NewDecoder(r.Body).Decode(&returned); err != nil || returned.GrantID != lease {
+				http.Error(w, `{"error":"grant_not_found"}`, http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(protocol.ReturnResponse{Returned: returned.Amount})
+		}
+	}))
+	defer leader.Close()
+
+	fp := NewFederatedProvider("p1", leader.URL, "follower-1")
+	fp.RegisterPool("pool1")
+	ctx := context.Background()
+
+	fp.Poll(ctx)
+	fp.TrackUsage("pool1", 30)
+	fp.Poll(ctx)
+	if grants[1].GrantID != "grant_1" || grants[1].Held != 70 {
+		t.Fatalf("expected the lease to be renewed holding 70, got %+v", grants[1])
+	}
+	if state := fp.pools["pool1"]; state.Granted != 200 {
+		t.Fatalf("expected 200 granted under the lease, got %d", state.Granted)
+	}
+
+	// The leader replaced the lease: what we held under the old one is gone
+	lease = "grant_2"
+	fp.Poll(ctx)
+	if state := fp.pools["pool1"]; state.GrantID != "grant_2" || state.Granted != 130 {
+		t.Fatalf("expected 100 granted under the new lease, got %+v", state)
+	}
+
+	// The leader closed the lease: we reopen one holding nothing
+	closed, lease = "grant_2", "grant_3"
+	n := len(grants)
+	fp.Poll(ctx)
+	if len(grants) != n+2 || grants[n+1].GrantID != "" || grants[n+1].Held != 0 {
+		t.Fatalf("expected a new lease to be asked for, got %+v", grants[n:])
+	}
+	if state := fp.pools["pool1"]; state.GrantID != "grant_3" || state.Granted != 130 {
+		t.Fatalf("expected 100 granted under the reopened lease, got %+v", state)
+	}
+	lease = "grant_2"
+	fp.pools["pool1"].GrantID = "grant_2"
+
+	router := NewUsageRouter()
+	router.Register(fp)
+	if err := router.Return(ctx); err != nil {
+		t.Fatalf("Return failed: %v", err)
+	}
+	if returned.GrantID != "grant_2" || returned.Amount != 100 || returned.Used != 30 {
+		t.Errorf("expected 100 returned under grant_2, got %+v", returned)
+	}
+	if state := fp.pools["pool1"]; state.GrantID != "" || state.Granted != state.UsedLocal {
+		t.Errorf("expected the lease to be closed, got %+v", state)
+	}
+
+	// A lease the leader no longer knows is dropped
+	fp.Poll(ctx)
+	lease = "grant_3"
+	if err := fp.Return(ctx); err != nil {
+		t.Fatalf("Return of an expired lease should not fail: %v", err)
+	}
+	if state := fp.pools["pool1"]; state.GrantID != "" {
+		t.Errorf("expected the expired lease to be dropped, got %+v", state)
+	}
+
+	// The leader closed the lease: we reopen one holding nothing
+	fp.Poll(ctx)
+	closed, lease = "grant_3", "grant_4"
+	asked := len(grants)
+	fp.Poll(ctx)
+	if len(grants) != asked+2 || grants[asked].GrantID != "grant_3" || grants[asked+1].GrantID != "" || grants[asked+1].Held != 0 {
+		t.Fatalf("expected a new lease to be asked for, got %+v", grants[asked:])
+	}
+	if state := fp.pools["pool1"]; state.GrantID != "grant_4" || state.Granted != state.UsedLocal+100 {
+		t.Errorf("expected 100 granted under the reopened lease, got %+v", state)
+	}
+}
@@ -0,0 +1,197 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appAuth authenticates as a GitHub App and mints per-installation tokens.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app
+type appAuth struct {
+	appID           int64
+	key             *rsa.PrivateKey
+	installationIDs []int64 // Fixed set; if empty, all installations are discovered
+
+	mu     sync.Mutex
+	tokens map[int64]installationToken
+}
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// parsePrivateKey decodes a PEM-encoded RSA key in PKCS#1 or PKCS#8 form.
+func parsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found in private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return key, nil
+}
+
+// jwt returns a short-lived RS256 JSON Web Token identifying the app.
+func (a *appAuth) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(), // Allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),   // GitHub allows at most 10 minutes
+		"iss": fmt.Sprintf("%d", a.appID),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// installations returns the configured installation IDs, or lists them from the API.
+func (g *GitHubProvider) installations(ctx context.Context) ([]int64, error) {
+	if len(g.app.installationIDs) > 0 {
+		return g.app.installationIDs, nil
+	}
+
+	jwt, err := g.app.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Follow Link: rel="next" so apps with more than one page of installations
+	// are fully covered.
+	var ids []int64
+	url := g.baseURL() + "/app/installations?per_page=100"
+	for url != "" {
+		var page []struct {
+			ID int64 `json:"id"`
+		}
+		next, err := g.doJSONPage(ctx, "GET", url, "Bearer "+jwt, &page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list installations: %w", err)
+		}
+		for _, inst := range page {
+			ids = append(ids, inst.ID)
+		}
+		url = next
+	}
+	return ids, nil
+}
+
+// installationToken returns a cached installation token, minting a new one when
+// it is missing or about to expire.
+func (g *GitHubProvider) installationToken(ctx context.Context, installationID int64) (string, error) {
+	now := time.Now()
+
+	g.app.mu.Lock()
+	cached, ok := g.app.tokens[installationID]
+	g.app.mu.Unlock()
+	if ok && now.Add(time.Minute).Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	jwt, err := g.app.jwt(now)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", g.baseURL(), installationID)
+	if err := g.doJSON(ctx, "POST", url, "Bearer "+jwt, &resp); err != nil {
+		return "", fmt.Errorf("failed to create token for installation %d: %w", installationID, err)
+	}
+
+	g.app.mu.Lock()
+	g.app.tokens[installationID] = installationToken{token: resp.Token, expiresAt: resp.ExpiresAt}
+	g.app.mu.Unlock()
+
+	return resp.Token, nil
+}
+
+// doJSON performs an authenticated request and decodes a JSON response.
+func (g *GitHubProvider) doJSON(ctx context.Context, method, url, auth string, out interface{}) error {
+	_, err := g.doJSONPage(ctx, method, url, auth, out)
+	return err
+}
+
+// doJSONPage is doJSON for paginated endpoints; it also returns the URL of the
+// next page from the Link header, or "" on the last page.
+func (g *GitHubProvider) doJSONPage(ctx context.Context, method, url, auth string, out interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return "", err
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink extracts the rel="next" target from an RFC 8288 Link header.
+func nextLink(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		target := strings.TrimSpace(segments[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
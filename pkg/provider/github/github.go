@@ -6,16 +6,35 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rmax-ai/ratelord/pkg/provider"
 )
 
+// poolPrefix is prepended to every /rate_limit resource name to form a pool ID,
+// e.g. "core" -> "github:core", "code_search" -> "github:code_search".
+const poolPrefix = "github:"
+
+// SecondaryRateLimitError reports a secondary (abuse) rate limit: GitHub answered
+// 403 or 429 with a Retry-After header instead of the requested resource.
+type SecondaryRateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *SecondaryRateLimitError) Error() string {
+	return fmt.Sprintf("secondary rate limit (HTTP %d), retry after %s", e.StatusCode, e.RetryAfter)
+}
+
 type GitHubProvider struct {
 	id            provider.ProviderID
 	token         string
 	enterpriseURL string
 	client        *http.Client
+	app           *appAuth // Set when authenticating as a GitHub App
 }
 
 func NewGitHubProvider(id provider.ProviderID, token string, enterpriseURL string) *GitHubProvider {
@@ -27,80 +46,255 @@ func NewGitHubProvider(id provider.ProviderID, token string, enterpriseURL strin
 	}
 }
 
+// NewGitHubAppProvider creates a provider that authenticates as a GitHub App.
+// Each installation gets its own token and its own set of pools
+// ("github:installation:<id>:<resource>"). If installationIDs is empty, all
+// installations of the app are discovered on every poll.
+func NewGitHubAppProvider(id provider.ProviderID, appID int64, privateKeyPEM []byte, installationIDs []int64, enterpriseURL string) (*GitHubProvider, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	p := NewGitHubProvider(id, "", enterpriseURL)
+	p.app = &appAuth{
+		appID:           appID,
+		key:             key,
+		installationIDs: installationIDs,
+		tokens:          make(map[int64]installationToken),
+	}
+	return p, nil
+}
+
 func (g *GitHubProvider) ID() provider.ProviderID {
 	return g.id
 }
 
-func (g *GitHubProvider) Poll(ctx context.Context) (provider.PollResult, error) {
-	baseURL := "https://api.github.com"
+func (g *GitHubProvider) baseURL() string {
 	if g.enterpriseURL != "" {
-		baseURL = g.enterpriseURL
+		return strings.TrimRight(g.enterpriseURL, "/")
 	}
-	url := baseURL + "/rate_limit"
+	return "https://api.github.com"
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return provider.PollResult{}, err
+func (g *GitHubProvider) Poll(ctx context.Context) (provider.PollResult, error) {
+	if g.app != nil {
+		return g.pollApp(ctx)
 	}
+
+	auth := ""
 	if g.token != "" {
-		req.Header.Set("Authorization", "token "+g.token)
+		auth = "token " + g.token
 	}
 
-	resp, err := g.client.Do(req)
+	usages, retryAfter, err := g.pollRateLimit(ctx, auth, poolPrefix)
+	if err != nil {
+		return provider.PollResult{ProviderID: g.id, Status: "error", Error: err, RetryAfter: retryAfter, Timestamp: time.Now()}, nil
+	}
+
+	return provider.PollResult{
+		ProviderID: g.id,
+		Status:     "success",
+		Timestamp:  time.Now(),
+		Usage:      usages,
+		State:      nil, // stateless
+	}, nil
+}
+
+// pollApp polls /rate_limit once per installation. A failing installation does
+// not hide the others; the poll only fails if no installation succeeded.
+func (g *GitHubProvider) pollApp(ctx context.Context) (provider.PollResult, error) {
+	ids, err := g.installations(ctx)
 	if err != nil {
 		return provider.PollResult{ProviderID: g.id, Status: "error", Error: err, Timestamp: time.Now()}, nil
 	}
+
+	var usages []provider.UsageObservation
+	var lastErr error
+	var retryAfter time.Duration
+	for _, instID := range ids {
+		token, err := g.installationToken(ctx, instID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		obs, ra, err := g.pollRateLimit(ctx, "token "+token, installationPrefix(instID))
+		if err != nil {
+			lastErr = fmt.Errorf("installation %d: %w", instID, err)
+			if ra > retryAfter {
+				retryAfter = ra
+			}
+			continue
+		}
+		usages = append(usages, obs...)
+	}
+
+	if lastErr != nil && len(usages) == 0 {
+		return provider.PollResult{ProviderID: g.id, Status: "error", Error: lastErr, RetryAfter: retryAfter, Timestamp: time.Now()}, nil
+	}
+
+	return provider.PollResult{
+		ProviderID: g.id,
+		Status:     "success",
+		Timestamp:  time.Now(),
+		Usage:      usages,
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+// pollRateLimit fetches /rate_limit and maps every returned resource to a pool.
+func (g *GitHubProvider) pollRateLimit(ctx context.Context, auth, prefix string) ([]provider.UsageObservation, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.baseURL()+"/rate_limit", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return provider.PollResult{ProviderID: g.id, Status: "error", Error: fmt.Errorf("HTTP %d", resp.StatusCode), Timestamp: time.Now()}, nil
+		if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 &&
+			(resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
+			return nil, retryAfter, &SecondaryRateLimitError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}
+		}
+		return nil, 0, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return provider.PollResult{ProviderID: g.id, Status: "error", Error: err, Timestamp: time.Now()}, nil
+		return nil, 0, err
 	}
 
 	var rateLimitResp struct {
 		Resources map[string]struct {
-			Limit     int   `json:"limit"`
-			Remaining int   `json:"remaining"`
+			Limit     int64 `json:"limit"`
+			Remaining int64 `json:"remaining"`
+			Used      int64 `json:"used"`
 			Reset     int64 `json:"reset"`
 		} `json:"resources"`
 	}
+	if err := json.Unmarshal(body, &rateLimitResp); err != nil {
+		return nil, 0, err
+	}
+
+	usages := make([]provider.UsageObservation, 0, len(rateLimitResp.Resources))
+	for res, data := range rateLimitResp.Resources {
+		usages = append(usages, provider.UsageObservation{
+			PoolID:    prefix + res,
+			Used:      data.Limit - data.Remaining,
+			Remaining: data.Remaining,
+			Limit:     data.Limit,
+			ResetAt:   time.Unix(data.Reset, 0),
+		})
+	}
+	return usages, 0, nil
+}
+
+// parseRetryAfter reads a Retry-After value in seconds; HTTP dates are not used by GitHub.
+func parseRetryAfter(v string) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// InstallationHeader names the installation a forwarded response was made
+// with. GitHub does not echo it, so clients of a GitHub App provider add it to
+// the headers they forward to POST /v1/observations.
+const InstallationHeader = "x-ratelord-installation-id"
+
+// ParseHeaders maps the x-ratelimit-* headers that GitHub attaches to every
+// REST and GraphQL response. The resource defaults to "core" when absent. For
+// GraphQL, limit and remaining are measured in points, so the pool tracks the
+// query point cost rather than request counts.
+//
+// A GitHub App provider attributes the observation to the installation pools
+// that Poll uses, taken from InstallationHeader or, when exactly one
+// installation is configured, from that one. Observations that name no
+// installation, or one the app is not configured for, are dropped.
+func (g *GitHubProvider) ParseHeaders(header http.Header, now time.Time) []provider.UsageObservation {
+	limitStr := header.Get("x-ratelimit-limit")
+	remStr := header.Get("x-ratelimit-remaining")
+	if limitStr == "" || remStr == "" {
+		return nil
+	}
+
+	prefix, ok := g.headerPoolPrefix(header)
+	if !ok {
+		return nil
+	}
+	resource := header.Get("x-ratelimit-resource")
+	if resource == "" {
+		resource = "core"
+	}
+	poolID := prefix + resource
 
-	err = json.Unmarshal(body, &rateLimitResp)
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
 	if err != nil {
-		return provider.PollResult{ProviderID: g.id, Status: "error", Error: err, Timestamp: time.Now()}, nil
+		return nil
+	}
+	rem, err := strconv.ParseInt(remStr, 10, 64)
+	if err != nil {
+		return nil
 	}
 
-	var usages []provider.UsageObservation
-	resourceMap := map[string]string{
-		"core":                 "github:core",
-		"search":               "github:search",
-		"graphql":              "github:graphql",
-		"integration_manifest": "github:integration_manifest",
+	used := limit - rem
+	if usedStr := header.Get("x-ratelimit-used"); usedStr != "" {
+		if u, err := strconv.ParseInt(usedStr, 10, 64); err == nil {
+			used = u
+		}
 	}
 
-	for res, data := range rateLimitResp.Resources {
-		if poolID, ok := resourceMap[res]; ok {
-			usages = append(usages, provider.UsageObservation{
-				PoolID:    poolID,
-				Used:      int64(data.Limit - data.Remaining),
-				Remaining: int64(data.Remaining),
-				Limit:     int64(data.Limit),
-				ResetAt:   time.Unix(data.Reset, 0),
-			})
+	resetAt := now
+	if resetStr := header.Get("x-ratelimit-reset"); resetStr != "" {
+		if r, err := strconv.ParseInt(resetStr, 10, 64); err == nil {
+			resetAt = time.Unix(r, 0)
 		}
 	}
 
-	return provider.PollResult{
-		ProviderID: g.id,
-		Status:     "success",
-		Timestamp:  time.Now(),
-		Usage:      usages,
-		State:      nil, // stateless
-	}, nil
+	return []provider.UsageObservation{{
+		PoolID:    poolID,
+		Used:      used,
+		Remaining: rem,
+		Limit:     limit,
+		ResetAt:   resetAt,
+	}}
+}
+
+// headerPoolPrefix returns the pool prefix for an observation: poolPrefix for
+// a token provider, or the installation prefix used by pollApp.
+func (g *GitHubProvider) headerPoolPrefix(header http.Header) (string, bool) {
+	if g.app == nil {
+		return poolPrefix, true
+	}
+	var instID int64
+	if v := header.Get(InstallationHeader); v != "" {
+		id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return "", false
+		}
+		instID = id
+	} else if len(g.app.installationIDs) == 1 {
+		instID = g.app.installationIDs[0]
+	} else {
+		return "", false
+	}
+	if len(g.app.installationIDs) > 0 && !slices.Contains(g.app.installationIDs, instID) {
+		return "", false
+	}
+	return installationPrefix(instID), true
+}
+
+// installationPrefix is the pool prefix of one app installation,
+// e.g. "github:installation:1111:".
+func installationPrefix(instID int64) string {
+	return fmt.Sprintf("%sinstallation:%d:", poolPrefix, instID)
 }
 
 func (g *GitHubProvider) Restore(state []byte) error {
@@ -2,9 +2,19 @@ package github
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -128,3 +138,254 @@ func TestRestore(t *testing.T) {
 		t.Errorf("Expected no error, got %v", err)
 	}
 }
+
+func TestParseHeaders(t *testing.T) {
+	p := NewGitHubProvider("test", "", "")
+	now := time.Now()
+
+	h := http.Header{}
+	h.Set("x-ratelimit-limit", "5000")
+	h.Set("x-ratelimit-remaining", "4990")
+	h.Set("x-ratelimit-used", "10")
+	h.Set("x-ratelimit-reset", "1640995200")
+	h.Set("x-ratelimit-resource", "search")
+
+	usages := p.ParseHeaders(h, now)
+	if len(usages) != 1 {
+		t.Fatalf("Expected 1 usage, got %d", len(usages))
+	}
+	if usages[0].PoolID != "github:search" {
+		t.Errorf("Expected pool github:search, got %s", usages[0].PoolID)
+	}
+	if usages[0].Used != 10 || usages[0].Remaining != 4990 || usages[0].Limit != 5000 {
+		t.Errorf("Unexpected usage %+v", usages[0])
+	}
+	if !usages[0].ResetAt.Equal(time.Unix(1640995200, 0)) {
+		t.Errorf("Expected reset %v, got %v", time.Unix(1640995200, 0), usages[0].ResetAt)
+	}
+
+	// Missing resource defaults to core
+	h.Del("x-ratelimit-resource")
+	usages = p.ParseHeaders(h, now)
+	if len(usages) != 1 || usages[0].PoolID != "github:core" {
+		t.Errorf("Expected github:core usage, got %+v", usages)
+	}
+}
+
+func TestParseHeaders_GitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	now := time.Now()
+
+	h := http.Header{}
+	h.Set("x-ratelimit-limit", "5000")
+	h.Set("x-ratelimit-remaining", "4990")
+	h.Set("x-ratelimit-resource", "search")
+
+	p, err := NewGitHubAppProvider("gh-app", 42, keyPEM, []int64{1111, 2222}, "")
+	if err != nil {
+		t.Fatalf("NewGitHubAppProvider failed: %v", err)
+	}
+
+	// With several installations the client must name one
+	if usages := p.ParseHeaders(h, now); usages != nil {
+		t.Errorf("Expected no usage without an installation, got %+v", usages)
+	}
+
+	h.Set(InstallationHeader, "2222")
+	usages := p.ParseHeaders(h, now)
+	if len(usages) != 1 || usages[0].PoolID != "github:installation:2222:search" {
+		t.Errorf("Expected github:installation:2222:search usage, got %+v", usages)
+	}
+
+	// Installations the app is not configured for have no pools
+	h.Set(InstallationHeader, "3333")
+	if usages := p.ParseHeaders(h, now); usages != nil {
+		t.Errorf("Expected no usage for an unknown installation, got %+v", usages)
+	}
+
+	// A single configured installation is used when none is named
+	single, err := NewGitHubAppProvider("gh-app", 42, keyPEM, []int64{1111}, "")
+	if err != nil {
+		t.Fatalf("NewGitHubAppProvider failed: %v", err)
+	}
+	h.Del(InstallationHeader)
+	usages = single.ParseHeaders(h, now)
+	if len(usages) != 1 || usages[0].PoolID != "github:installation:1111:search" {
+		t.Errorf("Expected github:installation:1111:search usage, got %+v", usages)
+	}
+}
+
+// fakeGitHub is a minimal stand-in for the GitHub REST API covering the
+// endpoints used by the provider.
+type fakeGitHub struct {
+	key           *rsa.PrivateKey
+	tokensMinted  int
+	secondaryHits bool
+}
+
+func (f *fakeGitHub) verifyJWT(auth string) bool {
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&f.key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		return false
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c struct {
+		Iss string `json:"iss"`
+		Exp int64  `json:"exp"`
+	}
+	return json.Unmarshal(claims, &c) == nil && c.Iss == "42" && c.Exp > time.Now().Unix()
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/app/installations":
+		if !f.verifyJWT(r.Header.Get("Authorization")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// One installation per page to exercise Link pagination
+		if r.URL.Query().Get("page") == "2" {
+			w.Write([]byte(`[{"id":2}]`))
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/app/installations?per_page=100&page=2>; rel="next", <http://%s/app/installations?per_page=100&page=2>; rel="last"`, r.Host, r.Host))
+		w.Write([]byte(`[{"id":1}]`))
+	case strings.HasPrefix(r.URL.Path, "/app/installations/") && strings.HasSuffix(r.URL.Path, "/access_tokens"):
+		if r.Method != "POST" || !f.verifyJWT(r.Header.Get("Authorization")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		f.tokensMinted++
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/app/installations/"), "/access_tokens")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      "ghs_inst" + id,
+			"expires_at": time.Now().Add(time.Hour).UTC(),
+		})
+	case r.URL.Path == "/rate_limit":
+		if f.secondaryHits {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		remaining := 4000
+		if r.Header.Get("Authorization") == "token ghs_inst2" {
+			remaining = 3000
+		}
+		fmt.Fprintf(w, `{"resources":{
+			"core":{"limit":5000,"remaining":%d,"used":%d,"reset":1640995200},
+			"graphql":{"limit":5000,"remaining":4900,"used":100,"reset":1640995200},
+			"code_search":{"limit":10,"remaining":9,"used":1,"reset":1640995200},
+			"actions_runner_registration":{"limit":10000,"remaining":10000,"used":0,"reset":1640995200},
+			"scim":{"limit":15000,"remaining":15000,"used":0,"reset":1640995200},
+			"dependency_snapshots":{"limit":100,"remaining":100,"used":0,"reset":1640995200}
+		}}`, remaining, 5000-remaining)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPoll_AllResources(t *testing.T) {
+	fake := &fakeGitHub{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p := NewGitHubProvider("test", "fake-token", server.URL)
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s)", err, result.Status)
+	}
+	if len(result.Usage) != 6 {
+		t.Fatalf("Expected 6 usages, got %d", len(result.Usage))
+	}
+	pools := map[string]bool{}
+	for _, u := range result.Usage {
+		pools[u.PoolID] = true
+	}
+	for _, want := range []string{"github:code_search", "github:actions_runner_registration", "github:scim", "github:dependency_snapshots"} {
+		if !pools[want] {
+			t.Errorf("Expected pool %s, got %v", want, pools)
+		}
+	}
+}
+
+func TestPoll_GitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	fake := &fakeGitHub{key: key}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p, err := NewGitHubAppProvider("gh-app", 42, keyPEM, nil, server.URL)
+	if err != nil {
+		t.Fatalf("NewGitHubAppProvider failed: %v", err)
+	}
+
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s): %v", err, result.Status, result.Error)
+	}
+	if len(result.Usage) != 12 {
+		t.Fatalf("Expected 6 pools per installation, got %d", len(result.Usage))
+	}
+
+	byPool := map[string]provider.UsageObservation{}
+	for _, u := range result.Usage {
+		byPool[u.PoolID] = u
+	}
+	if byPool["github:installation:1:core"].Remaining != 4000 {
+		t.Errorf("Unexpected installation 1 core: %+v", byPool["github:installation:1:core"])
+	}
+	if byPool["github:installation:2:core"].Remaining != 3000 {
+		t.Errorf("Unexpected installation 2 core: %+v", byPool["github:installation:2:core"])
+	}
+
+	// Tokens are cached across polls
+	if _, err := p.Poll(context.Background()); err != nil {
+		t.Fatalf("Second poll failed: %v", err)
+	}
+	if fake.tokensMinted != 2 {
+		t.Errorf("Expected 2 tokens minted, got %d", fake.tokensMinted)
+	}
+
+	if _, err := NewGitHubAppProvider("bad", 42, []byte("not a key"), nil, server.URL); err == nil {
+		t.Error("Expected error for invalid private key")
+	}
+}
+
+func TestPoll_SecondaryLimit(t *testing.T) {
+	fake := &fakeGitHub{secondaryHits: true}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	p := NewGitHubProvider("test", "fake-token", server.URL)
+	result, err := p.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error in result, got %v", err)
+	}
+	if result.Status != "error" {
+		t.Errorf("Expected status error, got %s", result.Status)
+	}
+	var secondary *SecondaryRateLimitError
+	if !errors.As(result.Error, &secondary) {
+		t.Fatalf("Expected SecondaryRateLimitError, got %v", result.Error)
+	}
+	if result.RetryAfter != 60*time.Second {
+		t.Errorf("Expected retry after 60s, got %v", result.RetryAfter)
+	}
+}
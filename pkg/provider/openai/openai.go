@@ -1,7 +1,10 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -11,15 +14,48 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/provider"
 )
 
+// DefaultProbeModel is used for the completion probe when no models are configured.
+const DefaultProbeModel = "gpt-4o-mini"
+
+// Options holds optional provider settings beyond the basic credentials.
+type Options struct {
+	ProjectID string   // Sent as 'OpenAI-Project' for project-scoped keys
+	Models    []string // Models (or Azure deployments) tracked with per-model pools
+
+	// Probe sends a minimal completion (max_tokens=1) when the lightweight
+	// request returns no rate limit headers. It consumes a tiny amount of quota.
+	Probe bool
+
+	// AzureURLTemplate switches to Azure OpenAI. "{deployment}" is replaced with
+	// each entry of Models, e.g.
+	// "https://myres.openai.azure.com/openai/deployments/{deployment}/chat/completions?api-version=2024-06-01".
+	// Requests authenticate with the 'api-key' header.
+	AzureURLTemplate string
+}
+
 type OpenAIProvider struct {
 	id      provider.ProviderID
 	token   string
 	orgID   string
 	baseURL string
+	opts    Options
 	client  *http.Client
 }
 
 func NewOpenAIProvider(id provider.ProviderID, token string, orgID string, baseURL string) *OpenAIProvider {
+	return newProvider(id, token, orgID, baseURL, Options{})
+}
+
+// NewOpenAIProviderWithOptions creates a provider with per-model pools, project
+// scoping, probing or Azure deployment support.
+func NewOpenAIProviderWithOptions(id provider.ProviderID, token string, orgID string, baseURL string, opts Options) (*OpenAIProvider, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return newProvider(id, token, orgID, baseURL, opts), nil
+}
+
+func newProvider(id provider.ProviderID, token string, orgID string, baseURL string, opts Options) *OpenAIProvider {
 	if baseURL == "" {
 		baseURL = "https://api.openai.com/v1"
 	}
@@ -28,23 +64,128 @@ func NewOpenAIProvider(id provider.ProviderID, token string, orgID string, baseU
 		token:   token,
 		orgID:   orgID,
 		baseURL: baseURL,
+		opts:    opts,
 		client:  &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
+// Validate reports option combinations the provider cannot poll. Azure
+// deployments are only reachable by name, so an Azure template needs Models.
+func (o Options) Validate() error {
+	if o.AzureURLTemplate != "" && len(o.Models) == 0 {
+		return errors.New("azure_url_template requires models: list the deployment names to poll")
+	}
+	return nil
+}
+
 func (o *OpenAIProvider) ID() provider.ProviderID {
 	return o.id
 }
 
 // Poll performs a lightweight request (List Models) to capture rate limit headers.
 // Note: This consumes a small amount of quota/requests itself.
+//
+// When models are configured, each model is polled separately and reported
+// under its own pools ("openai:<model>:requests", "openai:<model>:tokens").
 func (o *OpenAIProvider) Poll(ctx context.Context) (provider.PollResult, error) {
-	// "List Models" is generally cheap/cached and good for checking connectivity + headers.
+	models := o.opts.Models
+	if len(models) == 0 {
+		models = []string{""} // Account-wide pools
+	}
+
+	var usages []provider.UsageObservation
+	var retryAfter time.Duration
+	for _, model := range models {
+		obs, ra, err := o.pollModel(ctx, model)
+		if err != nil {
+			return provider.PollResult{ProviderID: o.id, Status: "error", Error: err, RetryAfter: ra, Timestamp: time.Now()}, nil
+		}
+		if ra > retryAfter {
+			retryAfter = ra
+		}
+		usages = append(usages, obs...)
+	}
+
+	return provider.PollResult{
+		ProviderID: o.id,
+		Status:     "success",
+		Timestamp:  time.Now(),
+		Usage:      usages,
+		State:      nil, // stateless
+		RetryAfter: retryAfter,
+	}, nil
+}
+
+// pollModel captures headers for one model. An empty model polls account-wide limits.
+func (o *OpenAIProvider) pollModel(ctx context.Context, model string) ([]provider.UsageObservation, time.Duration, error) {
+	if o.opts.AzureURLTemplate != "" {
+		// Azure has no cheap per-deployment GET that carries rate limit headers.
+		return o.probe(ctx, model)
+	}
+
+	// "List Models" (or "Retrieve Model") is generally cheap/cached and good for
+	// checking connectivity + headers.
 	url := fmt.Sprintf("%s/models", strings.TrimRight(o.baseURL, "/"))
+	if model != "" {
+		url += "/" + model
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return provider.PollResult{}, err
+		return nil, 0, err
+	}
+	o.setAuth(req)
+
+	usages, retryAfter, err := o.do(req, model)
+	if err != nil {
+		return nil, retryAfter, err
+	}
+	if len(usages) == 0 && o.opts.Probe {
+		return o.probe(ctx, model)
+	}
+	return usages, retryAfter, nil
+}
+
+// probe sends the cheapest possible chat completion to obtain rate limit headers.
+func (o *OpenAIProvider) probe(ctx context.Context, model string) ([]provider.UsageObservation, time.Duration, error) {
+	body := map[string]interface{}{
+		"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+		"max_tokens": 1,
+	}
+
+	var url string
+	if o.opts.AzureURLTemplate != "" {
+		// The deployment selects the model on Azure
+		url = strings.ReplaceAll(o.opts.AzureURLTemplate, "{deployment}", model)
+	} else {
+		url = fmt.Sprintf("%s/chat/completions", strings.TrimRight(o.baseURL, "/"))
+		if model == "" {
+			body["model"] = DefaultProbeModel
+		} else {
+			body["model"] = model
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.setAuth(req)
+
+	return o.do(req, model)
+}
+
+func (o *OpenAIProvider) setAuth(req *http.Request) {
+	if o.opts.AzureURLTemplate != "" {
+		if o.token != "" {
+			req.Header.Set("api-key", o.token)
+		}
+		return
 	}
 	if o.token != "" {
 		req.Header.Set("Authorization", "Bearer "+o.token)
@@ -52,65 +193,96 @@ func (o *OpenAIProvider) Poll(ctx context.Context) (provider.PollResult, error)
 	if o.orgID != "" {
 		req.Header.Set("OpenAI-Organization", o.orgID)
 	}
+	if o.opts.ProjectID != "" {
+		req.Header.Set("OpenAI-Project", o.opts.ProjectID)
+	}
+}
 
+// do executes the request and parses rate limit headers from the response.
+func (o *OpenAIProvider) do(req *http.Request, model string) ([]provider.UsageObservation, time.Duration, error) {
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return provider.PollResult{ProviderID: o.id, Status: "error", Error: err, Timestamp: time.Now()}, nil
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// Even if error, headers might be present, but usually we want a clean success for a poll.
-		// For 429 specifically, we definitely want the headers, but let's stick to standard flow for now.
-		// If 429, the headers are definitely there.
-		if resp.StatusCode != http.StatusTooManyRequests {
-			return provider.PollResult{ProviderID: o.id, Status: "error", Error: fmt.Errorf("HTTP %d", resp.StatusCode), Timestamp: time.Now()}, nil
+	// For 429 the headers are definitely there, so it still counts as a successful poll.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+		return nil, 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+	}
+
+	return o.parseHeaders(resp.Header, time.Now(), model), retryAfter, nil
+}
+
+// ParseHeaders maps the x-ratelimit-* response headers to usage observations.
+// Pool IDs are "openai:requests" and "openai:tokens", or the per-model pools
+// used by Poll when the 'openai-model' response header names a configured model
+// (either exactly or as a dated snapshot such as "gpt-4o-2024-08-06").
+//
+//	x-ratelimit-limit-requests: 5000
+//	x-ratelimit-remaining-requests: 4999
+//	x-ratelimit-reset-requests: 100ms
+//
+//	x-ratelimit-limit-tokens: 160000
+//	x-ratelimit-remaining-tokens: 159000
+//	x-ratelimit-reset-tokens: 6m0s
+func (o *OpenAIProvider) ParseHeaders(header http.Header, now time.Time) []provider.UsageObservation {
+	return o.parseHeaders(header, now, o.poolModel(header.Get("openai-model")))
+}
+
+// poolModel maps a response model name onto the configured model whose pools it
+// shares, preferring an exact match over the longest snapshot prefix. It returns
+// "" (account-wide pools) when no models are configured or none match.
+func (o *OpenAIProvider) poolModel(responseModel string) string {
+	responseModel = strings.TrimSpace(responseModel)
+	if responseModel == "" {
+		return ""
+	}
+	best := ""
+	for _, m := range o.opts.Models {
+		if m == responseModel {
+			return m
+		}
+		if strings.HasPrefix(responseModel, m+"-") && len(m) > len(best) {
+			best = m
 		}
 	}
+	return best
+}
 
+// parseHeaders is ParseHeaders with per-model pool IDs when model is set.
+func (o *OpenAIProvider) parseHeaders(header http.Header, now time.Time, model string) []provider.UsageObservation {
 	var usages []provider.UsageObservation
 
-	// OpenAI headers usually come in pairs: requests and tokens.
-	// We map them to pool IDs like "openai:requests" and "openai:tokens".
-	// Sometimes they differ by model (e.g. gpt-4 vs gpt-3.5), but the global/org headers are often generic.
-	// Recent OpenAI API updates might return headers specific to the requested resource model.
-	// Since we are calling /models, these limits might be "global" or specific to management API.
-	// However, usually the main inference limits are what we care about.
-	// If /models returns general limits, we use them. If not, this "Probe" strategy might need
-	// to hit a cheap chat completion with max_tokens=1.
-	//
-	// Let's assume for this v1 implementation that standard headers are returned.
-	// x-ratelimit-limit-requests: 5000
-	// x-ratelimit-remaining-requests: 4999
-	// x-ratelimit-reset-requests: 100ms
-	//
-	// x-ratelimit-limit-tokens: 160000
-	// x-ratelimit-remaining-tokens: 159000
-	// x-ratelimit-reset-tokens: 2s
-
-	extract := func(metric string, poolSuffix string) {
-		limitStr := resp.Header.Get(fmt.Sprintf("x-ratelimit-limit-%s", metric))
-		remStr := resp.Header.Get(fmt.Sprintf("x-ratelimit-remaining-%s", metric))
-		resetStr := resp.Header.Get(fmt.Sprintf("x-ratelimit-reset-%s", metric))
+	prefix := "openai:"
+	if model != "" {
+		prefix = fmt.Sprintf("openai:%s:", model)
+	}
+
+	extract := func(metric string) {
+		limitStr := header.Get(fmt.Sprintf("x-ratelimit-limit-%s", metric))
+		remStr := header.Get(fmt.Sprintf("x-ratelimit-remaining-%s", metric))
+		resetStr := header.Get(fmt.Sprintf("x-ratelimit-reset-%s", metric))
 
 		if limitStr != "" && remStr != "" {
 			limit, _ := strconv.ParseInt(limitStr, 10, 64)
 			rem, _ := strconv.ParseInt(remStr, 10, 64)
 
-			// OpenAI Reset string is often like "100ms" or "2s" or "6m0s"
-			// Go's time.ParseDuration handles this well.
-			resetDur, err := time.ParseDuration(resetStr)
-			resetAt := time.Now()
-			if err == nil {
-				resetAt = resetAt.Add(resetDur)
-			} else {
-				// Fallback: sometimes it might be seconds-integer? Unlikely for OpenAI modern API.
-				// If parse fails, use Now (conservative).
-				resetAt = time.Now()
+			// If parse fails, use now (conservative).
+			resetAt := now
+			if resetDur, ok := ParseResetDuration(resetStr); ok {
+				resetAt = now.Add(resetDur)
 			}
 
 			usages = append(usages, provider.UsageObservation{
-				PoolID:    fmt.Sprintf("openai:%s", poolSuffix),
+				PoolID:    prefix + metric,
 				Used:      limit - rem,
 				Remaining: rem,
 				Limit:     limit,
@@ -119,16 +291,43 @@ func (o *OpenAIProvider) Poll(ctx context.Context) (provider.PollResult, error)
 		}
 	}
 
-	extract("requests", "requests")
-	extract("tokens", "tokens")
+	extract("requests")
+	extract("tokens")
 
-	return provider.PollResult{
-		ProviderID: o.id,
-		Status:     "success",
-		Timestamp:  time.Now(),
-		Usage:      usages,
-		State:      nil, // stateless
-	}, nil
+	return usages
+}
+
+// ParseResetDuration parses OpenAI reset strings. These are Go-style compound
+// durations ("100ms", "6m0s", "1h2m3.5s"), optionally with a leading day
+// component ("1d2h") or a bare number of seconds ("20").
+func ParseResetDuration(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+
+	var days time.Duration
+	if i := strings.Index(s, "d"); i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, false
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		s = s[i+1:]
+		if s == "" {
+			return days, true
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false
+	}
+	return days + d, true
 }
 
 func (o *OpenAIProvider) Restore(state []byte) error {
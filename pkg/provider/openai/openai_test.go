@@ -2,11 +2,13 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/rmax-ai/ratelord/pkg/provider"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewOpenAIProvider(t *testing.T) {
@@ -178,3 +180,180 @@ func TestPoll_HTTPError(t *testing.T) {
 		t.Error("Expected error object, got nil")
 	}
 }
+
+func TestParseHeaders(t *testing.T) {
+	p := NewOpenAIProvider("test", "", "", "")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	h := http.Header{}
+	h.Set("x-ratelimit-limit-requests", "500")
+	h.Set("x-ratelimit-remaining-requests", "450")
+	h.Set("x-ratelimit-reset-requests", "2s")
+
+	usages := p.ParseHeaders(h, now)
+	if len(usages) != 1 {
+		t.Fatalf("Expected 1 usage, got %d", len(usages))
+	}
+	if usages[0].PoolID != "openai:requests" {
+		t.Errorf("Expected pool openai:requests, got %s", usages[0].PoolID)
+	}
+	if usages[0].Used != 50 {
+		t.Errorf("Expected used 50, got %d", usages[0].Used)
+	}
+	if !usages[0].ResetAt.Equal(now.Add(2 * time.Second)) {
+		t.Errorf("Expected reset at %v, got %v", now.Add(2*time.Second), usages[0].ResetAt)
+	}
+
+	if got := p.ParseHeaders(http.Header{}, now); len(got) != 0 {
+		t.Errorf("Expected no usages for empty headers, got %d", len(got))
+	}
+}
+
+func TestParseHeaders_PerModelPools(t *testing.T) {
+	p, err := NewOpenAIProviderWithOptions("test", "", "", "", Options{Models: []string{"gpt-4o", "gpt-4o-mini"}})
+	if err != nil {
+		t.Fatalf("NewOpenAIProviderWithOptions failed: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]string{
+		"gpt-4o-mini":            "openai:gpt-4o-mini:tokens",
+		"gpt-4o-mini-2024-07-18": "openai:gpt-4o-mini:tokens",
+		"gpt-4o-2024-08-06":      "openai:gpt-4o:tokens",
+		"o1-preview":             "openai:tokens",
+		"":                       "openai:tokens",
+	}
+	for model, want := range cases {
+		h := http.Header{}
+		h.Set("x-ratelimit-limit-tokens", "30000")
+		h.Set("x-ratelimit-remaining-tokens", "29000")
+		if model != "" {
+			h.Set("openai-model", model)
+		}
+		usages := p.ParseHeaders(h, now)
+		if len(usages) != 1 || usages[0].PoolID != want {
+			t.Errorf("model %q: expected pool %s, got %+v", model, want, usages)
+		}
+	}
+}
+
+func TestParseResetDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"100ms":    100 * time.Millisecond,
+		"6m0s":     6 * time.Minute,
+		"1h2m3.5s": time.Hour + 2*time.Minute + 3500*time.Millisecond,
+		"1d2h":     26 * time.Hour,
+		"20":       20 * time.Second,
+	}
+	for in, want := range cases {
+		got, ok := ParseResetDuration(in)
+		if !ok || got != want {
+			t.Errorf("ParseResetDuration(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	if _, ok := ParseResetDuration("soon"); ok {
+		t.Error("Expected failure for invalid reset string")
+	}
+}
+
+func TestPoll_PerModelPools(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("OpenAI-Project") != "proj_abc" {
+			t.Errorf("Expected OpenAI-Project header, got %q", r.Header.Get("OpenAI-Project"))
+		}
+		switch r.URL.Path {
+		case "/models/gpt-4o":
+			w.Header().Set("x-ratelimit-limit-tokens", "30000")
+			w.Header().Set("x-ratelimit-remaining-tokens", "29000")
+			w.Header().Set("x-ratelimit-reset-tokens", "1h2m3.5s")
+		case "/models/gpt-4o-mini":
+			// No headers: requires the probe
+		case "/chat/completions":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["model"] != "gpt-4o-mini" || body["max_tokens"] != float64(1) {
+				t.Errorf("Unexpected probe body: %v", body)
+			}
+			w.Header().Set("x-ratelimit-limit-requests", "500")
+			w.Header().Set("x-ratelimit-remaining-requests", "499")
+			w.Header().Set("x-ratelimit-reset-requests", "6m0s")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	p, err := NewOpenAIProviderWithOptions("test", "fake-token", "", server.URL, Options{
+		ProjectID: "proj_abc",
+		Models:    []string{"gpt-4o", "gpt-4o-mini"},
+		Probe:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAIProviderWithOptions failed: %v", err)
+	}
+
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s): %v", err, result.Status, result.Error)
+	}
+
+	byPool := map[string]provider.UsageObservation{}
+	for _, u := range result.Usage {
+		byPool[u.PoolID] = u
+	}
+	if len(byPool) != 2 {
+		t.Fatalf("Expected 2 pools, got %v", byPool)
+	}
+	if u, ok := byPool["openai:gpt-4o:tokens"]; !ok || u.Used != 1000 {
+		t.Errorf("Unexpected gpt-4o tokens pool: %+v", u)
+	}
+	if u, ok := byPool["openai:gpt-4o-mini:requests"]; !ok || u.Remaining != 499 {
+		t.Errorf("Unexpected gpt-4o-mini requests pool: %+v", u)
+	}
+}
+
+func TestPoll_Azure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "azure-key" {
+			t.Errorf("Expected api-key header, got %q", r.Header.Get("api-key"))
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Expected no Authorization header for Azure")
+		}
+		if r.URL.Path != "/openai/deployments/prod-4o/chat/completions" || r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("Unexpected URL %s", r.URL.String())
+		}
+		w.Header().Set("x-ratelimit-limit-tokens", "80000")
+		w.Header().Set("x-ratelimit-remaining-tokens", "79990")
+		w.Header().Set("x-ratelimit-reset-tokens", "1s")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	p, err := NewOpenAIProviderWithOptions("azure", "azure-key", "", "", Options{
+		Models:           []string{"prod-4o"},
+		AzureURLTemplate: server.URL + "/openai/deployments/{deployment}/chat/completions?api-version=2024-06-01",
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAIProviderWithOptions failed: %v", err)
+	}
+
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s): %v", err, result.Status, result.Error)
+	}
+	if len(result.Usage) != 1 || result.Usage[0].PoolID != "openai:prod-4o:tokens" {
+		t.Errorf("Expected openai:prod-4o:tokens, got %+v", result.Usage)
+	}
+}
+
+func TestNewOpenAIProviderWithOptions_AzureRequiresModels(t *testing.T) {
+	_, err := NewOpenAIProviderWithOptions("azure", "azure-key", "", "", Options{
+		AzureURLTemplate: "https://myres.openai.azure.com/openai/deployments/{deployment}/chat/completions?api-version=2024-06-01",
+	})
+	if err == nil || !strings.Contains(err.Error(), "requires models") {
+		t.Errorf("Expected error for Azure template without models, got %v", err)
+	}
+}
@@ -0,0 +1,325 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+)
+
+const (
+	DefaultTimeout        = 10 * time.Second
+	DefaultRestartBackoff = time.Second
+	maxRestartBackoff     = time.Minute
+)
+
+// ErrRestartBackoff is returned while a crashed plugin waits to be restarted.
+var ErrRestartBackoff = errors.New("plugin restart backoff in effect")
+
+// Config declares a plugin executable or socket.
+type Config struct {
+	ID      provider.ProviderID
+	Command string // Executable to launch; empty to connect to an existing Socket
+	Args    []string
+	Env     []string // Extra KEY=VALUE entries appended to the daemon's environment
+	Socket  string   // Unix socket path; empty to use stdio
+
+	Timeout        time.Duration // Per-call deadline (default 10s)
+	RestartBackoff time.Duration // Initial delay before restarting after a crash (default 1s)
+}
+
+// Plugin is a provider.Provider backed by a supervised plugin process.
+// The process is started on first use and restarted after it crashes or stops
+// responding, with exponential backoff between restarts. The last state
+// returned by Poll is replayed through Restore after every restart.
+type Plugin struct {
+	cfg Config
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	client    *rpc.Client
+	exited    chan struct{} // Closed when the current process exits
+	state     []byte
+	failures  int
+	nextStart time.Time
+	closed    bool
+}
+
+// New creates a plugin provider. The process is not started until the first call.
+func New(cfg Config) *Plugin {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.RestartBackoff <= 0 {
+		cfg.RestartBackoff = DefaultRestartBackoff
+	}
+	return &Plugin{cfg: cfg}
+}
+
+// ID returns the configured provider ID. It never calls the plugin.
+func (p *Plugin) ID() provider.ProviderID {
+	return p.cfg.ID
+}
+
+// Poll forwards to the plugin. Transport failures and timeouts are reported as
+// an error status, so the poller backs off as for any other provider error.
+func (p *Plugin) Poll(ctx context.Context) (provider.PollResult, error) {
+	var reply PollReply
+	err := p.call(ctx, "Poll", PollArgs{TimeoutMs: p.cfg.Timeout.Milliseconds()}, &reply)
+	if err != nil {
+		return provider.PollResult{ProviderID: p.cfg.ID, Status: "error", Error: err, Timestamp: time.Now()}, nil
+	}
+
+	result := toResult(p.cfg.ID, reply)
+	if result.State != nil {
+		p.mu.Lock()
+		p.state = result.State
+		p.mu.Unlock()
+	}
+	return result, nil
+}
+
+// Restore records the state and forwards it to the plugin.
+func (p *Plugin) Restore(state []byte) error {
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+	return p.call(context.Background(), "Restore", RestoreArgs{State: state}, &RestoreReply{})
+}
+
+// Close stops the plugin process. The plugin is not restarted afterwards.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.stopLocked()
+	return nil
+}
+
+// call invokes a method with the configured timeout, (re)starting the plugin if needed.
+func (p *Plugin) call(ctx context.Context, method string, args, reply interface{}) error {
+	client, err := p.ensure()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	c := client.Go(serviceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-c.Done:
+		if c.Error != nil {
+			var serverErr rpc.ServerError
+			if errors.As(c.Error, &serverErr) {
+				// The plugin answered; the connection is still healthy.
+				return fmt.Errorf("plugin %s: %s", p.cfg.ID, c.Error)
+			}
+			p.fail(client, c.Error)
+			return fmt.Errorf("plugin %s: %w", p.cfg.ID, c.Error)
+		}
+		p.mu.Lock()
+		p.failures = 0
+		p.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		// An unresponsive plugin is killed and restarted on the next call.
+		p.fail(client, ctx.Err())
+		return fmt.Errorf("plugin %s: %s timed out: %w", p.cfg.ID, method, ctx.Err())
+	}
+}
+
+// ensure returns a connected client, starting the plugin if it is not running.
+func (p *Plugin) ensure() (*rpc.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("plugin %s: closed", p.cfg.ID)
+	}
+	if p.client != nil {
+		return p.client, nil
+	}
+	if time.Now().Before(p.nextStart) {
+		return nil, fmt.Errorf("plugin %s: %w", p.cfg.ID, ErrRestartBackoff)
+	}
+
+	if err := p.startLocked(); err != nil {
+		p.scheduleRestartLocked()
+		return nil, fmt.Errorf("plugin %s: failed to start: %w", p.cfg.ID, err)
+	}
+	return p.client, nil
+}
+
+// startLocked launches the process (if any), connects and performs the handshake.
+func (p *Plugin) startLocked() error {
+	var conn io.ReadWriteCloser
+
+	if p.cfg.Command != "" {
+		cmd := exec.Command(p.cfg.Command, p.cfg.Args...)
+		cmd.Env = append(os.Environ(), p.cfg.Env...)
+		cmd.Stderr = os.Stderr // Plugin logs pass through to the daemon's log
+
+		var stdin io.WriteCloser
+		var stdout io.ReadCloser
+		if p.cfg.Socket != "" {
+			cmd.Env = append(cmd.Env, SocketEnvVar+"="+p.cfg.Socket)
+		} else {
+			var err error
+			if stdin, err = cmd.StdinPipe(); err != nil {
+				return err
+			}
+			if stdout, err = cmd.StdoutPipe(); err != nil {
+				return err
+			}
+		}
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		p.cmd = cmd
+		exited := make(chan struct{})
+		p.exited = exited
+		go p.wait(cmd, exited)
+
+		if p.cfg.Socket == "" {
+			conn = pipeConn{Reader: stdout, WriteCloser: stdin}
+		}
+	}
+
+	if conn == nil {
+		c, err := p.dial()
+		if err != nil {
+			p.stopLocked()
+			return err
+		}
+		conn = c
+	}
+
+	client := jsonrpc.NewClient(conn)
+
+	// Handshake
+	var id IDReply
+	c := client.Go(serviceName+".ID", IDArgs{}, &id, make(chan *rpc.Call, 1))
+	select {
+	case <-c.Done:
+	case <-time.After(p.cfg.Timeout):
+		c.Error = errors.New("handshake timed out")
+	}
+	if c.Error == nil && id.ProtocolVersion != ProtocolVersion {
+		c.Error = fmt.Errorf("unsupported protocol version %d (want %d)", id.ProtocolVersion, ProtocolVersion)
+	}
+	if c.Error != nil {
+		client.Close()
+		p.stopLocked()
+		return c.Error
+	}
+
+	p.client = client
+	log.Printf("Plugin %s started (reports id %q)", p.cfg.ID, id.ID)
+
+	// Replay the last known state into the fresh process
+	if p.state != nil {
+		rc := client.Go(serviceName+".Restore", RestoreArgs{State: p.state}, &RestoreReply{}, make(chan *rpc.Call, 1))
+		select {
+		case <-rc.Done:
+			if rc.Error != nil {
+				log.Printf("Plugin %s failed to restore state: %v", p.cfg.ID, rc.Error)
+			}
+		case <-time.After(p.cfg.Timeout):
+			log.Printf("Plugin %s timed out restoring state", p.cfg.ID)
+		}
+	}
+	return nil
+}
+
+// dial connects to the plugin socket, retrying until the plugin is listening.
+func (p *Plugin) dial() (net.Conn, error) {
+	deadline := time.Now().Add(p.cfg.Timeout)
+	for {
+		conn, err := net.Dial("unix", p.cfg.Socket)
+		if err == nil {
+			return conn, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		if p.exited != nil {
+			select {
+			case <-p.exited:
+				return nil, errors.New("plugin exited before listening")
+			default:
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// wait reaps the process and drops the connection if it was still current.
+func (p *Plugin) wait(cmd *exec.Cmd, exited chan struct{}) {
+	err := cmd.Wait()
+	close(exited)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != cmd {
+		return
+	}
+	if !p.closed {
+		log.Printf("Plugin %s exited: %v", p.cfg.ID, err)
+		p.scheduleRestartLocked()
+	}
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+	p.cmd = nil
+}
+
+// fail tears down a broken connection so the next call restarts the plugin.
+func (p *Plugin) fail(client *rpc.Client, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != client {
+		return // Already replaced
+	}
+	log.Printf("Plugin %s failed: %v", p.cfg.ID, err)
+	p.stopLocked()
+	p.scheduleRestartLocked()
+}
+
+func (p *Plugin) scheduleRestartLocked() {
+	backoff := p.cfg.RestartBackoff << p.failures
+	if backoff > maxRestartBackoff || backoff <= 0 {
+		backoff = maxRestartBackoff
+	}
+	p.failures++
+	p.nextStart = time.Now().Add(backoff)
+}
+
+func (p *Plugin) stopLocked() {
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+	if p.cmd != nil && p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	p.cmd = nil
+}
+
+// pipeConn joins the child's stdout and stdin into a single connection.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
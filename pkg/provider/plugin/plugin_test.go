@@ -0,0 +1,202 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+	"github.com/rmax-ai/ratelord/pkg/provider/plugin/plugintest"
+)
+
+const helperModeEnv = "RATELORD_PLUGIN_TEST_MODE"
+
+// helperProvider is served by the re-executed test binary.
+type helperProvider struct {
+	mode  string
+	polls int64
+}
+
+func (h *helperProvider) ID() provider.ProviderID { return "helper" }
+
+func (h *helperProvider) Poll(ctx context.Context) (provider.PollResult, error) {
+	h.polls++
+	switch h.mode {
+	case "crash":
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Hour)
+	case "fail":
+		return provider.PollResult{}, errors.New("upstream unavailable")
+	}
+	return provider.PollResult{
+		ProviderID: h.ID(),
+		Status:     "success",
+		Timestamp:  time.Now(),
+		Usage: []provider.UsageObservation{
+			{PoolID: "helper:pool", Used: h.polls, Remaining: 100 - h.polls, Limit: 100},
+		},
+		State:      []byte(strconv.FormatInt(h.polls, 10)),
+		RetryAfter: 2 * time.Second,
+	}, nil
+}
+
+func (h *helperProvider) Restore(state []byte) error {
+	if len(state) == 0 {
+		return nil
+	}
+	n, err := strconv.ParseInt(string(state), 10, 64)
+	if err != nil {
+		return err
+	}
+	h.polls = n
+	return nil
+}
+
+// TestMain lets the test binary act as a plugin when re-executed.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(helperModeEnv); mode != "" {
+		if err := Serve(&helperProvider{mode: mode}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func helperConfig(t *testing.T, mode string) Config {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Executable failed: %v", err)
+	}
+	return Config{
+		ID:             "plugin-helper",
+		Command:        exe,
+		Env:            []string{helperModeEnv + "=" + mode},
+		Timeout:        2 * time.Second,
+		RestartBackoff: 10 * time.Millisecond,
+	}
+}
+
+func TestPlugin_Stdio(t *testing.T) {
+	p := New(helperConfig(t, "ok"))
+	defer p.Close()
+
+	result, err := p.Poll(context.Background())
+	if err != nil || result.Status != "success" {
+		t.Fatalf("Expected success, got %v (%s): %v", err, result.Status, result.Error)
+	}
+	if result.ProviderID != "plugin-helper" {
+		t.Errorf("Expected configured provider ID, got %s", result.ProviderID)
+	}
+	if len(result.Usage) != 1 || result.Usage[0].PoolID != "helper:pool" || result.Usage[0].Used != 1 {
+		t.Errorf("Unexpected usage: %+v", result.Usage)
+	}
+	if result.RetryAfter != 2*time.Second {
+		t.Errorf("Expected retry after 2s, got %v", result.RetryAfter)
+	}
+
+	plugintest.Run(t, p)
+}
+
+func TestPlugin_Socket(t *testing.T) {
+	cfg := helperConfig(t, "ok")
+	cfg.Socket = filepath.Join(t.TempDir(), "plugin.sock")
+	p := New(cfg)
+	defer p.Close()
+
+	plugintest.Run(t, p)
+}
+
+func TestPlugin_ProviderError(t *testing.T) {
+	p := New(helperConfig(t, "fail"))
+	defer p.Close()
+
+	result, _ := p.Poll(context.Background())
+	if result.Status != "error" || result.Error == nil {
+		t.Fatalf("Expected error status, got %+v", result)
+	}
+
+	// A provider-level error does not restart the plugin
+	p.mu.Lock()
+	running := p.client != nil
+	p.mu.Unlock()
+	if !running {
+		t.Error("Expected plugin to keep running after provider error")
+	}
+}
+
+func TestPlugin_RestartAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	exe, _ := os.Executable()
+
+	// The first process crashes on Poll; the replacement works.
+	script := filepath.Join(dir, "plugin.sh")
+	marker := filepath.Join(dir, "crashed")
+	body := fmt.Sprintf("#!/bin/sh\nif [ ! -f %q ]; then touch %q; export %s=crash; else export %s=ok; fi\nexec %q\n",
+		marker, marker, helperModeEnv, helperModeEnv, exe)
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	cfg := helperConfig(t, "")
+	cfg.Command = script
+	cfg.Env = nil
+	p := New(cfg)
+	defer p.Close()
+
+	// Seed state so the restarted plugin resumes from it
+	if err := p.Restore([]byte("41")); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	result, _ := p.Poll(context.Background())
+	if result.Status != "error" {
+		t.Fatalf("Expected error from crashing plugin, got %+v", result)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		result, _ = p.Poll(context.Background())
+		if result.Status == "success" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if result.Status != "success" {
+		t.Fatalf("Expected plugin to recover, got %v", result.Error)
+	}
+	if result.Usage[0].Used != 42 {
+		t.Errorf("Expected restored state to carry over (used 42), got %d", result.Usage[0].Used)
+	}
+}
+
+func TestPlugin_Timeout(t *testing.T) {
+	cfg := helperConfig(t, "hang")
+	cfg.Timeout = 200 * time.Millisecond
+	p := New(cfg)
+	defer p.Close()
+
+	start := time.Now()
+	result, _ := p.Poll(context.Background())
+	if result.Status != "error" {
+		t.Fatalf("Expected timeout error, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Poll took %v despite timeout", elapsed)
+	}
+
+	// The hung process was killed
+	p.mu.Lock()
+	running := p.client != nil
+	p.mu.Unlock()
+	if running {
+		t.Error("Expected hung plugin to be stopped")
+	}
+}
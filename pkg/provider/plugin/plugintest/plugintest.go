@@ -0,0 +1,98 @@
+// Package plugintest is a conformance harness for provider plugins. Plugin
+// authors run it against their executable from an ordinary Go test:
+//
+//	func TestConformance(t *testing.T) {
+//		p := plugin.New(plugin.Config{ID: "my-plugin", Command: "./my-plugin"})
+//		defer p.Close()
+//		plugintest.Run(t, p)
+//	}
+package plugintest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+)
+
+// validStatuses are the PollResult.Status values the poller understands.
+var validStatuses = map[string]bool{"success": true, "partial": true, "error": true}
+
+// Run checks that p honours the provider contract. It works for in-process
+// providers as well as plugin.Plugin.
+func Run(t *testing.T, p provider.Provider) {
+	t.Helper()
+
+	t.Run("ID", func(t *testing.T) {
+		id := p.ID()
+		if id == "" {
+			t.Fatal("ID must not be empty")
+		}
+		if p.ID() != id {
+			t.Error("ID must be stable across calls")
+		}
+	})
+
+	var state []byte
+	t.Run("Poll", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		result, err := p.Poll(ctx)
+		if err != nil {
+			t.Fatalf("Poll returned error: %v", err)
+		}
+		if !validStatuses[result.Status] {
+			t.Errorf("Unexpected status %q", result.Status)
+		}
+		if result.Status == "error" {
+			t.Fatalf("Poll reported error: %v", result.Error)
+		}
+		if result.ProviderID != p.ID() {
+			t.Errorf("ProviderID %q does not match ID %q", result.ProviderID, p.ID())
+		}
+		if result.Timestamp.IsZero() {
+			t.Error("Timestamp must be set")
+		}
+		if result.RetryAfter < 0 {
+			t.Errorf("RetryAfter must not be negative, got %v", result.RetryAfter)
+		}
+
+		seen := make(map[string]bool)
+		for _, u := range result.Usage {
+			if u.PoolID == "" {
+				t.Error("Usage PoolID must not be empty")
+			}
+			if seen[u.PoolID] {
+				t.Errorf("Duplicate pool %q", u.PoolID)
+			}
+			seen[u.PoolID] = true
+			if u.Remaining < 0 || u.Used < 0 {
+				t.Errorf("Pool %q has negative usage: %+v", u.PoolID, u)
+			}
+			if u.Limit > 0 && u.Remaining > u.Limit {
+				t.Errorf("Pool %q remaining exceeds limit: %+v", u.PoolID, u)
+			}
+		}
+		state = result.State
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if err := p.Restore(nil); err != nil {
+			t.Errorf("Restore(nil) failed: %v", err)
+		}
+		if state != nil {
+			if err := p.Restore(state); err != nil {
+				t.Errorf("Restore of polled state failed: %v", err)
+			}
+		}
+	})
+
+	t.Run("PollAfterRestore", func(t *testing.T) {
+		result, err := p.Poll(context.Background())
+		if err != nil || result.Status == "error" {
+			t.Errorf("Poll after Restore failed: %v %v", err, result.Error)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+// Package plugin runs providers out of process. A plugin is an executable that
+// serves the provider.Provider contract as JSON-RPC (net/rpc/jsonrpc) over its
+// stdin/stdout, or over a Unix socket when RATELORD_PLUGIN_SOCKET is set.
+//
+// Methods:
+//
+//	Provider.ID      IDArgs      -> IDReply
+//	Provider.Poll    PollArgs    -> PollReply
+//	Provider.Restore RestoreArgs -> RestoreReply
+//
+// Plugins written in Go call Serve; other languages implement the same JSON
+// messages, e.g. {"method":"Provider.Poll","params":[{"timeout_ms":5000}],"id":1}.
+package plugin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+)
+
+// ProtocolVersion is bumped on incompatible changes to the messages below.
+const ProtocolVersion = 1
+
+// SocketEnvVar tells a plugin to listen on a Unix socket instead of stdio.
+const SocketEnvVar = "RATELORD_PLUGIN_SOCKET"
+
+const serviceName = "Provider"
+
+type IDArgs struct{}
+
+type IDReply struct {
+	ID              string `json:"id"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+type PollArgs struct {
+	TimeoutMs int64 `json:"timeout_ms"` // Deadline the daemon will wait for
+}
+
+type PollReply struct {
+	ProviderID   string        `json:"provider_id"`
+	Status       string        `json:"status"` // "success", "partial", "error"
+	Error        string        `json:"error,omitempty"`
+	Timestamp    time.Time     `json:"timestamp"`
+	Usage        []UsageRecord `json:"usage"`
+	State        []byte        `json:"state,omitempty"` // base64 in JSON
+	RetryAfterMs int64         `json:"retry_after_ms,omitempty"`
+}
+
+type UsageRecord struct {
+	PoolID    string    `json:"pool_id"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Limit     int64     `json:"limit"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+type RestoreArgs struct {
+	State []byte `json:"state"`
+}
+
+type RestoreReply struct{}
+
+// toReply converts a PollResult to its wire form.
+func toReply(r provider.PollResult) PollReply {
+	reply := PollReply{
+		ProviderID:   string(r.ProviderID),
+		Status:       r.Status,
+		Timestamp:    r.Timestamp,
+		Usage:        make([]UsageRecord, 0, len(r.Usage)),
+		State:        r.State,
+		RetryAfterMs: r.RetryAfter.Milliseconds(),
+	}
+	if r.Error != nil {
+		reply.Error = r.Error.Error()
+	}
+	for _, u := range r.Usage {
+		reply.Usage = append(reply.Usage, UsageRecord{
+			PoolID:    u.PoolID,
+			Used:      u.Used,
+			Remaining: u.Remaining,
+			Limit:     u.Limit,
+			ResetAt:   u.ResetAt,
+		})
+	}
+	return reply
+}
+
+// toResult converts a wire reply to a PollResult reported under id.
+func toResult(id provider.ProviderID, reply PollReply) provider.PollResult {
+	result := provider.PollResult{
+		ProviderID: id,
+		Status:     reply.Status,
+		Timestamp:  reply.Timestamp,
+		State:      reply.State,
+		RetryAfter: time.Duration(reply.RetryAfterMs) * time.Millisecond,
+	}
+	if result.Timestamp.IsZero() {
+		result.Timestamp = time.Now()
+	}
+	if reply.Error != "" {
+		result.Error = errors.New(reply.Error)
+		if result.Status == "" {
+			result.Status = "error"
+		}
+	}
+	for _, u := range reply.Usage {
+		result.Usage = append(result.Usage, provider.UsageObservation{
+			PoolID:    u.PoolID,
+			Used:      u.Used,
+			Remaining: u.Remaining,
+			Limit:     u.Limit,
+			ResetAt:   u.ResetAt,
+		})
+	}
+	return result
+}
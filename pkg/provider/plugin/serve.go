@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/provider"
+)
+
+// service adapts a provider.Provider to net/rpc method signatures.
+type service struct {
+	p provider.Provider
+}
+
+func (s *service) ID(args IDArgs, reply *IDReply) error {
+	reply.ID = string(s.p.ID())
+	reply.ProtocolVersion = ProtocolVersion
+	return nil
+}
+
+func (s *service) Poll(args PollArgs, reply *PollReply) error {
+	ctx := context.Background()
+	if args.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(args.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	result, err := s.p.Poll(ctx)
+	if err != nil {
+		return err
+	}
+	*reply = toReply(result)
+	return nil
+}
+
+func (s *service) Restore(args RestoreArgs, reply *RestoreReply) error {
+	return s.p.Restore(args.State)
+}
+
+func newServer(p provider.Provider) (*rpc.Server, error) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(serviceName, &service{p: p}); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// stdio joins stdin and stdout into a single connection.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdio) Close() error { return nil }
+
+// Serve runs p as a plugin until the daemon disconnects. It listens on the Unix
+// socket named by RATELORD_PLUGIN_SOCKET if set, and on stdin/stdout otherwise.
+// Plugins must write logs to stderr, since stdout carries the protocol.
+func Serve(p provider.Provider) error {
+	if path := os.Getenv(SocketEnvVar); path != "" {
+		return ServeSocket(p, path)
+	}
+	return ServeConn(p, stdio{Reader: os.Stdin, Writer: os.Stdout})
+}
+
+// ServeConn serves p on a single connection, returning when it is closed.
+func ServeConn(p provider.Provider, conn io.ReadWriteCloser) error {
+	srv, err := newServer(p)
+	if err != nil {
+		return err
+	}
+	srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+// ServeSocket listens on a Unix socket and serves each connection in turn.
+func ServeSocket(p provider.Provider, path string) error {
+	srv, err := newServer(p)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(path) // Stale socket from a previous run
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
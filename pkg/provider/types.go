@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"net/http"
 	"time"
 )
 
@@ -18,6 +19,10 @@ type PollResult struct {
 	// Usage data
 	Usage []UsageObservation
 	State []byte
+
+	// RetryAfter is an optional hint from the provider (e.g. a 429 Retry-After)
+	// to wait at least this long before polling again.
+	RetryAfter time.Duration
 }
 
 // UsageObservation represents a single point of usage data
@@ -40,3 +45,17 @@ type Provider interface {
 	// Restore restores the provider state from a previous poll
 	Restore(state []byte) error
 }
+
+// HeaderParser is implemented by providers that can derive usage observations
+// from the rate-limit headers of an ordinary API response. It is used to ingest
+// headers forwarded by clients (passive observation) without spending quota.
+type HeaderParser interface {
+	ParseHeaders(header http.Header, now time.Time) []UsageObservation
+}
+
+// PricingDefaulter is implemented by providers that know the list price of
+// their units. Defaults are cost per unit in micro-USD keyed by pool ID, and are
+// only used for pools without an explicit entry in the policy pricing table.
+type PricingDefaulter interface {
+	DefaultPricing() map[string]int64
+}
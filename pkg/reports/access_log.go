@@ -50,7 +50,7 @@ func (r *AccessLogReport) Generate(ctx context.Context, params ReportParams) (io
 	}
 
 	// Query events
-	events, err := r.store.QueryEvents(ctx, filter)
+	events, err := queryAllEvents(ctx, r.store, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
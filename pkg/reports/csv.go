@@ -47,7 +47,7 @@ func (r *EventReport) Generate(ctx context.Context, params ReportParams) (io.Rea
 	}
 
 	// Query events
-	events, err := r.store.QueryEvents(ctx, filter)
+	events, err := queryAllEvents(ctx, r.store, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
@@ -17,3 +17,23 @@ func NewReportGenerator(reportType ReportType, s ReportStore) (Generator, error)
 		return nil, fmt.Errorf("unknown report type: %s", reportType)
 	}
 }
+
+// NewFormattedReportGenerator creates a report generator writing the given
+// format. An empty format means CSV.
+func NewFormattedReportGenerator(reportType ReportType, format ReportFormat, s ReportStore) (Generator, error) {
+	switch format {
+	case "", ReportFormatCSV:
+		return NewReportGenerator(reportType, s)
+	case ReportFormatParquet:
+		if reportType == ReportTypeEvents {
+			return NewEventParquetReport(s), nil
+		}
+		gen, err := NewReportGenerator(reportType, s)
+		if err != nil {
+			return nil, err
+		}
+		return &csvParquetReport{csv: gen}, nil
+	default:
+		return nil, fmt.Errorf("unsupported report format: %s", format)
+	}
+}
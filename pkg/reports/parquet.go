@@ -0,0 +1,89 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/rmax-ai/ratelord/pkg/parquet"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// EventParquetReport exports raw events as Parquet, with the same columnar
+// schema as Parquet archives (store.EventParquetSchema).
+type EventParquetReport struct {
+	store ReportStore
+}
+
+// NewEventParquetReport creates a new EventParquetReport generator.
+func NewEventParquetReport(s ReportStore) *EventParquetReport {
+	return &EventParquetReport{store: s}
+}
+
+// Generate creates a Parquet file of the events matching the parameters.
+func (r *EventParquetReport) Generate(ctx context.Context, params ReportParams) (io.Reader, error) {
+	filter := store.EventFilter{
+		From: params.Start,
+		To:   params.End,
+	}
+	if identityID, ok := params.Filters["identity_id"].(string); ok && identityID != "" {
+		filter.IdentityID = identityID
+	}
+	if scopeID, ok := params.Filters["scope_id"].(string); ok && scopeID != "" {
+		filter.ScopeID = scopeID
+	}
+
+	events, err := queryAllEvents(ctx, r.store, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := store.WriteEventsParquet(buf, events); err != nil {
+		return nil, fmt.Errorf("failed to write parquet: %w", err)
+	}
+	return buf, nil
+}
+
+// csvParquetReport converts a CSV report to Parquet, one string column per
+// CSV column.
+type csvParquetReport struct {
+	csv Generator
+}
+
+func (r *csvParquetReport) Generate(ctx context.Context, params ReportParams) (io.Reader, error) {
+	out, err := r.csv.Generate(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("report has no header")
+	}
+
+	schema := make([]parquet.Column, len(records[0]))
+	for i, name := range records[0] {
+		schema[i] = parquet.Column{Name: name, Type: parquet.String}
+	}
+
+	buf := &bytes.Buffer{}
+	w := parquet.NewWriter(buf, schema)
+	row := make([]interface{}, len(schema))
+	for _, record := range records[1:] {
+		for i, v := range record {
+			row[i] = v
+		}
+		if err := w.Write(row); err != nil {
+			return nil, fmt.Errorf("failed to write parquet: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write parquet: %w", err)
+	}
+	return buf, nil
+}
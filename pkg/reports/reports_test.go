@@ -1,13 +1,20 @@
 package reports
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/rmax-ai/ratelord/pkg/blob"
 	"github.com/rmax-ai/ratelord/pkg/engine"
+	"github.com/rmax-ai/ratelord/pkg/parquet"
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
@@ -165,3 +172,105 @@ func TestUsageReport(t *testing.T) {
 		t.Errorf("Expected provider prov1, got %s", records[1][1])
 	}
 }
+
+func TestEventReport_IncludesArchive(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.NewStore(filepath.Join(dir, "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+	blobs := blob.NewLocalBlobStore(filepath.Join(dir, "blobs"))
+	ctx := context.Background()
+
+	// A year of decisions: the first 1500 archived, the rest still live
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	const archived, live = 1500, 20
+	for i := 0; i < archived+live; i++ {
+		ts := start.Add(time.Duration(i) * 5 * time.Hour)
+		evt := &store.Event{
+			EventID:    store.EventID(fmt.Sprintf("evt_%04d", i)),
+			EventType:  store.EventTypeIntentDecided,
+			TsEvent:    ts,
+			TsIngest:   ts,
+			Dimensions: store.EventDimensions{IdentityID: "team-a", ScopeID: "scope1"},
+			Payload:    json.RawMessage(`{"decision":"approve"}`),
+		}
+		if i < archived {
+			enc.Encode(evt)
+			continue
+		}
+		if err := st.AppendEvent(ctx, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+	gz.Close()
+	last := start.Add(time.Duration(archived-1) * 5 * time.Hour)
+	if err := blobs.Put(ctx, store.ArchiveKey(start, last, "seg"), &buf); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	r := NewEventReport(store.NewArchiveStore(st, blobs))
+	reader, err := r.Generate(ctx, ReportParams{Start: start, End: start.AddDate(1, 0, 0)})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if len(records) != 1+archived+live {
+		t.Errorf("Expected %d records, got %d", 1+archived+live, len(records))
+	}
+}
+
+func TestParquetReports(t *testing.T) {
+	now := time.Now().UTC()
+	s := &mockReportStore{
+		events: []*store.Event{
+			{EventID: "evt1", EventType: store.EventTypeIdentityRegistered, TsEvent: now, TsIngest: now, Payload: json.RawMessage(`{"foo":"bar"}`)},
+		},
+		usageStats: []store.UsageStat{{BucketTs: now, ProviderID: "prov1", PoolID: "pool1", TotalUsage: 100, EventCount: 10}},
+	}
+	params := ReportParams{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}
+	ctx := context.Background()
+
+	gen, err := NewFormattedReportGenerator(ReportTypeEvents, ReportFormatParquet, s)
+	if err != nil {
+		t.Fatalf("NewFormattedReportGenerator failed: %v", err)
+	}
+	out, err := gen.Generate(ctx, params)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	data, _ := io.ReadAll(out)
+	events, err := store.ReadEventsParquet(data)
+	if err != nil {
+		t.Fatalf("ReadEventsParquet failed: %v", err)
+	}
+	if len(events) != 1 || events[0].EventID != "evt1" || string(events[0].Payload) != `{"foo":"bar"}` {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	// Other reports keep their CSV columns
+	gen, _ = NewFormattedReportGenerator(ReportTypeUsage, ReportFormatParquet, s)
+	out, err = gen.Generate(ctx, params)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	data, _ = io.ReadAll(out)
+	schema, rows, err := parquet.ReadFile(data)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(schema) != 11 || schema[1].Name != "provider" || len(rows) != 1 || rows[0][1] != "prov1" {
+		t.Errorf("unexpected usage parquet: %+v %v", schema, rows)
+	}
+
+	if _, err := NewFormattedReportGenerator(ReportTypeUsage, "xml", s); err == nil {
+		t.Error("expected an unsupported format to fail")
+	}
+}
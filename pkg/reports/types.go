@@ -19,8 +19,9 @@ const (
 type ReportFormat string
 
 const (
-	ReportFormatCSV  ReportFormat = "csv"
-	ReportFormatJSON ReportFormat = "json"
+	ReportFormatCSV     ReportFormat = "csv"
+	ReportFormatJSON    ReportFormat = "json"
+	ReportFormatParquet ReportFormat = "parquet"
 )
 
 type ReportParams struct {
@@ -35,6 +36,29 @@ type ReportStore interface {
 	GetUsageStats(ctx context.Context, filter store.UsageFilter) ([]store.UsageStat, error)
 }
 
+// reportPageSize is the number of events fetched per query while generating
+// a report.
+const reportPageSize = 1000
+
+// queryAllEvents pages through every event matching filter, so that reports
+// over long ranges (e.g. a yearly chargeback) are not cut at one page. With
+// an archive-aware store the pages include archived events.
+func queryAllEvents(ctx context.Context, s ReportStore, filter store.EventFilter) ([]*store.Event, error) {
+	filter.Limit = reportPageSize
+	var all []*store.Event
+	for {
+		page, err := s.QueryEvents(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < reportPageSize {
+			return all, nil
+		}
+		filter.After = store.CursorAt(page[len(page)-1])
+	}
+}
+
 type Generator interface {
 	Generate(ctx context.Context, params ReportParams) (io.Reader, error)
 }
@@ -26,7 +26,7 @@ func (r *UsageReport) Generate(ctx context.Context, params ReportParams) (io.Rea
 	writer := csv.NewWriter(buf)
 
 	// Write CSV headers
-	headers := []string{"bucket_ts", "provider", "pool", "identity", "scope", "total_usage", "event_count"}
+	headers := []string{"bucket_ts", "provider", "pool", "identity", "scope", "total_usage", "event_count", "total_cost", "approved", "denied", "shaped"}
 	if err := writer.Write(headers); err != nil {
 		return nil, fmt.Errorf("failed to write headers: %w", err)
 	}
@@ -74,6 +74,10 @@ func (r *UsageReport) Generate(ctx context.Context, params ReportParams) (io.Rea
 			stat.ScopeID,
 			fmt.Sprintf("%d", stat.TotalUsage),
 			fmt.Sprintf("%d", stat.EventCount),
+			fmt.Sprintf("%d", stat.TotalCost),
+			fmt.Sprintf("%d", stat.ApprovedCount),
+			fmt.Sprintf("%d", stat.DeniedCount),
+			fmt.Sprintf("%d", stat.ShapedCount),
 		}
 
 		if err := writer.Write(row); err != nil {
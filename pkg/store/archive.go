@@ -0,0 +1,479 @@
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+)
+
+// ArchivePrefix is the blob key prefix under which archived events live.
+const ArchivePrefix = "events/"
+
+// archiveIngestSkew bounds how long after its ts_event an event may have been
+// ingested. Segments are keyed by ingest time while queries filter on event
+// time, so a segment starting later than To+skew cannot hold a match.
+const archiveIngestSkew = time.Hour
+
+// Archive segment formats.
+const (
+	ArchiveFormatJSONL   = "jsonl"   // Gzipped JSON Lines, one event per line
+	ArchiveFormatParquet = "parquet" // Parquet with EventParquetSchema
+)
+
+// ArchiveSegment is one archived batch of events. First and Last are the
+// ingest times of its oldest and newest event, truncated to the second.
+type ArchiveSegment struct {
+	Key    string
+	Format string
+	First  time.Time
+	Last   time.Time
+}
+
+// ArchiveKey returns the key for a segment holding events ingested between
+// first and last: events/YYYY/MM/DD/<first>_<last>_<id>.jsonl.gz, where the
+// date is that of first and the bounds are Unix seconds.
+func ArchiveKey(first, last time.Time, id string) string {
+	first, last = first.UTC(), last.UTC()
+	year, month, day := first.Date()
+	return fmt.Sprintf("%s%04d/%02d/%02d/%d_%d_%s.jsonl.gz",
+		ArchivePrefix, year, month, day, first.Unix(), last.Unix(), id)
+}
+
+// ArchiveParquetKey returns the key for a Parquet segment holding events of
+// one type that happened on one day. Keys use Hive-style partitions so that
+// query engines can prune them:
+// events/date=YYYY-MM-DD/event_type=<type>/<first>_<last>_<id>.parquet.
+func ArchiveParquetKey(day time.Time, eventType EventType, first, last time.Time, id string) string {
+	return fmt.Sprintf("%sdate=%s/event_type=%s/%d_%d_%s.parquet",
+		ArchivePrefix, day.UTC().Format("2006-01-02"), eventType, first.Unix(), last.Unix(), id)
+}
+
+// ParseArchiveKey parses a key produced by ArchiveKey or ArchiveParquetKey.
+func ParseArchiveKey(key string) (ArchiveSegment, bool) {
+	// Keys from LocalBlobStore.List use the OS path separator
+	key = strings.ReplaceAll(key, "\\", "/")
+	if !strings.HasPrefix(key, ArchivePrefix) {
+		return ArchiveSegment{}, false
+	}
+	base := path.Base(key)
+	seg := ArchiveSegment{Key: key}
+	var name string
+	switch {
+	case strings.HasSuffix(base, ".jsonl.gz"):
+		seg.Format, name = ArchiveFormatJSONL, strings.TrimSuffix(base, ".jsonl.gz")
+	case strings.HasSuffix(base, ".parquet"):
+		seg.Format, name = ArchiveFormatParquet, strings.TrimSuffix(base, ".parquet")
+	default:
+		return ArchiveSegment{}, false
+	}
+	parts := strings.SplitN(name, "_", 3)
+	if len(parts) != 3 {
+		return ArchiveSegment{}, false
+	}
+	first, err1 := strconv.ParseInt(parts[0], 10, 64)
+	last, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || last < first {
+		return ArchiveSegment{}, false
+	}
+	seg.First, seg.Last = time.Unix(first, 0).UTC(), time.Unix(last, 0).UTC()
+	return seg, true
+}
+
+// overlapsIngest reports whether the segment may hold events ingested in
+// [from, to]. Zero bounds are open.
+func (s ArchiveSegment) overlapsIngest(from, to time.Time) bool {
+	// Last is truncated, so its events may be up to a second later
+	if !from.IsZero() && s.Last.Add(time.Second).Before(from) {
+		return false
+	}
+	if !to.IsZero() && s.First.After(to) {
+		return false
+	}
+	return true
+}
+
+// Archive reads the event segments written to a blob store by the archive
+// worker.
+type Archive struct {
+	blobs blob.BlobStore
+}
+
+// NewArchive returns an Archive over b.
+func NewArchive(b blob.BlobStore) *Archive {
+	return &Archive{blobs: b}
+}
+
+// Segments lists the segments that may hold events with ts_event in
+// [from, to), oldest first. Zero bounds are open.
+func (a *Archive) Segments(ctx context.Context, from, to time.Time) ([]ArchiveSegment, error) {
+	keys, err := a.blobs.List(ctx, ArchivePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	// An event is never ingested before it happened
+	ingestTo := to
+	if !to.IsZero() {
+		ingestTo = to.Add(archiveIngestSkew)
+	}
+
+	var segments []ArchiveSegment
+	for _, key := range keys {
+		seg, ok := ParseArchiveKey(key)
+		if !ok || !seg.overlapsIngest(from, ingestTo) {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if !segments[i].First.Equal(segments[j].First) {
+			return segments[i].First.Before(segments[j].First)
+		}
+		return segments[i].Key < segments[j].Key
+	})
+	return segments, nil
+}
+
+// ReadSegment decodes the events of one segment, in archive order.
+func (a *Archive) ReadSegment(ctx context.Context, key string) ([]*Event, error) {
+	seg, ok := ParseArchiveKey(key)
+	if !ok {
+		return nil, fmt.Errorf("not an archive segment: %s", key)
+	}
+	rc, err := a.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if seg.Format == ArchiveFormatParquet {
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive %s: %w", key, err)
+		}
+		events, err := ReadEventsParquet(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode archive %s: %w", key, err)
+		}
+		return events, nil
+	}
+
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", key, err)
+	}
+	defer gz.Close()
+
+	var events []*Event
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	for dec.More() {
+		var evt Event
+		if err := dec.Decode(&evt); err != nil {
+			return nil, fmt.Errorf("failed to decode archive %s: %w", key, err)
+		}
+		events = append(events, &evt)
+	}
+	return events, nil
+}
+
+// QueryEvents returns the archived events matching filter, with the same
+// ordering, cursor and limit semantics as EventStore.QueryEvents.
+func (a *Archive) QueryEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
+	segments, err := a.Segments(ctx, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	return a.query(ctx, segments, filter)
+}
+
+func (a *Archive) query(ctx context.Context, segments []ArchiveSegment, filter EventFilter) ([]*Event, error) {
+	var events []*Event
+	for _, seg := range segments {
+		if filter.After != nil {
+			if !filter.Descending && !seg.overlapsIngest(filter.After.TsIngest, time.Time{}) {
+				continue
+			}
+			if filter.Descending && !seg.overlapsIngest(time.Time{}, filter.After.TsIngest) {
+				continue
+			}
+		}
+		segEvents, err := a.ReadSegment(ctx, seg.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range segEvents {
+			if filter.Matches(evt) {
+				events = append(events, evt)
+			}
+		}
+	}
+	return mergeEvents(nil, events, filter), nil
+}
+
+// Matches reports whether evt passes every condition of the filter, except
+// Limit. It mirrors the SQL of QueryEvents for events held outside the
+// database.
+func (f EventFilter) Matches(evt *Event) bool {
+	if !f.From.IsZero() && evt.TsEvent.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !evt.TsEvent.Before(f.To) {
+		return false
+	}
+	if len(f.EventTypes) > 0 {
+		found := false
+		for _, t := range f.EventTypes {
+			if evt.EventType == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.IdentityID != "" && evt.Dimensions.IdentityID != f.IdentityID {
+		return false
+	}
+	if f.ScopeID != "" && evt.Dimensions.ScopeID != f.ScopeID {
+		return false
+	}
+	if f.CorrelationID != "" && evt.Correlation.CorrelationID != f.CorrelationID {
+		return false
+	}
+	if f.CausationID != "" && evt.Correlation.CausationID != f.CausationID {
+		return false
+	}
+	if f.Epoch != nil && evt.Epoch != *f.Epoch {
+		return false
+	}
+	if f.ProviderID != "" || f.PoolID != "" {
+		providerID, poolID := PayloadProviderPool(evt.Payload)
+		if f.ProviderID != "" && providerID != f.ProviderID {
+			return false
+		}
+		if f.PoolID != "" && poolID != f.PoolID {
+			return false
+		}
+	}
+	if f.After != nil {
+		after := f.After.Less(*CursorAt(evt))
+		if f.Descending {
+			after = CursorAt(evt).Less(*f.After)
+		}
+		if !after {
+			return false
+		}
+	}
+	return true
+}
+
+// Less reports whether c comes before other in log order.
+func (c EventCursor) Less(other EventCursor) bool {
+	if !c.TsIngest.Equal(other.TsIngest) {
+		return c.TsIngest.Before(other.TsIngest)
+	}
+	return c.EventID < other.EventID
+}
+
+// mergeEvents combines two result sets into one in the filter's order,
+// dropping duplicate event IDs (the first copy wins) and applying its limit.
+func mergeEvents(a, b []*Event, filter EventFilter) []*Event {
+	seen := make(map[EventID]bool, len(a)+len(b))
+	merged := make([]*Event, 0, len(a)+len(b))
+	for _, evt := range append(append([]*Event{}, a...), b...) {
+		if seen[evt.EventID] {
+			continue
+		}
+		seen[evt.EventID] = true
+		merged = append(merged, evt)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if filter.Descending {
+			return CursorAt(merged[j]).Less(*CursorAt(merged[i]))
+		}
+		return CursorAt(merged[i]).Less(*CursorAt(merged[j]))
+	})
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = 1000
+	}
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// ArchiveStore is an EventStore whose QueryEvents also searches the archive,
+// so that readers such as reports and /v1/events keep seeing events after
+// the archive worker removed them from the live log. The archive is only
+// searched when the query reaches back past the oldest live event, or when
+// it sets IncludeArchived. Other reads only see the live log.
+type ArchiveStore struct {
+	EventStore
+	archive *Archive
+}
+
+// NewArchiveStore wraps st so that queries include events archived to b.
+func NewArchiveStore(st EventStore, b blob.BlobStore) *ArchiveStore {
+	return &ArchiveStore{EventStore: st, archive: NewArchive(b)}
+}
+
+// Archive returns the archive searched by this store.
+func (s *ArchiveStore) Archive() *Archive {
+	return s.archive
+}
+
+// QueryEvents queries the live log and the archive and merges the results.
+// An event present in both (e.g. after a restore) is returned once.
+func (s *ArchiveStore) QueryEvents(ctx context.Context, filter EventFilter) ([]*Event, error) {
+	live, err := s.EventStore.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if search, err := s.reachesArchive(ctx, filter); err != nil || !search {
+		return live, err
+	}
+
+	segments, err := s.archive.Segments(ctx, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// A full page from the live log already holds every event positioned
+	// before its last one, so segments entirely past it cannot contribute.
+	limit := filter.Limit
+	if limit == 0 {
+		limit = 1000
+	}
+	if len(live) >= limit {
+		edge := live[len(live)-1].TsIngest
+		kept := segments[:0]
+		for _, seg := range segments {
+			if filter.Descending && !seg.overlapsIngest(edge, time.Time{}) {
+				continue
+			}
+			if !filter.Descending && !seg.overlapsIngest(time.Time{}, edge) {
+				continue
+			}
+			kept = append(kept, seg)
+		}
+		segments = kept
+	}
+	if len(segments) == 0 {
+		return live, nil
+	}
+
+	archived, err := s.archive.query(ctx, segments, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archive: %w", err)
+	}
+	return mergeEvents(live, archived, filter), nil
+}
+
+// reachesArchive reports whether filter can match archived events. The
+// archive worker moves events out in log order, so every archived event was
+// ingested (and happened) no later than the oldest live event. Without a
+// From bound or cursor, a query is taken to be about the live log.
+func (s *ArchiveStore) reachesArchive(ctx context.Context, filter EventFilter) (bool, error) {
+	if filter.IncludeArchived {
+		return true, nil
+	}
+
+	// The oldest position the query can return
+	bound := filter.From
+	if filter.After != nil {
+		switch {
+		case !filter.Descending && filter.After.TsIngest.After(bound):
+			bound = filter.After.TsIngest // Paging forward past From
+		case filter.Descending && bound.IsZero():
+			bound = filter.After.TsIngest // Paging back from a cursor
+		}
+	}
+	if bound.IsZero() {
+		return false, nil
+	}
+
+	oldest, err := s.EventStore.QueryEvents(ctx, EventFilter{Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	if len(oldest) == 0 {
+		return true, nil // Everything may have been archived
+	}
+	return !bound.After(oldest[0].TsIngest), nil
+}
+
+// RestoreResult summarizes a RestoreArchive run.
+type RestoreResult struct {
+	Segments int // Segments read
+	Restored int // Events appended to the destination
+	Skipped  int // Events already present in the destination
+}
+
+// RestoreArchive appends the archived events with ts_event in [from, to) to
+// dst, in log order. Events already present in dst are skipped, so a restore
+// can be rerun after a failure. Restored events are new links in dst's hash
+// chain; their original links remain covered by archive tombstones.
+func RestoreArchive(ctx context.Context, a *Archive, dst EventStore, from, to time.Time) (RestoreResult, error) {
+	var res RestoreResult
+	segments, err := a.Segments(ctx, from, to)
+	if err != nil {
+		return res, err
+	}
+
+	filter := EventFilter{From: from, To: to}
+	for _, seg := range segments {
+		events, err := a.ReadSegment(ctx, seg.Key)
+		if err != nil {
+			return res, err
+		}
+		res.Segments++
+
+		var batch []*Event
+		for _, evt := range events {
+			if !filter.Matches(evt) {
+				continue
+			}
+			existing, err := dst.GetEvent(ctx, evt.EventID)
+			if err != nil {
+				return res, err
+			}
+			if existing != nil {
+				res.Skipped++
+				continue
+			}
+			batch = append(batch, evt)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		if ba, ok := dst.(BatchAppender); ok {
+			err = ba.AppendEvents(ctx, batch)
+		} else {
+			for _, evt := range batch {
+				if err = dst.AppendEvent(ctx, evt); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return res, fmt.Errorf("failed to restore %s: %w", seg.Key, err)
+		}
+		res.Restored += len(batch)
+	}
+	return res, nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/parquet"
+)
+
+// EventParquetSchema is the columnar layout of events in Parquet archives and
+// reports. The envelope is flattened into one column per field, named like
+// the columns of the events table; the payload stays a JSON document.
+// Columns may be added at the end, but never renamed or reordered.
+var EventParquetSchema = []parquet.Column{
+	{Name: "event_id", Type: parquet.String},
+	{Name: "event_type", Type: parquet.String},
+	{Name: "schema_version", Type: parquet.Int32},
+	{Name: "ts_event", Type: parquet.Timestamp},
+	{Name: "ts_ingest", Type: parquet.Timestamp},
+	{Name: "epoch", Type: parquet.Int64},
+	{Name: "origin_kind", Type: parquet.String},
+	{Name: "origin_id", Type: parquet.String},
+	{Name: "writer_id", Type: parquet.String},
+	{Name: "agent_id", Type: parquet.String},
+	{Name: "identity_id", Type: parquet.String},
+	{Name: "workload_id", Type: parquet.String},
+	{Name: "scope_id", Type: parquet.String},
+	{Name: "correlation_id", Type: parquet.String},
+	{Name: "causation_id", Type: parquet.String},
+	{Name: "payload", Type: parquet.JSON},
+}
+
+// WriteEventsParquet writes events as a Parquet file with
+// EventParquetSchema. Timestamps are stored with microsecond precision.
+func WriteEventsParquet(w io.Writer, events []*Event) error {
+	pw := parquet.NewWriter(w, EventParquetSchema)
+	for _, evt := range events {
+		payload := []byte(evt.Payload)
+		if len(payload) == 0 {
+			payload = []byte("null")
+		}
+		row := []interface{}{
+			string(evt.EventID),
+			string(evt.EventType),
+			int32(evt.SchemaVersion),
+			evt.TsEvent,
+			evt.TsIngest,
+			evt.Epoch,
+			evt.Source.OriginKind,
+			evt.Source.OriginID,
+			evt.Source.WriterID,
+			evt.Dimensions.AgentID,
+			evt.Dimensions.IdentityID,
+			evt.Dimensions.WorkloadID,
+			evt.Dimensions.ScopeID,
+			evt.Correlation.CorrelationID,
+			evt.Correlation.CausationID,
+			payload,
+		}
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+// ReadEventsParquet decodes a file written by WriteEventsParquet. Columns
+// are matched by name, so files with added columns remain readable.
+func ReadEventsParquet(data []byte) ([]*Event, error) {
+	schema, rows, err := parquet.ReadFile(data)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(schema))
+	for i, col := range schema {
+		index[col.Name] = i
+	}
+	for _, col := range EventParquetSchema {
+		if i, ok := index[col.Name]; !ok || schema[i].Type != col.Type {
+			return nil, fmt.Errorf("parquet file lacks column %s", col.Name)
+		}
+	}
+
+	events := make([]*Event, 0, len(rows))
+	for _, row := range rows {
+		str := func(name string) string { return row[index[name]].(string) }
+		ts := func(name string) time.Time { return row[index[name]].(time.Time) }
+		events = append(events, &Event{
+			EventID:       EventID(str("event_id")),
+			EventType:     EventType(str("event_type")),
+			SchemaVersion: int(row[index["schema_version"]].(int32)),
+			TsEvent:       ts("ts_event"),
+			TsIngest:      ts("ts_ingest"),
+			Epoch:         row[index["epoch"]].(int64),
+			Source: EventSource{
+				OriginKind: str("origin_kind"),
+				OriginID:   str("origin_id"),
+				WriterID:   str("writer_id"),
+			},
+			Dimensions: EventDimensions{
+				AgentID:    str("agent_id"),
+				IdentityID: str("identity_id"),
+				WorkloadID: str("workload_id"),
+				ScopeID:    str("scope_id"),
+			},
+			Correlation: EventCorrelation{
+				CorrelationID: str("correlation_id"),
+				CausationID:   str("causation_id"),
+			},
+			Payload: json.RawMessage(str("payload")),
+		})
+	}
+	return events, nil
+}
+
+// EventPartition is a group of events sharing the day of their ts_event
+// (UTC) and their type.
+type EventPartition struct {
+	Day       time.Time
+	EventType EventType
+	Events    []*Event
+}
+
+// PartitionEvents splits events by day and type, keeping their order within
+// each partition. Partitions are returned in order of first appearance.
+func PartitionEvents(events []*Event) []EventPartition {
+	type partitionKey struct {
+		day string
+		typ EventType
+	}
+	var partitions []EventPartition
+	index := make(map[partitionKey]int)
+	for _, evt := range events {
+		day := evt.TsEvent.UTC().Truncate(24 * time.Hour)
+		k := partitionKey{day: day.Format("2006-01-02"), typ: evt.EventType}
+		i, ok := index[k]
+		if !ok {
+			i = len(partitions)
+			index[k] = i
+			partitions = append(partitions, EventPartition{Day: day, EventType: evt.EventType})
+		}
+		partitions[i].Events = append(partitions[i].Events, evt)
+	}
+	return partitions
+}
@@ -0,0 +1,259 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+)
+
+// putSegment archives events the way the archive worker does.
+func putSegment(t *testing.T, b blob.BlobStore, events []*Event) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, evt := range events {
+		if err := enc.Encode(evt); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+	}
+	gz.Close()
+	key := ArchiveKey(events[0].TsIngest, events[len(events)-1].TsIngest, string(events[0].EventID))
+	if err := b.Put(context.Background(), key, &buf); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+}
+
+// countingBlobStore counts List calls, which are full scans of the archive.
+type countingBlobStore struct {
+	blob.BlobStore
+	lists atomic.Int64
+}
+
+func (b *countingBlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	b.lists.Add(1)
+	return b.BlobStore.List(ctx, prefix)
+}
+
+func archiveTestEvent(id string, ts time.Time) *Event {
+	evt := newBatchEvent(id)
+	evt.TsEvent, evt.TsIngest = ts, ts
+	return evt
+}
+
+func TestArchiveKey_RoundTrip(t *testing.T) {
+	first := time.Date(2025, 3, 1, 23, 59, 30, 0, time.UTC)
+	last := first.Add(time.Minute)
+	key := ArchiveKey(first, last, "abc_def")
+	if key != fmt.Sprintf("events/2025/03/01/%d_%d_abc_def.jsonl.gz", first.Unix(), last.Unix()) {
+		t.Fatalf("unexpected key %s", key)
+	}
+	seg, ok := ParseArchiveKey(key)
+	if !ok || !seg.First.Equal(first) || !seg.Last.Equal(last) {
+		t.Errorf("unexpected segment %+v (ok %v)", seg, ok)
+	}
+	for _, bad := range []string{"events/2025/03/01/notes.txt", "snapshots/1_2_x.jsonl.gz", "events/x_2_y.jsonl.gz"} {
+		if _, ok := ParseArchiveKey(bad); ok {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestArchiveStore_QueryEvents(t *testing.T) {
+	st := openBatchTestStore(t)
+	defer st.Close()
+	blobs := &countingBlobStore{BlobStore: blob.NewLocalBlobStore(t.TempDir())}
+	ctx := context.Background()
+
+	// Two archived segments of three events each, then three live events
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var all []EventID
+	for s := 0; s < 2; s++ {
+		var seg []*Event
+		for i := 0; i < 3; i++ {
+			n := s*3 + i
+			seg = append(seg, archiveTestEvent(fmt.Sprintf("evt_%d", n), base.Add(time.Duration(n)*time.Hour)))
+			all = append(all, EventID(fmt.Sprintf("evt_%d", n)))
+		}
+		putSegment(t, blobs, seg)
+	}
+	for n := 6; n < 9; n++ {
+		if err := st.AppendEvent(ctx, archiveTestEvent(fmt.Sprintf("evt_%d", n), base.Add(time.Duration(n)*time.Hour))); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+		all = append(all, EventID(fmt.Sprintf("evt_%d", n)))
+	}
+
+	as := NewArchiveStore(st, blobs)
+	ids := func(events []*Event) []EventID {
+		var out []EventID
+		for _, evt := range events {
+			out = append(out, evt.EventID)
+		}
+		return out
+	}
+
+	// Without a range, queries are about the live log
+	events, err := as.QueryEvents(ctx, EventFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if got := ids(events); fmt.Sprint(got) != fmt.Sprint(all[6:]) {
+		t.Errorf("expected %v, got %v", all[6:], got)
+	}
+	if lists := blobs.lists.Load(); lists != 0 {
+		t.Errorf("expected no archive listing, got %d", lists)
+	}
+	events, _ = as.QueryEvents(ctx, EventFilter{From: base.Add(7 * time.Hour)})
+	if got := ids(events); fmt.Sprint(got) != "[evt_7 evt_8]" || blobs.lists.Load() != 0 {
+		t.Errorf("expected a live-only range query, got %v (%d listings)", got, blobs.lists.Load())
+	}
+
+	events, err = as.QueryEvents(ctx, EventFilter{IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if got := ids(events); fmt.Sprint(got) != fmt.Sprint(all) {
+		t.Errorf("expected %v, got %v", all, got)
+	}
+
+	// The time range selects across the boundary
+	events, _ = as.QueryEvents(ctx, EventFilter{From: base.Add(4 * time.Hour), To: base.Add(7 * time.Hour)})
+	if got := ids(events); fmt.Sprint(got) != "[evt_4 evt_5 evt_6]" {
+		t.Errorf("unexpected range result %v", got)
+	}
+
+	// Paging newest first walks from the live log into the archive
+	var paged []EventID
+	filter := EventFilter{Descending: true, Limit: 4, IncludeArchived: true}
+	for {
+		page, err := as.QueryEvents(ctx, filter)
+		if err != nil {
+			t.Fatalf("QueryEvents failed: %v", err)
+		}
+		paged = append(paged, ids(page)...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.After = CursorAt(page[len(page)-1])
+	}
+	if len(paged) != len(all) || paged[0] != "evt_8" || paged[len(paged)-1] != "evt_0" {
+		t.Errorf("unexpected descending pages %v", paged)
+	}
+
+	// A restored event is returned once
+	if _, err := RestoreArchive(ctx, as.Archive(), st, base, base.Add(time.Hour)); err != nil {
+		t.Fatalf("RestoreArchive failed: %v", err)
+	}
+	events, _ = as.QueryEvents(ctx, EventFilter{IncludeArchived: true})
+	if len(events) != len(all) {
+		t.Errorf("expected %d events after restore, got %d", len(all), len(events))
+	}
+}
+
+func TestRestoreArchive(t *testing.T) {
+	blobs := blob.NewLocalBlobStore(t.TempDir())
+	archive := NewArchive(blobs)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var seg []*Event
+	for i := 0; i < 5; i++ {
+		seg = append(seg, archiveTestEvent(fmt.Sprintf("evt_%d", i), base.Add(time.Duration(i)*24*time.Hour)))
+	}
+	putSegment(t, blobs, seg)
+
+	scratch, err := NewStore(filepath.Join(t.TempDir(), "scratch.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer scratch.Close()
+
+	from, to := base.Add(24*time.Hour), base.Add(4*24*time.Hour)
+	res, err := RestoreArchive(ctx, archive, scratch, from, to)
+	if err != nil {
+		t.Fatalf("RestoreArchive failed: %v", err)
+	}
+	if res.Segments != 1 || res.Restored != 3 || res.Skipped != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	// Rerunning skips what is already there
+	res, err = RestoreArchive(ctx, archive, scratch, from, to)
+	if err != nil {
+		t.Fatalf("RestoreArchive failed: %v", err)
+	}
+	if res.Restored != 0 || res.Skipped != 3 {
+		t.Errorf("unexpected result on rerun %+v", res)
+	}
+
+	events, _ := scratch.QueryEvents(ctx, EventFilter{})
+	if len(events) != 3 || events[0].EventID != "evt_1" || !events[0].TsIngest.Equal(seg[1].TsIngest) {
+		t.Errorf("unexpected restored events %+v", events)
+	}
+	if report, err := VerifyChain(ctx, scratch, nil); err != nil || !report.OK() {
+		t.Errorf("restored chain broken: %+v (err %v)", report, err)
+	}
+}
+
+func TestArchive_ParquetSegments(t *testing.T) {
+	blobs := blob.NewLocalBlobStore(t.TempDir())
+	archive := NewArchive(blobs)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+	var events []*Event
+	for i := 0; i < 4; i++ {
+		evt := archiveTestEvent(fmt.Sprintf("evt_%d", i), base.Add(time.Duration(i)*30*time.Minute))
+		evt.Epoch = int64(i)
+		evt.Correlation = EventCorrelation{CorrelationID: "corr", CausationID: "cause"}
+		if i == 3 {
+			evt.EventType = EventTypeUsageObserved
+		}
+		events = append(events, evt)
+	}
+
+	// Day 1 decisions, day 2 decisions and day 2 usage
+	partitions := PartitionEvents(events)
+	if len(partitions) != 3 || len(partitions[0].Events) != 2 || partitions[1].Day.Day() != 2 {
+		t.Fatalf("unexpected partitions %+v", partitions)
+	}
+	for _, p := range partitions {
+		var buf bytes.Buffer
+		if err := WriteEventsParquet(&buf, p.Events); err != nil {
+			t.Fatalf("WriteEventsParquet failed: %v", err)
+		}
+		key := ArchiveParquetKey(p.Day, p.EventType, p.Events[0].TsIngest, p.Events[len(p.Events)-1].TsIngest, "x")
+		if err := blobs.Put(ctx, key, &buf); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	keys, _ := blobs.List(ctx, "events/date=2025-01-02/")
+	if len(keys) != 2 {
+		t.Errorf("expected 2 partitions on 2025-01-02, got %v", keys)
+	}
+
+	got, err := archive.QueryEvents(ctx, EventFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("expected %d events, got %d", len(events), len(got))
+	}
+	for i, evt := range got {
+		want := events[i]
+		if evt.EventID != want.EventID || evt.EventType != want.EventType || !evt.TsEvent.Equal(want.TsEvent) ||
+			evt.Epoch != want.Epoch || evt.Correlation != want.Correlation || evt.Dimensions != want.Dimensions ||
+			evt.Source != want.Source || string(evt.Payload) != string(want.Payload) {
+			t.Errorf("event %d does not round-trip:\ngot  %+v\nwant %+v", i, evt, want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AckPolicy controls when BatchingStore.AppendEvent returns. Each batch is
+// one commit of the wrapped store, synced to disk as the store is set to
+// (see SyncMode for SQLite).
+type AckPolicy string
+
+const (
+	// AckCommit returns once the batch holding the event is committed,
+	// with the commit's error. This is as durable as a direct append.
+	AckCommit AckPolicy = "commit"
+	// AckQueued returns as soon as the event is queued (write-behind).
+	// Callers needing durability call Flush, which reports failed writes.
+	// Appends of a writer already fenced off fail at once with ErrStaleEpoch.
+	AckQueued AckPolicy = "queued"
+)
+
+var (
+	// ErrQueueFull is returned when the append queue stays full for longer
+	// than BatchConfig.EnqueueTimeout. Callers should shed load and retry.
+	ErrQueueFull = errors.New("event append queue is full")
+	// ErrBatcherClosed is returned for appends after Close.
+	ErrBatcherClosed = errors.New("event batcher is closed")
+)
+
+// BatchConfig tunes a BatchingStore. Zero values select the defaults.
+type BatchConfig struct {
+	MaxBatch int // Events per commit (default 256)
+	// MaxDelay bounds how long the oldest queued event waits for others to
+	// join its batch. By default a batch is whatever queued up while the
+	// previous commit ran, which adds no latency.
+	MaxDelay time.Duration
+	// QueueSize is the number of pending appends before backpressure
+	// applies (default 4096).
+	QueueSize int
+	// EnqueueTimeout is how long an append waits for queue space before
+	// failing with ErrQueueFull (default 100ms). Negative fails at once.
+	EnqueueTimeout time.Duration
+	Ack            AckPolicy // Default AckCommit
+	// OnError, if set, is called by the writer for every failed append.
+	// It is how failures are noticed when nobody waits for them.
+	OnError func(events []*Event, err error)
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.MaxBatch <= 0 {
+		c.MaxBatch = 256
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 4096
+	}
+	if c.EnqueueTimeout == 0 {
+		c.EnqueueTimeout = 100 * time.Millisecond
+	}
+	if c.Ack == "" {
+		c.Ack = AckCommit
+	}
+	return c
+}
+
+// BatchStats counts the work done by a BatchingStore.
+type BatchStats struct {
+	Batches  uint64 // Commits issued
+	Events   uint64 // Events committed
+	Failed   uint64 // Events whose write failed
+	Rejected uint64 // Appends refused with ErrQueueFull
+}
+
+// appendRequest is one caller's append. Its events are committed together,
+// in order. A request without events is a Flush marker.
+type appendRequest struct {
+	events   []*Event
+	enqueued time.Time
+	done     chan error
+}
+
+// BatchingStore is an EventStore that funnels appends through a single
+// writer which commits them in batches (group commit). Concurrent callers
+// then share one transaction instead of paying a commit each.
+//
+// Events are committed in the order their appends were queued. Only
+// AppendEvent and AppendEvents are batched; other methods go straight to
+// the wrapped store. With AckQueued, reads may not yet see queued events.
+type BatchingStore struct {
+	EventStore
+	cfg   BatchConfig
+	queue chan *appendRequest
+	done  chan struct{}
+
+	mu     sync.RWMutex // Guards closed against sends on a closed queue
+	closed bool
+
+	errMu       sync.Mutex
+	deferredErr error // First failure since the last Flush (AckQueued)
+	staleEpoch  int64 // Highest epoch rejected with ErrStaleEpoch (AckQueued)
+
+	batches  atomic.Uint64
+	events   atomic.Uint64
+	failed   atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// NewBatchingStore wraps st and starts its writer. Close stops the writer
+// after committing everything queued, then closes st.
+func NewBatchingStore(st EventStore, cfg BatchConfig) *BatchingStore {
+	cfg = cfg.withDefaults()
+	b := &BatchingStore{
+		EventStore: st,
+		cfg:        cfg,
+		queue:      make(chan *appendRequest, cfg.QueueSize),
+		done:       make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// AppendEvent queues evt for the next batch. See AckPolicy for when it
+// returns. If ctx ends while waiting for the commit, the event may still be
+// written.
+func (b *BatchingStore) AppendEvent(ctx context.Context, evt *Event) error {
+	return b.AppendEvents(ctx, []*Event{evt})
+}
+
+// AppendEvents queues events to be committed together, in order, all or none.
+func (b *BatchingStore) AppendEvents(ctx context.Context, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	// Defaults are filled in here rather than by the writer, so callers
+	// never race with it on their event.
+	for _, evt := range events {
+		PrepareChainEvent(evt)
+	}
+
+	if b.cfg.Ack == AckQueued {
+		if err := b.checkStale(events); err != nil {
+			return err
+		}
+	}
+
+	req := &appendRequest{events: events, enqueued: time.Now(), done: make(chan error, 1)}
+	if err := b.enqueue(ctx, req, b.cfg.EnqueueTimeout); err != nil {
+		return err
+	}
+	if b.cfg.Ack == AckQueued {
+		return nil
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush waits until every append queued before the call is committed. It
+// returns the first write failure since the previous Flush, which is how
+// AckQueued callers learn about lost writes.
+func (b *BatchingStore) Flush(ctx context.Context) error {
+	req := &appendRequest{enqueued: time.Now(), done: make(chan error, 1)}
+	if err := b.enqueue(ctx, req, 0); err != nil {
+		return err
+	}
+	select {
+	case <-req.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.takeDeferredErr()
+}
+
+// Close commits everything queued, stops the writer and closes the wrapped
+// store. Like Flush, it reports a pending deferred write failure.
+func (b *BatchingStore) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	<-b.done
+	err := b.takeDeferredErr()
+	if cerr := b.EventStore.Close(); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// Stats returns counters since the store was created.
+func (b *BatchingStore) Stats() BatchStats {
+	return BatchStats{
+		Batches:  b.batches.Load(),
+		Events:   b.events.Load(),
+		Failed:   b.failed.Load(),
+		Rejected: b.rejected.Load(),
+	}
+}
+
+// enqueue adds req to the queue, waiting up to timeout for space when it is
+// full. A zero timeout waits as long as ctx allows.
+func (b *BatchingStore) enqueue(ctx context.Context, req *appendRequest, timeout time.Duration) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrBatcherClosed
+	}
+
+	select {
+	case b.queue <- req:
+		return nil
+	default:
+	}
+	if timeout < 0 {
+		b.rejected.Add(1)
+		return ErrQueueFull
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case b.queue <- req:
+		return nil
+	case <-expired:
+		b.rejected.Add(1)
+		return ErrQueueFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is the single writer. It takes the oldest request, gathers whatever
+// else arrives within MaxDelay of it (up to MaxBatch events) and commits.
+func (b *BatchingStore) run() {
+	defer close(b.done)
+
+	for req := range b.queue {
+		batch, open := b.fill([]*appendRequest{req})
+		b.commit(batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// fill adds queued requests to batch. It reports false once the queue is
+// closed and drained.
+func (b *BatchingStore) fill(batch []*appendRequest) ([]*appendRequest, bool) {
+	n := len(batch[0].events)
+	deadline := batch[0].enqueued.Add(b.cfg.MaxDelay)
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for n < b.cfg.MaxBatch {
+		// Take what is already queued before waiting.
+		select {
+		case req, ok := <-b.queue:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, req)
+			n += len(req.events)
+			continue
+		default:
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			break
+		}
+		if timer == nil {
+			timer = time.NewTimer(wait)
+		} else {
+			timer.Reset(wait)
+		}
+		select {
+		case req, ok := <-b.queue:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, req)
+			n += len(req.events)
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-timer.C:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// commit writes the batch in one transaction when the store supports it.
+// If that fails, each request is retried on its own so that one bad event
+// (e.g. a duplicate ID) only fails its own caller.
+func (b *BatchingStore) commit(batch []*appendRequest) {
+	ba, ok := b.EventStore.(BatchAppender)
+	if !ok {
+		for _, req := range batch {
+			b.finish(req, b.appendEach(req.events))
+		}
+		return
+	}
+
+	var events []*Event
+	writers := 0
+	for _, req := range batch {
+		events = append(events, req.events...)
+		if len(req.events) > 0 {
+			writers++
+		}
+	}
+	if len(events) == 0 {
+		for _, req := range batch {
+			b.finish(req, nil)
+		}
+		return
+	}
+
+	b.batches.Add(1)
+	err := ba.AppendEvents(context.Background(), events)
+	if err == nil || writers == 1 {
+		for _, req := range batch {
+			b.finish(req, err)
+		}
+		return
+	}
+
+	for _, req := range batch {
+		if len(req.events) == 0 {
+			b.finish(req, nil)
+			continue
+		}
+		b.batches.Add(1)
+		b.finish(req, ba.AppendEvents(context.Background(), req.events))
+	}
+}
+
+// appendEach is the fallback for stores without AppendEvents.
+func (b *BatchingStore) appendEach(events []*Event) error {
+	for _, evt := range events {
+		b.batches.Add(1)
+		if err := b.EventStore.AppendEvent(context.Background(), evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BatchingStore) finish(req *appendRequest, err error) {
+	if err != nil {
+		b.failed.Add(uint64(len(req.events)))
+		if b.cfg.OnError != nil {
+			b.cfg.OnError(req.events, err)
+		}
+		if b.cfg.Ack == AckQueued {
+			b.errMu.Lock()
+			if b.deferredErr == nil {
+				b.deferredErr = err
+			}
+			if errors.Is(err, ErrStaleEpoch) {
+				for _, evt := range req.events {
+					if evt.Epoch > b.staleEpoch {
+						b.staleEpoch = evt.Epoch
+					}
+				}
+			}
+			b.errMu.Unlock()
+		}
+	} else {
+		b.events.Add(uint64(len(req.events)))
+	}
+	req.done <- err
+}
+
+func (b *BatchingStore) takeDeferredErr() error {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	err := b.deferredErr
+	b.deferredErr = nil
+	return err
+}
+
+// checkStale fails appends carrying an epoch the store already rejected: the
+// lease has moved on, so they would be rejected too. This surfaces a fenced
+// write to a queued writer on its next append instead of at Flush.
+func (b *BatchingStore) checkStale(events []*Event) error {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	for _, evt := range events {
+		if evt.Epoch > 0 && evt.Epoch <= b.staleEpoch {
+			return fmt.Errorf("%w: event %s has epoch %d, a write at epoch %d was rejected", ErrStaleEpoch, evt.EventID, evt.Epoch, b.staleEpoch)
+		}
+	}
+	return nil
+}
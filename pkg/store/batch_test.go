@@ -0,0 +1,317 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBatchEvent(id string) *Event {
+	now := time.Now().UTC()
+	return &Event{
+		EventID:    EventID(id),
+		EventType:  EventTypeIntentDecided,
+		TsEvent:    now,
+		Source:     EventSource{OriginKind: "test", OriginID: "test", WriterID: "test"},
+		Dimensions: EventDimensions{AgentID: "a", IdentityID: "i", WorkloadID: "w", ScopeID: "s"},
+		Payload:    json.RawMessage(`{"decision":"approve"}`),
+	}
+}
+
+func openBatchTestStore(t testing.TB) *Store {
+	t.Helper()
+	st, err := NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	return st
+}
+
+// blockingStore stalls appends until release is closed.
+type blockingStore struct {
+	EventStore
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingStore) AppendEvent(ctx context.Context, evt *Event) error {
+	s.once.Do(func() { close(s.started) })
+	<-s.release
+	return nil
+}
+
+func (s *blockingStore) Close() error { return nil }
+
+func TestBatchingStore_GroupCommit(t *testing.T) {
+	st := openBatchTestStore(t)
+	b := NewBatchingStore(st, BatchConfig{MaxDelay: 20 * time.Millisecond})
+	defer b.Close()
+	ctx := context.Background()
+
+	const writers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- b.AppendEvent(ctx, newBatchEvent(fmt.Sprintf("evt_%d", i)))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	// Durable mode: every event is readable once AppendEvent returned
+	events, err := st.ReadEvents(ctx, time.Time{}, 100)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	if len(events) != writers {
+		t.Errorf("expected %d events, got %d", writers, len(events))
+	}
+	stats := b.Stats()
+	if stats.Events != writers || stats.Batches >= writers {
+		t.Errorf("expected appends to share commits, got %+v", stats)
+	}
+
+	report, err := VerifyChain(ctx, st, nil)
+	if err != nil || !report.OK() {
+		t.Errorf("chain broken after batched appends: %+v (err %v)", report, err)
+	}
+}
+
+func TestBatchingStore_PreservesOrder(t *testing.T) {
+	st := openBatchTestStore(t)
+	b := NewBatchingStore(st, BatchConfig{MaxBatch: 7, Ack: AckQueued})
+	defer b.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		if err := b.AppendEvent(ctx, newBatchEvent(fmt.Sprintf("evt_%03d", i))); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	links, err := st.ReadChain(ctx, 0, 200)
+	if err != nil {
+		t.Fatalf("ReadChain failed: %v", err)
+	}
+	if len(links) != 100 {
+		t.Fatalf("expected 100 links, got %d", len(links))
+	}
+	for i, link := range links {
+		if want := EventID(fmt.Sprintf("evt_%03d", i)); link.EventID != want {
+			t.Fatalf("position %d: got %s, want %s", link.Seq, link.EventID, want)
+		}
+	}
+}
+
+func TestBatchingStore_FailureOnlyAffectsItsCaller(t *testing.T) {
+	st := openBatchTestStore(t)
+	ctx := context.Background()
+	if err := st.AppendEvent(ctx, newBatchEvent("evt_dup")); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	// A long delay makes all three appends share one batch
+	b := NewBatchingStore(st, BatchConfig{MaxDelay: 50 * time.Millisecond})
+	defer b.Close()
+
+	ids := []string{"evt_1", "evt_dup", "evt_2"}
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			errs[i] = b.AppendEvent(ctx, newBatchEvent(id))
+		}(i, id)
+	}
+	wg.Wait()
+
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("unrelated appends failed: %v, %v", errs[0], errs[2])
+	}
+	if errs[1] == nil {
+		t.Error("expected the duplicate append to fail")
+	}
+	for _, id := range []EventID{"evt_1", "evt_2"} {
+		if evt, _ := st.GetEvent(ctx, id); evt == nil {
+			t.Errorf("expected %s to be stored", id)
+		}
+	}
+	if stats := b.Stats(); stats.Failed != 1 || stats.Events != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBatchingStore_DeferredFailureSurfacesOnFlush(t *testing.T) {
+	st := openBatchTestStore(t)
+	b := NewBatchingStore(st, BatchConfig{Ack: AckQueued})
+	defer b.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		// The second append is accepted, but its write fails
+		if err := b.AppendEvent(ctx, newBatchEvent("evt_1")); err != nil {
+			t.Fatalf("AppendEvent %d failed: %v", i, err)
+		}
+	}
+	if err := b.Flush(ctx); err == nil {
+		t.Error("expected Flush to report the failed write")
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Errorf("expected the failure to be reported once, got %v", err)
+	}
+}
+
+func TestBatchingStore_QueuedStaleEpochSurfacesOnNextAppend(t *testing.T) {
+	st := openBatchTestStore(t)
+	ctx := context.Background()
+
+	const lease = "ratelord-leader"
+	st.SetFence(lease)
+	// Two takeovers: the lease is at epoch 2
+	for _, holder := range []string{"node-a", "node-b"} {
+		if ok, err := st.Acquire(ctx, lease, holder, time.Hour); err != nil || !ok {
+			t.Fatalf("Acquire(%s) failed: %v", holder, err)
+		}
+		if err := st.Release(ctx, lease, holder); err != nil {
+			t.Fatalf("Release(%s) failed: %v", holder, err)
+		}
+	}
+
+	var fenced atomic.Bool
+	b := NewBatchingStore(st, BatchConfig{Ack: AckQueued, OnError: func(events []*Event, err error) {
+		if errors.Is(err, ErrStaleEpoch) {
+			fenced.Store(true)
+		}
+	}})
+	defer b.Close()
+
+	// Accepted, then rejected by the fence
+	stale := newBatchEvent("evt_1")
+	stale.Epoch = 1
+	if err := b.AppendEvent(ctx, stale); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+	b.Flush(ctx)
+	if !fenced.Load() {
+		t.Error("expected OnError to report ErrStaleEpoch")
+	}
+
+	// The deposed writer's next append fails at once; others are unaffected
+	next := newBatchEvent("evt_2")
+	next.Epoch = 1
+	if err := b.AppendEvent(ctx, next); !errors.Is(err, ErrStaleEpoch) {
+		t.Errorf("expected ErrStaleEpoch, got %v", err)
+	}
+	current := newBatchEvent("evt_3")
+	current.Epoch = 2
+	if err := b.AppendEvent(ctx, current); err != nil {
+		t.Errorf("AppendEvent at the current epoch failed: %v", err)
+	}
+	if err := b.Flush(ctx); err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+}
+
+func TestBatchingStore_Backpressure(t *testing.T) {
+	inner := &blockingStore{started: make(chan struct{}), release: make(chan struct{})}
+	b := NewBatchingStore(inner, BatchConfig{QueueSize: 1, EnqueueTimeout: 10 * time.Millisecond, Ack: AckQueued})
+	ctx := context.Background()
+
+	// The writer takes the first event and blocks in the store
+	if err := b.AppendEvent(ctx, newBatchEvent("evt_1")); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+	<-inner.started
+
+	// The second fills the queue, the third is refused
+	if err := b.AppendEvent(ctx, newBatchEvent("evt_2")); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+	if err := b.AppendEvent(ctx, newBatchEvent("evt_3")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// Waiting for space is also bounded by the caller's context
+	waitCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if err := b.AppendEvent(waitCtx, newBatchEvent("evt_3")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context deadline, got %v", err)
+	}
+
+	close(inner.release)
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if stats := b.Stats(); stats.Events != 2 || stats.Rejected != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if err := b.AppendEvent(ctx, newBatchEvent("evt_4")); !errors.Is(err, ErrBatcherClosed) {
+		t.Errorf("expected ErrBatcherClosed after Close, got %v", err)
+	}
+}
+
+func BenchmarkAppendEvent(b *testing.B) {
+	// Each benchmark simulates /v1/intent: concurrent callers, each
+	// waiting for its own decision to be durable.
+	run := func(b *testing.B, st EventStore) {
+		var seq atomic.Int64
+		ctx := context.Background()
+		b.SetParallelism(8)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				evt := newBatchEvent(fmt.Sprintf("evt_%d", seq.Add(1)))
+				if err := st.AppendEvent(ctx, evt); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	b.Run("Direct", func(b *testing.B) {
+		st := openBatchTestStore(b)
+		defer st.Close()
+		run(b, st)
+	})
+
+	for _, delay := range []time.Duration{0, time.Millisecond} {
+		b.Run(fmt.Sprintf("GroupCommit/delay=%s", delay), func(b *testing.B) {
+			st := NewBatchingStore(openBatchTestStore(b), BatchConfig{MaxDelay: delay})
+			defer st.Close()
+			run(b, st)
+			stats := st.Stats()
+			if stats.Batches > 0 {
+				b.ReportMetric(float64(stats.Events)/float64(stats.Batches), "events/batch")
+			}
+		})
+	}
+
+	b.Run("GroupCommitDeferred", func(b *testing.B) {
+		st := NewBatchingStore(openBatchTestStore(b), BatchConfig{Ack: AckQueued, EnqueueTimeout: time.Second})
+		defer st.Close()
+		run(b, st)
+		if err := st.Flush(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSubscriptionBuffer is the number of events a subscriber may fall
+// behind before it is dropped.
+const DefaultSubscriptionBuffer = 256
+
+// Broadcaster fans out appended events to in-process subscribers, such as
+// the /v1/events/stream endpoint. Publishing never blocks: a subscriber whose
+// buffer is full is closed with Lagged set and should resume from the store.
+type Broadcaster struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives events published after it was created.
+type Subscription struct {
+	C <-chan *Event
+
+	ch     chan *Event
+	b      *Broadcaster
+	once   sync.Once
+	lagged bool
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber with the given buffer size (default
+// DefaultSubscriptionBuffer). The caller must Close it when done.
+func (b *Broadcaster) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+	ch := make(chan *Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, b: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers evt to every subscriber without blocking.
+func (b *Broadcaster) Publish(evt *Event) {
+	var lagging []*Subscription
+
+	b.mu.RLock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- evt:
+		default:
+			lagging = append(lagging, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	if len(lagging) == 0 {
+		return
+	}
+	b.mu.Lock()
+	for _, sub := range lagging {
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			sub.lagged = true
+		}
+	}
+	b.mu.Unlock()
+	for _, sub := range lagging {
+		sub.closeChan()
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close ends all subscriptions. Later subscriptions are closed immediately.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subs
+	b.subs = make(map[*Subscription]struct{})
+	b.mu.Unlock()
+
+	for sub := range subs {
+		sub.closeChan()
+	}
+}
+
+// Close unregisters the subscription and closes C.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	delete(s.b.subs, s)
+	s.b.mu.Unlock()
+	s.closeChan()
+}
+
+// Lagged reports whether the subscription was dropped for falling behind.
+// Only meaningful once C has been closed.
+func (s *Subscription) Lagged() bool {
+	return s.lagged
+}
+
+func (s *Subscription) closeChan() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// BroadcastStore is an EventStore that publishes every successfully appended
+// event to a Broadcaster.
+type BroadcastStore struct {
+	EventStore
+	broadcaster *Broadcaster
+}
+
+// NewBroadcastStore wraps st so that AppendEvent feeds b.
+func NewBroadcastStore(st EventStore, b *Broadcaster) *BroadcastStore {
+	return &BroadcastStore{EventStore: st, broadcaster: b}
+}
+
+// AppendEvent persists the event, then publishes it. TsIngest is assigned
+// here when unset so subscribers see the persisted value.
+func (s *BroadcastStore) AppendEvent(ctx context.Context, evt *Event) error {
+	if evt.TsIngest.IsZero() {
+		evt.TsIngest = time.Now().UTC()
+	}
+	if err := s.EventStore.AppendEvent(ctx, evt); err != nil {
+		return err
+	}
+	s.broadcaster.Publish(evt)
+	return nil
+}
+
+// Broadcaster returns the broadcaster fed by this store.
+func (s *BroadcastStore) Broadcaster() *Broadcaster {
+	return s.broadcaster
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBroadcaster_PublishSubscribe(t *testing.T) {
+	b := NewBroadcaster()
+	sub1 := b.Subscribe(4)
+	sub2 := b.Subscribe(4)
+
+	b.Publish(&Event{EventID: "evt_1"})
+
+	for i, sub := range []*Subscription{sub1, sub2} {
+		select {
+		case evt := <-sub.C:
+			if evt.EventID != "evt_1" {
+				t.Errorf("subscriber %d: unexpected event %s", i, evt.EventID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("subscriber %d: no event received", i)
+		}
+	}
+
+	sub1.Close()
+	if _, ok := <-sub1.C; ok {
+		t.Error("expected closed channel after Close")
+	}
+	if b.Subscribers() != 1 {
+		t.Errorf("expected 1 subscriber, got %d", b.Subscribers())
+	}
+
+	b.Close()
+	if _, ok := <-sub2.C; ok {
+		t.Error("expected closed channel after broadcaster Close")
+	}
+	if sub2.Lagged() {
+		t.Error("subscription closed by shutdown must not report lag")
+	}
+}
+
+func TestBroadcaster_DropsLaggingSubscriber(t *testing.T) {
+	b := NewBroadcaster()
+	slow := b.Subscribe(1)
+	fast := b.Subscribe(4)
+
+	b.Publish(&Event{EventID: "evt_1"})
+	b.Publish(&Event{EventID: "evt_2"}) // slow's buffer is full
+
+	<-slow.C
+	if _, ok := <-slow.C; ok {
+		t.Fatal("expected the lagging subscriber to be closed")
+	}
+	if !slow.Lagged() {
+		t.Error("expected Lagged to be true")
+	}
+	if len(fast.C) != 2 {
+		t.Errorf("fast subscriber should have both events, has %d", len(fast.C))
+	}
+	slow.Close() // Closing again is safe
+}
+
+func TestBroadcastStore_PublishesPersistedEvents(t *testing.T) {
+	st, err := NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+
+	b := NewBroadcaster()
+	bs := NewBroadcastStore(st, b)
+	sub := b.Subscribe(4)
+	defer sub.Close()
+
+	evt := &Event{
+		EventID: "evt_1", EventType: EventTypeUsageObserved, SchemaVersion: 1, TsEvent: time.Now(),
+		Dimensions: EventDimensions{AgentID: "a", IdentityID: "i", WorkloadID: "w", ScopeID: "s"},
+	}
+	if err := bs.AppendEvent(context.Background(), evt); err != nil {
+		t.Fatalf("AppendEvent failed: %v", err)
+	}
+
+	got := <-sub.C
+	if got.EventID != "evt_1" || got.TsIngest.IsZero() {
+		t.Errorf("unexpected published event: %+v", got)
+	}
+	stored, err := st.GetEvent(context.Background(), "evt_1")
+	if err != nil || stored == nil {
+		t.Fatalf("GetEvent failed: %v", err)
+	}
+	if !stored.TsIngest.Equal(got.TsIngest) {
+		t.Errorf("published TsIngest %v differs from stored %v", got.TsIngest, stored.TsIngest)
+	}
+
+	// Failed appends are not published
+	if err := bs.AppendEvent(context.Background(), evt); err == nil {
+		t.Fatal("expected duplicate append to fail")
+	}
+	if len(sub.C) != 0 {
+		t.Error("failed append must not be published")
+	}
+}
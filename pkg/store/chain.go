@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+)
+
+// The event log is hash chained: every event is assigned the next sequence
+// number and stores the hash of its predecessor, so editing, reordering or
+// silently removing a row breaks the chain.
+//
+// Events that must be removed (pruning, archiving, GDPR erasure) leave a
+// tombstone row with their chain hashes, and the removal itself is recorded
+// as an events_tombstoned event. Periodic chain_checkpoint events sign the
+// head of the chain, so the log cannot be rewritten wholesale either.
+
+// ChainHead is the position and hash of the last chained event.
+// The chain is empty when Seq is zero.
+type ChainHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// ChainLink is one position of the chain. Event is nil when the event was
+// removed and only its tombstone remains.
+type ChainLink struct {
+	Seq         int64
+	EventID     EventID
+	EventType   EventType
+	PrevHash    string
+	Hash        string
+	ContentHash string // Only stored for tombstones; recomputed from Event otherwise
+	Event       *Event
+
+	TombstoneReason  string
+	TombstoneEventID EventID
+}
+
+// ChainStore is implemented by stores that maintain the hash chain.
+type ChainStore interface {
+	// ChainHead returns the current head of the chain.
+	ChainHead(ctx context.Context) (ChainHead, error)
+
+	// ReadChain returns up to limit links with sequence numbers above
+	// afterSeq, in order. Live events and tombstones are interleaved.
+	ReadChain(ctx context.Context, afterSeq int64, limit int) ([]ChainLink, error)
+}
+
+// Tombstone reasons.
+const (
+	TombstonePruned          = "pruned"
+	TombstoneArchived        = "archived"
+	TombstoneIdentityDeleted = "identity_deleted"
+)
+
+// TombstonePayload is the payload of an events_tombstoned event.
+type TombstonePayload struct {
+	Reason   string `json:"reason"`
+	Count    int64  `json:"count"`
+	FirstSeq int64  `json:"first_seq"`
+	LastSeq  int64  `json:"last_seq"`
+}
+
+// CheckpointPayload is the payload of a chain_checkpoint event.
+type CheckpointPayload struct {
+	Seq       int64  `json:"seq"`
+	Hash      string `json:"hash"`
+	PublicKey string `json:"public_key"` // Hex-encoded Ed25519 key
+	Signature string `json:"signature"`  // Hex-encoded signature of checkpointMessage
+}
+
+// ChainContentHash hashes the persisted content of an event. Fields are
+// length-prefixed so that no two events share an encoding. Timestamps are
+// hashed at microsecond precision, the finest resolution every backend keeps.
+func ChainContentHash(evt *Event) string {
+	h := sha256.New()
+	payload := evt.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	for _, field := range []string{
+		string(evt.EventID),
+		string(evt.EventType),
+		strconv.Itoa(evt.SchemaVersion),
+		chainTime(evt.TsEvent),
+		chainTime(evt.TsIngest),
+		strconv.FormatInt(evt.Epoch, 10),
+		evt.Source.OriginKind,
+		evt.Source.OriginID,
+		evt.Source.WriterID,
+		evt.Dimensions.AgentID,
+		evt.Dimensions.IdentityID,
+		evt.Dimensions.WorkloadID,
+		evt.Dimensions.ScopeID,
+		evt.Correlation.CorrelationID,
+		evt.Correlation.CausationID,
+		string(payload),
+	} {
+		writeField(h, field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ChainLinkHash derives the chain hash at seq from its predecessor's hash
+// and the event content hash.
+func ChainLinkHash(seq int64, prevHash, contentHash string) string {
+	h := sha256.New()
+	writeField(h, strconv.FormatInt(seq, 10))
+	writeField(h, prevHash)
+	writeField(h, contentHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeField(h hash.Hash, s string) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(s)))
+	h.Write(n[:])
+	h.Write([]byte(s))
+}
+
+func chainTime(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+// PrepareChainEvent fills in the defaults that are persisted and hashed:
+// TsIngest defaults to now and an empty payload is stored as {}.
+func PrepareChainEvent(evt *Event) {
+	if evt.TsIngest.IsZero() {
+		evt.TsIngest = time.Now().UTC()
+	}
+	if len(evt.Payload) == 0 {
+		evt.Payload = json.RawMessage("{}")
+	}
+}
+
+// NewTombstoneEvent builds the events_tombstoned event recording the removal
+// of count chained events between firstSeq and lastSeq.
+func NewTombstoneEvent(reason string, count, firstSeq, lastSeq int64) *Event {
+	now := time.Now().UTC()
+	payload, _ := json.Marshal(TombstonePayload{Reason: reason, Count: count, FirstSeq: firstSeq, LastSeq: lastSeq})
+	return &Event{
+		EventID:       EventID(fmt.Sprintf("tomb_%d", now.UnixNano())),
+		EventType:     EventTypeEventsTombstoned,
+		SchemaVersion: 1,
+		TsEvent:       now,
+		TsIngest:      now,
+		Source:        EventSource{OriginKind: "daemon", OriginID: "store", WriterID: "ratelord-d"},
+		Dimensions: EventDimensions{
+			AgentID:    SentinelSystem,
+			IdentityID: SentinelSystem,
+			WorkloadID: SentinelSystem,
+			ScopeID:    SentinelSystem,
+		},
+		Correlation: EventCorrelation{CorrelationID: fmt.Sprintf("tomb_%d", now.UnixNano()), CausationID: SentinelUnknown},
+		Payload:     payload,
+	}
+}
+
+// checkpointMessage is the byte string signed by a checkpoint.
+func checkpointMessage(seq int64, hash string) []byte {
+	return []byte(fmt.Sprintf("ratelord-chain-checkpoint\n%d\n%s", seq, hash))
+}
+
+// SignCheckpoint signs the given chain head.
+func SignCheckpoint(key ed25519.PrivateKey, head ChainHead) CheckpointPayload {
+	return CheckpointPayload{
+		Seq:       head.Seq,
+		Hash:      head.Hash,
+		PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: hex.EncodeToString(ed25519.Sign(key, checkpointMessage(head.Seq, head.Hash))),
+	}
+}
+
+// VerifySignature reports whether the checkpoint was signed by pub.
+func (c CheckpointPayload) VerifySignature(pub ed25519.PublicKey) bool {
+	sig, err := hex.DecodeString(c.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, checkpointMessage(c.Seq, c.Hash), sig)
+}
+
+// ParseCheckpointKey decodes a hex-encoded 32-byte Ed25519 seed.
+func ParseCheckpointKey(s string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(s)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("checkpoint key must be %d hex-encoded bytes", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParseCheckpointPublicKey decodes a hex-encoded Ed25519 public key.
+func ParseCheckpointPublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("checkpoint public key must be %d hex-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
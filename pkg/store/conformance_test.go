@@ -0,0 +1,19 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+	"github.com/rmax-ai/ratelord/pkg/store/storetest"
+)
+
+func TestConformance_SQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.EventStore {
+		st, err := store.NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+		if err != nil {
+			t.Fatalf("NewStore failed: %v", err)
+		}
+		return st
+	})
+}
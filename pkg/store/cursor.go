@@ -0,0 +1,47 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EventCursor is a position in the event log. Events are totally ordered by
+// ingest time, with the event ID breaking ties.
+type EventCursor struct {
+	TsIngest time.Time
+	EventID  EventID
+}
+
+type cursorJSON struct {
+	T time.Time `json:"t"`
+	I EventID   `json:"i"`
+}
+
+// CursorAt returns the cursor positioned at evt.
+func CursorAt(evt *Event) *EventCursor {
+	return &EventCursor{TsIngest: evt.TsIngest.UTC(), EventID: evt.EventID}
+}
+
+// Encode returns the opaque, URL-safe form of the cursor.
+func (c EventCursor) Encode() string {
+	data, _ := json.Marshal(cursorJSON{T: c.TsIngest.UTC(), I: c.EventID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeEventCursor parses a cursor produced by Encode.
+func DecodeEventCursor(s string) (*EventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursorJSON
+	if err := json.Unmarshal(data, &c); err != nil || c.I == "" || c.T.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &EventCursor{TsIngest: c.T.UTC(), EventID: c.I}, nil
+}
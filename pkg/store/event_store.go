@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// EventStore is the persistence backend for the event log and its derived
+// tables (usage rollups, snapshots, webhooks and worker state).
+// Store (SQLite) is the default implementation; postgres.PostgresStore allows
+// several hosts to share one log without shared disk.
+type EventStore interface {
+	// Event log
+	AppendEvent(ctx context.Context, evt *Event) error
+	ReadEvents(ctx context.Context, since time.Time, limit int) ([]*Event, error)
+	ReadRecentEvents(ctx context.Context, limit int) ([]*Event, error)
+	QueryEvents(ctx context.Context, filter EventFilter) ([]*Event, error)
+	GetEvent(ctx context.Context, eventID EventID) (*Event, error)
+
+	// Maintenance
+	PruneEvents(ctx context.Context, retention time.Duration, includeType string, excludeTypes []string) (int64, error)
+	ReadCandidateEvents(ctx context.Context, before time.Time, limit int) ([]*Event, error)
+	DeleteEvents(ctx context.Context, eventIDs []string) error
+	DeleteIdentityData(ctx context.Context, identityID string) error
+
+	// System state (worker checkpoints)
+	GetSystemState(ctx context.Context, key string) (string, error)
+	SetSystemState(ctx context.Context, key, value string) error
+
+	// Usage statistics
+	UpsertUsageStats(ctx context.Context, stats []UsageStat) error
+	GetUsageStats(ctx context.Context, filter UsageFilter) ([]UsageStat, error)
+	// PruneUsageStats deletes the rollups of one resolution older than before.
+	PruneUsageStats(ctx context.Context, bucket string, before time.Time) (int64, error)
+	// PruneUsageStatEvents forgets the events applied by UpsertUsageStats
+	// whose first stat is older than before; their stats would apply again.
+	PruneUsageStatEvents(ctx context.Context, before time.Time) (int64, error)
+
+	// Webhooks
+	RegisterWebhook(ctx context.Context, cfg *WebhookConfig) error
+	ListWebhooks(ctx context.Context) ([]*WebhookConfig, error)
+	DeleteWebhook(ctx context.Context, webhookID string) error
+
+	// Snapshots
+	SaveSnapshot(ctx context.Context, snap *Snapshot) error
+	GetLatestSnapshot(ctx context.Context) (*Snapshot, error)
+	GetLatestSnapshotTime(ctx context.Context) (time.Time, error)
+	ListSnapshots(ctx context.Context) ([]*Snapshot, error)
+	GetSnapshot(ctx context.Context, snapshotID string) (*Snapshot, error)
+	DeleteSnapshots(ctx context.Context, snapshotIDs []string) error
+
+	Close() error
+}
+
+// BatchAppender is implemented by stores that can append several events in
+// one transaction. The events are stored in order, all or none.
+type BatchAppender interface {
+	AppendEvents(ctx context.Context, events []*Event) error
+}
+
+var (
+	_ EventStore    = (*Store)(nil)
+	_ LeaseStore    = (*Store)(nil)
+	_ ChainStore    = (*Store)(nil)
+	_ BatchAppender = (*Store)(nil)
+	_ EpochFence    = (*Store)(nil)
+	_ Fenceable     = (*Store)(nil)
+)
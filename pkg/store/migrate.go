@@ -0,0 +1,319 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrUnknownSchemaVersion is returned when the database has migrations applied
+// that this binary does not know, i.e. it was written by a newer release.
+var ErrUnknownSchemaVersion = errors.New("database schema is newer than this binary")
+
+// ErrChecksumMismatch is returned when an applied migration differs from the
+// one compiled into this binary.
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// Dialect selects the SQL flavour used for migration bookkeeping.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// postgresMigrationLock is the advisory lock key serialising concurrent
+// migrators on a shared Postgres database.
+const postgresMigrationLock = 0x7261746c // "ratl"
+
+// Migration is a numbered, reversible schema change.
+// Up and Down may contain several statements; each runs in one transaction.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the Up script. Changing an applied migration is
+// detected on startup; add a new migration instead.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(m.Up)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus describes one known or applied migration.
+type MigrationStatus struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at,omitempty"`
+	Known     bool      `json:"known"`    // False if only recorded in the database
+	Modified  bool      `json:"modified"` // Applied checksum differs from the binary
+}
+
+// Migrator applies and reverts migrations recorded in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given ordered migrations.
+func NewMigrator(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, dialect: dialect, migrations: sorted}
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// bind rewrites '?' placeholders for the dialect.
+func (m *Migrator) bind(query string) string {
+	if m.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	ts := "DATETIME"
+	if m.dialect == DialectPostgres {
+		ts = "TIMESTAMPTZ"
+	}
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at %s NOT NULL
+	);`, ts))
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) applied(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}) (map[int]appliedMigration, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration plus any unknown applied ones, by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum(), Known: true}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.appliedAt.UTC()
+			s.Modified = a.checksum != s.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for version, a := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version: version, Name: a.name, Checksum: a.checksum, Applied: true, AppliedAt: a.appliedAt.UTC(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Version returns the highest applied migration version (0 for an empty database).
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for _, s := range statuses {
+		if s.Applied && s.Version > version {
+			version = s.Version
+		}
+	}
+	return version, nil
+}
+
+// Verify fails if the database has unknown (future) or modified migrations.
+func (m *Migrator) Verify(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if !s.Known {
+			return fmt.Errorf("%w: version %d (%s) is applied, latest known is %d", ErrUnknownSchemaVersion, s.Version, s.Name, m.Latest())
+		}
+		if s.Modified {
+			return fmt.Errorf("%w: version %d (%s)", ErrChecksumMismatch, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies pending migrations up to and including target (0 for all).
+// It verifies the database first and returns the migrations it applied.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	if err := m.Verify(ctx); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		applied, err := m.apply(ctx, mig)
+		if err != nil {
+			return done, err
+		}
+		if applied {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the given number of most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.Verify(ctx); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		reverted, err := m.revert(ctx, mig)
+		if err != nil {
+			return done, err
+		}
+		if reverted {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// apply runs one Up script and records it, in a single transaction.
+// It returns false if the migration was already applied.
+func (m *Migrator) apply(ctx context.Context, mig Migration) (bool, error) {
+	return m.inTx(ctx, mig, func(tx *sql.Tx, applied bool) (bool, error) {
+		if applied {
+			return false, nil
+		}
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return false, fmt.Errorf("migration %d (%s) failed: %w", mig.Version, mig.Name, err)
+		}
+		_, err := tx.ExecContext(ctx, m.bind(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`),
+			mig.Version, mig.Name, mig.Checksum(), time.Now().UTC())
+		if err != nil {
+			return false, fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+		return true, nil
+	})
+}
+
+// revert runs one Down script and removes its record, in a single transaction.
+// It returns false if the migration was not applied.
+func (m *Migrator) revert(ctx context.Context, mig Migration) (bool, error) {
+	return m.inTx(ctx, mig, func(tx *sql.Tx, applied bool) (bool, error) {
+		if !applied {
+			return false, nil
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return false, fmt.Errorf("migration %d (%s) is irreversible", mig.Version, mig.Name)
+		}
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return false, fmt.Errorf("reverting migration %d (%s) failed: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.bind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version); err != nil {
+			return false, fmt.Errorf("failed to unrecord migration %d: %w", mig.Version, err)
+		}
+		return true, nil
+	})
+}
+
+// inTx opens a transaction, takes the migration lock where supported and
+// re-reads whether mig is applied, so concurrent migrators never double-apply.
+func (m *Migrator) inTx(ctx context.Context, mig Migration, fn func(tx *sql.Tx, applied bool) (bool, error)) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if m.dialect == DialectPostgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+			return false, fmt.Errorf("failed to take migration lock: %w", err)
+		}
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, m.bind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), mig.Version).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", mig.Version, err)
+	}
+
+	changed, err := fn(tx, count > 0)
+	if err != nil || !changed {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", mig.Version, err)
+	}
+	return true, nil
+}
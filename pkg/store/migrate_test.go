@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", name).Scan(&n); err != nil {
+		t.Fatalf("failed to query sqlite_master: %v", err)
+	}
+	return n > 0
+}
+
+func TestMigrate_FreshDatabaseIsAtLatest(t *testing.T) {
+	st, err := NewStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+
+	m := st.Migrator()
+	version, err := m.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Version failed: %v", err)
+	}
+	if version != m.Latest() {
+		t.Errorf("expected version %d, got %d", m.Latest(), version)
+	}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || !s.Known || s.Modified || s.AppliedAt.IsZero() {
+			t.Errorf("unexpected status: %+v", s)
+		}
+	}
+}
+
+func TestMigrate_DownAndUp(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ratelord.db")
+	st, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+
+	m := st.Migrator()
+	reverted, err := m.Down(ctx, m.Latest())
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(reverted) != len(sqliteMigrations) {
+		t.Errorf("expected %d reverted migrations, got %d", len(sqliteMigrations), len(reverted))
+	}
+	if tableExists(t, st.db, "events") {
+		t.Error("events table should be dropped after reverting all migrations")
+	}
+	if v, _ := m.Version(ctx); v != 0 {
+		t.Errorf("expected version 0, got %d", v)
+	}
+
+	applied, err := m.Up(ctx, 1)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Errorf("expected only migration 1 to be applied, got %+v", applied)
+	}
+	if !tableExists(t, st.db, "events") {
+		t.Error("events table should exist after migrating up")
+	}
+
+	// Applying again at the latest version is a no-op
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up to latest failed: %v", err)
+	}
+	applied, err = m.Up(ctx, 0)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("expected Up to be a no-op at latest, got %v (err %v)", applied, err)
+	}
+}
+
+func TestMigrate_RefusesFutureVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelord.db")
+	st, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	_, err = st.db.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (999, 'from_the_future', 'x', ?)`, time.Now())
+	if err != nil {
+		t.Fatalf("failed to insert future migration: %v", err)
+	}
+	st.Close()
+
+	_, err = NewStore(path)
+	if !errors.Is(err, ErrUnknownSchemaVersion) {
+		t.Fatalf("expected ErrUnknownSchemaVersion, got %v", err)
+	}
+}
+
+func TestMigrate_DetectsModifiedMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelord.db")
+	st, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer st.Close()
+
+	if _, err := st.db.Exec(`UPDATE schema_migrations SET checksum = 'tampered' WHERE version = 1`); err != nil {
+		t.Fatalf("failed to tamper checksum: %v", err)
+	}
+
+	if err := st.Migrator().Verify(context.Background()); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestMigrate_FailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	st, err := OpenStore(filepath.Join(t.TempDir(), "ratelord.db"))
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	defer st.Close()
+
+	m := NewMigrator(st.db, DialectSQLite, []Migration{
+		{Version: 1, Name: "ok", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "broken", Up: "CREATE TABLE b (id INTEGER); INSERT INTO missing VALUES (1);", Down: "DROP TABLE b;"},
+	})
+
+	applied, err := m.Up(ctx, 0)
+	if err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+	if len(applied) != 1 {
+		t.Errorf("expected migration 1 to be applied before the failure, got %+v", applied)
+	}
+	if tableExists(t, st.db, "b") {
+		t.Error("table b must be rolled back with the failed migration")
+	}
+	if v, _ := m.Version(ctx); v != 1 {
+		t.Errorf("expected version 1, got %d", v)
+	}
+}
+
+func TestMigrate_AdoptsLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelord.db")
+
+	// A database created before versioned migrations: tables, no bookkeeping
+	legacy, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+	if _, err := legacy.db.Exec(sqliteMigrations[0].Up); err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+	if _, err := legacy.db.Exec(`INSERT INTO system_state (key, value) VALUES ('k', 'v')`); err != nil {
+		t.Fatalf("failed to seed legacy data: %v", err)
+	}
+	legacy.Close()
+
+	st, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore on a legacy database failed: %v", err)
+	}
+	defer st.Close()
+
+	if v, _ := st.Migrator().Version(context.Background()); v != st.Migrator().Latest() {
+		t.Errorf("expected legacy database at latest version, got %d", v)
+	}
+	if v, err := st.GetSystemState(context.Background(), "k"); err != nil || v != "v" {
+		t.Errorf("legacy data lost: %q (err %v)", v, err)
+	}
+}
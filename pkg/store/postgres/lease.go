@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// Acquire tries to acquire the lease. Returns true if successful.
+// If the lease is already held by holderID, it renews it.
+// Taking over an expired lease from another holder increments the epoch.
+func (s *PostgresStore) Acquire(ctx context.Context, name, holderID string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	expiry := now.Add(ttl)
+
+	// A single upsert is atomic under concurrent candidates: the conflict
+	// branch only applies if we own the lease or it has expired.
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO leases (name, holder_id, expires_at, version, epoch)
+		VALUES ($1, $2, $3, 1, 1)
+		ON CONFLICT (name) DO UPDATE SET
+			holder_id = excluded.holder_id,
+			expires_at = excluded.expires_at,
+			version = leases.version + 1,
+			epoch = CASE WHEN leases.holder_id <> excluded.holder_id THEN leases.epoch + 1 ELSE leases.epoch END
+		WHERE leases.holder_id = excluded.holder_id OR leases.expires_at < $4
+	`, name, holderID, expiry, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// Renew updates the expiry of an existing lease held by holderID.
+// Returns error if the lease is lost or stolen.
+func (s *PostgresStore) Renew(ctx context.Context, name, holderID string, ttl time.Duration) error {
+	expiry := time.Now().UTC().Add(ttl)
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE leases
+		SET expires_at = $1, version = version + 1
+		WHERE name = $2 AND holder_id = $3
+	`, expiry, name, holderID)
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("lease lost or stolen")
+	}
+	return nil
+}
+
+// Release releases the lease if held by holderID.
+func (s *PostgresStore) Release(ctx context.Context, name, holderID string) error {
+	// The row is kept, expired and without holder, so that the epoch keeps
+	// growing across releases and fences the appends of the former holder.
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE leases
+		SET holder_id = '', expires_at = $1, version = version + 1
+		WHERE name = $2 AND holder_id = $3
+	`, time.Time{}, name, holderID)
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
+// Get returns the current lease state.
+func (s *PostgresStore) Get(ctx context.Context, name string) (*store.Lease, error) {
+	var l store.Lease
+	err := s.db.QueryRowContext(ctx, `
+		SELECT name, holder_id, expires_at, version, epoch
+		FROM leases WHERE name = $1 AND holder_id <> ''
+	`, name).Scan(&l.Name, &l.HolderID, &l.ExpiresAt, &l.Version, &l.Epoch)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get lease: %w", err)
+	}
+	l.ExpiresAt = l.ExpiresAt.UTC()
+	return &l, nil
+}
+
+// SetFence makes appends reject events whose epoch is lower than that of the
+// lease name. The lease row is share-locked for the append transaction.
+func (s *PostgresStore) SetFence(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fence = name
+}
+
+// LeaseEpoch returns the epoch of the lease, held or not; zero if it was
+// never acquired.
+func (s *PostgresStore) LeaseEpoch(ctx context.Context, name string) (int64, error) {
+	var epoch int64
+	err := s.db.QueryRowContext(ctx, `SELECT epoch FROM leases WHERE name = $1`, name).Scan(&epoch)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get lease epoch: %w", err)
+	}
+	return epoch, nil
+}
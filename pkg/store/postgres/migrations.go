@@ -0,0 +1,250 @@
+package postgres
+
+import "github.com/rmax-ai/ratelord/pkg/store"
+
+// migrations mirrors the SQLite schema history version for version, so a
+// database can move between backends at the same schema version.
+// Payloads use JSON (not JSONB) so they are returned byte-for-byte as written.
+var migrations = []store.Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: `
+	CREATE TABLE IF NOT EXISTS events (
+		event_id TEXT PRIMARY KEY,
+		event_type TEXT NOT NULL,
+		schema_version INTEGER NOT NULL,
+		ts_event TIMESTAMPTZ NOT NULL,
+		ts_ingest TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+		origin_kind TEXT,
+		origin_id TEXT,
+		writer_id TEXT,
+
+		agent_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		workload_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+
+		correlation_id TEXT,
+		causation_id TEXT,
+
+		payload JSON NOT NULL,
+
+		epoch BIGINT DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_events_ts_ingest ON events(ts_ingest);
+	CREATE INDEX IF NOT EXISTS idx_events_ts_event ON events(ts_event);
+	CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type);
+	CREATE INDEX IF NOT EXISTS idx_events_identity ON events(identity_id);
+	CREATE INDEX IF NOT EXISTS idx_events_scope ON events(scope_id);
+	CREATE INDEX IF NOT EXISTS idx_events_correlation ON events(correlation_id);
+
+	CREATE TABLE IF NOT EXISTS system_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMPTZ DEFAULT now()
+	);
+
+	CREATE TABLE IF NOT EXISTS usage_hourly (
+		bucket_ts TIMESTAMPTZ NOT NULL,
+		provider_id TEXT NOT NULL,
+		pool_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+
+		total_usage BIGINT NOT NULL DEFAULT 0,
+		min_usage BIGINT NOT NULL DEFAULT 0,
+		max_usage BIGINT NOT NULL DEFAULT 0,
+		event_count BIGINT NOT NULL DEFAULT 0,
+
+		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS usage_daily (
+		bucket_ts TIMESTAMPTZ NOT NULL,
+		provider_id TEXT NOT NULL,
+		pool_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+
+		total_usage BIGINT NOT NULL DEFAULT 0,
+		min_usage BIGINT NOT NULL DEFAULT 0,
+		max_usage BIGINT NOT NULL DEFAULT 0,
+		event_count BIGINT NOT NULL DEFAULT 0,
+
+		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_usage_hourly_time ON usage_hourly(bucket_ts);
+	CREATE INDEX IF NOT EXISTS idx_usage_daily_time ON usage_daily(bucket_ts);
+
+	CREATE TABLE IF NOT EXISTS webhook_configs (
+		webhook_id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		active BOOLEAN NOT NULL DEFAULT TRUE
+	);
+
+	CREATE TABLE IF NOT EXISTS snapshots (
+		snapshot_id TEXT PRIMARY KEY,
+		schema_version INTEGER NOT NULL DEFAULT 1,
+		ts_snapshot TIMESTAMPTZ NOT NULL DEFAULT now(),
+		last_event_id TEXT NOT NULL REFERENCES events(event_id),
+		payload JSON NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_snapshots_ts ON snapshots(ts_snapshot DESC);
+
+	CREATE TABLE IF NOT EXISTS leases (
+		name TEXT PRIMARY KEY,
+		holder_id TEXT NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		version BIGINT NOT NULL DEFAULT 1,
+		epoch BIGINT NOT NULL DEFAULT 0
+	);
+	`,
+		Down: `
+	DROP TABLE IF EXISTS leases;
+	DROP TABLE IF EXISTS snapshots;
+	DROP TABLE IF EXISTS webhook_configs;
+	DROP TABLE IF EXISTS usage_daily;
+	DROP TABLE IF EXISTS usage_hourly;
+	DROP TABLE IF EXISTS system_state;
+	DROP TABLE IF EXISTS events;
+	`,
+	},
+	{
+		Version: 2,
+		Name:    "event_query_indexes",
+		Up: `
+	ALTER TABLE events ADD COLUMN IF NOT EXISTS provider_id TEXT;
+	ALTER TABLE events ADD COLUMN IF NOT EXISTS pool_id TEXT;
+
+	UPDATE events SET
+		provider_id = payload->>'provider_id',
+		pool_id = payload->>'pool_id'
+	WHERE json_typeof(payload) = 'object';
+
+	CREATE INDEX IF NOT EXISTS idx_events_cursor ON events(ts_ingest, event_id);
+	CREATE INDEX IF NOT EXISTS idx_events_type_cursor ON events(event_type, ts_ingest, event_id);
+	CREATE INDEX IF NOT EXISTS idx_events_provider_pool ON events(provider_id, pool_id, ts_ingest);
+	CREATE INDEX IF NOT EXISTS idx_events_causation ON events(causation_id);
+	CREATE INDEX IF NOT EXISTS idx_events_epoch ON events(epoch);
+	`,
+		Down: `
+	DROP INDEX IF EXISTS idx_events_epoch;
+	DROP INDEX IF EXISTS idx_events_causation;
+	DROP INDEX IF EXISTS idx_events_provider_pool;
+	DROP INDEX IF EXISTS idx_events_type_cursor;
+	DROP INDEX IF EXISTS idx_events_cursor;
+
+	ALTER TABLE events DROP COLUMN IF EXISTS pool_id;
+	ALTER TABLE events DROP COLUMN IF EXISTS provider_id;
+	`,
+	},
+	{
+		Version: 3,
+		Name:    "event_hash_chain",
+		Up: `
+	ALTER TABLE events ADD COLUMN IF NOT EXISTS seq BIGINT;
+	ALTER TABLE events ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+	ALTER TABLE events ADD COLUMN IF NOT EXISTS hash TEXT;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_events_seq ON events(seq);
+
+	CREATE TABLE IF NOT EXISTS chain_head (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		seq BIGINT NOT NULL,
+		hash TEXT NOT NULL
+	);
+	INSERT INTO chain_head (id, seq, hash) VALUES (1, 0, '') ON CONFLICT (id) DO NOTHING;
+
+	CREATE TABLE IF NOT EXISTS event_tombstones (
+		seq BIGINT PRIMARY KEY,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		tombstone_event_id TEXT NOT NULL,
+		deleted_at TIMESTAMPTZ NOT NULL
+	);
+	`,
+		Down: `
+	DROP TABLE IF EXISTS event_tombstones;
+	DROP TABLE IF EXISTS chain_head;
+	DROP INDEX IF EXISTS idx_events_seq;
+
+	ALTER TABLE events DROP COLUMN IF EXISTS hash;
+	ALTER TABLE events DROP COLUMN IF EXISTS prev_hash;
+	ALTER TABLE events DROP COLUMN IF EXISTS seq;
+	`,
+	},
+	{
+		Version: 4,
+		Name:    "usage_rollup_resolutions",
+		Up: `
+	CREATE TABLE IF NOT EXISTS usage_minutely (
+		bucket_ts TIMESTAMPTZ NOT NULL,
+		provider_id TEXT NOT NULL,
+		pool_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+
+		total_usage BIGINT NOT NULL DEFAULT 0,
+		min_usage BIGINT NOT NULL DEFAULT 0,
+		max_usage BIGINT NOT NULL DEFAULT 0,
+		event_count BIGINT NOT NULL DEFAULT 0,
+		total_cost BIGINT NOT NULL DEFAULT 0,
+		approved_count BIGINT NOT NULL DEFAULT 0,
+		denied_count BIGINT NOT NULL DEFAULT 0,
+		shaped_count BIGINT NOT NULL DEFAULT 0,
+
+		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_minutely_time ON usage_minutely(bucket_ts);
+
+	ALTER TABLE usage_hourly ADD COLUMN IF NOT EXISTS total_cost BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_hourly ADD COLUMN IF NOT EXISTS approved_count BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_hourly ADD COLUMN IF NOT EXISTS denied_count BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_hourly ADD COLUMN IF NOT EXISTS shaped_count BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN IF NOT EXISTS total_cost BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN IF NOT EXISTS approved_count BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN IF NOT EXISTS denied_count BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN IF NOT EXISTS shaped_count BIGINT NOT NULL DEFAULT 0;
+	`,
+		Down: `
+	ALTER TABLE usage_daily DROP COLUMN IF EXISTS shaped_count;
+	ALTER TABLE usage_daily DROP COLUMN IF EXISTS denied_count;
+	ALTER TABLE usage_daily DROP COLUMN IF EXISTS approved_count;
+	ALTER TABLE usage_daily DROP COLUMN IF EXISTS total_cost;
+	ALTER TABLE usage_hourly DROP COLUMN IF EXISTS shaped_count;
+	ALTER TABLE usage_hourly DROP COLUMN IF EXISTS denied_count;
+	ALTER TABLE usage_hourly DROP COLUMN IF EXISTS approved_count;
+	ALTER TABLE usage_hourly DROP COLUMN IF EXISTS total_cost;
+
+	DROP INDEX IF EXISTS idx_usage_minutely_time;
+	DROP TABLE IF EXISTS usage_minutely;
+	`,
+	},
+	{
+		Version: 5,
+		Name:    "usage_stat_events",
+		Up: `
+	CREATE TABLE IF NOT EXISTS usage_stat_events (
+		event_id TEXT PRIMARY KEY,
+		bucket_ts TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_stat_events_time ON usage_stat_events(bucket_ts);
+	`,
+		Down: `
+	DROP INDEX IF EXISTS idx_usage_stat_events_time;
+	DROP TABLE IF EXISTS usage_stat_events;
+	`,
+	},
+}
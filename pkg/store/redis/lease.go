@@ -10,6 +10,11 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
+var (
+	_ store.LeaseStore = (*RedisLeaseStore)(nil)
+	_ store.EpochFence = (*RedisLeaseStore)(nil)
+)
+
 type RedisLeaseStore struct {
 	client *redis.Client
 }
@@ -166,3 +171,13 @@ func (s *RedisLeaseStore) Get(ctx context.Context, name string) (*store.Lease, e
 		Epoch:     epoch,
 	}, nil
 }
+
+// LeaseEpoch returns the epoch of the lease, which outlives the lease key;
+// zero if it was never acquired.
+func (s *RedisLeaseStore) LeaseEpoch(ctx context.Context, name string) (int64, error) {
+	epoch, err := s.client.Get(ctx, s.makeEpochKey(name)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to get lease epoch: %w", err)
+	}
+	return epoch, nil
+}
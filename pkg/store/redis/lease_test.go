@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+func TestRedisLeaseStore_FencedStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	leases := NewRedisLeaseStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+
+	events, err := store.NewStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer events.Close()
+	st := store.NewFencedStore(events, leases, "leader")
+
+	evt := func(id string, epoch int64) *store.Event {
+		now := time.Now()
+		return &store.Event{
+			EventID:       store.EventID(id),
+			EventType:     store.EventTypeUsageObserved,
+			SchemaVersion: 1,
+			TsEvent:       now,
+			TsIngest:      now,
+			Epoch:         epoch,
+			Source:        store.EventSource{OriginKind: "daemon", OriginID: "test", WriterID: "ratelord-d"},
+			Dimensions:    store.EventDimensions{AgentID: "a", IdentityID: "i", WorkloadID: "w", ScopeID: "s"},
+			Correlation:   store.EventCorrelation{CorrelationID: "c", CausationID: "c"},
+		}
+	}
+
+	if ok, err := leases.Acquire(ctx, "leader", "node-a", time.Second); err != nil || !ok {
+		t.Fatalf("node-a should acquire the lease (ok=%v, err=%v)", ok, err)
+	}
+	if err := st.AppendEvent(ctx, evt("a1", 1)); err != nil {
+		t.Fatalf("append of the leader failed: %v", err)
+	}
+
+	// node-a's lease expires, node-b takes over; the epoch outlives the lease
+	mr.FastForward(2 * time.Second)
+	if ok, err := leases.Acquire(ctx, "leader", "node-b", time.Second); err != nil || !ok {
+		t.Fatalf("node-b should take over (ok=%v, err=%v)", ok, err)
+	}
+	if epoch, err := leases.LeaseEpoch(ctx, "leader"); err != nil || epoch != 2 {
+		t.Fatalf("expected epoch 2, got %d (err %v)", epoch, err)
+	}
+
+	if err := st.AppendEvent(ctx, evt("a2", 1)); !errors.Is(err, store.ErrStaleEpoch) {
+		t.Fatalf("expected ErrStaleEpoch for the deposed leader, got %v", err)
+	}
+	if err := st.AppendEvent(ctx, evt("b1", 2)); err != nil {
+		t.Fatalf("append of the new leader failed: %v", err)
+	}
+
+	if got, _ := events.GetEvent(ctx, "a2"); got != nil {
+		t.Errorf("fenced event was stored: %+v", got)
+	}
+}
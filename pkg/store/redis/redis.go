@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,25 +16,132 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
 )
 
-const poolsSet = "ratelord:pools"
+const (
+	poolKeyPrefix = "ratelord:pool:"
+	// poolsSet indexes the keys of all pools, so that GetAll needs no SCAN
+	poolsSet = "ratelord:pools"
+	// poolsChannel carries the key of every pool written, or clearAll
+	poolsChannel = "ratelord:pools:changed"
+	clearAll     = "*"
+)
 
+// RedisUsageStore keeps pool state in Redis hashes shared by several
+// daemons. Every write is a single MULTI/EXEC transaction. Set replaces a
+// whole pool; Increment updates its counters with HINCRBY, and SetResetAt
+// and SetForecast write a single field, so that the usage recorded by
+// concurrent writers adds up.
+//
+// While Watch runs, reads are served from a local cache that is invalidated
+// by the change notifications published on every write, by any node.
 type RedisUsageStore struct {
 	client *redis.Client
+
+	mu       sync.Mutex
+	cache    map[string]engine.PoolState // nil unless watching
+	gens     map[string]uint64           // invalidations per key
+	clearGen uint64                      // invalidations of all keys
 }
 
 func NewRedisUsageStore(client *redis.Client) *RedisUsageStore {
-	return &RedisUsageStore{client: client}
+	return &RedisUsageStore{client: client, gens: make(map[string]uint64)}
 }
 
 func (s *RedisUsageStore) makeKey(providerID, poolID string) string {
-	return fmt.Sprintf("ratelord:pool:%s:%s", providerID, poolID)
+	return fmt.Sprintf("%s%s:%s", poolKeyPrefix, providerID, poolID)
+}
+
+// Watch subscribes to the change notifications and enables the local cache
+// until ctx is done. The cache is dropped whenever the subscription is
+// lost, as notifications may have been missed, and Watch resubscribes.
+func (s *RedisUsageStore) Watch(ctx context.Context) {
+	for {
+		if err := s.watch(ctx); err != nil {
+			log.Printf("Redis usage store subscription lost: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *RedisUsageStore) watch(ctx context.Context) error {
+	sub := s.client.Subscribe(ctx, poolsChannel)
+	defer sub.Close()
+	defer s.setCaching(false)
+	// Receiving does not return on cancellation; closing unblocks it
+	stop := context.AfterFunc(ctx, func() { sub.Close() })
+	defer stop()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	s.setCaching(true)
+
+	for {
+		msg, err := sub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		s.invalidate(msg.Payload)
+	}
+}
+
+func (s *RedisUsageStore) setCaching(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = nil
+	if on {
+		s.cache = make(map[string]engine.PoolState)
+	}
+	s.clearGen++
+}
+
+func (s *RedisUsageStore) invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key == clearAll {
+		s.clearGen++
+		if s.cache != nil {
+			s.cache = make(map[string]engine.PoolState)
+		}
+		return
+	}
+	s.gens[key]++
+	delete(s.cache, key)
+}
+
+// cached returns the cached state of key, or the generation to pass to fill
+// after reading it from Redis.
+func (s *RedisUsageStore) cached(key string) (engine.PoolState, bool, [2]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.cache[key]
+	return state, ok, [2]uint64{s.clearGen, s.gens[key]}
 }
 
+// fill caches a state read from Redis, unless key was invalidated since the
+// read began: the state may predate the write that invalidated it.
+func (s *RedisUsageStore) fill(key string, state engine.PoolState, gen [2]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache != nil && gen == [2]uint64{s.clearGen, s.gens[key]} {
+		s.cache[key] = state
+	}
+}
+
+// Set replaces the state of a pool.
 func (s *RedisUsageStore) Set(state engine.PoolState) {
 	key := s.makeKey(state.ProviderID, state.PoolID)
 	ctx := context.Background()
 
 	fields := map[string]interface{}{
+		"provider_id":  state.ProviderID,
+		"pool_id":      state.PoolID,
 		"used":         strconv.FormatInt(state.Used, 10),
 		"remaining":    strconv.FormatInt(state.Remaining, 10),
 		"cost":         strconv.FormatInt(int64(state.Cost), 10),
@@ -41,6 +149,13 @@ func (s *RedisUsageStore) Set(state engine.PoolState) {
 		"last_updated": state.LastUpdated.Format(time.RFC3339),
 	}
 
+	if !state.LastObservedAt.IsZero() {
+		fields["last_observed_at"] = state.LastObservedAt.Format(time.RFC3339Nano)
+	}
+	if state.LastEventID != "" {
+		fields["last_event_id"] = state.LastEventID
+	}
+
 	if state.LatestForecast != nil {
 		forecastData, err := json.Marshal(state.LatestForecast)
 		if err != nil {
@@ -50,17 +165,26 @@ func (s *RedisUsageStore) Set(state engine.PoolState) {
 		fields["latest_forecast"] = string(forecastData)
 	}
 
-	if err := s.client.HSet(ctx, key, fields).Err(); err != nil {
-		log.Printf("Failed to HSET key %s: %v", key, err)
-		return
-	}
-	if err := s.client.SAdd(ctx, poolsSet, key).Err(); err != nil {
-		log.Printf("Failed to SADD key %s to set: %v", key, err)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, fields)
+		pipe.SAdd(ctx, poolsSet, key)
+		pipe.Publish(ctx, poolsChannel, key)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to set key %s: %v", key, err)
 	}
+	s.invalidate(key)
 }
 
 func (s *RedisUsageStore) Get(providerID, poolID string) (engine.PoolState, bool) {
 	key := s.makeKey(providerID, poolID)
+	cachedState, ok, gen := s.cached(key)
+	if ok {
+		return cachedState, true
+	}
+
 	ctx := context.Background()
 	fields, err := s.client.HGetAll(ctx, key).Result()
 	if err != nil {
@@ -74,43 +198,9 @@ func (s *RedisUsageStore) Get(providerID, poolID string) (engine.PoolState, bool
 		return engine.PoolState{}, false
 	}
 
-	state := engine.PoolState{
-		ProviderID: providerID,
-		PoolID:     poolID,
-	}
-
-	if usedStr, ok := fields["used"]; ok {
-		if used, err := strconv.ParseInt(usedStr, 10, 64); err == nil {
-			state.Used = used
-		}
-	}
-	if remainingStr, ok := fields["remaining"]; ok {
-		if remaining, err := strconv.ParseInt(remainingStr, 10, 64); err == nil {
-			state.Remaining = remaining
-		}
-	}
-	if costStr, ok := fields["cost"]; ok {
-		if cost, err := strconv.ParseInt(costStr, 10, 64); err == nil {
-			state.Cost = currency.MicroUSD(cost)
-		}
-	}
-	if resetAtStr, ok := fields["reset_at"]; ok {
-		if resetAt, err := time.Parse(time.RFC3339, resetAtStr); err == nil {
-			state.ResetAt = resetAt
-		}
-	}
-	if lastUpdatedStr, ok := fields["last_updated"]; ok {
-		if lastUpdated, err := time.Parse(time.RFC3339, lastUpdatedStr); err == nil {
-			state.LastUpdated = lastUpdated
-		}
-	}
-	if forecastStr, ok := fields["latest_forecast"]; ok && forecastStr != "" {
-		var forecast forecast.Forecast
-		if err := json.Unmarshal([]byte(forecastStr), &forecast); err == nil {
-			state.LatestForecast = &forecast
-		}
-	}
-
+	state := parsePoolState(fields)
+	state.ProviderID, state.PoolID = providerID, poolID
+	s.fill(key, state, gen)
 	return state, true
 }
 
@@ -147,92 +237,139 @@ func (s *RedisUsageStore) GetAll() []engine.PoolState {
 			continue
 		}
 
-		// Parse providerID and poolID from key
-		parts := strings.Split(key, ":")
-		if len(parts) != 4 || parts[0] != "ratelord" || parts[1] != "pool" {
-			log.Printf("Failed to parse key %s: invalid format", key)
-			continue
-		}
-		providerID := parts[2]
-		poolID := parts[3]
-
-		state := engine.PoolState{
-			ProviderID: providerID,
-			PoolID:     poolID,
-		}
-
-		if usedStr, ok := fields["used"]; ok {
-			if used, err := strconv.ParseInt(usedStr, 10, 64); err == nil {
-				state.Used = used
-			}
-		}
-		if remainingStr, ok := fields["remaining"]; ok {
-			if remaining, err := strconv.ParseInt(remainingStr, 10, 64); err == nil {
-				state.Remaining = remaining
-			}
-		}
-		if costStr, ok := fields["cost"]; ok {
-			if cost, err := strconv.ParseInt(costStr, 10, 64); err == nil {
-				state.Cost = currency.MicroUSD(cost)
+		state := parsePoolState(fields)
+		if state.ProviderID == "" {
+			// Written before the ids were stored in the hash; ids holding
+			// ':' cannot be told apart, so take the first one as separator
+			parts := strings.SplitN(strings.TrimPrefix(key, poolKeyPrefix), ":", 2)
+			if !strings.HasPrefix(key, poolKeyPrefix) || len(parts) != 2 {
+				log.Printf("Failed to parse key %s: invalid format", key)
+				continue
 			}
+			state.ProviderID, state.PoolID = parts[0], parts[1]
 		}
-		if resetAtStr, ok := fields["reset_at"]; ok {
-			if resetAt, err := time.Parse(time.RFC3339, resetAtStr); err == nil {
-				state.ResetAt = resetAt
-			}
-		}
-		if lastUpdatedStr, ok := fields["last_updated"]; ok {
-			if lastUpdated, err := time.Parse(time.RFC3339, lastUpdatedStr); err == nil {
-				state.LastUpdated = lastUpdated
-			}
-		}
-		if forecastStr, ok := fields["latest_forecast"]; ok && forecastStr != "" {
-			var f forecast.Forecast
-			if err := json.Unmarshal([]byte(forecastStr), &f); err == nil {
-				state.LatestForecast = &f
-			}
-		}
-
 		states = append(states, state)
 	}
 	return states
 }
 
+// clearScript deletes the indexed pools and the index at once, so that no
+// pool written meanwhile is left out of the index.
+var clearScript = redis.NewScript(`
+	local keys = redis.call("SMEMBERS", KEYS[1])
+	for _, key in ipairs(keys) do
+		redis.call("DEL", key)
+	end
+	redis.call("DEL", KEYS[1])
+	redis.call("PUBLISH", ARGV[1], ARGV[2])
+	return #keys
+`)
+
+// Clear deletes every pool.
 func (s *RedisUsageStore) Clear() {
 	ctx := context.Background()
-	keys, err := s.client.SMembers(ctx, poolsSet).Result()
-	if err != nil {
-		log.Printf("Failed to SMEMBERS %s during clear: %v", poolsSet, err)
-		return
+	if err := clearScript.Run(ctx, s.client, []string{poolsSet}, poolsChannel, clearAll).Err(); err != nil {
+		log.Printf("Failed to clear pools: %v", err)
 	}
-	if len(keys) > 0 {
-		if err := s.client.Del(ctx, keys...).Err(); err != nil {
-			log.Printf("Failed to DEL keys: %v", err)
-		}
+	s.invalidate(clearAll)
+}
+
+// Increment adds the deltas to the counters of a pool, creating it if needed.
+func (s *RedisUsageStore) Increment(providerID, poolID string, usedDelta, remainingDelta int64, costDelta currency.MicroUSD) {
+	key := s.makeKey(providerID, poolID)
+	ctx := context.Background()
+	currentTime := time.Now().Format(time.RFC3339)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "used", usedDelta)
+		pipe.HIncrBy(ctx, key, "remaining", remainingDelta)
+		pipe.HIncrBy(ctx, key, "cost", int64(costDelta))
+		pipe.HSet(ctx, key, "provider_id", providerID, "pool_id", poolID, "last_updated", currentTime)
+		pipe.SAdd(ctx, poolsSet, key)
+		pipe.Publish(ctx, poolsChannel, key)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to increment key %s: %v", key, err)
 	}
-	if err := s.client.Del(ctx, poolsSet).Err(); err != nil {
-		log.Printf("Failed to DEL set %s: %v", poolsSet, err)
+	s.invalidate(key)
+}
+
+// SetResetAt sets the reset time of a pool, creating it if needed.
+func (s *RedisUsageStore) SetResetAt(providerID, poolID string, resetAt time.Time) {
+	s.setField(providerID, poolID, "reset_at", resetAt.Format(time.RFC3339))
+}
+
+// SetForecast sets the latest forecast of a pool, creating it if needed.
+func (s *RedisUsageStore) SetForecast(providerID, poolID string, fc forecast.Forecast) {
+	forecastData, err := json.Marshal(fc)
+	if err != nil {
+		log.Printf("Failed to marshal LatestForecast: %v", err)
+		return
 	}
+	s.setField(providerID, poolID, "latest_forecast", string(forecastData))
 }
 
-func (s *RedisUsageStore) Increment(providerID, poolID string, usedDelta, remainingDelta int64, costDelta currency.MicroUSD) {
+func (s *RedisUsageStore) setField(providerID, poolID, field, value string) {
 	key := s.makeKey(providerID, poolID)
 	ctx := context.Background()
 	currentTime := time.Now().Format(time.RFC3339)
 
-	if err := s.client.HIncrBy(ctx, key, "used", usedDelta).Err(); err != nil {
-		log.Printf("Failed to HINCRBY used: %v", err)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, value, "provider_id", providerID, "pool_id", poolID, "last_updated", currentTime)
+		pipe.SAdd(ctx, poolsSet, key)
+		pipe.Publish(ctx, poolsChannel, key)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to set %s of key %s: %v", field, key, err)
 	}
-	if err := s.client.HIncrBy(ctx, key, "remaining", remainingDelta).Err(); err != nil {
-		log.Printf("Failed to HINCRBY remaining: %v", err)
+	s.invalidate(key)
+}
+
+// parsePoolState decodes the hash of a pool.
+func parsePoolState(fields map[string]string) engine.PoolState {
+	state := engine.PoolState{
+		ProviderID:  fields["provider_id"],
+		PoolID:      fields["pool_id"],
+		LastEventID: fields["last_event_id"],
 	}
-	if err := s.client.HIncrBy(ctx, key, "cost", int64(costDelta)).Err(); err != nil {
-		log.Printf("Failed to HINCRBY cost: %v", err)
+
+	if usedStr, ok := fields["used"]; ok {
+		if used, err := strconv.ParseInt(usedStr, 10, 64); err == nil {
+			state.Used = used
+		}
 	}
-	if err := s.client.HSet(ctx, key, "last_updated", currentTime).Err(); err != nil {
-		log.Printf("Failed to HSET last_updated: %v", err)
+	if remainingStr, ok := fields["remaining"]; ok {
+		if remaining, err := strconv.ParseInt(remainingStr, 10, 64); err == nil {
+			state.Remaining = remaining
+		}
 	}
-	if err := s.client.SAdd(ctx, poolsSet, key).Err(); err != nil {
-		log.Printf("Failed to SADD: %v", err)
+	if costStr, ok := fields["cost"]; ok {
+		if cost, err := strconv.ParseInt(costStr, 10, 64); err == nil {
+			state.Cost = currency.MicroUSD(cost)
+		}
+	}
+	if resetAtStr, ok := fields["reset_at"]; ok {
+		if resetAt, err := time.Parse(time.RFC3339, resetAtStr); err == nil {
+			state.ResetAt = resetAt
+		}
+	}
+	if lastUpdatedStr, ok := fields["last_updated"]; ok {
+		if lastUpdated, err := time.Parse(time.RFC3339, lastUpdatedStr); err == nil {
+			state.LastUpdated = lastUpdated
+		}
+	}
+	if observedStr, ok := fields["last_observed_at"]; ok {
+		if observed, err := time.Parse(time.RFC3339Nano, observedStr); err == nil {
+			state.LastObservedAt = observed
+		}
+	}
+	if forecastStr, ok := fields["latest_forecast"]; ok && forecastStr != "" {
+		var f forecast.Forecast
+		if err := json.Unmarshal([]byte(forecastStr), &f); err == nil {
+			state.LatestForecast = &f
+		}
 	}
+	return state
 }
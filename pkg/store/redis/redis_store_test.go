@@ -1,6 +1,9 @@
 package redis
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +13,7 @@ import (
 	"github.com/rmax-ai/ratelord/pkg/engine"
 	"github.com/rmax-ai/ratelord/pkg/engine/currency"
 	"github.com/rmax-ai/ratelord/pkg/engine/forecast"
+	"github.com/rmax-ai/ratelord/pkg/store"
 )
 
 // RunUsageStoreTests runs a comprehensive test suite against a UsageStore implementation
@@ -124,6 +128,29 @@ func RunUsageStoreTests(t *testing.T, store engine.UsageStore) {
 		}
 	})
 
+	t.Run("Field writes keep counters", func(t *testing.T) {
+		store.Clear()
+
+		store.Set(engine.PoolState{ProviderID: "p", PoolID: "pool", Used: 10, Remaining: 90})
+		resetAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		store.SetResetAt("p", "pool", resetAt)
+		store.SetForecast("p", "pool", forecast.Forecast{TTE: forecast.TimeToExhaustion{P99Seconds: 60}})
+
+		retrieved, ok := store.Get("p", "pool")
+		if !ok || retrieved.Used != 10 || retrieved.Remaining != 90 {
+			t.Fatalf("expected the counters to be kept, got %+v", retrieved)
+		}
+		if !retrieved.ResetAt.Equal(resetAt) || retrieved.LatestForecast == nil || retrieved.LatestForecast.TTE.P99Seconds != 60 {
+			t.Errorf("expected the reset time and forecast to be set, got %+v", retrieved)
+		}
+
+		// A pool is created by its first write
+		store.SetResetAt("p", "new-pool", resetAt)
+		if retrieved, ok := store.Get("p", "new-pool"); !ok || !retrieved.ResetAt.Equal(resetAt) {
+			t.Errorf("expected the pool to be created, got %+v", retrieved)
+		}
+	})
+
 	t.Run("GetAll", func(t *testing.T) {
 		store.Clear()
 
@@ -238,3 +265,169 @@ func TestRedisUsageStore(t *testing.T) {
 	// Run tests
 	RunUsageStoreTests(t, store)
 }
+
+func TestRedisUsageStore_PoolIDsWithColons(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := NewRedisUsageStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	store.Set(engine.PoolState{ProviderID: "github", PoolID: "github:core", Used: 1})
+	store.Increment("openai", "org:team:tokens", 2, -2, 0)
+
+	got := make(map[string]int64)
+	for _, s := range store.GetAll() {
+		got[s.ProviderID+"/"+s.PoolID] = s.Used
+	}
+	if len(got) != 2 || got["github/github:core"] != 1 || got["openai/org:team:tokens"] != 2 {
+		t.Errorf("unexpected pools %v", got)
+	}
+}
+
+func TestRedisUsageStore_SetReplaces(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := NewRedisUsageStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	store.Set(engine.PoolState{ProviderID: "p", PoolID: "pool", Used: 1, LastEventID: "evt_1", LatestForecast: &forecast.Forecast{}})
+	store.Set(engine.PoolState{ProviderID: "p", PoolID: "pool", Used: 2})
+	got, ok := store.Get("p", "pool")
+	if !ok || got.Used != 2 || got.LastEventID != "" || got.LatestForecast != nil {
+		t.Errorf("expected the second Set to replace the first, got %+v", got)
+	}
+}
+
+// Two daemons sharing one Redis lose no update to each other.
+func TestRedisUsageStore_ConcurrentNodes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	nodes := []*RedisUsageStore{
+		NewRedisUsageStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		NewRedisUsageStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, n := range nodes {
+		go n.Watch(ctx)
+	}
+
+	const perNode = 200
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(2)
+		go func(n *RedisUsageStore) {
+			defer wg.Done()
+			for i := 0; i < perNode; i++ {
+				n.Increment("shared", "pool", 1, -1, 10)
+			}
+		}(n)
+		go func(n *RedisUsageStore) {
+			defer wg.Done()
+			for i := 0; i < perNode; i++ {
+				n.Get("shared", "pool")
+				n.GetAll()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	want := int64(len(nodes) * perNode)
+	for i, n := range nodes {
+		waitFor(t, func() bool {
+			got, ok := n.Get("shared", "pool")
+			return ok && got.Used == want && got.Remaining == -want && got.Cost == currency.MicroUSD(want*10)
+		}, "node %d to read every increment", i)
+	}
+}
+
+// Two daemons applying usage to projections sharing one Redis add up their
+// intents, and their reset and forecast writes do not undo them.
+func TestRedisUsageStore_ConcurrentProjections(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var projections []*engine.UsageProjection
+	for i := 0; i < 2; i++ {
+		node := NewRedisUsageStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		go node.Watch(ctx)
+		projections = append(projections, engine.NewUsageProjectionWithStore(node))
+	}
+
+	event := func(eventType store.EventType, payload map[string]interface{}) store.Event {
+		payload["provider_id"], payload["pool_id"] = "shared", "pool"
+		data, _ := json.Marshal(payload)
+		now := time.Now()
+		return store.Event{EventID: store.EventID(fmt.Sprintf("evt_%d", now.UnixNano())), EventType: eventType, TsEvent: now, TsIngest: now, Payload: data}
+	}
+	projections[0].Apply(event(store.EventTypeUsageObserved, map[string]interface{}{"used": 0, "remaining": 1000}))
+
+	const perNode = 100
+	var wg sync.WaitGroup
+	for _, p := range projections {
+		wg.Add(2)
+		go func(p *engine.UsageProjection) {
+			defer wg.Done()
+			for i := 0; i < perNode; i++ {
+				if err := p.Apply(event(store.EventTypeUsageObserved, map[string]interface{}{"used": 1, "remaining": 999, "delta": 2, "cost": 5})); err != nil {
+					t.Errorf("Apply failed: %v", err)
+				}
+			}
+		}(p)
+		go func(p *engine.UsageProjection) {
+			defer wg.Done()
+			for i := 0; i < perNode; i++ {
+				p.Apply(event(store.EventTypeResetObserved, map[string]interface{}{"reset_at": time.Now().Add(time.Hour)}))
+				p.Apply(event(store.EventTypeForecastComputed, map[string]interface{}{"forecast": forecast.Forecast{}}))
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	want := int64(len(projections) * perNode * 2)
+	for i, p := range projections {
+		waitFor(t, func() bool {
+			got, ok := p.GetPoolState("shared", "pool")
+			return ok && got.Used == want && got.Remaining == 1000-want && got.Cost == currency.MicroUSD(want/2*5)
+		}, "projection %d to read every intent", i)
+	}
+}
+
+// A node's cached read is invalidated by the writes of the other.
+func TestRedisUsageStore_CacheInvalidation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	a := NewRedisUsageStore(client)
+	b := NewRedisUsageStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Watch(ctx)
+	waitFor(t, func() bool { return len(mr.PubSubChannels("")) == 1 }, "b to subscribe")
+
+	a.Set(engine.PoolState{ProviderID: "p", PoolID: "pool", Used: 1})
+	waitFor(t, func() bool { got, _ := b.Get("p", "pool"); return got.Used == 1 }, "b to read the pool")
+
+	// A change that is not announced is not seen: b serves its cache
+	client.HSet(ctx, a.makeKey("p", "pool"), "used", "5")
+	if got, _ := b.Get("p", "pool"); got.Used != 1 {
+		t.Errorf("expected b to serve its cached state, got %+v", got)
+	}
+
+	a.Increment("p", "pool", 2, 0, 0)
+	waitFor(t, func() bool { got, _ := b.Get("p", "pool"); return got.Used == 7 }, "b to see the increment")
+
+	a.Clear()
+	waitFor(t, func() bool { _, ok := b.Get("p", "pool"); return !ok }, "b to see the clear")
+
+	// Without the subscription, b reads Redis directly
+	cancel()
+	waitFor(t, func() bool { return len(mr.PubSubChannels("")) == 0 }, "b to unsubscribe")
+	client.HSet(context.Background(), a.makeKey("p", "pool"), "used", "9")
+	waitFor(t, func() bool { got, _ := b.Get("p", "pool"); return got.Used == 9 }, "b to read Redis")
+}
+
+func waitFor(t *testing.T, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for "+format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+)
+
+// SnapshotPrefix is the blob key prefix under which exported snapshots live.
+const SnapshotPrefix = "snapshots/"
+
+// SnapshotExport is a snapshot as stored in a blob store. It carries its
+// checkpoint event so that it can be imported into a store that does not
+// hold the event log it was taken from.
+type SnapshotExport struct {
+	Snapshot   *Snapshot `json:"snapshot"`
+	Checkpoint *Event    `json:"checkpoint"`
+}
+
+// ExportedSnapshot identifies a snapshot in a blob store.
+type ExportedSnapshot struct {
+	Key        string
+	SnapshotID string
+	TsSnapshot time.Time
+}
+
+// SnapshotKey returns the key of an exported snapshot:
+// snapshots/YYYY/MM/DD/<ts>_<id>.json.gz, where ts is in Unix nanoseconds.
+func SnapshotKey(snap *Snapshot) string {
+	ts := snap.TsSnapshot.UTC()
+	year, month, day := ts.Date()
+	return fmt.Sprintf("%s%04d/%02d/%02d/%d_%s.json.gz",
+		SnapshotPrefix, year, month, day, ts.UnixNano(), snap.SnapshotID)
+}
+
+// ParseSnapshotKey parses a key produced by SnapshotKey.
+func ParseSnapshotKey(key string) (ExportedSnapshot, bool) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	base := path.Base(key)
+	if !strings.HasPrefix(key, SnapshotPrefix) || !strings.HasSuffix(base, ".json.gz") {
+		return ExportedSnapshot{}, false
+	}
+	parts := strings.SplitN(strings.TrimSuffix(base, ".json.gz"), "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return ExportedSnapshot{}, false
+	}
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return ExportedSnapshot{}, false
+	}
+	return ExportedSnapshot{Key: key, SnapshotID: parts[1], TsSnapshot: time.Unix(0, ts).UTC()}, true
+}
+
+// ListExportedSnapshots returns the snapshots in b, newest first.
+func ListExportedSnapshots(ctx context.Context, b blob.BlobStore) ([]ExportedSnapshot, error) {
+	keys, err := b.List(ctx, SnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	var snaps []ExportedSnapshot
+	for _, key := range keys {
+		if snap, ok := ParseSnapshotKey(key); ok {
+			snaps = append(snaps, snap)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].TsSnapshot.After(snaps[j].TsSnapshot)
+	})
+	return snaps, nil
+}
+
+// PutSnapshotExport writes exp to b and returns its key.
+func PutSnapshotExport(ctx context.Context, b blob.BlobStore, exp *SnapshotExport) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(exp); err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	key := SnapshotKey(exp.Snapshot)
+	if err := b.Put(ctx, key, &buf); err != nil {
+		return "", fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	return key, nil
+}
+
+// GetSnapshotExport reads the exported snapshot at key.
+func GetSnapshotExport(ctx context.Context, b blob.BlobStore, key string) (*SnapshotExport, error) {
+	rc, err := b.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot %s: %w", key, err)
+	}
+	defer gz.Close()
+
+	var exp SnapshotExport
+	if err := json.NewDecoder(gz).Decode(&exp); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %s: %w", key, err)
+	}
+	if exp.Snapshot == nil || exp.Checkpoint == nil || exp.Checkpoint.EventID != exp.Snapshot.LastEventID {
+		return nil, fmt.Errorf("snapshot %s is incomplete", key)
+	}
+	return &exp, nil
+}
+
+// ExportSnapshot loads snapshotID and its checkpoint event from st and
+// writes them to b. It returns the blob key.
+func ExportSnapshot(ctx context.Context, st EventStore, b blob.BlobStore, snapshotID string) (string, error) {
+	snap, err := st.GetSnapshot(ctx, snapshotID)
+	if err != nil {
+		return "", err
+	}
+	if snap == nil {
+		return "", fmt.Errorf("snapshot %s not found", snapshotID)
+	}
+	checkpoint, err := st.GetEvent(ctx, snap.LastEventID)
+	if err != nil {
+		return "", err
+	}
+	if checkpoint == nil {
+		return "", fmt.Errorf("checkpoint event %s of snapshot %s not found", snap.LastEventID, snapshotID)
+	}
+	return PutSnapshotExport(ctx, b, &SnapshotExport{Snapshot: snap, Checkpoint: checkpoint})
+}
+
+// ImportSnapshot saves an exported snapshot into dst. The checkpoint event
+// is appended first unless dst already holds it, so that the daemon can
+// start from the snapshot without the rest of the log. Importing a snapshot
+// that dst already has is a no-op.
+func ImportSnapshot(ctx context.Context, dst EventStore, exp *SnapshotExport) error {
+	existing, err := dst.GetSnapshot(ctx, exp.Snapshot.SnapshotID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	checkpoint, err := dst.GetEvent(ctx, exp.Checkpoint.EventID)
+	if err != nil {
+		return err
+	}
+	if checkpoint == nil {
+		if err := dst.AppendEvent(ctx, exp.Checkpoint); err != nil {
+			return fmt.Errorf("failed to import checkpoint event: %w", err)
+		}
+	}
+	return dst.SaveSnapshot(ctx, exp.Snapshot)
+}
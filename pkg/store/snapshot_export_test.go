@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/blob"
+)
+
+func TestSnapshotKey_RoundTrip(t *testing.T) {
+	ts := time.Date(2025, 3, 1, 23, 59, 30, 123456789, time.UTC)
+	key := SnapshotKey(&Snapshot{SnapshotID: "snap_1", TsSnapshot: ts})
+	exp, ok := ParseSnapshotKey(key)
+	if !ok || exp.SnapshotID != "snap_1" || !exp.TsSnapshot.Equal(ts) || exp.Key != key {
+		t.Errorf("unexpected parse of %s: %+v (ok %v)", key, exp, ok)
+	}
+	for _, bad := range []string{"snapshots/2025/03/01/notes.txt", "events/2025/03/01/1_snap.json.gz", "snapshots/x_snap.json.gz", "snapshots/1_.json.gz"} {
+		if _, ok := ParseSnapshotKey(bad); ok {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestExportImportSnapshot(t *testing.T) {
+	ctx := context.Background()
+	src := openBatchTestStore(t)
+	defer src.Close()
+	blobs := blob.NewLocalBlobStore(t.TempDir())
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"evt_0", "evt_1"} {
+		if err := src.AppendEvent(ctx, archiveTestEvent(id, base.Add(time.Duration(i)*time.Hour))); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+	for i, id := range []string{"snap_a", "snap_b"} {
+		if err := src.SaveSnapshot(ctx, &Snapshot{
+			SnapshotID: id, SchemaVersion: 1, TsSnapshot: base.Add(time.Duration(i+1) * time.Hour),
+			LastEventID: EventID(fmt.Sprintf("evt_%d", i)), Payload: json.RawMessage(`{"n":1}`),
+		}); err != nil {
+			t.Fatalf("SaveSnapshot failed: %v", err)
+		}
+		if _, err := ExportSnapshot(ctx, src, blobs, id); err != nil {
+			t.Fatalf("ExportSnapshot failed: %v", err)
+		}
+	}
+	if _, err := ExportSnapshot(ctx, src, blobs, "missing"); err == nil {
+		t.Error("expected exporting an unknown snapshot to fail")
+	}
+
+	exported, err := ListExportedSnapshots(ctx, blobs)
+	if err != nil {
+		t.Fatalf("ListExportedSnapshots failed: %v", err)
+	}
+	if len(exported) != 2 || exported[0].SnapshotID != "snap_b" {
+		t.Fatalf("expected snap_b first, got %+v", exported)
+	}
+	exp, err := GetSnapshotExport(ctx, blobs, exported[0].Key)
+	if err != nil {
+		t.Fatalf("GetSnapshotExport failed: %v", err)
+	}
+
+	// Importing twice into an empty store adds the checkpoint and snapshot once
+	dst := openBatchTestStore(t)
+	defer dst.Close()
+	for i := 0; i < 2; i++ {
+		if err := ImportSnapshot(ctx, dst, exp); err != nil {
+			t.Fatalf("ImportSnapshot failed: %v", err)
+		}
+	}
+	events, _ := dst.QueryEvents(ctx, EventFilter{})
+	if len(events) != 1 || events[0].EventID != "evt_1" || !events[0].TsIngest.Equal(base.Add(time.Hour)) {
+		t.Errorf("unexpected events after import %+v", events)
+	}
+	snap, err := dst.GetLatestSnapshot(ctx)
+	if err != nil || snap == nil || snap.SnapshotID != "snap_b" || string(snap.Payload) != `{"n":1}` {
+		t.Errorf("unexpected snapshot after import %+v (err %v)", snap, err)
+	}
+}
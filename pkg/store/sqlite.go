@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -14,13 +16,71 @@ import (
 // Store manages the SQLite connection and schema.
 type Store struct {
 	db *sql.DB
+
+	// mu serializes writes that extend the hash chain.
+	mu sync.Mutex
+
+	// fence is the lease whose epoch appends are checked against, if any.
+	fence string
 }
 
+// SyncMode is how SQLite syncs commits to disk (PRAGMA synchronous).
+type SyncMode string
+
+const (
+	// SyncFull syncs the write-ahead log on every commit, so a committed
+	// event survives a power loss.
+	SyncFull SyncMode = "full"
+	// SyncNormal syncs the write-ahead log only at checkpoints. Commits
+	// survive a crash of the daemon, but the latest ones may be lost on a
+	// power loss. Commits are faster.
+	SyncNormal SyncMode = "normal"
+)
+
 // NewStore initializes the SQLite database connection.
-// It enables WAL mode for concurrency and durability.
+// It enables WAL mode for concurrency and durability, and applies pending
+// schema migrations.
 func NewStore(dbPath string) (*Store, error) {
-	// Open the database
-	db, err := sql.Open("sqlite3", dbPath)
+	return NewStoreWithSync(dbPath, SyncFull)
+}
+
+// NewStoreWithSync is NewStore with commits synced to disk as set by mode.
+func NewStoreWithSync(dbPath string, mode SyncMode) (*Store, error) {
+	s, err := openStore(dbPath, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize schema
+	if err := s.migrate(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("schema migration failed: %w", err)
+	}
+
+	return s, nil
+}
+
+// OpenStore opens the SQLite database without applying migrations.
+// It is used by 'ratelord admin migrate' to inspect and change the schema version.
+func OpenStore(dbPath string) (*Store, error) {
+	return openStore(dbPath, SyncFull)
+}
+
+func openStore(dbPath string, mode SyncMode) (*Store, error) {
+	if mode != SyncFull && mode != SyncNormal {
+		return nil, fmt.Errorf("invalid sync mode %q", mode)
+	}
+
+	// Open the database. The sync mode is set per connection, so it goes in
+	// the DSN for every connection of the pool to apply it.
+	dsn := dbPath
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += "_sync=" + strings.ToUpper(string(mode))
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open sqlite db: %w", err)
 	}
@@ -40,14 +100,7 @@ func NewStore(dbPath string) (*Store, error) {
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
-	s := &Store{db: db}
-
-	// Initialize schema
-	if err := s.migrate(); err != nil {
-		return nil, fmt.Errorf("schema migration failed: %w", err)
-	}
-
-	return s, nil
+	return &Store{db: db}, nil
 }
 
 // Close closes the underlying database connection.
@@ -55,210 +108,80 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-// migrate creates the necessary tables if they don't exist.
-func (s *Store) migrate() error {
-	// Schema for the append-only events table
-	// We store the canonical envelope fields as columns for querying,
-	// and the full payload as a JSON blob.
-	query := `
-	CREATE TABLE IF NOT EXISTS events (
-		event_id TEXT PRIMARY KEY,
-		event_type TEXT NOT NULL,
-		schema_version INTEGER NOT NULL,
-		ts_event DATETIME NOT NULL,
-		ts_ingest DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		
-		-- Source metadata
-		origin_kind TEXT,
-		origin_id TEXT,
-		writer_id TEXT,
-
-		-- Dimensions (Mandatory)
-		agent_id TEXT NOT NULL,
-		identity_id TEXT NOT NULL,
-		workload_id TEXT NOT NULL,
-		scope_id TEXT NOT NULL,
-
-		-- Correlation
-		correlation_id TEXT,
-		causation_id TEXT,
-
-		-- Payload
-		payload JSON NOT NULL,
-
-		-- Epoch (Cluster term)
-		epoch INTEGER DEFAULT 0
-	);
-	
-	-- Index for replay by ingestion order (implicit rowid is efficient, but ingest time is explicit)
-	CREATE INDEX IF NOT EXISTS idx_events_ts_ingest ON events(ts_ingest);
-	
-	-- Index for querying by event time
-	CREATE INDEX IF NOT EXISTS idx_events_ts_event ON events(ts_event);
-	
-	-- Index for filtering by event type
-	CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type);
-	
-	-- Index for filtering by identity
-	CREATE INDEX IF NOT EXISTS idx_events_identity ON events(identity_id);
-	
-	-- Index for filtering by scope
-	CREATE INDEX IF NOT EXISTS idx_events_scope ON events(scope_id);
-	
-	-- Index for lookup by correlation (common access pattern)
-	CREATE INDEX IF NOT EXISTS idx_events_correlation ON events(correlation_id);
-	
-	-- Track background worker progress
-	CREATE TABLE IF NOT EXISTS system_state (
-		key TEXT PRIMARY KEY,
-		value TEXT NOT NULL,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	-- Hourly Aggregation
-	CREATE TABLE IF NOT EXISTS usage_hourly (
-		bucket_ts DATETIME NOT NULL,
-		provider_id TEXT NOT NULL,
-		pool_id TEXT NOT NULL,
-		identity_id TEXT NOT NULL,
-		scope_id TEXT NOT NULL,
-		
-		total_usage INTEGER NOT NULL DEFAULT 0,
-		min_usage INTEGER NOT NULL DEFAULT 0,
-		max_usage INTEGER NOT NULL DEFAULT 0,
-		event_count INTEGER NOT NULL DEFAULT 0,
-		
-		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
-	);
-
-	-- Daily Aggregation
-	CREATE TABLE IF NOT EXISTS usage_daily (
-		bucket_ts DATETIME NOT NULL,
-		provider_id TEXT NOT NULL,
-		pool_id TEXT NOT NULL,
-		identity_id TEXT NOT NULL,
-		scope_id TEXT NOT NULL,
-		
-		total_usage INTEGER NOT NULL DEFAULT 0,
-		min_usage INTEGER NOT NULL DEFAULT 0,
-		max_usage INTEGER NOT NULL DEFAULT 0,
-		event_count INTEGER NOT NULL DEFAULT 0,
-		
-		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_usage_hourly_time ON usage_hourly(bucket_ts);
-	CREATE INDEX IF NOT EXISTS idx_usage_daily_time ON usage_daily(bucket_ts);
-
-	-- Webhook Configurations
-	CREATE TABLE IF NOT EXISTS webhook_configs (
-		webhook_id TEXT PRIMARY KEY,
-		url TEXT NOT NULL,
-		secret TEXT NOT NULL,
-		events TEXT NOT NULL, -- Stored as JSON array
-		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		active BOOLEAN NOT NULL DEFAULT 1
-	);
-
-	-- Snapshots table for state persistence and faster recovery
-	CREATE TABLE IF NOT EXISTS snapshots (
-		snapshot_id TEXT PRIMARY KEY,
-		schema_version INTEGER NOT NULL DEFAULT 1,
-		ts_snapshot DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		last_event_id TEXT NOT NULL,
-		payload JSON NOT NULL,
-
-		FOREIGN KEY(last_event_id) REFERENCES events(event_id)
-	);
-
-	-- Index to quickly find the most recent snapshot for recovery
-	CREATE INDEX IF NOT EXISTS idx_snapshots_ts ON snapshots(ts_snapshot DESC);
-
-	-- Leases table for distributed locking (Leader Election)
-	CREATE TABLE IF NOT EXISTS leases (
-		name TEXT PRIMARY KEY,
-		holder_id TEXT NOT NULL,
-		expires_at DATETIME NOT NULL,
-		version INTEGER NOT NULL DEFAULT 1,
-		epoch INTEGER NOT NULL DEFAULT 0
-	);
-	`
+// Migrator returns the schema migrator for this database.
+func (s *Store) Migrator() *Migrator {
+	return NewMigrator(s.db, DialectSQLite, sqliteMigrations)
+}
 
-	if _, err := s.db.Exec(query); err != nil {
-		return fmt.Errorf("failed to create events table: %w", err)
+// migrate applies pending migrations. It refuses to run against a database
+// written by a newer release or with modified migrations.
+func (s *Store) migrate() error {
+	ctx := context.Background()
+
+	// Databases created before versioned migrations have tables but no
+	// schema_migrations. Bring them to the initial schema, which is then
+	// recorded without changes (its statements are idempotent).
+	var legacy int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'events'
+		AND NOT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')
+	`).Scan(&legacy)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if legacy > 0 {
+		// Ignore errors (duplicate column)
+		s.db.Exec("ALTER TABLE events ADD COLUMN epoch INTEGER DEFAULT 0;")
+		s.db.Exec("ALTER TABLE leases ADD COLUMN epoch INTEGER DEFAULT 0;")
 	}
 
-	// Migrations for existing tables
-	// Ignore errors (duplicate column)
-	s.db.Exec("ALTER TABLE events ADD COLUMN epoch INTEGER DEFAULT 0;")
-	s.db.Exec("ALTER TABLE leases ADD COLUMN epoch INTEGER DEFAULT 0;")
-
-	return nil
+	_, err = s.Migrator().Up(ctx, 0)
+	return err
 }
 
 // AppendEvent writes a single event to the database.
-// It is an append-only operation.
+// It is an append-only operation: the event is assigned the next position
+// of the hash chain. Defaults (TsIngest, empty payload) are written back to evt.
 func (s *Store) AppendEvent(ctx context.Context, evt *Event) error {
-	query := `
-	INSERT INTO events (
-		event_id,
-		event_type,
-		schema_version,
-		ts_event,
-		ts_ingest,
-		origin_kind,
-		origin_id,
-		writer_id,
-		agent_id,
-		identity_id,
-		workload_id,
-		scope_id,
-		correlation_id,
-		causation_id,
-		payload,
-		epoch
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
-	`
-
-	// Ensure ts_ingest is set. If zero, default to current time.
-	// Although the DB has a default, setting it explicitly ensures
-	// the struct in memory matches what's persisted if we continue to use it.
-	tsIngest := evt.TsIngest
-	if tsIngest.IsZero() {
-		tsIngest = time.Now().UTC()
-	}
+	return s.AppendEvents(ctx, []*Event{evt})
+}
 
-	// Payload is already []byte (json.RawMessage), so we can store it directly.
-	// If it's nil, we should probably store empty JSON object "{}" or null depending on requirements.
-	// The schema says JSON NOT NULL, so let's default to "{}" if nil/empty.
-	payload := evt.Payload
-	if len(payload) == 0 {
-		payload = []byte("{}")
+// AppendEvents writes events in order within a single transaction, so that
+// the whole batch costs one commit. Either all events are stored or none.
+func (s *Store) AppendEvents(ctx context.Context, events []*Event) error {
+	if len(events) == 0 {
+		return nil
 	}
 
-	_, err := s.db.ExecContext(ctx, query,
-		evt.EventID,
-		evt.EventType,
-		evt.SchemaVersion,
-		evt.TsEvent,
-		tsIngest,
-		evt.Source.OriginKind,
-		evt.Source.OriginID,
-		evt.Source.WriterID,
-		evt.Dimensions.AgentID,
-		evt.Dimensions.IdentityID,
-		evt.Dimensions.WorkloadID,
-		evt.Dimensions.ScopeID,
-		evt.Correlation.CorrelationID,
-		evt.Correlation.CausationID,
-		payload,
-		evt.Epoch,
-	)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to append event %s: %w", evt.EventID, err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
+	for _, evt := range events {
+		if err := s.appendTx(ctx, tx, evt); err != nil {
+			return err
+		}
+	}
+	if s.fence != "" {
+		// The appends took the write lock, so no election can complete
+		// between this check and the commit
+		var epoch int64
+		err := tx.QueryRowContext(ctx, `SELECT epoch FROM leases WHERE name = ?`, s.fence).Scan(&epoch)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to read lease epoch: %w", err)
+		}
+		if err := CheckEpochs(events, epoch); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %d events: %w", len(events), err)
+	}
 	return nil
 }
 
@@ -456,17 +379,40 @@ func (s *Store) QueryEvents(ctx context.Context, filter EventFilter) ([]*Event,
 		}
 	}
 
-	if filter.IdentityID != "" {
-		query += " AND identity_id = ?"
-		args = append(args, filter.IdentityID)
+	for _, eq := range []struct {
+		column string
+		value  string
+	}{
+		{"identity_id", filter.IdentityID},
+		{"scope_id", filter.ScopeID},
+		{"provider_id", filter.ProviderID},
+		{"pool_id", filter.PoolID},
+		{"correlation_id", filter.CorrelationID},
+		{"causation_id", filter.CausationID},
+	} {
+		if eq.value != "" {
+			query += " AND " + eq.column + " = ?"
+			args = append(args, eq.value)
+		}
 	}
 
-	if filter.ScopeID != "" {
-		query += " AND scope_id = ?"
-		args = append(args, filter.ScopeID)
+	if filter.Epoch != nil {
+		query += " AND epoch = ?"
+		args = append(args, *filter.Epoch)
 	}
 
-	query += " ORDER BY ts_event ASC"
+	order, cmp := "ASC", ">"
+	if filter.Descending {
+		order, cmp = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		// Row-value comparison lets SQLite seek idx_events_cursor directly.
+		query += fmt.Sprintf(" AND (ts_ingest, event_id) %s (?, ?)", cmp)
+		args = append(args, filter.After.TsIngest.UTC(), filter.After.EventID)
+	}
+
+	query += fmt.Sprintf(" ORDER BY ts_ingest %s, event_id %s", order, order)
 
 	limit := filter.Limit
 	if limit == 0 {
@@ -549,6 +495,9 @@ func (s *Store) SetSystemState(ctx context.Context, key, value string) error {
 	return nil
 }
 
+// UpsertUsageStats merges stats into the table of their bucket (see
+// UsageStatTable): totals and counts add up, min and max widen. The stats of
+// an event already recorded in usage_stat_events are skipped.
 func (s *Store) UpsertUsageStats(ctx context.Context, stats []UsageStat) error {
 	if len(stats) == 0 {
 		return nil
@@ -560,34 +509,60 @@ func (s *Store) UpsertUsageStats(ctx context.Context, stats []UsageStat) error {
 	}
 	defer tx.Rollback()
 
+	applied := make(map[EventID]bool)
 	for _, stat := range stats {
-		var table string
-		if stat.BucketTs.Minute() == 0 && stat.BucketTs.Second() == 0 {
-			if stat.BucketTs.Hour() == 0 {
-				table = "usage_daily"
-			} else {
-				table = "usage_hourly"
+		table, err := UsageStatTable(stat)
+		if err != nil {
+			return err
+		}
+
+		if stat.EventID != "" {
+			apply, seen := applied[stat.EventID]
+			if !seen {
+				res, err := tx.ExecContext(ctx, `
+					INSERT INTO usage_stat_events (event_id, bucket_ts) VALUES (?, ?)
+					ON CONFLICT (event_id) DO NOTHING;
+				`, stat.EventID, stat.BucketTs)
+				if err != nil {
+					return fmt.Errorf("failed to record usage stat event: %w", err)
+				}
+				n, err := res.RowsAffected()
+				if err != nil {
+					return fmt.Errorf("failed to record usage stat event: %w", err)
+				}
+				apply = n == 1
+				applied[stat.EventID] = apply
+			}
+			if !apply {
+				continue
 			}
-		} else {
-			return fmt.Errorf("invalid bucket_ts for stat: must be at top of hour or day")
 		}
 
 		query := fmt.Sprintf(`
 			INSERT INTO %s (
 				bucket_ts, provider_id, pool_id, identity_id, scope_id,
-				total_usage, min_usage, max_usage, event_count
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				total_usage, min_usage, max_usage, event_count,
+				total_cost, approved_count, denied_count, shaped_count
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			ON CONFLICT (bucket_ts, provider_id, pool_id, identity_id, scope_id)
 			DO UPDATE SET
 				total_usage = total_usage + excluded.total_usage,
-				min_usage = MIN(min_usage, excluded.min_usage),
+				min_usage = CASE
+					WHEN event_count = 0 THEN excluded.min_usage
+					WHEN excluded.event_count = 0 THEN min_usage
+					ELSE MIN(min_usage, excluded.min_usage) END,
 				max_usage = MAX(max_usage, excluded.max_usage),
-				event_count = event_count + excluded.event_count;
+				event_count = event_count + excluded.event_count,
+				total_cost = total_cost + excluded.total_cost,
+				approved_count = approved_count + excluded.approved_count,
+				denied_count = denied_count + excluded.denied_count,
+				shaped_count = shaped_count + excluded.shaped_count;
 		`, table)
 
-		_, err := tx.ExecContext(ctx, query,
+		_, err = tx.ExecContext(ctx, query,
 			stat.BucketTs, stat.ProviderID, stat.PoolID, stat.IdentityID, stat.ScopeID,
 			stat.TotalUsage, stat.MinUsage, stat.MaxUsage, stat.EventCount,
+			stat.TotalCost, stat.ApprovedCount, stat.DeniedCount, stat.ShapedCount,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to upsert usage stat: %w", err)
@@ -603,16 +578,19 @@ func (s *Store) UpsertUsageStats(ctx context.Context, stats []UsageStat) error {
 
 // GetUsageStats retrieves usage statistics based on the provided filter.
 func (s *Store) GetUsageStats(ctx context.Context, filter UsageFilter) ([]UsageStat, error) {
-	var table string
-	if filter.Bucket == "day" {
-		table = "usage_daily"
-	} else {
-		table = "usage_hourly"
+	bucket := filter.Bucket
+	if bucket == "" {
+		bucket = BucketHour
+	}
+	table, ok := UsageTable(bucket)
+	if !ok {
+		return nil, fmt.Errorf("invalid bucket %q", filter.Bucket)
 	}
 
 	query := fmt.Sprintf(`
 		SELECT bucket_ts, provider_id, pool_id, identity_id, scope_id,
-		       total_usage, min_usage, max_usage, event_count
+		       total_usage, min_usage, max_usage, event_count,
+		       total_cost, approved_count, denied_count, shaped_count
 		FROM %s
 		WHERE bucket_ts >= ? AND bucket_ts < ?
 	`, table)
@@ -646,7 +624,7 @@ func (s *Store) GetUsageStats(ctx context.Context, filter UsageFilter) ([]UsageS
 
 	var stats []UsageStat
 	for rows.Next() {
-		var stat UsageStat
+		stat := UsageStat{Bucket: bucket}
 		err := rows.Scan(
 			&stat.BucketTs,
 			&stat.ProviderID,
@@ -657,6 +635,10 @@ func (s *Store) GetUsageStats(ctx context.Context, filter UsageFilter) ([]UsageS
 			&stat.MinUsage,
 			&stat.MaxUsage,
 			&stat.EventCount,
+			&stat.TotalCost,
+			&stat.ApprovedCount,
+			&stat.DeniedCount,
+			&stat.ShapedCount,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan usage stat row: %w", err)
@@ -671,6 +653,28 @@ func (s *Store) GetUsageStats(ctx context.Context, filter UsageFilter) ([]UsageS
 	return stats, nil
 }
 
+// PruneUsageStats deletes the rollups of bucket older than before.
+func (s *Store) PruneUsageStats(ctx context.Context, bucket string, before time.Time) (int64, error) {
+	table, ok := UsageTable(bucket)
+	if !ok {
+		return 0, fmt.Errorf("invalid bucket %q", bucket)
+	}
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE bucket_ts < ?", table), before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune usage stats: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// PruneUsageStatEvents forgets the applied events older than before.
+func (s *Store) PruneUsageStatEvents(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM usage_stat_events WHERE bucket_ts < ?", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune usage stat events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
 // RegisterWebhook creates a new webhook configuration.
 func (s *Store) RegisterWebhook(ctx context.Context, cfg *WebhookConfig) error {
 	query := `
@@ -796,6 +800,73 @@ func (s *Store) GetLatestSnapshotTime(ctx context.Context) (time.Time, error) {
 	return ts, nil
 }
 
+// ListSnapshots returns all snapshots, newest first, without their payloads.
+func (s *Store) ListSnapshots(ctx context.Context) ([]*Snapshot, error) {
+	query := `
+		SELECT snapshot_id, schema_version, ts_snapshot, last_event_id
+		FROM snapshots
+		ORDER BY ts_snapshot DESC;
+		`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snaps []*Snapshot
+	for rows.Next() {
+		var snap Snapshot
+		if err := rows.Scan(&snap.SnapshotID, &snap.SchemaVersion, &snap.TsSnapshot, &snap.LastEventID); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		snaps = append(snaps, &snap)
+	}
+	return snaps, rows.Err()
+}
+
+// GetSnapshot retrieves a snapshot by ID.
+// Returns nil, nil if it does not exist.
+func (s *Store) GetSnapshot(ctx context.Context, snapshotID string) (*Snapshot, error) {
+	query := `
+		SELECT snapshot_id, schema_version, ts_snapshot, last_event_id, payload
+		FROM snapshots
+		WHERE snapshot_id = ?;
+		`
+	var snap Snapshot
+	err := s.db.QueryRowContext(ctx, query, snapshotID).Scan(
+		&snap.SnapshotID,
+		&snap.SchemaVersion,
+		&snap.TsSnapshot,
+		&snap.LastEventID,
+		&snap.Payload,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get snapshot %s: %w", snapshotID, err)
+	}
+	return &snap, nil
+}
+
+// DeleteSnapshots removes the given snapshots. Unknown IDs are ignored.
+func (s *Store) DeleteSnapshots(ctx context.Context, snapshotIDs []string) error {
+	if len(snapshotIDs) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(snapshotIDs))
+	args := make([]interface{}, len(snapshotIDs))
+	for i, id := range snapshotIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	query := fmt.Sprintf(`DELETE FROM snapshots WHERE snapshot_id IN (%s)`, strings.Join(placeholders, ","))
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete snapshots: %w", err)
+	}
+	return nil
+}
+
 // GetEvent retrieves a single event by ID.
 func (s *Store) GetEvent(ctx context.Context, eventID EventID) (*Event, error) {
 	query := `
@@ -880,11 +951,11 @@ func (s *Store) PruneEvents(ctx context.Context, retention time.Duration, includ
 		cutoffTime = snapEvent.TsIngest
 	}
 
-	query := `DELETE FROM events WHERE ts_ingest < ?`
+	where := `ts_ingest < ?`
 	args := []interface{}{cutoffTime}
 
 	if includeType != "" {
-		query += " AND event_type = ?"
+		where += " AND event_type = ?"
 		args = append(args, includeType)
 	} else if len(excludeTypes) > 0 {
 		placeholders := make([]string, len(excludeTypes))
@@ -892,17 +963,24 @@ func (s *Store) PruneEvents(ctx context.Context, retention time.Duration, includ
 			placeholders[i] = "?"
 			args = append(args, t)
 		}
-		query += fmt.Sprintf(" AND event_type NOT IN (%s)", strings.Join(placeholders, ","))
+		where += fmt.Sprintf(" AND event_type NOT IN (%s)", strings.Join(placeholders, ","))
 	}
 
-	res, err := s.db.ExecContext(ctx, query, args...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to prune events: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rows, err := res.RowsAffected()
+	rows, err := s.tombstoneTx(ctx, tx, TombstonePruned, where, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return rows, nil
@@ -982,11 +1060,15 @@ func (s *Store) ReadCandidateEvents(ctx context.Context, before time.Time, limit
 
 // DeleteIdentityData performs a hard delete of all data associated with a specific identity.
 // This includes events and usage statistics. The operation is performed in a transaction.
+// Deleted events leave tombstones so that the hash chain remains verifiable.
 func (s *Store) DeleteIdentityData(ctx context.Context, identityID string) error {
 	if identityID == "" {
 		return fmt.Errorf("identityID cannot be empty")
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -994,11 +1076,17 @@ func (s *Store) DeleteIdentityData(ctx context.Context, identityID string) error
 	defer tx.Rollback()
 
 	// Delete from events table
-	_, err = tx.ExecContext(ctx, "DELETE FROM events WHERE identity_id = ?", identityID)
+	_, err = s.tombstoneTx(ctx, tx, TombstoneIdentityDeleted, "identity_id = ?", identityID)
 	if err != nil {
 		return fmt.Errorf("failed to delete events for identity %s: %w", identityID, err)
 	}
 
+	// Delete from usage_minutely table
+	_, err = tx.ExecContext(ctx, "DELETE FROM usage_minutely WHERE identity_id = ?", identityID)
+	if err != nil {
+		return fmt.Errorf("failed to delete minutely usage for identity %s: %w", identityID, err)
+	}
+
 	// Delete from usage_hourly table
 	_, err = tx.ExecContext(ctx, "DELETE FROM usage_hourly WHERE identity_id = ?", identityID)
 	if err != nil {
@@ -1018,7 +1106,8 @@ func (s *Store) DeleteIdentityData(ctx context.Context, identityID string) error
 	return nil
 }
 
-// DeleteEvents deletes events by their IDs.
+// DeleteEvents deletes events by their IDs, typically after they have been
+// archived. Deleted events leave tombstones in the hash chain.
 func (s *Store) DeleteEvents(ctx context.Context, eventIDs []string) error {
 	if len(eventIDs) == 0 {
 		return nil
@@ -1031,12 +1120,27 @@ func (s *Store) DeleteEvents(ctx context.Context, eventIDs []string) error {
 		args[i] = id
 	}
 
-	query := fmt.Sprintf("DELETE FROM events WHERE event_id IN (%s)", strings.Join(placeholders, ","))
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	_, err := s.db.ExecContext(ctx, query, args...)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where := fmt.Sprintf("event_id IN (%s)", strings.Join(placeholders, ","))
+	if _, err := s.tombstoneTx(ctx, tx, TombstoneArchived, where, args...); err != nil {
 		return fmt.Errorf("failed to delete events: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
 	return nil
 }
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tombstoneBatch bounds the number of events removed per statement.
+const tombstoneBatch = 1000
+
+// eventColumnList is the canonical envelope, in the order scanned by scanChainRow.
+const eventColumnList = `event_id, event_type, schema_version, ts_event, ts_ingest,
+		origin_kind, origin_id, writer_id,
+		agent_id, identity_id, workload_id, scope_id,
+		correlation_id, causation_id, payload, epoch`
+
+// appendTx chains evt to the current head and inserts it within tx.
+// Claiming the next position first takes SQLite's write lock, so a
+// concurrent writer cannot read the same head.
+func (s *Store) appendTx(ctx context.Context, tx *sql.Tx, evt *Event) error {
+	PrepareChainEvent(evt)
+
+	var seq int64
+	var prevHash string
+	err := tx.QueryRowContext(ctx, `UPDATE chain_head SET seq = seq + 1 WHERE id = 1 RETURNING seq, hash`).Scan(&seq, &prevHash)
+	if err != nil {
+		return fmt.Errorf("failed to advance chain head: %w", err)
+	}
+	hash := ChainLinkHash(seq, prevHash, ChainContentHash(evt))
+
+	query := `
+	INSERT INTO events (
+		event_id,
+		event_type,
+		schema_version,
+		ts_event,
+		ts_ingest,
+		origin_kind,
+		origin_id,
+		writer_id,
+		agent_id,
+		identity_id,
+		workload_id,
+		scope_id,
+		correlation_id,
+		causation_id,
+		payload,
+		epoch,
+		provider_id,
+		pool_id,
+		seq,
+		prev_hash,
+		hash
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`
+	providerID, poolID := PayloadProviderPool(evt.Payload)
+
+	_, err = tx.ExecContext(ctx, query,
+		evt.EventID,
+		evt.EventType,
+		evt.SchemaVersion,
+		evt.TsEvent,
+		evt.TsIngest,
+		evt.Source.OriginKind,
+		evt.Source.OriginID,
+		evt.Source.WriterID,
+		evt.Dimensions.AgentID,
+		evt.Dimensions.IdentityID,
+		evt.Dimensions.WorkloadID,
+		evt.Dimensions.ScopeID,
+		evt.Correlation.CorrelationID,
+		evt.Correlation.CausationID,
+		[]byte(evt.Payload),
+		evt.Epoch,
+		nullString(providerID),
+		nullString(poolID),
+		seq,
+		prevHash,
+		hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append event %s: %w", evt.EventID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE chain_head SET hash = ? WHERE id = 1`, hash); err != nil {
+		return fmt.Errorf("failed to update chain head: %w", err)
+	}
+	return nil
+}
+
+// tombstoneTx deletes the events matching where, leaving a tombstone for
+// every chained one, and appends the events_tombstoned event recording the
+// removal. Returns the number of deleted events.
+func (s *Store) tombstoneTx(ctx context.Context, tx *sql.Tx, reason, where string, args ...interface{}) (int64, error) {
+	tomb := NewTombstoneEvent(reason, 0, 0, 0)
+	payload := TombstonePayload{Reason: reason}
+	now := time.Now().UTC()
+
+	var deleted int64
+	for {
+		query := fmt.Sprintf(`SELECT %s, seq, prev_hash, hash FROM events WHERE %s LIMIT %d`, eventColumnList, where, tombstoneBatch)
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to select events: %w", err)
+		}
+		links, err := scanChainRows(rows)
+		if err != nil {
+			return 0, err
+		}
+		if len(links) == 0 {
+			break
+		}
+
+		ids := make([]interface{}, len(links))
+		placeholders := make([]string, len(links))
+		for i, link := range links {
+			ids[i] = link.EventID
+			placeholders[i] = "?"
+			if link.Seq == 0 {
+				// Written before the chain existed; nothing to account for.
+				continue
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO event_tombstones (seq, event_id, event_type, prev_hash, content_hash, hash, reason, tombstone_event_id, deleted_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				link.Seq, link.EventID, link.EventType, link.PrevHash, ChainContentHash(link.Event), link.Hash, reason, tomb.EventID, now,
+			)
+			if err != nil {
+				return 0, fmt.Errorf("failed to record tombstone for %s: %w", link.EventID, err)
+			}
+			payload.Count++
+			if payload.FirstSeq == 0 || link.Seq < payload.FirstSeq {
+				payload.FirstSeq = link.Seq
+			}
+			payload.LastSeq = max(payload.LastSeq, link.Seq)
+		}
+
+		query = fmt.Sprintf("DELETE FROM events WHERE event_id IN (%s)", strings.Join(placeholders, ","))
+		if _, err := tx.ExecContext(ctx, query, ids...); err != nil {
+			return 0, fmt.Errorf("failed to delete events: %w", err)
+		}
+		deleted += int64(len(links))
+	}
+
+	if payload.Count > 0 {
+		tomb.Payload, _ = json.Marshal(payload)
+		if err := s.appendTx(ctx, tx, tomb); err != nil {
+			return 0, err
+		}
+	}
+	return deleted, nil
+}
+
+// ChainHead returns the current head of the hash chain.
+func (s *Store) ChainHead(ctx context.Context) (ChainHead, error) {
+	var head ChainHead
+	err := s.db.QueryRowContext(ctx, `SELECT seq, hash FROM chain_head WHERE id = 1`).Scan(&head.Seq, &head.Hash)
+	if err != nil {
+		return ChainHead{}, fmt.Errorf("failed to read chain head: %w", err)
+	}
+	return head, nil
+}
+
+// ReadChain returns up to limit chain links after afterSeq, merging live
+// events with the tombstones of removed ones.
+func (s *Store) ReadChain(ctx context.Context, afterSeq int64, limit int) ([]ChainLink, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, seq, prev_hash, hash FROM events
+		WHERE seq > ? ORDER BY seq LIMIT ?`, eventColumnList), afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chain: %w", err)
+	}
+	links, err := scanChainRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT seq, event_id, event_type, prev_hash, content_hash, hash, reason, tombstone_event_id
+		FROM event_tombstones
+		WHERE seq > ? ORDER BY seq LIMIT ?`, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tombstones: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link ChainLink
+		if err := rows.Scan(&link.Seq, &link.EventID, &link.EventType, &link.PrevHash, &link.ContentHash, &link.Hash, &link.TombstoneReason, &link.TombstoneEventID); err != nil {
+			return nil, fmt.Errorf("failed to scan tombstone row: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return mergeChainLinks(links, limit), nil
+}
+
+// scanChainRows reads rows of eventColumnList followed by seq, prev_hash
+// and hash, and closes rows. Unchained events have a zero Seq.
+func scanChainRows(rows *sql.Rows) ([]ChainLink, error) {
+	defer rows.Close()
+
+	var links []ChainLink
+	for rows.Next() {
+		var evt Event
+		var payload []byte
+		var seq sql.NullInt64
+		var prevHash, hash sql.NullString
+
+		err := rows.Scan(
+			&evt.EventID,
+			&evt.EventType,
+			&evt.SchemaVersion,
+			&evt.TsEvent,
+			&evt.TsIngest,
+			&evt.Source.OriginKind,
+			&evt.Source.OriginID,
+			&evt.Source.WriterID,
+			&evt.Dimensions.AgentID,
+			&evt.Dimensions.IdentityID,
+			&evt.Dimensions.WorkloadID,
+			&evt.Dimensions.ScopeID,
+			&evt.Correlation.CorrelationID,
+			&evt.Correlation.CausationID,
+			&payload,
+			&evt.Epoch,
+			&seq,
+			&prevHash,
+			&hash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		evt.Payload = json.RawMessage(payload)
+
+		links = append(links, ChainLink{
+			Seq:       seq.Int64,
+			EventID:   evt.EventID,
+			EventType: evt.EventType,
+			PrevHash:  prevHash.String,
+			Hash:      hash.String,
+			Event:     &evt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return links, nil
+}
+
+// mergeChainLinks orders links by sequence and keeps the first limit.
+func mergeChainLinks(links []ChainLink, limit int) []ChainLink {
+	sort.Slice(links, func(i, j int) bool { return links[i].Seq < links[j].Seq })
+	if len(links) > limit {
+		links = links[:limit]
+	}
+	return links
+}
@@ -81,11 +81,13 @@ func (s *Store) Renew(ctx context.Context, name, holderID string, ttl time.Durat
 
 // Release releases the lease if held by holderID.
 func (s *Store) Release(ctx context.Context, name, holderID string) error {
-	// We can delete the row, or just set expires_at to 0 (or past).
-	// Deleting is cleaner for "no leader".
+	// The row is kept, expired and without holder, so that the epoch keeps
+	// growing across releases and fences the appends of the former holder.
 	_, err := s.db.ExecContext(ctx, `
-		DELETE FROM leases WHERE name = ? AND holder_id = ?
-	`, name, holderID)
+		UPDATE leases
+		SET holder_id = '', expires_at = ?, version = version + 1
+		WHERE name = ? AND holder_id = ?
+	`, time.Time{}, name, holderID)
 
 	if err != nil {
 		return fmt.Errorf("failed to release lease: %w", err)
@@ -99,7 +101,7 @@ func (s *Store) Get(ctx context.Context, name string) (*Lease, error) {
 	var l Lease
 	err := s.db.QueryRowContext(ctx, `
 		SELECT name, holder_id, expires_at, version, epoch
-		FROM leases WHERE name = ?
+		FROM leases WHERE name = ? AND holder_id != ''
 	`, name).Scan(&l.Name, &l.HolderID, &l.ExpiresAt, &l.Version, &l.Epoch)
 
 	if err != nil {
@@ -111,3 +113,22 @@ func (s *Store) Get(ctx context.Context, name string) (*Lease, error) {
 
 	return &l, nil
 }
+
+// SetFence makes appends reject events whose epoch is lower than that of the
+// lease name. The check runs within the append transaction.
+func (s *Store) SetFence(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fence = name
+}
+
+// LeaseEpoch returns the epoch of the lease, held or not; zero if it was
+// never acquired.
+func (s *Store) LeaseEpoch(ctx context.Context, name string) (int64, error) {
+	var epoch int64
+	err := s.db.QueryRowContext(ctx, `SELECT epoch FROM leases WHERE name = ?`, name).Scan(&epoch)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get lease epoch: %w", err)
+	}
+	return epoch, nil
+}
@@ -0,0 +1,286 @@
+package store
+
+// sqliteMigrations is the ordered schema history of the SQLite store.
+// Applied migrations must never be edited; their checksums are verified on
+// startup. Append a new entry for every schema change.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		// Schema for the append-only events table
+		// We store the canonical envelope fields as columns for querying,
+		// and the full payload as a JSON blob.
+		Up: `
+	CREATE TABLE IF NOT EXISTS events (
+		event_id TEXT PRIMARY KEY,
+		event_type TEXT NOT NULL,
+		schema_version INTEGER NOT NULL,
+		ts_event DATETIME NOT NULL,
+		ts_ingest DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		
+		-- Source metadata
+		origin_kind TEXT,
+		origin_id TEXT,
+		writer_id TEXT,
+
+		-- Dimensions (Mandatory)
+		agent_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		workload_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+
+		-- Correlation
+		correlation_id TEXT,
+		causation_id TEXT,
+
+		-- Payload
+		payload JSON NOT NULL,
+
+		-- Epoch (Cluster term)
+		epoch INTEGER DEFAULT 0
+	);
+	
+	-- Index for replay by ingestion order (implicit rowid is efficient, but ingest time is explicit)
+	CREATE INDEX IF NOT EXISTS idx_events_ts_ingest ON events(ts_ingest);
+	
+	-- Index for querying by event time
+	CREATE INDEX IF NOT EXISTS idx_events_ts_event ON events(ts_event);
+	
+	-- Index for filtering by event type
+	CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type);
+	
+	-- Index for filtering by identity
+	CREATE INDEX IF NOT EXISTS idx_events_identity ON events(identity_id);
+	
+	-- Index for filtering by scope
+	CREATE INDEX IF NOT EXISTS idx_events_scope ON events(scope_id);
+	
+	-- Index for lookup by correlation (common access pattern)
+	CREATE INDEX IF NOT EXISTS idx_events_correlation ON events(correlation_id);
+	
+	-- Track background worker progress
+	CREATE TABLE IF NOT EXISTS system_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Hourly Aggregation
+	CREATE TABLE IF NOT EXISTS usage_hourly (
+		bucket_ts DATETIME NOT NULL,
+		provider_id TEXT NOT NULL,
+		pool_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+		
+		total_usage INTEGER NOT NULL DEFAULT 0,
+		min_usage INTEGER NOT NULL DEFAULT 0,
+		max_usage INTEGER NOT NULL DEFAULT 0,
+		event_count INTEGER NOT NULL DEFAULT 0,
+		
+		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
+	);
+
+	-- Daily Aggregation
+	CREATE TABLE IF NOT EXISTS usage_daily (
+		bucket_ts DATETIME NOT NULL,
+		provider_id TEXT NOT NULL,
+		pool_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+		
+		total_usage INTEGER NOT NULL DEFAULT 0,
+		min_usage INTEGER NOT NULL DEFAULT 0,
+		max_usage INTEGER NOT NULL DEFAULT 0,
+		event_count INTEGER NOT NULL DEFAULT 0,
+		
+		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_usage_hourly_time ON usage_hourly(bucket_ts);
+	CREATE INDEX IF NOT EXISTS idx_usage_daily_time ON usage_daily(bucket_ts);
+
+	-- Webhook Configurations
+	CREATE TABLE IF NOT EXISTS webhook_configs (
+		webhook_id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL, -- Stored as JSON array
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		active BOOLEAN NOT NULL DEFAULT 1
+	);
+
+	-- Snapshots table for state persistence and faster recovery
+	CREATE TABLE IF NOT EXISTS snapshots (
+		snapshot_id TEXT PRIMARY KEY,
+		schema_version INTEGER NOT NULL DEFAULT 1,
+		ts_snapshot DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_event_id TEXT NOT NULL,
+		payload JSON NOT NULL,
+
+		FOREIGN KEY(last_event_id) REFERENCES events(event_id)
+	);
+
+	-- Index to quickly find the most recent snapshot for recovery
+	CREATE INDEX IF NOT EXISTS idx_snapshots_ts ON snapshots(ts_snapshot DESC);
+
+	-- Leases table for distributed locking (Leader Election)
+	CREATE TABLE IF NOT EXISTS leases (
+		name TEXT PRIMARY KEY,
+		holder_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1,
+		epoch INTEGER NOT NULL DEFAULT 0
+	);
+	`,
+		Down: `
+	DROP TABLE IF EXISTS leases;
+	DROP TABLE IF EXISTS snapshots;
+	DROP TABLE IF EXISTS webhook_configs;
+	DROP TABLE IF EXISTS usage_daily;
+	DROP TABLE IF EXISTS usage_hourly;
+	DROP TABLE IF EXISTS system_state;
+	DROP TABLE IF EXISTS events;
+	`,
+	},
+	{
+		Version: 2,
+		Name:    "event_query_indexes",
+		// Provider and pool are promoted from the payload so that audits can
+		// filter on them, and composite indexes back cursor pagination in
+		// (ts_ingest, event_id) order.
+		Up: `
+	ALTER TABLE events ADD COLUMN provider_id TEXT;
+	ALTER TABLE events ADD COLUMN pool_id TEXT;
+
+	UPDATE events SET
+		provider_id = json_extract(payload, '$.provider_id'),
+		pool_id = json_extract(payload, '$.pool_id')
+	WHERE json_valid(payload);
+
+	CREATE INDEX IF NOT EXISTS idx_events_cursor ON events(ts_ingest, event_id);
+	CREATE INDEX IF NOT EXISTS idx_events_type_cursor ON events(event_type, ts_ingest, event_id);
+	CREATE INDEX IF NOT EXISTS idx_events_provider_pool ON events(provider_id, pool_id, ts_ingest);
+	CREATE INDEX IF NOT EXISTS idx_events_causation ON events(causation_id);
+	CREATE INDEX IF NOT EXISTS idx_events_epoch ON events(epoch);
+	`,
+		Down: `
+	DROP INDEX IF EXISTS idx_events_epoch;
+	DROP INDEX IF EXISTS idx_events_causation;
+	DROP INDEX IF EXISTS idx_events_provider_pool;
+	DROP INDEX IF EXISTS idx_events_type_cursor;
+	DROP INDEX IF EXISTS idx_events_cursor;
+
+	ALTER TABLE events DROP COLUMN pool_id;
+	ALTER TABLE events DROP COLUMN provider_id;
+	`,
+	},
+	{
+		Version: 3,
+		Name:    "event_hash_chain",
+		// Every appended event takes the next sequence number and the hash of
+		// its predecessor. Events written before this migration stay unchained.
+		// Removed events leave a tombstone so the chain stays verifiable.
+		Up: `
+	ALTER TABLE events ADD COLUMN seq INTEGER;
+	ALTER TABLE events ADD COLUMN prev_hash TEXT;
+	ALTER TABLE events ADD COLUMN hash TEXT;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_events_seq ON events(seq);
+
+	CREATE TABLE IF NOT EXISTS chain_head (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		seq INTEGER NOT NULL,
+		hash TEXT NOT NULL
+	);
+	INSERT OR IGNORE INTO chain_head (id, seq, hash) VALUES (1, 0, '');
+
+	CREATE TABLE IF NOT EXISTS event_tombstones (
+		seq INTEGER PRIMARY KEY,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		prev_hash TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		tombstone_event_id TEXT NOT NULL,
+		deleted_at DATETIME NOT NULL
+	);
+	`,
+		Down: `
+	DROP TABLE IF EXISTS event_tombstones;
+	DROP TABLE IF EXISTS chain_head;
+	DROP INDEX IF EXISTS idx_events_seq;
+
+	ALTER TABLE events DROP COLUMN hash;
+	ALTER TABLE events DROP COLUMN prev_hash;
+	ALTER TABLE events DROP COLUMN seq;
+	`,
+	},
+	{
+		Version: 4,
+		Name:    "usage_rollup_resolutions",
+		// Minute buckets are downsampled into the hourly and daily tables.
+		// Rollups also carry the cost and the intent decisions per identity.
+		Up: `
+	CREATE TABLE IF NOT EXISTS usage_minutely (
+		bucket_ts DATETIME NOT NULL,
+		provider_id TEXT NOT NULL,
+		pool_id TEXT NOT NULL,
+		identity_id TEXT NOT NULL,
+		scope_id TEXT NOT NULL,
+
+		total_usage INTEGER NOT NULL DEFAULT 0,
+		min_usage INTEGER NOT NULL DEFAULT 0,
+		max_usage INTEGER NOT NULL DEFAULT 0,
+		event_count INTEGER NOT NULL DEFAULT 0,
+		total_cost INTEGER NOT NULL DEFAULT 0,
+		approved_count INTEGER NOT NULL DEFAULT 0,
+		denied_count INTEGER NOT NULL DEFAULT 0,
+		shaped_count INTEGER NOT NULL DEFAULT 0,
+
+		PRIMARY KEY (bucket_ts, provider_id, pool_id, identity_id, scope_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_minutely_time ON usage_minutely(bucket_ts);
+
+	ALTER TABLE usage_hourly ADD COLUMN total_cost INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_hourly ADD COLUMN approved_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_hourly ADD COLUMN denied_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_hourly ADD COLUMN shaped_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN total_cost INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN approved_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN denied_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE usage_daily ADD COLUMN shaped_count INTEGER NOT NULL DEFAULT 0;
+	`,
+		Down: `
+	ALTER TABLE usage_daily DROP COLUMN shaped_count;
+	ALTER TABLE usage_daily DROP COLUMN denied_count;
+	ALTER TABLE usage_daily DROP COLUMN approved_count;
+	ALTER TABLE usage_daily DROP COLUMN total_cost;
+	ALTER TABLE usage_hourly DROP COLUMN shaped_count;
+	ALTER TABLE usage_hourly DROP COLUMN denied_count;
+	ALTER TABLE usage_hourly DROP COLUMN approved_count;
+	ALTER TABLE usage_hourly DROP COLUMN total_cost;
+
+	DROP INDEX IF EXISTS idx_usage_minutely_time;
+	DROP TABLE IF EXISTS usage_minutely;
+	`,
+	},
+	{
+		Version: 5,
+		Name:    "usage_stat_events",
+		// Events whose stats were applied by UpsertUsageStats, so that a
+		// retried rollup does not count them twice.
+		Up: `
+	CREATE TABLE IF NOT EXISTS usage_stat_events (
+		event_id TEXT PRIMARY KEY,
+		bucket_ts DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_stat_events_time ON usage_stat_events(bucket_ts);
+	`,
+		Down: `
+	DROP INDEX IF EXISTS idx_usage_stat_events_time;
+	DROP TABLE IF EXISTS usage_stat_events;
+	`,
+	},
+}
@@ -82,6 +82,38 @@ func TestNewStore(t *testing.T) {
 	}
 }
 
+func TestNewStoreWithSync(t *testing.T) {
+	// PRAGMA synchronous reports 1 for NORMAL and 2 for FULL
+	for mode, want := range map[SyncMode]int{SyncNormal: 1, SyncFull: 2} {
+		store, err := NewStoreWithSync(filepath.Join(t.TempDir(), "ratelord.db"), mode)
+		if err != nil {
+			t.Fatalf("NewStoreWithSync(%s) failed: %v", mode, err)
+		}
+		defer store.Close()
+
+		// Every connection of the pool syncs the same way
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			conn, err := store.db.Conn(ctx)
+			if err != nil {
+				t.Fatalf("Conn failed: %v", err)
+			}
+			defer conn.Close()
+			var got int
+			if err := conn.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&got); err != nil {
+				t.Fatalf("PRAGMA synchronous failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("%s: expected synchronous=%d on connection %d, got %d", mode, want, i, got)
+			}
+		}
+	}
+
+	if _, err := NewStoreWithSync(filepath.Join(t.TempDir(), "ratelord.db"), "off"); err == nil {
+		t.Error("expected an unknown sync mode to be rejected")
+	}
+}
+
 func TestAppendEvent(t *testing.T) {
 	// Setup
 	tmpDir, err := os.MkdirTemp("", "ratelord-store-test-append")
@@ -0,0 +1,859 @@
+// Package storetest is a conformance suite for store.EventStore backends.
+// Every backend runs it from an ordinary Go test:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.EventStore {
+//			return openEmptyStore(t)
+//		})
+//	}
+//
+// open must return an empty store; it is called once per subtest.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rmax-ai/ratelord/pkg/store"
+)
+
+// base is the reference time for generated events. Timestamps are kept at
+// microsecond precision, the finest resolution every backend preserves.
+var base = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Run checks that the backend returned by open honours the EventStore contract.
+// If the backend also implements store.LeaseStore, leases are checked too.
+func Run(t *testing.T, open func(t *testing.T) store.EventStore) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st store.EventStore)
+	}{
+		{"AppendAndGet", testAppendAndGet},
+		{"DuplicateEventID", testDuplicateEventID},
+		{"ReadEvents", testReadEvents},
+		{"ReadRecentEvents", testReadRecentEvents},
+		{"QueryEvents", testQueryEvents},
+		{"QueryEventsPagination", testQueryEventsPagination},
+		{"SystemState", testSystemState},
+		{"UsageStats", testUsageStats},
+		{"Webhooks", testWebhooks},
+		{"Snapshots", testSnapshots},
+		{"PruneEvents", testPruneEvents},
+		{"ArchiveCandidates", testArchiveCandidates},
+		{"DeleteIdentityData", testDeleteIdentityData},
+		{"HashChain", testHashChain},
+		{"Leases", testLeases},
+		{"Fencing", testFencing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := open(t)
+			defer st.Close()
+			tt.fn(t, st)
+		})
+	}
+}
+
+// newEvent builds an event ingested i seconds after base.
+func newEvent(i int, eventType store.EventType, identityID string) *store.Event {
+	ts := base.Add(time.Duration(i) * time.Second)
+	return &store.Event{
+		EventID:       store.EventID(fmt.Sprintf("evt_%03d", i)),
+		EventType:     eventType,
+		SchemaVersion: 1,
+		TsEvent:       ts,
+		TsIngest:      ts,
+		Epoch:         int64(i % 3),
+		Source:        store.EventSource{OriginKind: "daemon", OriginID: "test", WriterID: "ratelord-d"},
+		Dimensions:    store.EventDimensions{AgentID: "agent-1", IdentityID: identityID, WorkloadID: "wl-1", ScopeID: "scope-1"},
+		Correlation:   store.EventCorrelation{CorrelationID: "corr-1", CausationID: "cause-1"},
+		Payload:       json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)),
+	}
+}
+
+func mustAppend(t *testing.T, st store.EventStore, events ...*store.Event) {
+	t.Helper()
+	for _, evt := range events {
+		if err := st.AppendEvent(context.Background(), evt); err != nil {
+			t.Fatalf("AppendEvent(%s) failed: %v", evt.EventID, err)
+		}
+	}
+}
+
+func ids(events []*store.Event) []store.EventID {
+	out := make([]store.EventID, len(events))
+	for i, e := range events {
+		out[i] = e.EventID
+	}
+	return out
+}
+
+// withoutTombstones drops the events_tombstoned events recording deletions.
+func withoutTombstones(events []*store.Event) []*store.Event {
+	var out []*store.Event
+	for _, e := range events {
+		if e.EventType != store.EventTypeEventsTombstoned {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func expectIDs(t *testing.T, what string, events []*store.Event, want ...store.EventID) {
+	t.Helper()
+	got := ids(events)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func testAppendAndGet(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	want := newEvent(1, store.EventTypeUsageObserved, "id-1")
+	mustAppend(t, st, want)
+
+	got, err := st.GetEvent(ctx, want.EventID)
+	if err != nil {
+		t.Fatalf("GetEvent failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetEvent returned nil for an existing event")
+	}
+
+	if got.EventType != want.EventType || got.SchemaVersion != want.SchemaVersion || got.Epoch != want.Epoch {
+		t.Errorf("header mismatch: got %+v", got)
+	}
+	if !got.TsEvent.Equal(want.TsEvent) || !got.TsIngest.Equal(want.TsIngest) {
+		t.Errorf("timestamps mismatch: got %v/%v, want %v/%v", got.TsEvent, got.TsIngest, want.TsEvent, want.TsIngest)
+	}
+	if got.Source != want.Source || got.Dimensions != want.Dimensions || got.Correlation != want.Correlation {
+		t.Errorf("envelope mismatch: got %+v", got)
+	}
+	if string(got.Payload) != string(want.Payload) {
+		t.Errorf("payload mismatch: got %s, want %s", got.Payload, want.Payload)
+	}
+
+	missing, err := st.GetEvent(ctx, "evt_missing")
+	if err != nil {
+		t.Fatalf("GetEvent for a missing event failed: %v", err)
+	}
+	if missing != nil {
+		t.Errorf("expected nil for a missing event, got %+v", missing)
+	}
+
+	// Empty payloads are stored as an empty object
+	empty := newEvent(2, store.EventTypeUsageObserved, "id-1")
+	empty.Payload = nil
+	mustAppend(t, st, empty)
+	got, err = st.GetEvent(ctx, empty.EventID)
+	if err != nil || got == nil {
+		t.Fatalf("GetEvent failed: %v", err)
+	}
+	if string(got.Payload) != "{}" {
+		t.Errorf("expected {} for an empty payload, got %s", got.Payload)
+	}
+}
+
+func testDuplicateEventID(t *testing.T, st store.EventStore) {
+	evt := newEvent(1, store.EventTypeUsageObserved, "id-1")
+	mustAppend(t, st, evt)
+	if err := st.AppendEvent(context.Background(), evt); err == nil {
+		t.Error("expected an error when appending a duplicate event ID")
+	}
+}
+
+func testReadEvents(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	// Append out of order; reads are ordered by ingestion time
+	mustAppend(t, st,
+		newEvent(3, store.EventTypeUsageObserved, "id-1"),
+		newEvent(1, store.EventTypeUsageObserved, "id-1"),
+		newEvent(2, store.EventTypeUsageObserved, "id-1"),
+	)
+
+	events, err := st.ReadEvents(ctx, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "all", events, "evt_001", "evt_002", "evt_003")
+
+	events, err = st.ReadEvents(ctx, base.Add(time.Second), 10)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "since is exclusive", events, "evt_002", "evt_003")
+
+	events, err = st.ReadEvents(ctx, time.Time{}, 2)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "limit", events, "evt_001", "evt_002")
+}
+
+func testReadRecentEvents(t *testing.T, st store.EventStore) {
+	for i := 1; i <= 5; i++ {
+		mustAppend(t, st, newEvent(i, store.EventTypeUsageObserved, "id-1"))
+	}
+
+	events, err := st.ReadRecentEvents(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("ReadRecentEvents failed: %v", err)
+	}
+	expectIDs(t, "newest first", events, "evt_005", "evt_004", "evt_003")
+}
+
+func testQueryEvents(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	usage := newEvent(3, store.EventTypeUsageObserved, "id-2")
+	usage.Payload = json.RawMessage(`{"provider_id":"github-main","pool_id":"github:core","used":10}`)
+	failure := newEvent(4, store.EventTypeProviderError, "id-2")
+	failure.Payload = json.RawMessage(`{"provider_id":"github-main","error":"HTTP 502"}`)
+	failure.Correlation = store.EventCorrelation{CorrelationID: "corr-2", CausationID: "evt_003"}
+	mustAppend(t, st,
+		newEvent(1, store.EventTypeUsageObserved, "id-1"),
+		newEvent(2, store.EventTypeIntentDecided, "id-1"),
+		usage,
+		failure,
+	)
+	other := newEvent(5, store.EventTypeUsageObserved, "id-2")
+	other.Dimensions.ScopeID = "scope-2"
+	mustAppend(t, st, other)
+
+	epoch := int64(1)
+
+	cases := []struct {
+		name   string
+		filter store.EventFilter
+		want   []store.EventID
+	}{
+		{"all", store.EventFilter{}, []store.EventID{"evt_001", "evt_002", "evt_003", "evt_004", "evt_005"}},
+		{"time range", store.EventFilter{From: base.Add(2 * time.Second), To: base.Add(4 * time.Second)}, []store.EventID{"evt_002", "evt_003"}},
+		{"types", store.EventFilter{EventTypes: []store.EventType{store.EventTypeIntentDecided, store.EventTypeProviderError}}, []store.EventID{"evt_002", "evt_004"}},
+		{"identity", store.EventFilter{IdentityID: "id-2"}, []store.EventID{"evt_003", "evt_004", "evt_005"}},
+		{"scope", store.EventFilter{ScopeID: "scope-2"}, []store.EventID{"evt_005"}},
+		{"limit", store.EventFilter{Limit: 2}, []store.EventID{"evt_001", "evt_002"}},
+		{"provider", store.EventFilter{ProviderID: "github-main"}, []store.EventID{"evt_003", "evt_004"}},
+		{"pool", store.EventFilter{PoolID: "github:core"}, []store.EventID{"evt_003"}},
+		{"correlation", store.EventFilter{CorrelationID: "corr-2"}, []store.EventID{"evt_004"}},
+		{"causation", store.EventFilter{CausationID: "evt_003"}, []store.EventID{"evt_004"}},
+		{"epoch", store.EventFilter{Epoch: &epoch}, []store.EventID{"evt_001", "evt_004"}},
+		{"descending", store.EventFilter{Descending: true, Limit: 2}, []store.EventID{"evt_005", "evt_004"}},
+	}
+	for _, c := range cases {
+		events, err := st.QueryEvents(ctx, c.filter)
+		if err != nil {
+			t.Fatalf("%s: QueryEvents failed: %v", c.name, err)
+		}
+		expectIDs(t, c.name, events, c.want...)
+	}
+}
+
+// testQueryEventsPagination walks the log page by page in both directions,
+// including events that share an ingest timestamp.
+func testQueryEventsPagination(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	var all []store.EventID
+	for i := 1; i <= 7; i++ {
+		evt := newEvent(i, store.EventTypeUsageObserved, "id-1")
+		evt.TsIngest = base.Add(time.Duration(i/2) * time.Second) // Pairs tie on ts_ingest
+		mustAppend(t, st, evt)
+		all = append(all, evt.EventID)
+	}
+
+	for _, desc := range []bool{false, true} {
+		want := append([]store.EventID(nil), all...)
+		if desc {
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+
+		var got []*store.Event
+		filter := store.EventFilter{Descending: desc, Limit: 3}
+		for page := 0; page < 10; page++ {
+			events, err := st.QueryEvents(ctx, filter)
+			if err != nil {
+				t.Fatalf("QueryEvents failed: %v", err)
+			}
+			got = append(got, events...)
+			if len(events) < filter.Limit {
+				break
+			}
+
+			// Cursors survive a round trip through their opaque form.
+			cursor, err := store.DecodeEventCursor(store.CursorAt(events[len(events)-1]).Encode())
+			if err != nil {
+				t.Fatalf("DecodeEventCursor failed: %v", err)
+			}
+			filter.After = cursor
+		}
+		expectIDs(t, fmt.Sprintf("descending=%v", desc), got, want...)
+	}
+}
+
+func testSystemState(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+
+	if _, err := st.GetSystemState(ctx, "missing"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+
+	if err := st.SetSystemState(ctx, "rollup_last_ts", "a"); err != nil {
+		t.Fatalf("SetSystemState failed: %v", err)
+	}
+	if err := st.SetSystemState(ctx, "rollup_last_ts", "b"); err != nil {
+		t.Fatalf("SetSystemState (overwrite) failed: %v", err)
+	}
+
+	got, err := st.GetSystemState(ctx, "rollup_last_ts")
+	if err != nil {
+		t.Fatalf("GetSystemState failed: %v", err)
+	}
+	if got != "b" {
+		t.Errorf("expected b, got %q", got)
+	}
+}
+
+func testUsageStats(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	hour := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	stat := func(ts time.Time, pool string, total, min, max int) store.UsageStat {
+		return store.UsageStat{
+			BucketTs: ts, ProviderID: "prov-1", PoolID: pool, IdentityID: "id-1", ScopeID: "scope-1",
+			TotalUsage: total, MinUsage: min, MaxUsage: max, EventCount: 1,
+		}
+	}
+
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{
+		stat(hour, "pool-a", 10, 10, 10),
+		stat(hour, "pool-b", 7, 7, 7),
+		stat(day, "pool-a", 10, 10, 10),
+	}); err != nil {
+		t.Fatalf("UpsertUsageStats failed: %v", err)
+	}
+	// A second upsert into the same bucket merges
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{stat(hour, "pool-a", 5, 5, 5)}); err != nil {
+		t.Fatalf("UpsertUsageStats (merge) failed: %v", err)
+	}
+
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{stat(hour.Add(time.Minute), "pool-a", 1, 1, 1)}); err == nil {
+		t.Error("expected an error for a bucket not aligned to the hour")
+	}
+
+	stats, err := st.GetUsageStats(ctx, store.UsageFilter{From: day, To: day.Add(24 * time.Hour), Bucket: "hour", PoolID: "pool-a"})
+	if err != nil {
+		t.Fatalf("GetUsageStats failed: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 hourly stat, got %d", len(stats))
+	}
+	got := stats[0]
+	if !got.BucketTs.Equal(hour) || got.TotalUsage != 15 || got.MinUsage != 5 || got.MaxUsage != 10 || got.EventCount != 2 {
+		t.Errorf("unexpected merged stat: %+v", got)
+	}
+
+	stats, err = st.GetUsageStats(ctx, store.UsageFilter{From: day, To: day.Add(24 * time.Hour), Bucket: "hour"})
+	if err != nil {
+		t.Fatalf("GetUsageStats failed: %v", err)
+	}
+	if len(stats) != 2 {
+		t.Errorf("expected 2 hourly stats without a pool filter, got %d", len(stats))
+	}
+
+	stats, err = st.GetUsageStats(ctx, store.UsageFilter{From: day, To: day.Add(24 * time.Hour), Bucket: "day"})
+	if err != nil {
+		t.Fatalf("GetUsageStats failed: %v", err)
+	}
+	if len(stats) != 1 || stats[0].TotalUsage != 10 {
+		t.Errorf("unexpected daily stats: %+v", stats)
+	}
+
+	// Explicit resolutions, with cost and decision counts
+	minute := hour.Add(5 * time.Minute)
+	rollup := func(bucket string, ts time.Time) store.UsageStat {
+		s := stat(ts, "pool-a", 3, 1, 2)
+		s.Bucket, s.TotalCost, s.ApprovedCount, s.DeniedCount, s.ShapedCount = bucket, 30, 2, 1, 1
+		return s
+	}
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{rollup(store.BucketMinute, minute), rollup(store.BucketMinute, minute), rollup(store.BucketHour, day)}); err != nil {
+		t.Fatalf("UpsertUsageStats (buckets) failed: %v", err)
+	}
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{rollup(store.BucketHour, minute)}); err == nil {
+		t.Error("expected an error for an hourly bucket not aligned to the hour")
+	}
+	stats, err = st.GetUsageStats(ctx, store.UsageFilter{From: day, To: day.Add(24 * time.Hour), Bucket: store.BucketMinute})
+	if err != nil {
+		t.Fatalf("GetUsageStats (minute) failed: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 minute stat, got %+v", stats)
+	}
+	if got := stats[0]; !got.BucketTs.Equal(minute) || got.Bucket != store.BucketMinute || got.TotalUsage != 6 || got.TotalCost != 60 ||
+		got.ApprovedCount != 4 || got.DeniedCount != 2 || got.ShapedCount != 2 {
+		t.Errorf("unexpected minute stat: %+v", got)
+	}
+	// An hourly bucket at midnight stays hourly when named
+	stats, _ = st.GetUsageStats(ctx, store.UsageFilter{From: day, To: hour, Bucket: store.BucketHour})
+	if len(stats) != 1 || !stats[0].BucketTs.Equal(day) || stats[0].TotalCost != 30 {
+		t.Errorf("unexpected midnight hourly stats: %+v", stats)
+	}
+
+	n, err := st.PruneUsageStats(ctx, store.BucketMinute, minute.Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 pruned minute stat, got %d (err %v)", n, err)
+	}
+	if stats, _ := st.GetUsageStats(ctx, store.UsageFilter{From: day, To: day.Add(24 * time.Hour), Bucket: store.BucketMinute}); len(stats) != 0 {
+		t.Errorf("expected no minute stats after pruning, got %+v", stats)
+	}
+	if stats, _ := st.GetUsageStats(ctx, store.UsageFilter{From: day, To: day.Add(24 * time.Hour), Bucket: store.BucketHour}); len(stats) != 3 {
+		t.Errorf("expected pruning minutes to keep the hourly stats, got %+v", stats)
+	}
+
+	// The stats of an event apply once, however often they are retried
+	late := minute.Add(10 * time.Minute)
+	ofEvent := func(id store.EventID, bucket string, ts time.Time) store.UsageStat {
+		s := stat(ts, "pool-c", 4, 4, 4)
+		s.Bucket, s.EventID = bucket, id
+		return s
+	}
+	for i := 0; i < 2; i++ {
+		if err := st.UpsertUsageStats(ctx, []store.UsageStat{
+			ofEvent("evt_a", store.BucketMinute, late), ofEvent("evt_a", store.BucketHour, hour),
+			ofEvent("evt_b", store.BucketMinute, late),
+		}); err != nil {
+			t.Fatalf("UpsertUsageStats (events) failed: %v", err)
+		}
+	}
+	stats, _ = st.GetUsageStats(ctx, store.UsageFilter{From: late, To: late.Add(time.Minute), Bucket: store.BucketMinute, PoolID: "pool-c"})
+	if len(stats) != 1 || stats[0].TotalUsage != 8 || stats[0].EventCount != 2 {
+		t.Errorf("expected each event counted once, got %+v", stats)
+	}
+	stats, _ = st.GetUsageStats(ctx, store.UsageFilter{From: hour, To: hour.Add(time.Hour), Bucket: store.BucketHour, PoolID: "pool-c"})
+	if len(stats) != 1 || stats[0].TotalUsage != 4 {
+		t.Errorf("expected the hourly stat of the event once, got %+v", stats)
+	}
+	// Once forgotten, an event applies again
+	if n, err := st.PruneUsageStatEvents(ctx, late.Add(time.Minute)); err != nil || n != 2 {
+		t.Errorf("expected 2 forgotten events, got %d (err %v)", n, err)
+	}
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{ofEvent("evt_b", store.BucketMinute, late)}); err != nil {
+		t.Fatalf("UpsertUsageStats failed: %v", err)
+	}
+	stats, _ = st.GetUsageStats(ctx, store.UsageFilter{From: late, To: late.Add(time.Minute), Bucket: store.BucketMinute, PoolID: "pool-c"})
+	if len(stats) != 1 || stats[0].TotalUsage != 12 {
+		t.Errorf("expected a forgotten event to apply again, got %+v", stats)
+	}
+}
+
+func testWebhooks(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	cfg := &store.WebhookConfig{
+		WebhookID: "wh-1",
+		URL:       "https://example.com/hook",
+		Secret:    "s3cret",
+		Events:    []string{"intent_decided", "provider_error"},
+		CreatedAt: base,
+		Active:    true,
+	}
+	if err := st.RegisterWebhook(ctx, cfg); err != nil {
+		t.Fatalf("RegisterWebhook failed: %v", err)
+	}
+
+	hooks, err := st.ListWebhooks(ctx)
+	if err != nil {
+		t.Fatalf("ListWebhooks failed: %v", err)
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("expected 1 webhook, got %d", len(hooks))
+	}
+	got := hooks[0]
+	if got.WebhookID != cfg.WebhookID || got.URL != cfg.URL || got.Secret != cfg.Secret || !got.Active ||
+		fmt.Sprint(got.Events) != fmt.Sprint(cfg.Events) || !got.CreatedAt.Equal(cfg.CreatedAt) {
+		t.Errorf("webhook mismatch: got %+v", got)
+	}
+
+	if err := st.DeleteWebhook(ctx, "wh-1"); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	hooks, err = st.ListWebhooks(ctx)
+	if err != nil {
+		t.Fatalf("ListWebhooks failed: %v", err)
+	}
+	if len(hooks) != 0 {
+		t.Errorf("expected no webhooks after delete, got %d", len(hooks))
+	}
+}
+
+func testSnapshots(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+
+	snap, err := st.GetLatestSnapshot(ctx)
+	if err != nil || snap != nil {
+		t.Fatalf("expected no snapshot, got %+v (err %v)", snap, err)
+	}
+	ts, err := st.GetLatestSnapshotTime(ctx)
+	if err != nil || !ts.IsZero() {
+		t.Fatalf("expected zero snapshot time, got %v (err %v)", ts, err)
+	}
+
+	mustAppend(t, st, newEvent(1, store.EventTypeUsageObserved, "id-1"), newEvent(2, store.EventTypeUsageObserved, "id-1"))
+	for i, id := range []store.EventID{"evt_001", "evt_002"} {
+		err := st.SaveSnapshot(ctx, &store.Snapshot{
+			SnapshotID:    fmt.Sprintf("snap-%d", i),
+			SchemaVersion: 1,
+			TsSnapshot:    base.Add(time.Duration(i) * time.Minute),
+			LastEventID:   id,
+			Payload:       json.RawMessage(fmt.Sprintf(`{"i":%d}`, i)),
+		})
+		if err != nil {
+			t.Fatalf("SaveSnapshot failed: %v", err)
+		}
+	}
+
+	snap, err = st.GetLatestSnapshot(ctx)
+	if err != nil || snap == nil {
+		t.Fatalf("GetLatestSnapshot failed: %v", err)
+	}
+	if snap.SnapshotID != "snap-1" || snap.LastEventID != "evt_002" || string(snap.Payload) != `{"i":1}` {
+		t.Errorf("unexpected latest snapshot: %+v", snap)
+	}
+
+	ts, err = st.GetLatestSnapshotTime(ctx)
+	if err != nil {
+		t.Fatalf("GetLatestSnapshotTime failed: %v", err)
+	}
+	if !ts.Equal(base.Add(time.Minute)) {
+		t.Errorf("expected snapshot time %v, got %v", base.Add(time.Minute), ts)
+	}
+
+	snaps, err := st.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(snaps) != 2 || snaps[0].SnapshotID != "snap-1" || snaps[1].LastEventID != "evt_001" || !snaps[1].TsSnapshot.Equal(base) {
+		t.Errorf("unexpected snapshot list: %+v", snaps)
+	}
+
+	snap, err = st.GetSnapshot(ctx, "snap-0")
+	if err != nil || snap == nil || string(snap.Payload) != `{"i":0}` {
+		t.Errorf("unexpected snapshot snap-0: %+v (err %v)", snap, err)
+	}
+	if snap, err = st.GetSnapshot(ctx, "missing"); err != nil || snap != nil {
+		t.Errorf("expected no snapshot, got %+v (err %v)", snap, err)
+	}
+
+	if err := st.DeleteSnapshots(ctx, []string{"snap-1", "missing"}); err != nil {
+		t.Fatalf("DeleteSnapshots failed: %v", err)
+	}
+	snap, err = st.GetLatestSnapshot(ctx)
+	if err != nil || snap == nil || snap.SnapshotID != "snap-0" {
+		t.Errorf("expected snap-0 to remain, got %+v (err %v)", snap, err)
+	}
+}
+
+func testPruneEvents(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	mustAppend(t, st,
+		newEvent(1, store.EventTypeUsageObserved, "id-1"),
+		newEvent(2, store.EventTypeIntentDecided, "id-1"),
+		newEvent(3, store.EventTypeUsageObserved, "id-1"),
+	)
+
+	// Pruning without a snapshot is refused
+	if _, err := st.PruneEvents(ctx, 0, "", nil); err == nil {
+		t.Fatal("expected PruneEvents to fail without a snapshot")
+	}
+
+	if err := st.SaveSnapshot(ctx, &store.Snapshot{
+		SnapshotID: "snap-1", SchemaVersion: 1, TsSnapshot: base, LastEventID: "evt_003", Payload: json.RawMessage(`{}`),
+	}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	// Everything before the snapshot boundary is eligible; only usage events are dropped
+	n, err := st.PruneEvents(ctx, 0, string(store.EventTypeUsageObserved), nil)
+	if err != nil {
+		t.Fatalf("PruneEvents failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 pruned event, got %d", n)
+	}
+
+	events, err := st.ReadEvents(ctx, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "after prune", withoutTombstones(events), "evt_002", "evt_003")
+}
+
+func testArchiveCandidates(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	for i := 1; i <= 4; i++ {
+		mustAppend(t, st, newEvent(i, store.EventTypeUsageObserved, "id-1"))
+	}
+
+	events, err := st.ReadCandidateEvents(ctx, base.Add(3*time.Second), 10)
+	if err != nil {
+		t.Fatalf("ReadCandidateEvents failed: %v", err)
+	}
+	expectIDs(t, "candidates", events, "evt_001", "evt_002")
+
+	if err := st.DeleteEvents(ctx, []string{"evt_001", "evt_002"}); err != nil {
+		t.Fatalf("DeleteEvents failed: %v", err)
+	}
+	if err := st.DeleteEvents(ctx, nil); err != nil {
+		t.Fatalf("DeleteEvents with no IDs failed: %v", err)
+	}
+
+	events, err = st.ReadEvents(ctx, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "after delete", withoutTombstones(events), "evt_003", "evt_004")
+}
+
+func testDeleteIdentityData(t *testing.T, st store.EventStore) {
+	ctx := context.Background()
+	mustAppend(t, st,
+		newEvent(1, store.EventTypeUsageObserved, "id-1"),
+		newEvent(2, store.EventTypeUsageObserved, "id-2"),
+	)
+	hour := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := st.UpsertUsageStats(ctx, []store.UsageStat{
+		{BucketTs: hour, ProviderID: "p", PoolID: "pool", IdentityID: "id-1", ScopeID: "s", TotalUsage: 1, EventCount: 1},
+		{BucketTs: hour, ProviderID: "p", PoolID: "pool", IdentityID: "id-2", ScopeID: "s", TotalUsage: 1, EventCount: 1},
+	}); err != nil {
+		t.Fatalf("UpsertUsageStats failed: %v", err)
+	}
+
+	if err := st.DeleteIdentityData(ctx, ""); err == nil {
+		t.Error("expected an error for an empty identity")
+	}
+	if err := st.DeleteIdentityData(ctx, "id-1"); err != nil {
+		t.Fatalf("DeleteIdentityData failed: %v", err)
+	}
+
+	events, err := st.ReadEvents(ctx, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "remaining events", withoutTombstones(events), "evt_002")
+
+	stats, err := st.GetUsageStats(ctx, store.UsageFilter{From: hour, To: hour.Add(time.Hour), Bucket: "hour"})
+	if err != nil {
+		t.Fatalf("GetUsageStats failed: %v", err)
+	}
+	if len(stats) != 1 || stats[0].IdentityID != "id-2" {
+		t.Errorf("unexpected remaining stats: %+v", stats)
+	}
+}
+
+func testHashChain(t *testing.T, st store.EventStore) {
+	cs, ok := st.(store.ChainStore)
+	if !ok {
+		t.Skip("backend does not implement store.ChainStore")
+	}
+	ctx := context.Background()
+
+	head, err := cs.ChainHead(ctx)
+	if err != nil {
+		t.Fatalf("ChainHead failed: %v", err)
+	}
+	if head.Seq != 0 || head.Hash != "" {
+		t.Errorf("expected an empty chain, got %+v", head)
+	}
+
+	for i := 1; i <= 4; i++ {
+		mustAppend(t, st, newEvent(i, store.EventTypeUsageObserved, fmt.Sprintf("id-%d", i%2)))
+	}
+	links, err := cs.ReadChain(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("ReadChain failed: %v", err)
+	}
+	if len(links) != 4 {
+		t.Fatalf("expected 4 links, got %d", len(links))
+	}
+	prev := ""
+	for i, link := range links {
+		if link.Seq != int64(i+1) || link.PrevHash != prev || link.Event == nil {
+			t.Errorf("link %d: unexpected %+v", i, link)
+		}
+		if want := store.ChainLinkHash(link.Seq, prev, store.ChainContentHash(link.Event)); link.Hash != want {
+			t.Errorf("link %d: hash %s, want %s", i, link.Hash, want)
+		}
+		prev = link.Hash
+	}
+
+	// Deleting an identity leaves tombstones and an events_tombstoned event
+	if err := st.DeleteIdentityData(ctx, "id-1"); err != nil {
+		t.Fatalf("DeleteIdentityData failed: %v", err)
+	}
+	links, err = cs.ReadChain(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("ReadChain failed: %v", err)
+	}
+	if len(links) != 5 {
+		t.Fatalf("expected 5 links after deletion, got %d", len(links))
+	}
+	tomb := links[4]
+	if tomb.EventType != store.EventTypeEventsTombstoned || tomb.Event == nil {
+		t.Fatalf("expected the last link to record the deletion, got %+v", tomb)
+	}
+	for _, i := range []int{0, 2} {
+		if links[i].Event != nil || links[i].TombstoneReason != store.TombstoneIdentityDeleted || links[i].TombstoneEventID != tomb.EventID {
+			t.Errorf("link %d: expected a tombstone, got %+v", i, links[i])
+		}
+	}
+
+	paged, err := cs.ReadChain(ctx, 2, 2)
+	if err != nil {
+		t.Fatalf("ReadChain failed: %v", err)
+	}
+	if len(paged) != 2 || paged[0].Seq != 3 || paged[1].Seq != 4 {
+		t.Errorf("unexpected page: %+v", paged)
+	}
+
+	report, err := store.VerifyChain(ctx, st, nil)
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if !report.OK() || report.Events != 3 || report.Tombstones != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func testLeases(t *testing.T, st store.EventStore) {
+	ls, ok := st.(store.LeaseStore)
+	if !ok {
+		t.Skip("backend does not implement store.LeaseStore")
+	}
+	ctx := context.Background()
+
+	ok, err := ls.Acquire(ctx, "leader", "node-a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("node-a should acquire a free lease (ok=%v, err=%v)", ok, err)
+	}
+	ok, err = ls.Acquire(ctx, "leader", "node-b", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("node-b must not acquire a held lease (ok=%v, err=%v)", ok, err)
+	}
+	if err := ls.Renew(ctx, "leader", "node-a", time.Minute); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+	if err := ls.Renew(ctx, "leader", "node-b", time.Minute); err == nil {
+		t.Error("Renew by a non-holder must fail")
+	}
+
+	lease, err := ls.Get(ctx, "leader")
+	if err != nil || lease == nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if lease.HolderID != "node-a" || lease.Epoch != 1 {
+		t.Errorf("unexpected lease: %+v", lease)
+	}
+
+	// Let node-a's lease expire, then node-b takes over with a new epoch
+	if _, err := ls.Acquire(ctx, "leader", "node-a", -time.Second); err != nil {
+		t.Fatalf("Acquire (expire) failed: %v", err)
+	}
+	ok, err = ls.Acquire(ctx, "leader", "node-b", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("node-b should take over an expired lease (ok=%v, err=%v)", ok, err)
+	}
+	lease, err = ls.Get(ctx, "leader")
+	if err != nil || lease == nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if lease.HolderID != "node-b" || lease.Epoch != 2 {
+		t.Errorf("expected node-b at epoch 2, got %+v", lease)
+	}
+
+	if err := ls.Release(ctx, "leader", "node-b"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	lease, err = ls.Get(ctx, "leader")
+	if err != nil || lease != nil {
+		t.Errorf("expected no lease after release, got %+v (err %v)", lease, err)
+	}
+
+	// Epochs keep growing across releases
+	ok, err = ls.Acquire(ctx, "leader", "node-a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("node-a should acquire a released lease (ok=%v, err=%v)", ok, err)
+	}
+	lease, err = ls.Get(ctx, "leader")
+	if err != nil || lease == nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if lease.HolderID != "node-a" || lease.Epoch != 3 {
+		t.Errorf("expected node-a at epoch 3, got %+v", lease)
+	}
+}
+
+func testFencing(t *testing.T, st store.EventStore) {
+	ls, ok := st.(store.LeaseStore)
+	if !ok {
+		t.Skip("backend does not implement store.LeaseStore")
+	}
+	fs, ok := st.(store.Fenceable)
+	if !ok {
+		t.Skip("backend does not implement store.Fenceable")
+	}
+	ef := st.(store.EpochFence)
+	ctx := context.Background()
+	fs.SetFence("leader")
+
+	withEpoch := func(i int, epoch int64) *store.Event {
+		evt := newEvent(i, store.EventTypeUsageObserved, "id-1")
+		evt.Epoch = epoch
+		return evt
+	}
+
+	// Nothing is fenced before the lease is first acquired
+	if epoch, err := ef.LeaseEpoch(ctx, "leader"); err != nil || epoch != 0 {
+		t.Fatalf("expected epoch 0 for a new lease, got %d (err %v)", epoch, err)
+	}
+	mustAppend(t, st, withEpoch(1, 1))
+
+	if _, err := ls.Acquire(ctx, "leader", "node-a", time.Minute); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := ls.Release(ctx, "leader", "node-a"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := ls.Acquire(ctx, "leader", "node-b", time.Minute); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if epoch, err := ef.LeaseEpoch(ctx, "leader"); err != nil || epoch != 2 {
+		t.Fatalf("expected epoch 2 after takeover, got %d (err %v)", epoch, err)
+	}
+
+	// node-a, deposed, still writes with epoch 1
+	if err := st.AppendEvent(ctx, withEpoch(2, 1)); !errors.Is(err, store.ErrStaleEpoch) {
+		t.Fatalf("expected ErrStaleEpoch, got %v", err)
+	}
+	// A batch holding one stale event is rejected as a whole
+	if ba, ok := st.(store.BatchAppender); ok {
+		err := ba.AppendEvents(ctx, []*store.Event{withEpoch(3, 2), withEpoch(4, 1)})
+		if !errors.Is(err, store.ErrStaleEpoch) {
+			t.Fatalf("expected ErrStaleEpoch for the batch, got %v", err)
+		}
+	}
+	// Writes of the current leader, and those outside leadership, pass
+	mustAppend(t, st, withEpoch(5, 2), withEpoch(6, 0))
+
+	events, err := st.ReadEvents(ctx, time.Time{}, 100)
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	expectIDs(t, "appended events", events, "evt_001", "evt_005", "evt_006")
+}
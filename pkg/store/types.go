@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -24,6 +25,10 @@ const (
 	EventTypeIdentityDeleted      EventType = "identity_deleted"
 	EventTypePolicyUpdated        EventType = "policy_updated"
 	EventTypeGrantIssued          EventType = "grant_issued"
+	EventTypeGrantReclaimed       EventType = "grant_reclaimed"
+	EventTypeGrantReturned        EventType = "grant_returned"
+	EventTypeEventsTombstoned     EventType = "events_tombstoned"
+	EventTypeChainCheckpoint      EventType = "chain_checkpoint"
 )
 
 // Lease represents a distributed lock or leadership claim.
@@ -91,24 +96,112 @@ type EventCorrelation struct {
 	CausationID   string `json:"causation_id"`
 }
 
+// PayloadProviderPool extracts the provider_id and pool_id carried by
+// provider-related payloads. Stores index them for filtering.
+func PayloadProviderPool(payload json.RawMessage) (providerID, poolID string) {
+	var keys struct {
+		ProviderID string `json:"provider_id"`
+		PoolID     string `json:"pool_id"`
+	}
+	// Payloads without these keys (or not objects at all) simply yield "".
+	_ = json.Unmarshal(payload, &keys)
+	return keys.ProviderID, keys.PoolID
+}
+
+// Resolutions of the usage rollups. Minute buckets are downsampled into hour
+// and day buckets, and each resolution can be kept for a different time.
+const (
+	BucketMinute = "minute"
+	BucketHour   = "hour"
+	BucketDay    = "day"
+)
+
+// UsageBuckets lists the rollup resolutions, finest first.
+var UsageBuckets = []string{BucketMinute, BucketHour, BucketDay}
+
+// BucketSize returns the width of a rollup bucket.
+func BucketSize(bucket string) (time.Duration, bool) {
+	switch bucket {
+	case BucketMinute:
+		return time.Minute, true
+	case BucketHour:
+		return time.Hour, true
+	case BucketDay:
+		return 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// UsageTable returns the table holding the rollups of bucket.
+func UsageTable(bucket string) (string, bool) {
+	switch bucket {
+	case BucketMinute:
+		return "usage_minutely", true
+	case BucketHour:
+		return "usage_hourly", true
+	case BucketDay:
+		return "usage_daily", true
+	}
+	return "", false
+}
+
+// UsageStatTable returns the table a stat is written to. Stats without a
+// Bucket are routed by alignment: midnight to the daily table, the top of
+// any other hour to the hourly table.
+func UsageStatTable(stat UsageStat) (string, error) {
+	bucket := stat.Bucket
+	if bucket == "" {
+		if stat.BucketTs.Minute() != 0 || stat.BucketTs.Second() != 0 {
+			return "", fmt.Errorf("invalid bucket_ts for stat: must be at top of hour or day")
+		}
+		bucket = BucketHour
+		if stat.BucketTs.Hour() == 0 {
+			bucket = BucketDay
+		}
+	}
+	size, ok := BucketSize(bucket)
+	if !ok {
+		return "", fmt.Errorf("invalid bucket %q for stat", bucket)
+	}
+	if !stat.BucketTs.Equal(stat.BucketTs.Truncate(size)) {
+		return "", fmt.Errorf("invalid bucket_ts for stat: %s is not aligned to the %s", stat.BucketTs.Format(time.RFC3339), bucket)
+	}
+	table, _ := UsageTable(bucket)
+	return table, nil
+}
+
 // UsageStat represents aggregated usage statistics for a time bucket.
+// TotalUsage and TotalCost (in micro-USD) are the consumption within the
+// bucket; MinUsage and MaxUsage bound the values of its EventCount usage
+// observations. The decision counts come from intent decisions.
+//
+// EventID, when set, is the single event the stat is derived from.
+// UpsertUsageStats applies the stats of such an event once, so that a retry
+// passing the event again does not count it twice; all the stats of an
+// event must be passed in the same call.
 type UsageStat struct {
-	BucketTs   time.Time `json:"bucket_ts"`
-	ProviderID string    `json:"provider_id"`
-	PoolID     string    `json:"pool_id"`
-	IdentityID string    `json:"identity_id"`
-	ScopeID    string    `json:"scope_id"`
-	TotalUsage int       `json:"total_usage"`
-	MinUsage   int       `json:"min_usage"`
-	MaxUsage   int       `json:"max_usage"`
-	EventCount int       `json:"event_count"`
+	BucketTs      time.Time `json:"bucket_ts"`
+	Bucket        string    `json:"bucket,omitempty"`
+	ProviderID    string    `json:"provider_id"`
+	PoolID        string    `json:"pool_id"`
+	IdentityID    string    `json:"identity_id"`
+	ScopeID       string    `json:"scope_id"`
+	TotalUsage    int       `json:"total_usage"`
+	MinUsage      int       `json:"min_usage"`
+	MaxUsage      int       `json:"max_usage"`
+	EventCount    int       `json:"event_count"`
+	TotalCost     int64     `json:"total_cost"`
+	ApprovedCount int       `json:"approved_count"`
+	DeniedCount   int       `json:"denied_count"`
+	ShapedCount   int       `json:"shaped_count"`
+	EventID       EventID   `json:"-"`
 }
 
 // UsageFilter defines filters for querying usage statistics.
 type UsageFilter struct {
 	From       time.Time
 	To         time.Time
-	Bucket     string // "hour" or "day"
+	Bucket     string // "minute", "hour" or "day"
 	ProviderID string
 	PoolID     string
 	IdentityID string
@@ -116,13 +209,24 @@ type UsageFilter struct {
 }
 
 // EventFilter defines filters for querying events.
+// Results are ordered by (ts_ingest, event_id); From and To bound ts_event.
 type EventFilter struct {
-	From       time.Time
-	To         time.Time
-	EventTypes []EventType
-	IdentityID string
-	ScopeID    string
-	Limit      int
+	From          time.Time
+	To            time.Time
+	EventTypes    []EventType
+	IdentityID    string
+	ScopeID       string
+	ProviderID    string
+	PoolID        string
+	CorrelationID string
+	CausationID   string
+	Epoch         *int64       // nil matches any epoch
+	After         *EventCursor // Exclusive position to continue from, in the requested order
+	Descending    bool         // Newest first
+	Limit         int
+	// IncludeArchived makes an ArchiveStore search the archive even when the
+	// range does not reach back past the live log.
+	IncludeArchived bool
 }
 
 // WebhookConfig represents a registered webhook endpoint for event notifications.
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Problem kinds reported by VerifyChain.
+const (
+	ChainGap           = "gap"            // Sequence numbers with neither an event nor a tombstone
+	ChainModified      = "modified"       // Content no longer matches the stored hash
+	ChainBrokenLink    = "broken_link"    // prev_hash differs from the predecessor's hash
+	ChainHeadMismatch  = "head_mismatch"  // The recorded head differs from the last link
+	ChainBadCheckpoint = "bad_checkpoint" // Invalid signature, or the signed hash is not in the chain
+	ChainBadTombstone  = "bad_tombstone"  // Tombstones not matching an events_tombstoned event
+)
+
+// verifyBatch is the number of links read per round trip.
+const verifyBatch = 1000
+
+// ChainProblem describes one integrity violation.
+type ChainProblem struct {
+	Seq     int64   `json:"seq,omitempty"`
+	EventID EventID `json:"event_id,omitempty"`
+	Kind    string  `json:"kind"`
+	Detail  string  `json:"detail"`
+}
+
+// ChainReport summarizes a verification run.
+type ChainReport struct {
+	Head        ChainHead `json:"head"`
+	Events      int64     `json:"events"`     // Live events verified
+	Tombstones  int64     `json:"tombstones"` // Removed events still accounted for
+	Checkpoints int       `json:"checkpoints"`
+	// LastCheckpointSeq is the newest position vouched for by a signature.
+	// Without a public key checkpoints are only checked against the chain.
+	LastCheckpointSeq int64          `json:"last_checkpoint_seq"`
+	Authenticated     bool           `json:"authenticated"`
+	Problems          []ChainProblem `json:"problems"`
+}
+
+// OK reports whether no problems were found.
+func (r *ChainReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *ChainReport) problem(seq int64, id EventID, kind, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, ChainProblem{Seq: seq, EventID: id, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+}
+
+type checkpointRef struct {
+	eventID EventID
+	payload CheckpointPayload
+}
+
+// VerifyChain walks the whole hash chain of st and reports gaps, modified
+// or reordered events, unaccounted tombstones and checkpoints that do not
+// match. When pub is set, checkpoint signatures must verify against it.
+func VerifyChain(ctx context.Context, st EventStore, pub ed25519.PublicKey) (*ChainReport, error) {
+	cs, ok := st.(ChainStore)
+	if !ok {
+		return nil, fmt.Errorf("store does not maintain a hash chain")
+	}
+
+	head, err := cs.ChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &ChainReport{Head: head, Authenticated: pub != nil, Problems: []ChainProblem{}}
+
+	checkpoints, err := loadCheckpoints(ctx, st, pub, report)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tombstone accounting: expected counts from events_tombstoned events
+	// (-1 when that event was itself removed) against tombstone rows.
+	expected := make(map[EventID]int64)
+	tombstoned := make(map[EventID]int64)
+
+	var prevSeq int64
+	var prevHash string
+	beyondHead := false
+	for !beyondHead {
+		links, err := cs.ReadChain(ctx, prevSeq, verifyBatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chain after %d: %w", prevSeq, err)
+		}
+		if len(links) == 0 {
+			break
+		}
+
+		for _, link := range links {
+			// Links past the recorded head mean the head was rewound.
+			if link.Seq > head.Seq {
+				report.problem(link.Seq, link.EventID, ChainHeadMismatch, "chain continues past the recorded head %d", head.Seq)
+				beyondHead = true
+				break
+			}
+
+			switch {
+			case link.Seq != prevSeq+1:
+				report.problem(link.Seq, link.EventID, ChainGap, "positions %d to %d are missing", prevSeq+1, link.Seq-1)
+			case link.PrevHash != prevHash:
+				report.problem(link.Seq, link.EventID, ChainBrokenLink, "prev_hash does not match position %d", prevSeq)
+			}
+
+			contentHash := link.ContentHash
+			if link.Event != nil {
+				contentHash = ChainContentHash(link.Event)
+			}
+			if ChainLinkHash(link.Seq, link.PrevHash, contentHash) != link.Hash {
+				report.problem(link.Seq, link.EventID, ChainModified, "content does not match the chained hash")
+			}
+
+			for _, cp := range checkpoints[link.Seq] {
+				if cp.payload.Hash != link.Hash {
+					report.problem(link.Seq, cp.eventID, ChainBadCheckpoint, "checkpoint hash differs from the chain at position %d", link.Seq)
+					continue
+				}
+				report.Checkpoints++
+				report.LastCheckpointSeq = link.Seq
+			}
+			delete(checkpoints, link.Seq)
+
+			if link.Event == nil {
+				report.Tombstones++
+				tombstoned[link.TombstoneEventID]++
+				if link.EventType == EventTypeEventsTombstoned {
+					expected[link.EventID] = -1
+				}
+			} else {
+				report.Events++
+				if link.EventType == EventTypeEventsTombstoned {
+					var p TombstonePayload
+					if err := json.Unmarshal(link.Event.Payload, &p); err != nil {
+						report.problem(link.Seq, link.EventID, ChainBadTombstone, "invalid payload: %v", err)
+					}
+					expected[link.EventID] = p.Count
+				}
+			}
+
+			prevSeq, prevHash = link.Seq, link.Hash
+		}
+	}
+
+	if !beyondHead {
+		switch {
+		case prevSeq != head.Seq:
+			report.problem(head.Seq, "", ChainGap, "positions %d to %d are missing", prevSeq+1, head.Seq)
+		case prevHash != head.Hash:
+			report.problem(head.Seq, "", ChainHeadMismatch, "recorded head hash differs from the last link")
+		}
+	}
+
+	for seq, cps := range checkpoints {
+		for _, cp := range cps {
+			report.problem(seq, cp.eventID, ChainBadCheckpoint, "checkpoint refers to position %d, which is not in the chain", seq)
+		}
+	}
+
+	for id, n := range tombstoned {
+		want, ok := expected[id]
+		switch {
+		case !ok:
+			report.problem(0, id, ChainBadTombstone, "%d tombstones cite an unknown events_tombstoned event", n)
+		case want >= 0 && want != n:
+			report.problem(0, id, ChainBadTombstone, "event records %d removals but %d tombstones cite it", want, n)
+		}
+	}
+	for id, want := range expected {
+		if want > 0 && tombstoned[id] == 0 {
+			report.problem(0, id, ChainBadTombstone, "event records %d removals but no tombstones cite it", want)
+		}
+	}
+
+	return report, nil
+}
+
+// loadCheckpoints indexes chain_checkpoint events by the position they sign,
+// reporting those whose signature does not verify.
+func loadCheckpoints(ctx context.Context, st EventStore, pub ed25519.PublicKey, report *ChainReport) (map[int64][]checkpointRef, error) {
+	checkpoints := make(map[int64][]checkpointRef)
+	filter := EventFilter{EventTypes: []EventType{EventTypeChainCheckpoint}, Limit: verifyBatch}
+	for {
+		events, err := st.QueryEvents(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+		}
+		for _, evt := range events {
+			var p CheckpointPayload
+			if err := json.Unmarshal(evt.Payload, &p); err != nil {
+				report.problem(0, evt.EventID, ChainBadCheckpoint, "invalid payload: %v", err)
+				continue
+			}
+			if pub != nil {
+				if p.PublicKey != hex.EncodeToString(pub) || !p.VerifySignature(pub) {
+					report.problem(p.Seq, evt.EventID, ChainBadCheckpoint, "signature does not verify with the given public key")
+					continue
+				}
+			}
+			checkpoints[p.Seq] = append(checkpoints[p.Seq], checkpointRef{eventID: evt.EventID, payload: p})
+		}
+		if len(events) < filter.Limit {
+			return checkpoints, nil
+		}
+		filter.After = CursorAt(events[len(events)-1])
+	}
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// seedChain appends n usage events and a checkpoint signed with key over them.
+func seedChain(t *testing.T, s *Store, n int, key ed25519.PrivateKey) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for i := 1; i <= n; i++ {
+		evt := &Event{
+			EventID:    EventID(fmt.Sprintf("evt_%d", i)),
+			EventType:  EventTypeUsageObserved,
+			TsEvent:    now.Add(time.Duration(i) * time.Second),
+			TsIngest:   now.Add(time.Duration(i) * time.Second),
+			Source:     EventSource{OriginKind: "test", OriginID: "test", WriterID: "test"},
+			Dimensions: EventDimensions{AgentID: "a", IdentityID: "i", WorkloadID: "w", ScopeID: "s"},
+			Payload:    json.RawMessage(fmt.Sprintf(`{"used":%d}`, i)),
+		}
+		if err := s.AppendEvent(ctx, evt); err != nil {
+			t.Fatalf("AppendEvent failed: %v", err)
+		}
+	}
+
+	head, err := s.ChainHead(ctx)
+	if err != nil {
+		t.Fatalf("ChainHead failed: %v", err)
+	}
+	payload, _ := json.Marshal(SignCheckpoint(key, head))
+	cp := &Event{
+		EventID:    "cp_1",
+		EventType:  EventTypeChainCheckpoint,
+		TsEvent:    now.Add(time.Minute),
+		TsIngest:   now.Add(time.Minute),
+		Dimensions: EventDimensions{AgentID: SentinelSystem, IdentityID: SentinelSystem, WorkloadID: SentinelSystem, ScopeID: SentinelSystem},
+		Payload:    payload,
+	}
+	if err := s.AppendEvent(ctx, cp); err != nil {
+		t.Fatalf("AppendEvent (checkpoint) failed: %v", err)
+	}
+}
+
+func verify(t *testing.T, s *Store, pub ed25519.PublicKey) *ChainReport {
+	t.Helper()
+	report, err := VerifyChain(context.Background(), s, pub)
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	return report
+}
+
+func expectProblem(t *testing.T, report *ChainReport, kind string, seq int64) {
+	t.Helper()
+	for _, p := range report.Problems {
+		if p.Kind == kind && p.Seq == seq {
+			return
+		}
+	}
+	t.Errorf("expected a %s problem at %d, got %+v", kind, seq, report.Problems)
+}
+
+func TestVerifyChain(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	pub := key.Public().(ed25519.PublicKey)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	t.Run("Intact", func(t *testing.T) {
+		s, _, cleanup := setupTestStore(t)
+		defer cleanup()
+		seedChain(t, s, 5, key)
+
+		report := verify(t, s, pub)
+		if !report.OK() {
+			t.Fatalf("expected an intact chain, got %+v", report.Problems)
+		}
+		if report.Events != 6 || report.Checkpoints != 1 || report.LastCheckpointSeq != 5 || !report.Authenticated {
+			t.Errorf("unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		s, _, cleanup := setupTestStore(t)
+		defer cleanup()
+		seedChain(t, s, 3, key)
+
+		expectProblem(t, verify(t, s, otherPub), ChainBadCheckpoint, 3)
+	})
+
+	t.Run("ModifiedEvent", func(t *testing.T) {
+		s, _, cleanup := setupTestStore(t)
+		defer cleanup()
+		seedChain(t, s, 5, key)
+
+		if _, err := s.db.Exec(`UPDATE events SET payload = '{"used":0}' WHERE event_id = 'evt_2'`); err != nil {
+			t.Fatal(err)
+		}
+		expectProblem(t, verify(t, s, pub), ChainModified, 2)
+	})
+
+	t.Run("DeletedEvent", func(t *testing.T) {
+		s, _, cleanup := setupTestStore(t)
+		defer cleanup()
+		seedChain(t, s, 5, key)
+
+		if _, err := s.db.Exec(`DELETE FROM events WHERE event_id = 'evt_3'`); err != nil {
+			t.Fatal(err)
+		}
+		expectProblem(t, verify(t, s, pub), ChainGap, 4)
+	})
+
+	t.Run("RewrittenTail", func(t *testing.T) {
+		s, _, cleanup := setupTestStore(t)
+		defer cleanup()
+		seedChain(t, s, 5, key)
+
+		// Drop the tail and rewind the head consistently; only the signed
+		// checkpoint still vouches for the removed positions.
+		if _, err := s.db.Exec(`DELETE FROM events WHERE seq >= 4 AND event_type != 'chain_checkpoint'`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.db.Exec(`UPDATE events SET seq = 4, prev_hash = (SELECT hash FROM events WHERE seq = 3) WHERE event_id = 'cp_1'`); err != nil {
+			t.Fatal(err)
+		}
+		report := verify(t, s, pub)
+		expectProblem(t, report, ChainModified, 4)
+		expectProblem(t, report, ChainBadCheckpoint, 5)
+	})
+
+	t.Run("Tombstones", func(t *testing.T) {
+		s, _, cleanup := setupTestStore(t)
+		defer cleanup()
+		seedChain(t, s, 5, key)
+
+		if err := s.DeleteEvents(context.Background(), []string{"evt_1", "evt_2"}); err != nil {
+			t.Fatalf("DeleteEvents failed: %v", err)
+		}
+		report := verify(t, s, pub)
+		if !report.OK() || report.Tombstones != 2 || report.Events != 5 {
+			t.Fatalf("expected tombstones to keep the chain intact, got %+v", report)
+		}
+
+		// Removing the tombstone record leaves the deletion unaccounted for
+		if _, err := s.db.Exec(`DELETE FROM event_tombstones WHERE seq = 1`); err != nil {
+			t.Fatal(err)
+		}
+		report = verify(t, s, pub)
+		expectProblem(t, report, ChainGap, 2)
+		if len(report.Problems) != 2 || report.Problems[1].Kind != ChainBadTombstone {
+			t.Errorf("expected a tombstone count mismatch, got %+v", report.Problems)
+		}
+	})
+}
@@ -5,6 +5,7 @@ import { EventTimeline } from './components/EventTimeline';
 import { EventList } from './components/EventList';
 import { EventDetailPanel } from './components/EventDetailPanel';
 import { useHistoryEvents } from './hooks/useHistoryEvents';
+import { Event } from '../../lib/api';
 
 export default function HistoryView() {
   const [searchParams, setSearchParams] = useSearchParams();
@@ -23,7 +24,8 @@ export default function HistoryView() {
     to: getInitialDate('to', now)
   });
 
-  const [selectedEventId, setSelectedEventId] = useState<string | null>(null);
+  // Kept as an object: lineage navigation can select events outside the loaded range
+  const [selectedEvent, setSelectedEvent] = useState<Event | null>(null);
 
   // Sync state to URL
   useEffect(() => {
@@ -40,8 +42,6 @@ export default function HistoryView() {
     limit: 500 // Cap for performance
   });
 
-  const selectedEvent = events.find(e => e.event_id === selectedEventId) || null;
-
   const handleTimeChange = (from: Date, to: Date) => {
     setDateRange({ from, to });
   };
@@ -87,8 +87,8 @@ export default function HistoryView() {
         ) : (
            <EventList 
              events={events} 
-             onSelect={(e) => setSelectedEventId(e.event_id)} 
-             selectedId={selectedEventId}
+             onSelect={setSelectedEvent} 
+             selectedId={selectedEvent?.event_id ?? null}
            />
         )}
 
@@ -96,7 +96,8 @@ export default function HistoryView() {
         {selectedEvent && (
           <EventDetailPanel 
             event={selectedEvent} 
-            onClose={() => setSelectedEventId(null)} 
+            onClose={() => setSelectedEvent(null)} 
+            onSelect={setSelectedEvent}
           />
         )}
       </div>
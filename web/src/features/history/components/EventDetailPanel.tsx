@@ -1,13 +1,15 @@
 import { X, Copy, Check } from 'lucide-react';
 import { Event } from '../../../lib/api';
 import { useState } from 'react';
+import { EventLineage } from './EventLineage';
 
 interface EventDetailPanelProps {
   event: Event | null;
   onClose: () => void;
+  onSelect: (event: Event) => void;
 }
 
-export function EventDetailPanel({ event, onClose }: EventDetailPanelProps) {
+export function EventDetailPanel({ event, onClose, onSelect }: EventDetailPanelProps) {
   const [copied, setCopied] = useState(false);
 
   if (!event) return null;
@@ -114,6 +116,12 @@ export function EventDetailPanel({ event, onClose }: EventDetailPanelProps) {
           </div>
         )}
 
+        {/* Causal Lineage */}
+        <div>
+          <span className="text-xs text-slate-500 uppercase tracking-wider font-semibold mb-2 block">Lineage</span>
+          <EventLineage event={event} onSelect={onSelect} />
+        </div>
+
         {/* JSON Payload */}
         <div>
           <span className="text-xs text-slate-500 uppercase tracking-wider font-semibold mb-2 block">Payload</span>
@@ -140,6 +148,10 @@ export function EventDetailPanel({ event, onClose }: EventDetailPanelProps) {
                 <span className="text-slate-600">Correlation:</span>
                 <span>{event.correlation.correlation_id}</span>
              </div>
+              <div className="flex gap-2">
+                <span className="text-slate-600">Causation:</span>
+                <span>{event.correlation.causation_id}</span>
+             </div>
            </div>
         </div>
       </div>
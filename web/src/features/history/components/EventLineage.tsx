@@ -0,0 +1,54 @@
+import { GitBranch } from 'lucide-react';
+import { Event, LineageNode } from '../../../lib/api';
+import { cn } from '../../../lib/utils';
+import { useEventLineage } from '../hooks/useEventLineage';
+
+interface EventLineageProps {
+  event: Event;
+  onSelect: (event: Event) => void;
+}
+
+// EventLineage shows the causation chain of an event: its causes back to the
+// originating poll, and the events it caused in turn.
+export function EventLineage({ event, onSelect }: EventLineageProps) {
+  const { data: lineage, isLoading, error } = useEventLineage(event.event_id);
+
+  if (isLoading) {
+    return <div className="text-xs text-slate-500">Loading lineage...</div>;
+  }
+  if (error || !lineage) {
+    return <div className="text-xs text-red-400">Lineage unavailable</div>;
+  }
+
+  const nodes = [...lineage.nodes].sort((a, b) => a.depth - b.depth);
+  const minDepth = nodes.length ? nodes[0].depth : 0;
+
+  return (
+    <div className="bg-slate-950 rounded-lg border border-slate-800 p-4 text-xs font-mono space-y-1">
+      {lineage.missing_cause && (
+        <div className="text-slate-600 italic">cause {lineage.missing_cause} is no longer stored</div>
+      )}
+      {nodes.map((node: LineageNode) => (
+        <button
+          key={node.event_id}
+          onClick={() => onSelect(node)}
+          disabled={node.relation === 'self'}
+          style={{ paddingLeft: `${(node.depth - minDepth) * 16}px` }}
+          className={cn(
+            "w-full flex items-center gap-2 text-left rounded px-1 py-0.5 transition-colors",
+            node.relation === 'self' ? "bg-indigo-900/30 text-indigo-300" : "text-slate-400 hover:bg-slate-800"
+          )}
+        >
+          <GitBranch className="w-3 h-3 flex-shrink-0 text-slate-600" />
+          <span className="text-slate-500">{new Date(node.ts_event).toLocaleTimeString()}</span>
+          <span className="text-indigo-400">{node.event_type}</span>
+          <span className="truncate text-slate-600">{node.event_id}</span>
+        </button>
+      ))}
+      {lineage.truncated && <div className="text-slate-600 italic">more effects not shown</div>}
+      {lineage.correlated.length > 0 && (
+        <div className="text-slate-600 pt-1">+ {lineage.correlated.length} correlated event(s)</div>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,10 @@
+import { useQuery } from '@tanstack/react-query';
+import { api, Lineage } from '../../../lib/api';
+
+export function useEventLineage(eventId: string | undefined) {
+  return useQuery<Lineage, Error>({
+    queryKey: ['lineage', eventId],
+    queryFn: () => api.fetchLineage(eventId!),
+    enabled: !!eventId,
+  });
+}
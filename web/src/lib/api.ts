@@ -24,6 +24,20 @@ export interface Event {
   payload: Record<string, any>;
 }
 
+export interface LineageNode extends Event {
+  relation: 'ancestor' | 'self' | 'descendant';
+  depth: number; // Negative for causes, positive for effects
+}
+
+export interface Lineage {
+  event_id: string;
+  nodes: LineageNode[];
+  edges: { from: string; to: string }[];
+  correlated: string[];
+  missing_cause?: string;
+  truncated: boolean;
+}
+
 export interface Identity {
   id: string;
   kind: string;
@@ -80,6 +94,13 @@ export const api = {
     return response.json();
   },
 
+  async fetchLineage(eventId: string, depth?: number): Promise<Lineage> {
+    const query = depth !== undefined ? `?depth=${depth}` : '';
+    const response = await fetch(`${API_BASE}/events/${encodeURIComponent(eventId)}/lineage${query}`);
+    if (!response.ok) throw new Error('Failed to fetch lineage');
+    return response.json();
+  },
+
   async fetchStatus(): Promise<Status> {
     const response = await fetch(`${API_BASE}/status`);
     if (!response.ok) throw new Error('Failed to fetch status');